@@ -0,0 +1,61 @@
+package connect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const adminTestToken = "0123456789abcdef0123456789abcdef"
+
+type adminTestSuite struct {
+	suite.Suite
+	handler http.Handler
+	actor   string
+}
+
+func (a *adminTestSuite) SetupTest() {
+	a.actor = ""
+	a.handler = NewAdminAuthenticator(map[string]string{adminTestToken: "alice"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.actor = actorFromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func (a *adminTestSuite) request(authorization string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	recorder := httptest.NewRecorder()
+	a.handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (a *adminTestSuite) TestAuthenticated() {
+	response := a.request("Bearer " + adminTestToken)
+	a.Equal(http.StatusNoContent, response.Code)
+	a.Equal("alice", a.actor)
+}
+
+func (a *adminTestSuite) TestMissingToken() {
+	response := a.request("")
+	a.Equal(http.StatusUnauthorized, response.Code)
+	a.Equal("Bearer", response.Header().Get("WWW-Authenticate"))
+	a.Empty(a.actor)
+}
+
+func (a *adminTestSuite) TestWrongToken() {
+	response := a.request("Bearer " + adminTestToken[1:])
+	a.Equal(http.StatusUnauthorized, response.Code)
+	a.Empty(a.actor)
+
+	response = a.request("Basic " + adminTestToken)
+	a.Equal(http.StatusUnauthorized, response.Code)
+}
+
+func TestAdminTestSuite(t *testing.T) {
+	suite.Run(t, new(adminTestSuite))
+}
@@ -0,0 +1,82 @@
+package connect
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"gitlab.mreg.io/my-registry/auth/domain/audit"
+	"gitlab.mreg.io/my-registry/auth/logging"
+	serviceAudit "gitlab.mreg.io/my-registry/auth/service/audit"
+)
+
+type auditEvent struct {
+	ID         string          `json:"id"`
+	Type       audit.EventType `json:"type"`
+	Actor      string          `json:"actor,omitempty"`
+	SessionID  string          `json:"session_id,omitempty"`
+	IPAddress  string          `json:"ip_address,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	Outcome    audit.Outcome   `json:"outcome"`
+	Action     string          `json:"action,omitempty"`
+	CreateTime time.Time       `json:"create_time"`
+}
+
+// NewAuditEventsHandler lists the audit log of the identity named by the
+// {identity} path value, a page_size and page_token query at a time. It is
+// served behind NewAdminAuthenticator.
+func NewAuditEventsHandler(service serviceAudit.Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		identityID := r.PathValue("identity")
+		if _, err := uuid.Parse(identityID); err != nil {
+			http.Error(w, "invalid identity ID", http.StatusBadRequest)
+			return
+		}
+		var pageSize int
+		if value := r.URL.Query().Get("page_size"); value != "" {
+			var err error
+			if pageSize, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "invalid page size", http.StatusBadRequest)
+				return
+			}
+		}
+		ctx := logging.With(r.Context(), logging.IdentityID(identityID))
+		events, next, err := service.ListEvents(ctx, identityID, pageSize, r.URL.Query().Get("page_token"))
+		switch {
+		case errors.Is(err, serviceAudit.ErrInvalidPageSize):
+			http.Error(w, "invalid page size", http.StatusBadRequest)
+			return
+		case errors.Is(err, audit.ErrInvalidPageToken):
+			http.Error(w, "invalid page token", http.StatusBadRequest)
+			return
+		case err != nil:
+			slog.ErrorContext(ctx, "error listing audit events", logging.Error(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		body := make([]auditEvent, 0, len(events))
+		for _, event := range events {
+			item := auditEvent{
+				ID:         event.ID,
+				Type:       event.Type,
+				Actor:      event.Actor,
+				SessionID:  event.SessionID,
+				UserAgent:  event.UserAgent,
+				Outcome:    event.Outcome,
+				Action:     event.Action,
+				CreateTime: event.CreateTime,
+			}
+			if event.IPAddress.IsValid() {
+				item.IPAddress = event.IPAddress.String()
+			}
+			body = append(body, item)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"events": body, "next_page_token": next})
+	})
+}
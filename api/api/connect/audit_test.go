@@ -0,0 +1,100 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/audit"
+	serviceAudit "gitlab.mreg.io/my-registry/auth/service/audit"
+)
+
+type mockAuditService struct {
+	mock.Mock
+}
+
+func (m *mockAuditService) ListEvents(ctx context.Context, identityID string, pageSize int, pageToken string) ([]audit.Event, string, error) {
+	args := m.Called(ctx, identityID, pageSize, pageToken)
+	events, _ := args.Get(0).([]audit.Event)
+	return events, args.String(1), args.Error(2)
+}
+
+type auditTestSuite struct {
+	suite.Suite
+	service *mockAuditService
+	handler http.Handler
+}
+
+func (a *auditTestSuite) SetupTest() {
+	a.service = new(mockAuditService)
+	mux := http.NewServeMux()
+	mux.Handle("GET /admin/identities/{identity}/audit-events", NewAuditEventsHandler(a.service))
+	a.handler = NewAdminAuthenticator(map[string]string{adminTestToken: "alice"}, mux)
+}
+
+func (a *auditTestSuite) listEvents(identityID, query string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/admin/identities/"+identityID+"/audit-events"+query, nil)
+	request.Header.Set("Authorization", "Bearer "+adminTestToken)
+	recorder := httptest.NewRecorder()
+	a.handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (a *auditTestSuite) TestListEvents() {
+	createTime := time.Date(2026, time.October, 19, 16, 4, 33, 0, time.UTC)
+	a.service.On("ListEvents", mock.Anything, lockoutTestIdentityID, 2, "token").Return([]audit.Event{
+		{
+			ID:         "01928f5e-8c4d-7e5f-a06b-1c2d3e4f5a6b",
+			Type:       audit.EventLogin,
+			IdentityID: lockoutTestIdentityID,
+			IPAddress:  netip.MustParseAddr("192.0.2.1"),
+			UserAgent:  "Mozilla",
+			Outcome:    audit.OutcomeFailure,
+			CreateTime: createTime,
+		},
+	}, "next", nil).Once()
+
+	response := a.listEvents(lockoutTestIdentityID, "?page_size=2&page_token=token")
+	a.Require().Equal(http.StatusOK, response.Code)
+	a.Equal("no-store", response.Header().Get("Cache-Control"))
+	var body struct {
+		Events        []map[string]any `json:"events"`
+		NextPageToken string           `json:"next_page_token"`
+	}
+	a.Require().NoError(json.Unmarshal(response.Body.Bytes(), &body))
+	a.Equal("next", body.NextPageToken)
+	a.Equal([]map[string]any{{
+		"id":          "01928f5e-8c4d-7e5f-a06b-1c2d3e4f5a6b",
+		"type":        "login",
+		"ip_address":  "192.0.2.1",
+		"user_agent":  "Mozilla",
+		"outcome":     "failure",
+		"create_time": "2026-10-19T16:04:33Z",
+	}}, body.Events)
+	a.service.AssertExpectations(a.T())
+}
+
+func (a *auditTestSuite) TestListEvents_Errors() {
+	a.Equal(http.StatusBadRequest, a.listEvents("not-an-id", "").Code)
+	a.Equal(http.StatusBadRequest, a.listEvents(lockoutTestIdentityID, "?page_size=many").Code)
+
+	a.service.On("ListEvents", mock.Anything, lockoutTestIdentityID, -1, "").Return(nil, "", serviceAudit.ErrInvalidPageSize).Once()
+	a.Equal(http.StatusBadRequest, a.listEvents(lockoutTestIdentityID, "?page_size=-1").Code)
+	a.service.On("ListEvents", mock.Anything, lockoutTestIdentityID, 0, "bad").Return(nil, "", audit.ErrInvalidPageToken).Once()
+	a.Equal(http.StatusBadRequest, a.listEvents(lockoutTestIdentityID, "?page_token=bad").Code)
+	a.service.On("ListEvents", mock.Anything, lockoutTestIdentityID, 0, "").Return(nil, "", errors.New("internal")).Once()
+	a.Equal(http.StatusInternalServerError, a.listEvents(lockoutTestIdentityID, "").Code)
+	a.service.AssertExpectations(a.T())
+}
+
+func TestAuditTestSuite(t *testing.T) {
+	suite.Run(t, new(auditTestSuite))
+}
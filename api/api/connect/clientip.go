@@ -0,0 +1,200 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"connectrpc.com/connect"
+
+	"gitlab.mreg.io/my-registry/auth/logging"
+)
+
+// ClientIPResolver finds the address of the client behind the proxies in
+// front of the server.
+//
+// Forwarding headers are only read when the connection comes from a trusted
+// proxy, and are then walked from the right: every hop appended by a trusted
+// proxy is skipped and the first untrusted one is the client. Entries left of
+// it could have been sent by the client itself. A trusted proxy recording an
+// unknown or obfuscated address ends the walk, and the last trusted address
+// is used. Forwarded (RFC 7239) takes precedence over X-Forwarded-For, which
+// takes precedence over X-Real-IP.
+type ClientIPResolver struct {
+	trustedProxies []netip.Prefix
+}
+
+// NewClientIPResolver creates a resolver trusting the given networks. With
+// no trusted proxies the connection address is always used.
+func NewClientIPResolver(trustedProxies []netip.Prefix) *ClientIPResolver {
+	return &ClientIPResolver{trustedProxies}
+}
+
+// Trusted reports whether addr belongs to a trusted proxy.
+func (c *ClientIPResolver) Trusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range c.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the client address of a request received from peer.
+func (c *ClientIPResolver) Resolve(peer string, header http.Header) (netip.Addr, error) {
+	remote, err := parseHost(peer)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("peer address: %w", err)
+	}
+	if !c.Trusted(remote) {
+		return remote, nil
+	}
+
+	hops, err := forwardedHops(header)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		if hiddenNode(hops[i]) {
+			break
+		}
+		hop, err := parseHost(hops[i])
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("forwarded address %q: %w", hops[i], err)
+		}
+		client = hop
+		if !c.Trusted(hop) {
+			break
+		}
+	}
+	return client, nil
+}
+
+// forwardedHops lists the client addresses recorded by proxies, nearest to
+// the client first.
+func forwardedHops(header http.Header) ([]string, error) {
+	if values := header.Values("Forwarded"); len(values) > 0 {
+		return parseForwarded(strings.Join(values, ","))
+	}
+	if values := header.Values("X-Forwarded-For"); len(values) > 0 {
+		var hops []string
+		for _, value := range strings.Split(strings.Join(values, ","), ",") {
+			hops = append(hops, strings.TrimSpace(value))
+		}
+		return hops, nil
+	}
+	if value := header.Get("X-Real-IP"); value != "" {
+		return []string{strings.TrimSpace(value)}, nil
+	}
+	return nil, nil
+}
+
+// hiddenNode reports whether a proxy recorded node instead of the address
+// it received the request from, as "unknown" or an obfuscated identifier
+// such as "_hidden".
+func hiddenNode(node string) bool {
+	return strings.EqualFold(node, "unknown") || strings.HasPrefix(node, "_")
+}
+
+// parseForwarded returns the for= parameter of every element of a Forwarded
+// header, without obfuscated ports. Elements without one are reported as
+// "unknown".
+func parseForwarded(value string) ([]string, error) {
+	var hops []string
+	for _, element := range splitQuoted(value, ',') {
+		hop := "unknown"
+		for _, pair := range splitQuoted(element, ';') {
+			name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				return nil, fmt.Errorf("malformed Forwarded pair %q", pair)
+			}
+			if strings.EqualFold(name, "for") {
+				hop = strings.Trim(value, `"`)
+				if i := strings.LastIndex(hop, ":_"); i > 0 {
+					hop = hop[:i]
+				}
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops, nil
+}
+
+// splitQuoted splits s at sep, ignoring separators in quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// parseHost parses an address with an optional port, such as "192.0.2.1",
+// "192.0.2.1:4711", "[2001:db8::1]:4711" or "2001:db8::1".
+func parseHost(host string) (netip.Addr, error) {
+	if host == "" {
+		return netip.Addr{}, errors.New("empty address")
+	}
+	if addrPort, err := netip.ParseAddrPort(host); err == nil {
+		return addrPort.Addr().Unmap(), nil
+	}
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.Unmap(), nil
+}
+
+type clientIPKey struct{}
+
+func withClientIP(ctx context.Context, addr netip.Addr) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, addr)
+}
+
+// clientIPFromContext returns the address stored by NewClientIPInterceptor.
+func clientIPFromContext(ctx context.Context) (netip.Addr, bool) {
+	addr, ok := ctx.Value(clientIPKey{}).(netip.Addr)
+	return addr, ok
+}
+
+// NewClientIPInterceptor resolves the client address once per call and
+// stores it in the context for the interceptors and handlers that follow.
+// Requests whose forwarding headers cannot be parsed are rejected with
+// CodeInvalidArgument.
+func NewClientIPInterceptor(resolver *ClientIPResolver) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if req.Spec().IsClient {
+				return next(ctx, req)
+			}
+			clientIP, err := resolver.Resolve(req.Peer().Addr, req.Header())
+			if err != nil {
+				// Only trusted proxies are listened to, so they are the ones
+				// to fix
+				slog.WarnContext(ctx, "invalid forwarding headers", logging.Error(err))
+				return nil, newError(ctx, connect.CodeInvalidArgument, reasonInvalidArgument, nil, nil)
+			}
+			return next(withClientIP(ctx, clientIP), req)
+		}
+	}
+}
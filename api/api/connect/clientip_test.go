@@ -0,0 +1,165 @@
+package connect
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// newTestClientIPInterceptor trusts the loopback address httptest servers
+// are reached from, so that tests can pick the client IP with headers.
+func newTestClientIPInterceptor() connect.Interceptor {
+	return NewClientIPInterceptor(NewClientIPResolver([]netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}))
+}
+
+type clientIPTestSuite struct {
+	suite.Suite
+	resolver *ClientIPResolver
+}
+
+func (c *clientIPTestSuite) SetupTest() {
+	c.resolver = NewClientIPResolver([]netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8:ffff::/48"),
+	})
+}
+
+func (c *clientIPTestSuite) resolve(peer string, header ...string) (string, error) {
+	h := make(http.Header)
+	for i := 0; i < len(header); i += 2 {
+		h.Add(header[i], header[i+1])
+	}
+	addr, err := c.resolver.Resolve(peer, h)
+	return addr.String(), err
+}
+
+func (c *clientIPTestSuite) TestUntrustedPeer() {
+	// Headers sent directly by clients are ignored
+	addr, err := c.resolve("198.51.100.7:4711", "X-Forwarded-For", "192.0.2.1")
+	c.Require().NoError(err)
+	c.Equal("198.51.100.7", addr)
+
+	c.resolver = NewClientIPResolver(nil)
+	addr, err = c.resolve("[2001:db8::1]:4711", "X-Real-IP", "192.0.2.1")
+	c.Require().NoError(err)
+	c.Equal("2001:db8::1", addr)
+}
+
+func (c *clientIPTestSuite) TestXForwardedFor() {
+	// Spoofed entries left of the first untrusted hop are ignored
+	addr, err := c.resolve("10.0.0.1:4711", "X-Forwarded-For", "203.0.113.9, 192.0.2.1, 10.0.0.2")
+	c.Require().NoError(err)
+	c.Equal("192.0.2.1", addr)
+
+	// Every proxy may add its own header line
+	addr, err = c.resolve("10.0.0.1:4711", "X-Forwarded-For", "203.0.113.9", "X-Forwarded-For", "192.0.2.1")
+	c.Require().NoError(err)
+	c.Equal("192.0.2.1", addr)
+
+	// Only trusted hops: the leftmost one is the client
+	addr, err = c.resolve("10.0.0.1:4711", "X-Forwarded-For", "10.0.0.3, 10.0.0.2")
+	c.Require().NoError(err)
+	c.Equal("10.0.0.3", addr)
+
+	// No header: the proxy itself is the client
+	addr, err = c.resolve("10.0.0.1:4711")
+	c.Require().NoError(err)
+	c.Equal("10.0.0.1", addr)
+
+	_, err = c.resolve("10.0.0.1:4711", "X-Forwarded-For", "192.0.2.1, garbage")
+	c.Error(err)
+}
+
+func (c *clientIPTestSuite) TestForwarded() {
+	addr, err := c.resolve("10.0.0.1:4711",
+		"Forwarded", `for=203.0.113.9;proto=https, for="[2001:db8:cafe::17]:4711";by=10.0.0.1`,
+		"X-Forwarded-For", "198.51.100.7")
+	c.Require().NoError(err)
+	c.Equal("2001:db8:cafe::17", addr)
+
+	addr, err = c.resolve("10.0.0.1:4711", "Forwarded", `For="192.0.2.1";host="a;b,c", for=10.0.0.2`)
+	c.Require().NoError(err)
+	c.Equal("192.0.2.1", addr)
+
+	// Obfuscated ports are dropped
+	addr, err = c.resolve("10.0.0.1:4711", "Forwarded", `for="[2001:db8:cafe::17]:_port", for=10.0.0.2:_port`)
+	c.Require().NoError(err)
+	c.Equal("2001:db8:cafe::17", addr)
+
+	// A trusted proxy that does not know or hides its client leaves us with
+	// the last trusted address
+	addr, err = c.resolve("10.0.0.1:4711", "Forwarded", "for=192.0.2.1, for=_hidden, for=10.0.0.2")
+	c.Require().NoError(err)
+	c.Equal("10.0.0.2", addr)
+	addr, err = c.resolve("10.0.0.1:4711", "Forwarded", "for=unknown")
+	c.Require().NoError(err)
+	c.Equal("10.0.0.1", addr)
+	addr, err = c.resolve("10.0.0.1:4711", "Forwarded", "proto=https")
+	c.Require().NoError(err)
+	c.Equal("10.0.0.1", addr)
+
+	_, err = c.resolve("10.0.0.1:4711", "Forwarded", "for")
+	c.Error(err)
+}
+
+func (c *clientIPTestSuite) TestXRealIP() {
+	addr, err := c.resolve("[2001:db8:ffff::1]:4711", "X-Real-IP", "192.0.2.1")
+	c.Require().NoError(err)
+	c.Equal("192.0.2.1", addr)
+
+	addr, err = c.resolve("10.0.0.1:4711", "X-Real-IP", "192.0.2.1", "X-Forwarded-For", "198.51.100.7")
+	c.Require().NoError(err)
+	c.Equal("198.51.100.7", addr)
+}
+
+func (c *clientIPTestSuite) TestInvalidPeer() {
+	_, err := c.resolve("", "X-Forwarded-For", "192.0.2.1")
+	c.Error(err)
+}
+
+func (c *clientIPTestSuite) TestInterceptor() {
+	var resolved netip.Addr
+	mux := http.NewServeMux()
+	mux.Handle(loggingTestProcedure, connect.NewUnaryHandler(
+		loggingTestProcedure,
+		func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			resolved, _ = clientIPFromContext(ctx)
+			return connect.NewResponse(&emptypb.Empty{}), nil
+		},
+		connect.WithInterceptors(newTestClientIPInterceptor()),
+	))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := connect.NewClient[emptypb.Empty, emptypb.Empty](server.Client(), server.URL+loggingTestProcedure)
+
+	_, err := client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	c.Require().NoError(err)
+	c.Equal("127.0.0.1", resolved.String())
+
+	req := connect.NewRequest(&emptypb.Empty{})
+	req.Header().Set("X-Forwarded-For", "192.0.2.1")
+	_, err = client.CallUnary(context.Background(), req)
+	c.Require().NoError(err)
+	c.Equal("192.0.2.1", resolved.String())
+
+	req.Header().Set("X-Forwarded-For", "not an address")
+	_, err = client.CallUnary(context.Background(), req)
+	var connectErr *connect.Error
+	c.Require().ErrorAs(err, &connectErr)
+	c.Equal(connect.CodeInvalidArgument, connectErr.Code())
+	c.Require().NotEmpty(connectErr.Details())
+	info, err := connectErr.Details()[0].Value()
+	c.Require().NoError(err)
+	c.Equal(reasonInvalidArgument, info.(*errdetails.ErrorInfo).GetReason())
+}
+
+func TestClientIPTestSuite(t *testing.T) {
+	suite.Run(t, new(clientIPTestSuite))
+}
@@ -1,15 +1,23 @@
 package connect
 
 import (
+	"context"
 	"errors"
-	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"connectrpc.com/connect"
 
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
+// errorDomain is the ErrorInfo domain of every error of the server.
+const errorDomain = "auth.mreg.io"
+
 func wrapErrorAsConnectResponse(err *connect.Error, msg proto.Message) *connect.Error {
 	detail, detailErr := connect.NewErrorDetail(msg)
 	if detailErr == nil {
@@ -18,75 +26,68 @@ func wrapErrorAsConnectResponse(err *connect.Error, msg proto.Message) *connect.
 	return err
 }
 
-func internalError() error {
-	err := connect.NewError(connect.CodeInternal, errors.New("an internal server error occurred"))
-
-	// Optionally, you can add additional error info using DebugInfo or ErrorInfo
-	debugInfo := &errdetails.DebugInfo{
-		Detail: "An unexpected error occurred",
+// newError returns an error of code whose ErrorInfo tells clients the
+// reason, followed by details and the message of the reason in the
+// language of the client. The message formats args.
+func newError(ctx context.Context, code connect.Code, reason string, metadata map[string]string, details []proto.Message, args ...any) *connect.Error {
+	err := connect.NewError(code, errors.New(strings.ToLower(strings.ReplaceAll(reason, "_", " "))))
+	wrapErrorAsConnectResponse(err, &errdetails.ErrorInfo{Reason: reason, Domain: errorDomain, Metadata: metadata})
+	for _, detail := range details {
+		wrapErrorAsConnectResponse(err, detail)
 	}
-	return wrapErrorAsConnectResponse(err, debugInfo)
+	return wrapErrorAsConnectResponse(err, localizedMessage(ctx, reason, args...))
 }
 
-func errorMissingHeader(header string) error {
-	err := connect.NewError(connect.CodeInvalidArgument, errors.New("missing header"))
-	violation := &errdetails.BadRequest_FieldViolation{
-		Field:       header,
-		Description: fmt.Sprintf("The %s header is required but was not provided.", header),
+// localizeDetails returns copies of details whose violation descriptions,
+// which are catalog keys, are written in the language of ctx.
+func localizeDetails(ctx context.Context, details []proto.Message) []proto.Message {
+	localized := make([]proto.Message, 0, len(details))
+	for _, detail := range details {
+		detail = proto.Clone(detail)
+		switch detail := detail.(type) {
+		case *errdetails.BadRequest:
+			for _, violation := range detail.GetFieldViolations() {
+				_, violation.Description = localize(ctx, violation.GetDescription())
+			}
+		case *errdetails.PreconditionFailure:
+			for _, violation := range detail.GetViolations() {
+				_, violation.Description = localize(ctx, violation.GetDescription())
+			}
+		}
+		localized = append(localized, detail)
 	}
-
-	// Create a BadRequest error detail message
-	badRequest := &errdetails.BadRequest{
-		FieldViolations: []*errdetails.BadRequest_FieldViolation{violation},
-	}
-
-	return wrapErrorAsConnectResponse(err, badRequest)
+	return localized
 }
 
-func errorEmailExist() error {
-	err := connect.NewError(connect.CodeAlreadyExists, errors.New("email exist"))
-
-	// Create a BadRequest error detail message
-	badRequest := &errdetails.ResourceInfo{
-		ResourceType: "User",
-		ResourceName: "email",
-		Description:  "The email address is already registered.",
-	}
-	return wrapErrorAsConnectResponse(err, badRequest)
+func internalError(ctx context.Context) error {
+	return newError(ctx, connect.CodeInternal, reasonInternal, nil, nil)
 }
 
-func errorInsecurePassword() error {
-	err := connect.NewError(connect.CodeInvalidArgument, errors.New("insecure password"))
-
-	// Create a BadRequest error detail message
-	info := &errdetails.ResourceInfo{
-		ResourceType: "Credential",
-		ResourceName: "password",
-		Description:  "The password is not strong enough.",
+func errorMissingHeader(ctx context.Context, header string) error {
+	_, description := localize(ctx, descriptionMissingHeader, header)
+	violation := &errdetails.BadRequest_FieldViolation{
+		Field:       header,
+		Description: description,
 	}
-	return wrapErrorAsConnectResponse(err, info)
+	details := []proto.Message{&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{violation}}}
+	return newError(ctx, connect.CodeInvalidArgument, reasonMissingHeader, map[string]string{"header": header}, details, header)
 }
 
-func errorSessionExpired() error {
-	err := connect.NewError(connect.CodeUnauthenticated, errors.New("session expired"))
-
-	// Create a ResourceInfo error detail message
-	info := &errdetails.ResourceInfo{
-		ResourceType: "Authentication",
-		ResourceName: "session",
-		Description:  "The user session has expired. Please log in again.",
-	}
-	return wrapErrorAsConnectResponse(err, info)
+func errorUnauthenticated(ctx context.Context) error {
+	return newError(ctx, connect.CodeUnauthenticated, reasonUnauthenticated, nil, nil)
 }
 
-func errorFlowExpired() error {
-	err := connect.NewError(connect.CodeUnauthenticated, errors.New("flow expired"))
+func errorRateLimited(ctx context.Context, retryAfter time.Duration) error {
+	return errorRetryAfter(ctx, connect.CodeResourceExhausted, reasonRateLimited, retryAfter)
+}
 
-	// Create a ResourceInfo error detail message
-	info := &errdetails.ResourceInfo{
-		ResourceType: "Authentication",
-		ResourceName: "flow",
-		Description:  "The registration flow has expired. Please refresh the page.",
-	}
-	return wrapErrorAsConnectResponse(err, info)
+// errorRetryAfter returns an error telling clients to wait retryAfter
+// before trying again, in a RetryInfo, in the ErrorInfo metadata and in a
+// Retry-After header.
+func errorRetryAfter(ctx context.Context, code connect.Code, reason string, retryAfter time.Duration) *connect.Error {
+	seconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+	details := []proto.Message{&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}}
+	err := newError(ctx, code, reason, map[string]string{"retry_after": seconds}, details)
+	err.Meta().Set("Retry-After", seconds)
+	return err
 }
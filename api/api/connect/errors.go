@@ -0,0 +1,199 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/registration"
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+	"gitlab.mreg.io/my-registry/auth/logging"
+	serviceLockout "gitlab.mreg.io/my-registry/auth/service/lockout"
+	serviceRegistration "gitlab.mreg.io/my-registry/auth/service/registration"
+)
+
+// errorMapping declares how a domain error is returned to clients.
+type errorMapping struct {
+	target error
+	code   connect.Code
+	// reason is the stable ErrorInfo reason clients branch on.
+	reason   string
+	metadata map[string]string
+	// details follow the ErrorInfo, to help clients recover. The
+	// descriptions of their violations are catalog keys, written in the
+	// language of the client.
+	details []proto.Message
+}
+
+// errorRegistry maps the errors of the services to connect errors. Errors
+// are matched with errors.Is, in order.
+var errorRegistry = []errorMapping{
+	{
+		target: serviceRegistration.ErrEmailExists,
+		code:   connect.CodeAlreadyExists,
+		reason: reasonEmailExists,
+	},
+	{
+		target: serviceRegistration.ErrInsecurePassword,
+		code:   connect.CodeInvalidArgument,
+		reason: reasonInsecurePassword,
+		details: []proto.Message{&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{{
+			Field:       "registration_flow.password.password",
+			Description: descriptionInsecurePassword,
+		}}}},
+	},
+	{
+		target: serviceRegistration.ErrSessionExpired,
+		code:   connect.CodeUnauthenticated,
+		reason: reasonSessionExpired,
+	},
+	{
+		target: serviceRegistration.ErrUnauthenticated,
+		code:   connect.CodeUnauthenticated,
+		reason: reasonUnauthenticated,
+	},
+	{
+		target: serviceRegistration.ErrFlowExpired,
+		code:   connect.CodeUnauthenticated,
+		reason: reasonFlowExpired,
+	},
+	{
+		target:  identity.ErrHasherBusy,
+		code:    connect.CodeResourceExhausted,
+		reason:  reasonHasherBusy,
+		details: []proto.Message{&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Second)}},
+	},
+	{
+		target:   serviceRegistration.ErrChallengeFailed,
+		code:     connect.CodeFailedPrecondition,
+		reason:   reasonChallengeFailed,
+		metadata: map[string]string{"header": challengeSolutionHeader},
+		details: []proto.Message{&errdetails.PreconditionFailure{Violations: []*errdetails.PreconditionFailure_Violation{{
+			Type:        "CHALLENGE",
+			Subject:     challengeSolutionHeader,
+			Description: descriptionChallengeFailed,
+		}}}},
+	},
+	{
+		target:   registration.ErrCaptchaFailed,
+		code:     connect.CodeFailedPrecondition,
+		reason:   reasonCaptchaFailed,
+		metadata: map[string]string{"header": captchaTokenHeader},
+		details: []proto.Message{&errdetails.PreconditionFailure{Violations: []*errdetails.PreconditionFailure_Violation{{
+			Type:        "CAPTCHA",
+			Subject:     captchaTokenHeader,
+			Description: descriptionCaptchaFailed,
+		}}}},
+	},
+	{
+		target: serviceRegistration.ErrRiskBlocked,
+		code:   connect.CodePermissionDenied,
+		reason: reasonDeviceBlocked,
+	},
+	// The lockout errors come wrapped in a RetryAfterError, whose delay is
+	// returned with them.
+	{
+		target: serviceLockout.ErrAccountLocked,
+		code:   connect.CodeResourceExhausted,
+		reason: reasonAccountLocked,
+	},
+	{
+		target: serviceLockout.ErrAddressLocked,
+		code:   connect.CodeResourceExhausted,
+		reason: reasonAddressLocked,
+	},
+	{
+		target: serviceLockout.ErrTooManyAttempts,
+		code:   connect.CodeResourceExhausted,
+		reason: reasonTooManyAttempts,
+	},
+	// The errors of the repositories come last, after the errors of the
+	// services that are more precise about the same failures.
+	{
+		target: store.ErrNotFound,
+		code:   connect.CodeNotFound,
+		reason: reasonNotFound,
+	},
+	{
+		target: store.ErrAlreadyExists,
+		code:   connect.CodeAlreadyExists,
+		reason: reasonAlreadyExists,
+	},
+	{
+		target: store.ErrInvalid,
+		code:   connect.CodeInvalidArgument,
+		reason: reasonInvalidArgument,
+	},
+}
+
+// codeReasons are the ErrorInfo reasons of the connect errors built outside
+// of this package, such as the violations of the validation interceptor.
+var codeReasons = map[connect.Code]string{
+	connect.CodeInvalidArgument: reasonInvalidArgument,
+	connect.CodeUnauthenticated: reasonUnauthenticated,
+	connect.CodeNotFound:        reasonNotFound,
+	connect.CodeAlreadyExists:   reasonAlreadyExists,
+	connect.CodeInternal:        reasonInternal,
+}
+
+// toConnectError returns the connect error of err. Errors that are
+// already connect errors are kept, with the ErrorInfo of their code added
+// when they lack one, and errors missing from the registry are logged and
+// hidden behind an internal error.
+func toConnectError(ctx context.Context, err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		if reason, ok := codeReasons[connectErr.Code()]; ok && !hasErrorInfo(connectErr) {
+			wrapErrorAsConnectResponse(connectErr, &errdetails.ErrorInfo{Reason: reason, Domain: errorDomain})
+			wrapErrorAsConnectResponse(connectErr, localizedMessage(ctx, reason))
+		}
+		return err
+	}
+	for _, mapping := range errorRegistry {
+		if errors.Is(err, mapping.target) {
+			var retryErr *serviceLockout.RetryAfterError
+			if errors.As(err, &retryErr) {
+				return errorRetryAfter(ctx, mapping.code, mapping.reason, retryErr.RetryAfter)
+			}
+			return newError(ctx, mapping.code, mapping.reason, mapping.metadata, localizeDetails(ctx, mapping.details))
+		}
+	}
+	slog.ErrorContext(ctx, "unexpected error", logging.Error(err))
+	return internalError(ctx)
+}
+
+// NewErrorInterceptor returns the errors of the handlers as connect
+// errors, following the registry. It goes after the logging and metrics
+// interceptors, so that they see the final codes.
+func NewErrorInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			res, err := next(ctx, req)
+			if err != nil && !req.Spec().IsClient {
+				return nil, toConnectError(ctx, err)
+			}
+			return res, err
+		}
+	}
+}
+
+// hasErrorInfo reports whether err carries an ErrorInfo detail.
+func hasErrorInfo(err *connect.Error) bool {
+	name := string((&errdetails.ErrorInfo{}).ProtoReflect().Descriptor().FullName())
+	for _, detail := range err.Details() {
+		if detail.Type() == name {
+			return true
+		}
+	}
+	return false
+}
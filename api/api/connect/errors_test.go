@@ -0,0 +1,135 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/text/language"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+	serviceLockout "gitlab.mreg.io/my-registry/auth/service/lockout"
+	serviceRegistration "gitlab.mreg.io/my-registry/auth/service/registration"
+)
+
+type errorsTestSuite struct {
+	suite.Suite
+}
+
+// details returns the ErrorInfo and LocalizedMessage of err.
+func (s *errorsTestSuite) details(err error) (*errdetails.ErrorInfo, *errdetails.LocalizedMessage) {
+	var connectErr *connect.Error
+	s.Require().ErrorAs(err, &connectErr)
+	var info *errdetails.ErrorInfo
+	var message *errdetails.LocalizedMessage
+	for _, detail := range connectErr.Details() {
+		value, err := detail.Value()
+		s.Require().NoError(err)
+		switch value := value.(type) {
+		case *errdetails.ErrorInfo:
+			info = value
+		case *errdetails.LocalizedMessage:
+			message = value
+		}
+	}
+	s.Require().NotNil(info)
+	s.Require().NotNil(message)
+	return info, message
+}
+
+func (s *errorsTestSuite) TestToConnectError() {
+	ctx := withLanguage(context.Background(), language.French)
+	err := toConnectError(ctx, fmt.Errorf("completing registration flow: %w", serviceRegistration.ErrEmailExists))
+	s.Equal(connect.CodeAlreadyExists, connect.CodeOf(err))
+	info, message := s.details(err)
+	s.Equal("EMAIL_EXISTS", info.GetReason())
+	s.Equal("auth.mreg.io", info.GetDomain())
+	s.Equal("fr", message.GetLocale())
+
+	err = toConnectError(ctx, serviceRegistration.ErrChallengeFailed)
+	info, _ = s.details(err)
+	s.Equal(map[string]string{"header": challengeSolutionHeader}, info.GetMetadata())
+
+	// Errors of the repositories that the services let through
+	err = toConnectError(ctx, fmt.Errorf("completing registration flow: %w", store.ErrNotFound))
+	s.Equal(connect.CodeNotFound, connect.CodeOf(err))
+	err = toConnectError(ctx, &store.ConstraintError{Kind: store.ErrInvalid, Table: "sessions", Constraint: "sessions_check"})
+	s.Equal(connect.CodeInvalidArgument, connect.CodeOf(err))
+	s.NotContains(err.Error(), "sessions_check")
+
+	// Unknown errors do not leak
+	err = toConnectError(ctx, errors.New("connection refused"))
+	s.Equal(connect.CodeInternal, connect.CodeOf(err))
+	s.NotContains(err.Error(), "connection refused")
+
+	// Errors built by the handlers are kept
+	err = errorMissingHeader(ctx, "User-Agent")
+	s.Same(err, toConnectError(ctx, err))
+	s.Same(context.Canceled, toConnectError(ctx, context.Canceled))
+
+	// Errors built elsewhere, such as by the validation interceptor, get
+	// the ErrorInfo of their code and keep their message
+	err = connect.NewError(connect.CodeInvalidArgument, errors.New("validation error: email: value must be a valid email address"))
+	s.Same(err, toConnectError(ctx, err))
+	info, message = s.details(err)
+	s.Equal(reasonInvalidArgument, info.GetReason())
+	s.Equal("fr", message.GetLocale())
+	s.Contains(err.Error(), "valid email address")
+	s.Len(err.(*connect.Error).Details(), 2)
+	toConnectError(ctx, err)
+	s.Len(err.(*connect.Error).Details(), 2)
+}
+
+func (s *errorsTestSuite) TestToConnectError_RetryAfter() {
+	ctx := context.Background()
+	err := toConnectError(ctx, fmt.Errorf("checking lockout: %w",
+		&serviceLockout.RetryAfterError{Err: serviceLockout.ErrAccountLocked, RetryAfter: 90 * time.Second}))
+	s.Equal(connect.CodeResourceExhausted, connect.CodeOf(err))
+	info, _ := s.details(err)
+	s.Equal(reasonAccountLocked, info.GetReason())
+	s.Equal("90", info.GetMetadata()["retry_after"])
+
+	var connectErr *connect.Error
+	s.Require().ErrorAs(err, &connectErr)
+	s.Equal("90", connectErr.Meta().Get("Retry-After"))
+	var retryInfo *errdetails.RetryInfo
+	for _, detail := range connectErr.Details() {
+		value, err := detail.Value()
+		s.Require().NoError(err)
+		if value, ok := value.(*errdetails.RetryInfo); ok {
+			retryInfo = value
+		}
+	}
+	s.Require().NotNil(retryInfo)
+	s.Equal(90*time.Second, retryInfo.GetRetryDelay().AsDuration())
+
+	// Without a delay, the error is returned as registered
+	err = toConnectError(ctx, serviceLockout.ErrAddressLocked)
+	s.Equal(connect.CodeResourceExhausted, connect.CodeOf(err))
+	info, _ = s.details(err)
+	s.Equal(reasonAddressLocked, info.GetReason())
+	s.Empty(info.GetMetadata())
+}
+
+func (s *errorsTestSuite) TestRegistry() {
+	seen := make(map[error]bool)
+	for _, mapping := range errorRegistry {
+		s.False(seen[mapping.target], "%v is mapped twice", mapping.target)
+		seen[mapping.target] = true
+		s.NotEqual(connect.CodeInternal, mapping.code, mapping.reason)
+		for _, catalog := range catalogs {
+			s.NotEmpty(catalog[mapping.reason], mapping.reason)
+		}
+	}
+	s.True(seen[identity.ErrHasherBusy])
+}
+
+func TestErrorsTestSuite(t *testing.T) {
+	suite.Run(t, new(errorsTestSuite))
+}
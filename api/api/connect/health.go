@@ -0,0 +1,115 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/grpchealth"
+
+	"gitlab.mreg.io/my-registry/auth/logging"
+)
+
+// readinessTimeout bounds the time spent on all readiness checks, so that a
+// hanging dependency fails the probe instead of blocking it.
+const readinessTimeout = 2 * time.Second
+
+// errDraining is reported by readiness checks once the server shuts down.
+var errDraining = errors.New("server is shutting down")
+
+// ReadinessCheck reports whether a dependency of the server is usable.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Health serves the liveness and readiness probes, over plain HTTP and as
+// the grpc.health.v1 service.
+type Health struct {
+	services []string
+	checks   []ReadinessCheck
+	draining atomic.Bool
+}
+
+// NewHealth creates the probes for the given fully-qualified service names.
+// The server is ready when every check passes.
+func NewHealth(services []string, checks ...ReadinessCheck) *Health {
+	return &Health{services: services, checks: checks}
+}
+
+// Drain makes the server report that it is not ready, so that load balancers
+// stop sending new requests while in-flight ones complete.
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+// ready runs every check and returns the failures by name.
+func (h *Health) ready(ctx context.Context) map[string]error {
+	failures := make(map[string]error)
+	if h.draining.Load() {
+		failures["server"] = errDraining
+		return failures
+	}
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+	for _, check := range h.checks {
+		if err := check.Check(ctx); err != nil {
+			failures[check.Name] = err
+		}
+	}
+	return failures
+}
+
+// LivenessHandler reports whether the process is running. It never checks
+// dependencies, since restarting the server would not fix them.
+func (h *Health) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ok\n"))
+	})
+}
+
+// ReadinessHandler reports whether the server should receive traffic. The
+// body lists the status of each check without the underlying errors, which
+// are only logged.
+func (h *Health) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		failures := h.ready(ctx)
+		checks := make(map[string]string, len(h.checks)+1)
+		for _, check := range h.checks {
+			checks[check.Name] = "ok"
+		}
+		for name, err := range failures {
+			slog.WarnContext(ctx, "readiness check failed", slog.String("check", name), logging.Error(err))
+			checks[name] = "unavailable"
+		}
+
+		status := http.StatusOK
+		if len(failures) > 0 {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]any{"checks": checks})
+	})
+}
+
+// Check implements grpchealth.Checker. The whole process and every
+// registered service share the same readiness.
+func (h *Health) Check(ctx context.Context, req *grpchealth.CheckRequest) (*grpchealth.CheckResponse, error) {
+	if req.Service != "" && !slices.Contains(h.services, req.Service) {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("unknown service %s", req.Service))
+	}
+	if len(h.ready(ctx)) > 0 {
+		return &grpchealth.CheckResponse{Status: grpchealth.StatusNotServing}, nil
+	}
+	return &grpchealth.CheckResponse{Status: grpchealth.StatusServing}, nil
+}
@@ -0,0 +1,90 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/grpchealth"
+	"github.com/stretchr/testify/suite"
+)
+
+const healthTestService = "test.v1.TestService"
+
+type healthTestSuite struct {
+	suite.Suite
+	databaseErr error
+	health      *Health
+}
+
+func (h *healthTestSuite) SetupTest() {
+	h.databaseErr = nil
+	h.health = NewHealth([]string{healthTestService}, ReadinessCheck{
+		Name: "database",
+		Check: func(context.Context) error {
+			return h.databaseErr
+		},
+	})
+}
+
+func (h *healthTestSuite) readiness() (int, map[string]string) {
+	recorder := httptest.NewRecorder()
+	h.health.ReadinessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var body struct {
+		Checks map[string]string `json:"checks"`
+	}
+	h.Require().NoError(json.NewDecoder(recorder.Body).Decode(&body))
+	return recorder.Code, body.Checks
+}
+
+func (h *healthTestSuite) TestLiveness() {
+	h.databaseErr = errors.New("connection refused")
+	recorder := httptest.NewRecorder()
+	h.health.LivenessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	h.Equal(http.StatusOK, recorder.Code)
+}
+
+func (h *healthTestSuite) TestReadiness() {
+	code, checks := h.readiness()
+	h.Equal(http.StatusOK, code)
+	h.Equal(map[string]string{"database": "ok"}, checks)
+
+	h.databaseErr = errors.New("connection refused")
+	code, checks = h.readiness()
+	h.Equal(http.StatusServiceUnavailable, code)
+	h.Equal(map[string]string{"database": "unavailable"}, checks)
+}
+
+func (h *healthTestSuite) TestReadiness_Draining() {
+	h.health.Drain()
+	code, checks := h.readiness()
+	h.Equal(http.StatusServiceUnavailable, code)
+	h.Equal("unavailable", checks["server"])
+}
+
+func (h *healthTestSuite) TestCheck() {
+	ctx := context.Background()
+	res, err := h.health.Check(ctx, &grpchealth.CheckRequest{})
+	h.Require().NoError(err)
+	h.Equal(grpchealth.StatusServing, res.Status)
+
+	res, err = h.health.Check(ctx, &grpchealth.CheckRequest{Service: healthTestService})
+	h.Require().NoError(err)
+	h.Equal(grpchealth.StatusServing, res.Status)
+
+	h.databaseErr = errors.New("connection refused")
+	res, err = h.health.Check(ctx, &grpchealth.CheckRequest{Service: healthTestService})
+	h.Require().NoError(err)
+	h.Equal(grpchealth.StatusNotServing, res.Status)
+
+	_, err = h.health.Check(ctx, &grpchealth.CheckRequest{Service: "unknown.v1.Service"})
+	h.Equal(connect.CodeNotFound, connect.CodeOf(err))
+}
+
+func TestHealthTestSuite(t *testing.T) {
+	suite.Run(t, new(healthTestSuite))
+}
@@ -0,0 +1,206 @@
+package connect
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+
+	"connectrpc.com/connect"
+	"golang.org/x/text/language"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"gopkg.in/yaml.v3"
+
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+	"gitlab.mreg.io/my-registry/auth/logging"
+)
+
+// The reasons of the errors returned to clients. They key the message
+// catalogs in locales/, one YAML file per language.
+const (
+	reasonInternal         = "INTERNAL"
+	reasonMissingHeader    = "MISSING_HEADER"
+	reasonUnauthenticated  = "UNAUTHENTICATED"
+	reasonEmailExists      = "EMAIL_EXISTS"
+	reasonInsecurePassword = "INSECURE_PASSWORD"
+	reasonSessionExpired   = "SESSION_EXPIRED"
+	reasonFlowExpired      = "FLOW_EXPIRED"
+	reasonHasherBusy       = "HASHER_BUSY"
+	reasonChallengeFailed  = "CHALLENGE_FAILED"
+	reasonCaptchaFailed    = "CAPTCHA_FAILED"
+	reasonDeviceBlocked    = "DEVICE_BLOCKED"
+	reasonRateLimited      = "RATE_LIMITED"
+	reasonAccountLocked    = "ACCOUNT_LOCKED"
+	reasonAddressLocked    = "ADDRESS_LOCKED"
+	reasonTooManyAttempts  = "TOO_MANY_ATTEMPTS"
+	reasonNotFound         = "NOT_FOUND"
+	reasonAlreadyExists    = "ALREADY_EXISTS"
+	reasonInvalidArgument  = "INVALID_ARGUMENT"
+)
+
+// The descriptions of the violations returned with some errors, keying the
+// same catalogs.
+const (
+	descriptionMissingHeader    = "MISSING_HEADER_DESCRIPTION"
+	descriptionInsecurePassword = "INSECURE_PASSWORD_DESCRIPTION"
+	descriptionChallengeFailed  = "CHALLENGE_FAILED_DESCRIPTION"
+	descriptionCaptchaFailed    = "CAPTCHA_FAILED_DESCRIPTION"
+)
+
+// defaultLanguage is used when the client accepts none of the catalogs.
+var defaultLanguage = language.English
+
+//go:embed locales/*.yaml
+var localeFiles embed.FS
+
+var catalogs, catalogLanguages = loadCatalogs()
+
+var languageMatcher = language.NewMatcher(catalogLanguages)
+
+// loadCatalogs reads the message catalogs and returns them with their
+// languages, the default first.
+func loadCatalogs() (map[language.Tag]map[string]string, []language.Tag) {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	catalogs := make(map[language.Tag]map[string]string)
+	languages := []language.Tag{defaultLanguage}
+	for _, file := range files {
+		tag, err := language.Parse(strings.TrimSuffix(file.Name(), ".yaml"))
+		if err != nil {
+			panic(fmt.Sprintf("locale %s: %v", file.Name(), err))
+		}
+		data, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var messages map[string]string
+		if err := yaml.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("locale %s: %v", file.Name(), err))
+		}
+		catalogs[tag] = messages
+		if tag != defaultLanguage {
+			languages = append(languages, tag)
+		}
+	}
+	return catalogs, languages
+}
+
+// negotiateLanguage returns the catalog language that best matches an
+// Accept-Language header.
+func negotiateLanguage(acceptLanguage string) language.Tag {
+	// A malformed header matches nothing and selects the default language
+	preferred, _, _ := language.ParseAcceptLanguage(acceptLanguage)
+	return matchLanguage(preferred...)
+}
+
+// matchLanguage returns the catalog language that best matches preferred,
+// or the default one.
+func matchLanguage(preferred ...language.Tag) language.Tag {
+	// The matched tag carries the regional extensions of the client, the
+	// index points at the catalog itself
+	_, index, confidence := languageMatcher.Match(preferred...)
+	if confidence == language.No {
+		return defaultLanguage
+	}
+	return catalogLanguages[index]
+}
+
+// identityLanguage returns the catalog language of the locale of the
+// identity signed in with the session cookie of header. It reports false
+// for anonymous or invalid sessions and for identities without a locale.
+func identityLanguage(ctx context.Context, sessions session.Repository, header http.Header) (language.Tag, bool) {
+	cookies, err := http.ParseCookie(header.Get("Cookie"))
+	if err != nil {
+		return defaultLanguage, false
+	}
+	var sessionID string
+	for _, cookie := range cookies {
+		if cookie.Name == "session_id" {
+			sessionID = cookie.Value
+			break
+		}
+	}
+	if sessionID == "" {
+		return defaultLanguage, false
+	}
+
+	sessionData := &session.Session{ID: sessionID}
+	if err := sessions.QuerySessionByID(ctx, sessionData); err != nil {
+		// The handlers tell the client about unknown sessions
+		if !errors.Is(err, store.ErrNotFound) && !errors.Is(err, store.ErrInvalid) {
+			slog.WarnContext(ctx, "error querying session locale", logging.Error(err))
+		}
+		return defaultLanguage, false
+	}
+	if !sessionData.Active || sessionData.IsExpired() || sessionData.Identity.Locale == "" {
+		return defaultLanguage, false
+	}
+	tag, err := language.Parse(sessionData.Identity.Locale)
+	if err != nil {
+		return defaultLanguage, false
+	}
+	return matchLanguage(tag), true
+}
+
+type languageKey struct{}
+
+func withLanguage(ctx context.Context, tag language.Tag) context.Context {
+	return context.WithValue(ctx, languageKey{}, tag)
+}
+
+// languageFromContext returns the language stored by
+// NewLocaleInterceptor, or the default one.
+func languageFromContext(ctx context.Context) language.Tag {
+	if tag, ok := ctx.Value(languageKey{}).(language.Tag); ok {
+		return tag
+	}
+	return defaultLanguage
+}
+
+// NewLocaleInterceptor sets the language of the error messages of every
+// call. Calls signed in with the session of an identity use the locale of
+// the identity, looked up in sessions, and the others negotiate it from
+// their Accept-Language header.
+func NewLocaleInterceptor(sessions session.Repository) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if req.Spec().IsClient {
+				return next(ctx, req)
+			}
+			tag, ok := identityLanguage(ctx, sessions, req.Header())
+			if !ok {
+				tag = negotiateLanguage(req.Header().Get("Accept-Language"))
+			}
+			return next(withLanguage(ctx, tag), req)
+		}
+	}
+}
+
+// localize returns the message of key in the language of ctx, formatted
+// with args, and the language it is written in. Keys missing from a
+// catalog fall back to the default language.
+func localize(ctx context.Context, key string, args ...any) (language.Tag, string) {
+	tag := languageFromContext(ctx)
+	message, ok := catalogs[tag][key]
+	if !ok {
+		tag, message = defaultLanguage, catalogs[defaultLanguage][key]
+	}
+	if len(args) > 0 {
+		message = fmt.Sprintf(message, args...)
+	}
+	return tag, message
+}
+
+// localizedMessage returns the message of reason in the language of ctx,
+// formatted with args.
+func localizedMessage(ctx context.Context, reason string, args ...any) *errdetails.LocalizedMessage {
+	tag, message := localize(ctx, reason, args...)
+	return &errdetails.LocalizedMessage{Locale: tag.String(), Message: message}
+}
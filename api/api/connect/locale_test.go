@@ -0,0 +1,124 @@
+package connect
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/text/language"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/memory"
+)
+
+type localeTestSuite struct {
+	suite.Suite
+}
+
+func (s *localeTestSuite) TestNegotiateLanguage() {
+	for acceptLanguage, expected := range map[string]language.Tag{
+		"":                         language.English,
+		"fr-CA,fr;q=0.9,en;q=0.8":  language.French,
+		"de-DE,de;q=0.9,en;q=0.5":  language.English,
+		"de-DE,fr;q=0.5":           language.French,
+		"ja":                       language.English,
+		"not a language, fr;q=0.2": language.English,
+		"en-GB;q=0.9,fr-BE;q=0.1":  language.English,
+		"*":                        language.English,
+	} {
+		s.Equal(expected.String(), negotiateLanguage(acceptLanguage).String(), acceptLanguage)
+	}
+}
+
+func (s *localeTestSuite) TestCatalogs() {
+	keys := []string{
+		reasonInternal, reasonMissingHeader, reasonUnauthenticated, reasonEmailExists, reasonInsecurePassword,
+		reasonSessionExpired, reasonFlowExpired, reasonHasherBusy, reasonChallengeFailed, reasonCaptchaFailed,
+		reasonDeviceBlocked, reasonRateLimited, reasonAccountLocked, reasonAddressLocked, reasonTooManyAttempts,
+		reasonNotFound, reasonAlreadyExists, reasonInvalidArgument,
+		descriptionMissingHeader, descriptionInsecurePassword, descriptionChallengeFailed, descriptionCaptchaFailed,
+	}
+	s.Require().Contains(catalogs, language.French)
+	for tag, catalog := range catalogs {
+		for _, key := range keys {
+			s.NotEmpty(catalog[key], "%s has no message for %s", tag, key)
+		}
+		s.Len(catalog, len(keys), "%s has messages for unknown keys", tag)
+	}
+}
+
+func (s *localeTestSuite) TestLocalizedMessage() {
+	message := localizedMessage(context.Background(), reasonMissingHeader, "User-Agent")
+	s.Equal("en", message.GetLocale())
+	s.Equal("The User-Agent header is required but was not provided.", message.GetMessage())
+
+	ctx := withLanguage(context.Background(), language.French)
+	message = localizedMessage(ctx, reasonEmailExists)
+	s.Equal("fr", message.GetLocale())
+	s.Equal("Cette adresse e-mail est déjà enregistrée.", message.GetMessage())
+}
+
+func (s *localeTestSuite) TestLocalizeDetails() {
+	ctx := withLanguage(context.Background(), language.French)
+	details := []proto.Message{
+		&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{{
+			Field:       "registration_flow.password.password",
+			Description: descriptionInsecurePassword,
+		}}},
+		&errdetails.PreconditionFailure{Violations: []*errdetails.PreconditionFailure_Violation{{
+			Type:        "CAPTCHA",
+			Subject:     captchaTokenHeader,
+			Description: descriptionCaptchaFailed,
+		}}},
+	}
+
+	localized := localizeDetails(ctx, details)
+	s.Require().Len(localized, 2)
+	s.Equal("Le mot de passe n'est pas assez robuste.", localized[0].(*errdetails.BadRequest).GetFieldViolations()[0].GetDescription())
+	s.Equal("Le jeton CAPTCHA est absent, invalide ou expiré.", localized[1].(*errdetails.PreconditionFailure).GetViolations()[0].GetDescription())
+	// The registry keeps its keys
+	s.Equal(descriptionInsecurePassword, details[0].(*errdetails.BadRequest).GetFieldViolations()[0].GetDescription())
+}
+
+func (s *localeTestSuite) TestIdentityLanguage() {
+	ctx := context.Background()
+	memoryStore := memory.NewStore()
+	identities := memory.NewIdentityRepository(memoryStore)
+	sessions := memory.NewSessionRepository(memoryStore)
+	owner := &identity.Identity{Emails: []identity.Email{{Value: "user@example.com"}}, Locale: "fr"}
+	s.Require().NoError(identities.CreateIdentity(ctx, owner))
+	signedIn := &session.Session{
+		Active:         true,
+		ExpiryInterval: time.Hour,
+		Devices:        []session.Device{session.NewDevice(netip.MustParseAddr("192.0.2.1"), "Mozilla/5.0")},
+		Identity:       owner,
+	}
+	s.Require().NoError(sessions.CreateSession(ctx, signedIn))
+	anonymous := &session.Session{
+		Active:         true,
+		ExpiryInterval: time.Hour,
+		Devices:        []session.Device{session.NewDevice(netip.MustParseAddr("192.0.2.1"), "Mozilla/5.0")},
+	}
+	s.Require().NoError(sessions.CreateSession(ctx, anonymous))
+
+	header := http.Header{"Cookie": {"session_id=" + signedIn.ID}}
+	tag, ok := identityLanguage(ctx, sessions, header)
+	s.True(ok)
+	s.Equal(language.French, tag)
+
+	for _, cookie := range []string{"", "session_id=" + anonymous.ID, "session_id=" + uuid.NewString(), "session_id=invalid"} {
+		_, ok := identityLanguage(ctx, sessions, http.Header{"Cookie": {cookie}})
+		s.False(ok, cookie)
+	}
+}
+
+func TestLocaleTestSuite(t *testing.T) {
+	suite.Run(t, new(localeTestSuite))
+}
@@ -0,0 +1,83 @@
+package connect
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"gitlab.mreg.io/my-registry/auth/domain/lockout"
+	"gitlab.mreg.io/my-registry/auth/logging"
+	serviceLockout "gitlab.mreg.io/my-registry/auth/service/lockout"
+)
+
+type lockoutEvent struct {
+	Type        lockout.EventType `json:"type"`
+	IPAddress   string            `json:"ip_address,omitempty"`
+	LockedUntil *time.Time        `json:"locked_until,omitempty"`
+	CreateTime  time.Time         `json:"create_time"`
+}
+
+// NewLockoutEventsHandler lists the lock events of the account signed in
+// with the session cookie, so that owners can see when and from where their
+// account was locked. The administrators who lifted locks are not named.
+func NewLockoutEventsHandler(service serviceLockout.Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		cookie, err := r.Cookie("session_id")
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		ctx := r.Context()
+		events, err := service.ListEvents(ctx, cookie.Value)
+		switch {
+		case errors.Is(err, serviceLockout.ErrUnauthenticated):
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		case err != nil:
+			slog.ErrorContext(ctx, "error listing lockout events", logging.Error(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		body := make([]lockoutEvent, 0, len(events))
+		for _, event := range events {
+			item := lockoutEvent{Type: event.Type, CreateTime: event.CreateTime}
+			if event.IPAddress.IsValid() {
+				item.IPAddress = event.IPAddress.String()
+			}
+			if !event.LockedUntil.IsZero() {
+				item.LockedUntil = &event.LockedUntil
+			}
+			body = append(body, item)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"events": body})
+	})
+}
+
+// NewUnlockHandler lifts the lock of the identity named by the {identity}
+// path value. It is served behind NewAdminAuthenticator, which names the
+// administrator recorded with the unlock.
+func NewUnlockHandler(service serviceLockout.Service) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identityID := r.PathValue("identity")
+		if _, err := uuid.Parse(identityID); err != nil {
+			http.Error(w, "invalid identity ID", http.StatusBadRequest)
+			return
+		}
+		ctx := logging.With(r.Context(), logging.IdentityID(identityID))
+		err := service.Unlock(ctx, identityID, actorFromContext(ctx))
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.Is(err, serviceLockout.ErrIdentityNotFound):
+			http.Error(w, "identity not found", http.StatusNotFound)
+		default:
+			slog.ErrorContext(ctx, "error unlocking identity", logging.Error(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	})
+}
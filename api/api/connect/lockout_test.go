@@ -0,0 +1,125 @@
+package connect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/lockout"
+	serviceLockout "gitlab.mreg.io/my-registry/auth/service/lockout"
+)
+
+type mockLockoutService struct {
+	serviceLockout.Service
+	mock.Mock
+}
+
+func (m *mockLockoutService) Unlock(ctx context.Context, identityID string, actor string) error {
+	return m.Called(ctx, identityID, actor).Error(0)
+}
+
+func (m *mockLockoutService) ListEvents(ctx context.Context, sessionID string) ([]lockout.Event, error) {
+	args := m.Called(ctx, sessionID)
+	events, _ := args.Get(0).([]lockout.Event)
+	return events, args.Error(1)
+}
+
+const lockoutTestIdentityID = "c935b23d-6cb4-448a-814e-b42aec9ef6cf"
+
+type lockoutTestSuite struct {
+	suite.Suite
+	service *mockLockoutService
+	mux     *http.ServeMux
+}
+
+func (l *lockoutTestSuite) SetupTest() {
+	l.service = new(mockLockoutService)
+	l.mux = http.NewServeMux()
+	l.mux.Handle("GET /identity/lockout-events", NewLockoutEventsHandler(l.service))
+	l.mux.Handle("POST /admin/identities/{identity}/unlock",
+		NewAdminAuthenticator(map[string]string{adminTestToken: "alice"}, NewUnlockHandler(l.service)))
+}
+
+func (l *lockoutTestSuite) serve(request *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	l.mux.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (l *lockoutTestSuite) listEvents(sessionID string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/identity/lockout-events", nil)
+	if sessionID != "" {
+		request.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+	}
+	return l.serve(request)
+}
+
+func (l *lockoutTestSuite) unlock(identityID string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/admin/identities/"+identityID+"/unlock", nil)
+	request.Header.Set("Authorization", "Bearer "+adminTestToken)
+	return l.serve(request)
+}
+
+func (l *lockoutTestSuite) TestListEvents() {
+	lockedUntil := time.Date(2026, time.October, 19, 12, 15, 0, 0, time.UTC)
+	l.service.On("ListEvents", mock.Anything, preSessionID).Return([]lockout.Event{
+		{Type: lockout.EventUnlocked, Actor: "alice", CreateTime: lockedUntil.Add(-time.Minute)},
+		{Type: lockout.EventLocked, IPAddress: netip.MustParseAddr("192.0.2.1"), LockedUntil: lockedUntil, CreateTime: lockedUntil.Add(-15 * time.Minute)},
+	}, nil).Once()
+
+	response := l.listEvents(preSessionID)
+	l.Require().Equal(http.StatusOK, response.Code)
+	l.Equal("no-store", response.Header().Get("Cache-Control"))
+	var body struct {
+		Events []map[string]any `json:"events"`
+	}
+	l.Require().NoError(json.Unmarshal(response.Body.Bytes(), &body))
+	l.Require().Len(body.Events, 2)
+	l.Equal(map[string]any{"type": "unlocked", "create_time": "2026-10-19T12:14:00Z"}, body.Events[0])
+	l.Equal(map[string]any{
+		"type":         "locked",
+		"ip_address":   "192.0.2.1",
+		"locked_until": "2026-10-19T12:15:00Z",
+		"create_time":  "2026-10-19T12:00:00Z",
+	}, body.Events[1])
+}
+
+func (l *lockoutTestSuite) TestListEvents_Unauthenticated() {
+	l.Equal(http.StatusUnauthorized, l.listEvents("").Code)
+
+	l.service.On("ListEvents", mock.Anything, preSessionID).Return(nil, serviceLockout.ErrUnauthenticated).Once()
+	l.Equal(http.StatusUnauthorized, l.listEvents(preSessionID).Code)
+
+	l.service.On("ListEvents", mock.Anything, "other").Return(nil, errors.New("internal")).Once()
+	l.Equal(http.StatusInternalServerError, l.listEvents("other").Code)
+}
+
+func (l *lockoutTestSuite) TestUnlock() {
+	l.service.On("Unlock", mock.Anything, lockoutTestIdentityID, "alice").Return(nil).Once()
+	l.Equal(http.StatusNoContent, l.unlock(lockoutTestIdentityID).Code)
+	l.service.AssertExpectations(l.T())
+}
+
+func (l *lockoutTestSuite) TestUnlock_Errors() {
+	l.Equal(http.StatusBadRequest, l.unlock("not-an-id").Code)
+
+	l.service.On("Unlock", mock.Anything, lockoutTestIdentityID, "alice").Return(serviceLockout.ErrIdentityNotFound).Once()
+	l.Equal(http.StatusNotFound, l.unlock(lockoutTestIdentityID).Code)
+
+	// Only administrators may unlock
+	request := httptest.NewRequest(http.MethodPost, "/admin/identities/"+lockoutTestIdentityID+"/unlock", nil)
+	l.Equal(http.StatusUnauthorized, l.serve(request).Code)
+	l.service.AssertExpectations(l.T())
+}
+
+func TestLockoutTestSuite(t *testing.T) {
+	suite.Run(t, new(lockoutTestSuite))
+}
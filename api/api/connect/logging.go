@@ -0,0 +1,85 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/google/uuid"
+
+	"gitlab.mreg.io/my-registry/auth/logging"
+)
+
+// RequestIDHeader carries the correlation ID of a request. It is propagated
+// from the caller when valid and generated otherwise.
+const RequestIDHeader = "X-Request-Id"
+
+const maxRequestIDLength = 128
+
+// NewLoggingInterceptor assigns a request ID to every call, stores it in the
+// context together with the procedure and the client IP resolved by
+// NewClientIPInterceptor for the loggers downstream, and logs the outcome of
+// the call.
+func NewLoggingInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if req.Spec().IsClient {
+				return next(ctx, req)
+			}
+
+			requestID := req.Header().Get(RequestIDHeader)
+			if !validRequestID(requestID) {
+				requestID = uuid.New().String()
+			}
+			attrs := []slog.Attr{logging.RequestID(requestID), logging.Procedure(req.Spec().Procedure)}
+			if clientIP, ok := clientIPFromContext(ctx); ok {
+				attrs = append(attrs, logging.ClientIP(clientIP))
+			}
+			ctx = logging.With(ctx, attrs...)
+
+			start := time.Now()
+			res, err := next(ctx, req)
+			duration := slog.Duration("duration", time.Since(start))
+			if err != nil {
+				var connectErr *connect.Error
+				if errors.As(err, &connectErr) {
+					connectErr.Meta().Set(RequestIDHeader, requestID)
+				}
+				slog.LogAttrs(ctx, errorLevel(connect.CodeOf(err)), "request failed",
+					slog.String("code", connect.CodeOf(err).String()), duration, logging.Error(err))
+				return nil, err
+			}
+			res.Header().Set(RequestIDHeader, requestID)
+			slog.LogAttrs(ctx, slog.LevelInfo, "request completed", duration)
+			return res, nil
+		}
+	}
+}
+
+// validRequestID only accepts IDs that are safe to log and echo back.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// errorLevel logs server side failures as errors and client mistakes as
+// warnings.
+func errorLevel(code connect.Code) slog.Level {
+	switch code {
+	case connect.CodeInternal, connect.CodeUnknown, connect.CodeDataLoss, connect.CodeUnavailable:
+		return slog.LevelError
+	default:
+		return slog.LevelWarn
+	}
+}
@@ -0,0 +1,130 @@
+package connect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"gitlab.mreg.io/my-registry/auth/logging"
+)
+
+const loggingTestProcedure = "/test.v1.TestService/Ping"
+
+type loggingTestSuite struct {
+	suite.Suite
+	output         *bytes.Buffer
+	previousLogger *slog.Logger
+	handlerErr     error
+	server         *httptest.Server
+	client         *connect.Client[emptypb.Empty, emptypb.Empty]
+}
+
+func (l *loggingTestSuite) SetupTest() {
+	l.output = new(bytes.Buffer)
+	l.previousLogger = slog.Default()
+	slog.SetDefault(logging.New(l.output, slog.LevelInfo))
+	l.handlerErr = nil
+
+	mux := http.NewServeMux()
+	mux.Handle(loggingTestProcedure, connect.NewUnaryHandler(
+		loggingTestProcedure,
+		func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			slog.InfoContext(ctx, "handling request", "email", "test@example.com")
+			if l.handlerErr != nil {
+				return nil, l.handlerErr
+			}
+			return connect.NewResponse(&emptypb.Empty{}), nil
+		},
+		connect.WithInterceptors(newTestClientIPInterceptor(), NewLoggingInterceptor()),
+	))
+	l.server = httptest.NewServer(mux)
+	l.client = connect.NewClient[emptypb.Empty, emptypb.Empty](l.server.Client(), l.server.URL+loggingTestProcedure)
+}
+
+func (l *loggingTestSuite) TearDownTest() {
+	l.server.Close()
+	slog.SetDefault(l.previousLogger)
+}
+
+func (l *loggingTestSuite) records() []map[string]any {
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(l.output.String()), "\n") {
+		var record map[string]any
+		l.Require().NoError(json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func (l *loggingTestSuite) TestGeneratesRequestID() {
+	req := connect.NewRequest(&emptypb.Empty{})
+	req.Header().Set("X-Forwarded-For", "192.0.2.1")
+	res, err := l.client.CallUnary(context.Background(), req)
+	l.Require().NoError(err)
+	requestID := res.Header().Get(RequestIDHeader)
+	l.Require().NotEmpty(requestID)
+
+	records := l.records()
+	l.Require().Len(records, 2)
+	for _, record := range records {
+		l.Equal(requestID, record[logging.RequestIDKey])
+		l.Equal(loggingTestProcedure, record[logging.ProcedureKey])
+		l.Equal("192.0.2.1", record[logging.ClientIPKey])
+	}
+	l.Equal("[REDACTED]", records[0]["email"])
+	l.Equal("request completed", records[1]["msg"])
+	l.Equal("INFO", records[1]["level"])
+}
+
+func (l *loggingTestSuite) TestPropagatesRequestID() {
+	req := connect.NewRequest(&emptypb.Empty{})
+	req.Header().Set(RequestIDHeader, "upstream-id.1")
+	res, err := l.client.CallUnary(context.Background(), req)
+	l.Require().NoError(err)
+	l.Equal("upstream-id.1", res.Header().Get(RequestIDHeader))
+
+	// IDs that are unsafe to log are replaced
+	req.Header().Set(RequestIDHeader, "bad\tid")
+	res, err = l.client.CallUnary(context.Background(), req)
+	l.Require().NoError(err)
+	l.NotEqual("bad\tid", res.Header().Get(RequestIDHeader))
+	l.NotEmpty(res.Header().Get(RequestIDHeader))
+}
+
+func (l *loggingTestSuite) TestLogsErrors() {
+	l.handlerErr = connect.NewError(connect.CodeInternal, errors.New("cannot register test@example.com"))
+	_, err := l.client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	var connectErr *connect.Error
+	l.Require().ErrorAs(err, &connectErr)
+	l.NotEmpty(connectErr.Meta().Get(RequestIDHeader))
+
+	records := l.records()
+	l.Require().Len(records, 2)
+	l.Equal("request failed", records[1]["msg"])
+	l.Equal("ERROR", records[1]["level"])
+	l.Equal("internal", records[1]["code"])
+	l.NotContains(records[1][logging.ErrorKey], "test@example.com")
+}
+
+func (l *loggingTestSuite) TestLogsClientErrorsAsWarnings() {
+	l.handlerErr = connect.NewError(connect.CodeInvalidArgument, errors.New("missing header"))
+	_, err := l.client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	l.Require().Error(err)
+
+	records := l.records()
+	l.Equal("WARN", records[len(records)-1]["level"])
+}
+
+func TestLoggingTestSuite(t *testing.T) {
+	suite.Run(t, new(loggingTestSuite))
+}
@@ -0,0 +1,30 @@
+package connect
+
+import (
+	"context"
+	"time"
+
+	"connectrpc.com/connect"
+
+	"gitlab.mreg.io/my-registry/auth/metrics"
+)
+
+// NewMetricsInterceptor records the duration and result code of every
+// handled call.
+func NewMetricsInterceptor(m *metrics.Metrics) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if req.Spec().IsClient {
+				return next(ctx, req)
+			}
+			start := time.Now()
+			res, err := next(ctx, req)
+			code := "ok"
+			if err != nil {
+				code = connect.CodeOf(err).String()
+			}
+			m.ObserveRPC(req.Spec().Procedure, code, time.Since(start))
+			return res, err
+		}
+	}
+}
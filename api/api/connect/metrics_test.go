@@ -0,0 +1,72 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"gitlab.mreg.io/my-registry/auth/metrics"
+)
+
+type metricsTestSuite struct {
+	suite.Suite
+	metrics    *metrics.Metrics
+	handlerErr error
+	server     *httptest.Server
+	client     *connect.Client[emptypb.Empty, emptypb.Empty]
+}
+
+func (m *metricsTestSuite) SetupTest() {
+	m.metrics = metrics.New()
+	m.handlerErr = nil
+
+	mux := http.NewServeMux()
+	mux.Handle(loggingTestProcedure, connect.NewUnaryHandler(
+		loggingTestProcedure,
+		func(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			if m.handlerErr != nil {
+				return nil, m.handlerErr
+			}
+			return connect.NewResponse(&emptypb.Empty{}), nil
+		},
+		connect.WithInterceptors(NewMetricsInterceptor(m.metrics)),
+	))
+	m.server = httptest.NewServer(mux)
+	m.client = connect.NewClient[emptypb.Empty, emptypb.Empty](m.server.Client(), m.server.URL+loggingTestProcedure)
+}
+
+func (m *metricsTestSuite) TearDownTest() {
+	m.server.Close()
+}
+
+func (m *metricsTestSuite) scrape() string {
+	recorder := httptest.NewRecorder()
+	m.metrics.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, err := io.ReadAll(recorder.Body)
+	m.Require().NoError(err)
+	return string(body)
+}
+
+func (m *metricsTestSuite) TestRecordsCodes() {
+	_, err := m.client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	m.Require().NoError(err)
+
+	m.handlerErr = connect.NewError(connect.CodeResourceExhausted, errors.New("slow down"))
+	_, err = m.client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	m.Require().Error(err)
+
+	body := m.scrape()
+	m.Contains(body, `auth_rpc_duration_seconds_count{code="ok",procedure="/test.v1.TestService/Ping"} 1`)
+	m.Contains(body, `auth_rpc_duration_seconds_count{code="resource_exhausted",procedure="/test.v1.TestService/Ping"} 1`)
+}
+
+func TestMetricsTestSuite(t *testing.T) {
+	suite.Run(t, new(metricsTestSuite))
+}
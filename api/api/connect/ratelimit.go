@@ -0,0 +1,97 @@
+package connect
+
+import (
+	"context"
+	"log/slog"
+
+	auth "buf.build/gen/go/mreg/protobuf/protocolbuffers/go/mreg/auth/v1alpha1"
+	"connectrpc.com/connect"
+
+	"gitlab.mreg.io/my-registry/auth/domain/ratelimit"
+	"gitlab.mreg.io/my-registry/auth/logging"
+)
+
+// RateLimitRule configures the token buckets enforced for one procedure.
+// A zero Limit disables the corresponding bucket.
+type RateLimitRule struct {
+	// PerIP is shared by requests from the same client address.
+	PerIP ratelimit.Limit
+	// PerPrefix is shared by requests from the same /24 (IPv4) or /64 (IPv6).
+	PerPrefix ratelimit.Limit
+	// PerEmail is shared by requests targeting the same email address.
+	PerEmail ratelimit.Limit
+}
+
+type rateLimitBucket struct {
+	key   string
+	limit ratelimit.Limit
+}
+
+// NewRateLimitInterceptor rejects requests with CodeResourceExhausted once
+// any of the buckets configured for the procedure is empty. The tokens
+// already taken from the other buckets are then refunded, so that rejected
+// requests do not count against the client.
+func NewRateLimitInterceptor(repository ratelimit.Repository, rules map[string]RateLimitRule) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			rule, ok := rules[req.Spec().Procedure]
+			if !ok || req.Spec().IsClient {
+				return next(ctx, req)
+			}
+
+			buckets := rateLimitBuckets(ctx, req, rule)
+			for i, bucket := range buckets {
+				result, err := repository.Take(ctx, bucket.key, bucket.limit)
+				if err != nil {
+					slog.ErrorContext(ctx, "error taking rate limit token", logging.Error(err))
+					refundRateLimitTokens(ctx, repository, buckets[:i])
+					return nil, internalError(ctx)
+				}
+				if !result.Allowed {
+					refundRateLimitTokens(ctx, repository, buckets[:i])
+					return nil, errorRateLimited(ctx, result.RetryAfter)
+				}
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// refundRateLimitTokens gives back the tokens taken from buckets. Failures
+// are logged only, as the request is rejected anyway.
+func refundRateLimitTokens(ctx context.Context, repository ratelimit.Repository, buckets []rateLimitBucket) {
+	for _, bucket := range buckets {
+		if err := repository.Refund(ctx, bucket.key, bucket.limit); err != nil {
+			slog.WarnContext(ctx, "error refunding rate limit token", logging.Error(err))
+		}
+	}
+}
+
+func rateLimitBuckets(ctx context.Context, req connect.AnyRequest, rule RateLimitRule) []rateLimitBucket {
+	procedure := req.Spec().Procedure
+	var buckets []rateLimitBucket
+	// An unspecified address such as the web app's 0.0.0.0 placeholder
+	// would put every client in one bucket
+	if clientIP, ok := clientIPFromContext(ctx); ok && !clientIP.IsUnspecified() {
+		if rule.PerIP.Enabled() {
+			buckets = append(buckets, rateLimitBucket{ratelimit.IPKey(procedure, clientIP), rule.PerIP})
+		}
+		if rule.PerPrefix.Enabled() {
+			buckets = append(buckets, rateLimitBucket{ratelimit.PrefixKey(procedure, clientIP), rule.PerPrefix})
+		}
+	}
+	if email := targetEmail(req.Any()); email != "" && rule.PerEmail.Enabled() {
+		buckets = append(buckets, rateLimitBucket{ratelimit.EmailKey(procedure, email), rule.PerEmail})
+	}
+	return buckets
+}
+
+// targetEmail returns the email address a request acts on, if any.
+func targetEmail(msg any) string {
+	switch msg := msg.(type) {
+	case *auth.CompleteRegistrationFlowRequest:
+		return msg.GetRegistrationFlow().GetTraits().GetEmail()
+	default:
+		return ""
+	}
+}
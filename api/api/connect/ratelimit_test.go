@@ -0,0 +1,155 @@
+package connect
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	authConnect "buf.build/gen/go/mreg/protobuf/connectrpc/go/mreg/auth/v1alpha1/authv1alpha1connect"
+	auth "buf.build/gen/go/mreg/protobuf/protocolbuffers/go/mreg/auth/v1alpha1"
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"gitlab.mreg.io/my-registry/auth/config"
+	"gitlab.mreg.io/my-registry/auth/domain/ratelimit"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/memory"
+)
+
+const rateLimitTestProcedure = "/test.v1.TestService/Ping"
+
+var rateLimitTestRule = RateLimitRule{
+	PerIP:     ratelimit.Limit{Burst: 2, Period: time.Minute},
+	PerPrefix: ratelimit.Limit{Burst: 3, Period: time.Minute},
+}
+
+type rateLimitTestSuite struct {
+	suite.Suite
+	repository ratelimit.Repository
+	server     *httptest.Server
+	client     *connect.Client[emptypb.Empty, emptypb.Empty]
+}
+
+func (r *rateLimitTestSuite) SetupTest() {
+	r.repository = memory.NewRateLimitRepository()
+	interceptor := NewRateLimitInterceptor(r.repository, map[string]RateLimitRule{
+		rateLimitTestProcedure: rateLimitTestRule,
+	})
+	mux := http.NewServeMux()
+	mux.Handle(rateLimitTestProcedure, connect.NewUnaryHandler(
+		rateLimitTestProcedure,
+		func(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			return connect.NewResponse(&emptypb.Empty{}), nil
+		},
+		connect.WithInterceptors(newTestClientIPInterceptor(), interceptor),
+	))
+	r.server = httptest.NewServer(mux)
+	r.client = connect.NewClient[emptypb.Empty, emptypb.Empty](r.server.Client(), r.server.URL+rateLimitTestProcedure)
+}
+
+func (r *rateLimitTestSuite) TearDownTest() {
+	r.server.Close()
+}
+
+func (r *rateLimitTestSuite) call(clientIP string) error {
+	req := connect.NewRequest(&emptypb.Empty{})
+	if clientIP != "" {
+		req.Header().Set("X-Forwarded-For", clientIP)
+	}
+	_, err := r.client.CallUnary(context.Background(), req)
+	return err
+}
+
+func (r *rateLimitTestSuite) TestPerIP() {
+	r.Require().NoError(r.call("192.0.2.1"))
+	r.Require().NoError(r.call("192.0.2.1"))
+
+	err := r.call("192.0.2.1")
+	r.Require().Equal(connect.CodeResourceExhausted, connect.CodeOf(err))
+
+	var connectErr *connect.Error
+	r.Require().ErrorAs(err, &connectErr)
+	r.Equal("60", connectErr.Meta().Get("Retry-After"))
+	r.Require().Len(connectErr.Details(), 3)
+	detail, err := connectErr.Details()[0].Value()
+	r.Require().NoError(err)
+	info, ok := detail.(*errdetails.ErrorInfo)
+	r.Require().True(ok)
+	r.Equal("RATE_LIMITED", info.GetReason())
+	r.Equal("60", info.GetMetadata()["retry_after"])
+	detail, err = connectErr.Details()[1].Value()
+	r.Require().NoError(err)
+	retryInfo, ok := detail.(*errdetails.RetryInfo)
+	r.Require().True(ok)
+	r.InDelta(time.Minute, retryInfo.GetRetryDelay().AsDuration(), float64(time.Second))
+	detail, err = connectErr.Details()[2].Value()
+	r.Require().NoError(err)
+	message, ok := detail.(*errdetails.LocalizedMessage)
+	r.Require().True(ok)
+	r.Equal("en", message.GetLocale())
+}
+
+func (r *rateLimitTestSuite) TestPerPrefix() {
+	r.Require().NoError(r.call("192.0.2.1"))
+	r.Require().NoError(r.call("192.0.2.2"))
+	r.Require().NoError(r.call("192.0.2.3"))
+	r.Require().Equal(connect.CodeResourceExhausted, connect.CodeOf(r.call("192.0.2.4")))
+
+	// Another network is unaffected
+	r.Require().NoError(r.call("198.51.100.1"))
+}
+
+func (r *rateLimitTestSuite) TestRejectedRequestsAreRefunded() {
+	r.Require().NoError(r.call("192.0.2.1"))
+	r.Require().NoError(r.call("192.0.2.2"))
+	r.Require().NoError(r.call("192.0.2.3"))
+	// Taken from the bucket of the address, then rejected by the prefix
+	r.Require().Equal(connect.CodeResourceExhausted, connect.CodeOf(r.call("192.0.2.1")))
+
+	// The address kept its second token
+	key := ratelimit.IPKey(rateLimitTestProcedure, netip.MustParseAddr("192.0.2.1"))
+	result, err := r.repository.Take(context.Background(), key, rateLimitTestRule.PerIP)
+	r.Require().NoError(err)
+	r.True(result.Allowed)
+	result, err = r.repository.Take(context.Background(), key, rateLimitTestRule.PerIP)
+	r.Require().NoError(err)
+	r.False(result.Allowed)
+}
+
+func (r *rateLimitTestSuite) TestPeerAddressFallback() {
+	r.Require().NoError(r.call(""))
+	r.Require().NoError(r.call(""))
+	r.Require().Equal(connect.CodeResourceExhausted, connect.CodeOf(r.call("")))
+}
+
+func (r *rateLimitTestSuite) TestUnspecifiedAddressIsNotLimited() {
+	for range 5 {
+		r.Require().NoError(r.call("0.0.0.0"))
+	}
+}
+
+func (r *rateLimitTestSuite) TestTargetEmail() {
+	email := "test@example.com"
+	r.Equal(email, targetEmail(&auth.CompleteRegistrationFlowRequest{
+		RegistrationFlow: &auth.RegistrationFlow{Traits: &auth.IdentityTraits{Email: &email}},
+	}))
+	r.Empty(targetEmail(&auth.CreateRegistrationFlowRequest{}))
+}
+
+func (r *rateLimitTestSuite) TestDefaultRulesNameServedProcedures() {
+	served := []string{
+		authConnect.RegistrationServiceCreateRegistrationFlowProcedure,
+		authConnect.RegistrationServiceCompleteRegistrationFlowProcedure,
+	}
+	for procedure := range config.Default().RateLimit.Rules {
+		r.Contains(served, procedure)
+	}
+}
+
+func TestRateLimitTestSuite(t *testing.T) {
+	suite.Run(t, new(rateLimitTestSuite))
+}
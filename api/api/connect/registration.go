@@ -2,11 +2,11 @@ package connect
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"net/netip"
-	"strings"
+	"path"
+	"strconv"
 	"time"
 
 	authConnect "buf.build/gen/go/mreg/protobuf/connectrpc/go/mreg/auth/v1alpha1/authv1alpha1connect"
@@ -15,12 +15,21 @@ import (
 
 	"gitlab.mreg.io/my-registry/auth/domain/identity"
 	"gitlab.mreg.io/my-registry/auth/domain/registration"
-	"gitlab.mreg.io/my-registry/auth/infrastructure/cockroachdb"
+	"gitlab.mreg.io/my-registry/auth/logging"
 
 	serviceRegistration "gitlab.mreg.io/my-registry/auth/service/registration"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// Proof of work challenges and CAPTCHA tokens are not part of the
+// registration flow messages, so they travel in headers.
+const (
+	challengeSeedHeader       = "X-Challenge-Seed"
+	challengeDifficultyHeader = "X-Challenge-Difficulty"
+	challengeSolutionHeader   = "X-Challenge-Solution"
+	captchaTokenHeader        = "X-Captcha-Token"
+)
+
 type registrationHandler struct {
 	registrationService serviceRegistration.Service
 }
@@ -32,31 +41,24 @@ func NewRegistrationHandler(registrationService serviceRegistration.Service) aut
 func (r *registrationHandler) CreateRegistrationFlow(ctx context.Context, req *connect.Request[auth.CreateRegistrationFlowRequest]) (*connect.Response[auth.CreateRegistrationFlowResponse], error) {
 	headers := req.Header()
 	userAgent := headers.Get("User-Agent")
-	xForwardedFor := headers.Get("X-Forwarded-For")
-	var clientIP netip.Addr
-	var err error
 	if userAgent == "" {
-		return nil, errorMissingHeader("User-Agent")
+		return nil, errorMissingHeader(ctx, "User-Agent")
 	}
-	if xForwardedFor != "" {
-		clientIPString, _, _ := strings.Cut(xForwardedFor, ",")
-		clientIP, err = netip.ParseAddr(clientIPString)
-		if err != nil {
-			return nil, connect.NewError(connect.CodeInvalidArgument, err)
-		}
-	} else {
-		return nil, errorMissingHeader("X-Forwarded-For")
+	clientIP, ok := clientIPFromContext(ctx)
+	if !ok {
+		slog.ErrorContext(ctx, "client IP not resolved, is the interceptor installed?")
+		return nil, internalError(ctx)
 	}
 
 	flow, sessionData, err := r.registrationService.CreateRegistrationFlow(ctx, clientIP, userAgent)
 	if err != nil {
-		fmt.Printf("error creating registration flow: %v\n", err)
-		return nil, internalError()
+		return nil, fmt.Errorf("creating registration flow: %w", err)
 	}
+	ctx = logging.With(ctx, logging.FlowID(flow.FlowID), logging.SessionID(sessionData.ID))
 	eTag, err := flow.ETag()
 	if err != nil {
-		fmt.Printf("error generating etag in registration flow: %v\n", err)
-		return nil, internalError()
+		slog.ErrorContext(ctx, "error generating etag in registration flow", logging.Error(err))
+		return nil, internalError(ctx)
 	}
 	res := &auth.CreateRegistrationFlowResponse{
 		RegistrationFlow: &auth.RegistrationFlow{
@@ -78,6 +80,10 @@ func (r *registrationHandler) CreateRegistrationFlow(ctx context.Context, req *c
 	}
 	response := connect.NewResponse[auth.CreateRegistrationFlowResponse](res)
 	response.Header().Add("Set-Cookie", cookie.String())
+	if flow.Challenge != nil {
+		response.Header().Set(challengeSeedHeader, flow.Challenge.Seed)
+		response.Header().Set(challengeDifficultyHeader, strconv.Itoa(int(flow.Challenge.Difficulty)))
+	}
 	return response, nil
 }
 
@@ -88,7 +94,7 @@ func (r *registrationHandler) CompleteRegistrationFlow(ctx context.Context, req
 	cookie := headers.Get("Cookie")
 	parsedCookies, err := http.ParseCookie(cookie)
 	if err != nil {
-		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("unauthenticated"))
+		return nil, errorUnauthenticated(ctx)
 	}
 	var sessionID string
 	for _, cookie := range parsedCookies {
@@ -98,24 +104,19 @@ func (r *registrationHandler) CompleteRegistrationFlow(ctx context.Context, req
 		}
 	}
 	if sessionID == "" {
-		return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("unauthenticated"))
+		return nil, errorUnauthenticated(ctx)
 	}
+	name := req.Msg.GetRegistrationFlow().GetName()
+	logCtx := logging.With(ctx, logging.FlowID(path.Base(name)), logging.SessionID(sessionID))
 
 	userAgent := headers.Get("User-Agent")
-	xForwardedFor := headers.Get("X-Forwarded-For")
-
-	var clientIP netip.Addr
 	if userAgent == "" {
-		return nil, errorMissingHeader("User-Agent")
+		return nil, errorMissingHeader(ctx, "User-Agent")
 	}
-	if xForwardedFor != "" {
-		clientIPString, _, _ := strings.Cut(xForwardedFor, ",")
-		clientIP, err = netip.ParseAddr(clientIPString)
-		if err != nil {
-			return nil, connect.NewError(connect.CodeInvalidArgument, err)
-		}
-	} else {
-		return nil, errorMissingHeader("X-Forwarded-For")
+	clientIP, ok := clientIPFromContext(ctx)
+	if !ok {
+		slog.ErrorContext(logCtx, "client IP not resolved, is the interceptor installed?")
+		return nil, internalError(ctx)
 	}
 	timezone := req.Msg.GetRegistrationFlow().GetTraits().GetTimezone().GetId()
 	_, err = time.LoadLocation(timezone)
@@ -124,8 +125,10 @@ func (r *registrationHandler) CompleteRegistrationFlow(ctx context.Context, req
 	}
 
 	flow := &registration.Flow{
-		SessionID: sessionID, // Use the extracted session ID
-		Password:  req.Msg.GetRegistrationFlow().GetPassword().GetPassword(),
+		SessionID:    sessionID, // Use the extracted session ID
+		Password:     req.Msg.GetRegistrationFlow().GetPassword().GetPassword(),
+		Solution:     headers.Get(challengeSolutionHeader),
+		CaptchaToken: headers.Get(captchaTokenHeader),
 		Identity: &identity.Identity{
 			Emails: []identity.Email{
 				{
@@ -133,42 +136,30 @@ func (r *registrationHandler) CompleteRegistrationFlow(ctx context.Context, req
 				},
 			},
 			Timezone: timezone,
+			// the emails of the identity follow the language of its
+			// registration
+			Locale: languageFromContext(ctx).String(),
 		},
 	}
 
-	// Complete the registration flow, handling potential errors
-	name := req.Msg.GetRegistrationFlow().GetName()
+	// Complete the registration flow; errors are mapped by the error
+	// interceptor
 	sessionData, err := r.registrationService.CompleteRegistrationFlow(ctx, flow, name, clientIP, userAgent)
 	if err != nil {
-		switch {
-		case errors.Is(err, cockroachdb.ErrConstraint):
-			return nil, internalError()
-		case errors.Is(err, serviceRegistration.ErrEmailExists):
-			return nil, errorEmailExist()
-		case errors.Is(err, serviceRegistration.ErrInsecurePassword):
-			return nil, errorInsecurePassword()
-		case errors.Is(err, serviceRegistration.ErrSessionExpired):
-			return nil, errorSessionExpired()
-		case errors.Is(err, serviceRegistration.ErrUnauthenticated):
-			return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("unauthenticated"))
-		case errors.Is(err, serviceRegistration.ErrFlowExpired):
-			return nil, errorFlowExpired()
-		default:
-			return nil, internalError()
-		}
+		return nil, fmt.Errorf("completing registration flow %s: %w", path.Base(name), err)
 	}
 	identityData := flow.Identity
 	// Generate ETag for the identity and address
 	identityEtag, err := identityData.ETag()
 	if err != nil {
-		fmt.Printf("error generating identityEtag in registration flow: %v\n", err)
-		return nil, internalError()
+		slog.ErrorContext(logCtx, "error generating identity etag in registration flow", logging.Error(err))
+		return nil, internalError(ctx)
 	}
 	email := identityData.Emails[0]
 	addressEtag, err := email.ETag()
 	if err != nil {
-		fmt.Printf("error generating addressEtag in registration flow: %v\n", err)
-		return nil, internalError()
+		slog.ErrorContext(logCtx, "error generating address etag in registration flow", logging.Error(err))
+		return nil, internalError(ctx)
 	}
 
 	// Prepare the response message with identity data
@@ -12,6 +12,7 @@ import (
 
 	"github.com/google/uuid"
 
+	"golang.org/x/text/language"
 	"google.golang.org/genproto/googleapis/type/datetime"
 
 	"gitlab.mreg.io/my-registry/auth/domain/identity"
@@ -77,8 +78,7 @@ var (
 func (h *handlerTestSuite) TestCreateRegistrationFlow() {
 	req := connect.NewRequest[auth.CreateRegistrationFlowRequest](&auth.CreateRegistrationFlowRequest{})
 	req.Header().Set("User-Agent", UA)
-	req.Header().Set("X-Forwarded-For", IP)
-	ctx := context.Background()
+	ctx := withClientIP(context.Background(), netip.MustParseAddr(IP))
 
 	clientIP, err := netip.ParseAddr(IP)
 	h.Require().NoError(err)
@@ -137,13 +137,12 @@ func (h *handlerTestSuite) TestCreateRegistrationFlow_WithoutIP() {
 
 	_, err := h.handler.CreateRegistrationFlow(ctx, req)
 	// Assert proper call to repository
-	h.Require().Error(err) // no ip address provided
+	h.Require().Error(err) // client IP not resolved by the interceptor
 }
 
 func (h *handlerTestSuite) TestCreateRegistrationFlow_WithoutUA() {
 	req := connect.NewRequest[auth.CreateRegistrationFlowRequest](&auth.CreateRegistrationFlowRequest{})
-	req.Header().Set("X-Forwarded-For", IP)
-	ctx := context.Background()
+	ctx := withClientIP(context.Background(), netip.MustParseAddr(IP))
 
 	_, err := h.handler.CreateRegistrationFlow(ctx, req)
 	// Assert proper call to repository
@@ -175,12 +174,12 @@ func (h *handlerTestSuite) TestCompleteRegistrationFlow() {
 	}
 	req.Header().Add("Cookie", cookie.String())
 	req.Header().Set("User-Agent", UA)
-	req.Header().Set("X-Forwarded-For", IP)
 
 	clientIP, err := netip.ParseAddr(IP)
 	h.Require().NoError(err)
 
-	ctx := context.Background()
+	// the identity registers in the language of the request
+	ctx := withLanguage(withClientIP(context.Background(), netip.MustParseAddr(IP)), language.French)
 	flow := &registration.Flow{
 		SessionID: preSessionID, // Use the extracted session ID
 		Password:  req.Msg.GetRegistrationFlow().GetPassword().GetPassword(),
@@ -191,6 +190,7 @@ func (h *handlerTestSuite) TestCompleteRegistrationFlow() {
 				},
 			},
 			Timezone: timezone,
+			Locale:   "fr",
 		},
 	}
 	// Mock CSRF verification
@@ -275,12 +275,11 @@ func (h *handlerTestSuite) TestCompleteRegistrationFlow_NoCookie() {
 		},
 	})
 	req.Header().Set("User-Agent", UA)
-	req.Header().Set("X-Forwarded-For", IP)
 
 	clientIP, err := netip.ParseAddr(IP)
 	h.Require().NoError(err)
 	name := req.Msg.GetRegistrationFlow().GetName()
-	ctx := context.Background()
+	ctx := withClientIP(context.Background(), netip.MustParseAddr(IP))
 	flow := &registration.Flow{
 		SessionID: preSessionID, // Use the extracted session ID
 		Password:  req.Msg.GetRegistrationFlow().GetPassword().GetPassword(),
@@ -291,6 +290,7 @@ func (h *handlerTestSuite) TestCompleteRegistrationFlow_NoCookie() {
 				},
 			},
 			Timezone: timezone,
+			Locale:   "en",
 		},
 	}
 	// Mock CSRF verification
@@ -331,12 +331,11 @@ func (h *handlerTestSuite) TestCompleteRegistrationFlow_NoCookieWithNameSessionI
 	}
 	req.Header().Add("Cookie", cookie.String())
 	req.Header().Set("User-Agent", UA)
-	req.Header().Set("X-Forwarded-For", IP)
 
 	clientIP, err := netip.ParseAddr(IP)
 	h.Require().NoError(err)
 	name := req.Msg.GetRegistrationFlow().GetName()
-	ctx := context.Background()
+	ctx := withClientIP(context.Background(), netip.MustParseAddr(IP))
 	flow := &registration.Flow{
 		SessionID: preSessionID, // Use the extracted session ID
 		Password:  req.Msg.GetRegistrationFlow().GetPassword().GetPassword(),
@@ -347,6 +346,7 @@ func (h *handlerTestSuite) TestCompleteRegistrationFlow_NoCookieWithNameSessionI
 				},
 			},
 			Timezone: timezone,
+			Locale:   "en",
 		},
 	}
 	// Mock CSRF verification
@@ -388,13 +388,12 @@ func (h *handlerTestSuite) TestCompleteRegistrationFlow_ServiceError() {
 	}
 	req.Header().Add("Cookie", cookie.String())
 	req.Header().Set("User-Agent", UA)
-	req.Header().Set("X-Forwarded-For", IP)
 
 	clientIP, err := netip.ParseAddr(IP)
 	h.Require().NoError(err)
 
 	name := req.Msg.GetRegistrationFlow().GetName()
-	ctx := context.Background()
+	ctx := withClientIP(context.Background(), netip.MustParseAddr(IP))
 	flow := &registration.Flow{
 		SessionID: preSessionID, // Use the extracted session ID
 		Password:  req.Msg.GetRegistrationFlow().GetPassword().GetPassword(),
@@ -405,6 +404,7 @@ func (h *handlerTestSuite) TestCompleteRegistrationFlow_ServiceError() {
 				},
 			},
 			Timezone: timezone,
+			Locale:   "en",
 		},
 	}
 	// Mock CSRF verification
@@ -417,7 +417,7 @@ func (h *handlerTestSuite) TestCompleteRegistrationFlow_ServiceError() {
 		Return(nil, errors.New("internal")).Once()
 
 	_, err = h.handler.CompleteRegistrationFlow(ctx, req)
-	h.Require().Equal(err.Error(), internalError().Error())
+	h.Require().Equal(internalError(ctx).Error(), toConnectError(ctx, err).Error())
 	h.mockService.AssertExpectations(h.T())
 	call1.Unset()
 
@@ -428,9 +428,114 @@ func (h *handlerTestSuite) TestCompleteRegistrationFlow_ServiceError() {
 		Return(nil, registrationService.ErrEmailExists).Once()
 
 	_, err = h.handler.CompleteRegistrationFlow(ctx, req)
-	h.Require().Equal(err.Error(), errorEmailExist().Error())
+	h.Require().ErrorIs(err, registrationService.ErrEmailExists)
+	h.Require().Equal(connect.CodeAlreadyExists, connect.CodeOf(toConnectError(ctx, err)))
 	h.mockService.AssertExpectations(h.T())
 	call2.Unset()
+
+	call3 := h.mockService.
+		On("CompleteRegistrationFlow", ctx, flow, name, clientIP, UA).
+		Run(func(_ mock.Arguments) {
+		}).
+		Return(nil, identity.ErrHasherBusy).Once()
+
+	_, err = h.handler.CompleteRegistrationFlow(ctx, req)
+	h.Require().Equal(connect.CodeResourceExhausted, connect.CodeOf(toConnectError(ctx, err)))
+	h.mockService.AssertExpectations(h.T())
+	call3.Unset()
+
+	call4 := h.mockService.
+		On("CompleteRegistrationFlow", ctx, flow, name, clientIP, UA).
+		Return(nil, registrationService.ErrChallengeFailed).Once()
+
+	_, err = h.handler.CompleteRegistrationFlow(ctx, req)
+	h.Require().ErrorIs(err, registrationService.ErrChallengeFailed)
+	h.mockService.AssertExpectations(h.T())
+	call4.Unset()
+
+	call5 := h.mockService.
+		On("CompleteRegistrationFlow", ctx, flow, name, clientIP, UA).
+		Return(nil, registration.ErrCaptchaFailed).Once()
+
+	_, err = h.handler.CompleteRegistrationFlow(ctx, req)
+	h.Require().Equal(connect.CodeFailedPrecondition, connect.CodeOf(toConnectError(ctx, err)))
+	h.mockService.AssertExpectations(h.T())
+	call5.Unset()
+
+	call6 := h.mockService.
+		On("CompleteRegistrationFlow", ctx, flow, name, clientIP, UA).
+		Return(nil, registrationService.ErrRiskBlocked).Once()
+
+	_, err = h.handler.CompleteRegistrationFlow(ctx, req)
+	h.Require().Equal(connect.CodePermissionDenied, connect.CodeOf(toConnectError(ctx, err)))
+	h.mockService.AssertExpectations(h.T())
+	call6.Unset()
+}
+
+func (h *handlerTestSuite) TestCreateRegistrationFlow_RiskBlocked() {
+	req := connect.NewRequest[auth.CreateRegistrationFlowRequest](&auth.CreateRegistrationFlowRequest{})
+	req.Header().Set("User-Agent", UA)
+	ctx := withClientIP(context.Background(), netip.MustParseAddr(IP))
+
+	call := h.mockService.
+		On("CreateRegistrationFlow", ctx, netip.MustParseAddr(IP), UA).
+		Return(nil, nil, registrationService.ErrRiskBlocked).
+		Once()
+
+	_, err := h.handler.CreateRegistrationFlow(ctx, req)
+	h.Require().Equal(connect.CodePermissionDenied, connect.CodeOf(toConnectError(ctx, err)))
+	h.mockService.AssertExpectations(h.T())
+	call.Unset()
+}
+
+func (h *handlerTestSuite) TestCreateRegistrationFlow_Challenge() {
+	req := connect.NewRequest[auth.CreateRegistrationFlowRequest](&auth.CreateRegistrationFlowRequest{})
+	req.Header().Set("User-Agent", UA)
+	ctx := withClientIP(context.Background(), netip.MustParseAddr(IP))
+
+	clientIP, err := netip.ParseAddr(IP)
+	h.Require().NoError(err)
+	call := h.mockService.
+		On("CreateRegistrationFlow", ctx, clientIP, UA).
+		Return(&registration.Flow{
+			FlowID:    "0dc909cb-8c0f-4dc8-98b9-e82d77eb9d79",
+			ExpiresAt: time.Now().Add(time.Hour),
+			SessionID: "c2e577de-2fbc-4fa4-8dcd-321a960ebb36",
+			Challenge: &registration.Challenge{Seed: "c2VlZA", Difficulty: 18},
+		}, &session.Session{ID: "c2e577de-2fbc-4fa4-8dcd-321a960ebb36"}, nil).
+		Once()
+
+	res, err := h.handler.CreateRegistrationFlow(ctx, req)
+	h.Require().NoError(err)
+	h.Equal("c2VlZA", res.Header().Get(challengeSeedHeader))
+	h.Equal("18", res.Header().Get(challengeDifficultyHeader))
+	call.Unset()
+}
+
+func (h *handlerTestSuite) TestCompleteRegistrationFlow_ChallengeHeaders() {
+	req := connect.NewRequest[auth.CompleteRegistrationFlowRequest](&auth.CompleteRegistrationFlowRequest{
+		RegistrationFlow: &auth.RegistrationFlow{
+			Name: "registrationFlows/" + uuid.New().String(),
+			Traits: &auth.IdentityTraits{
+				Email: &filledEmail,
+			},
+		},
+	})
+	req.Header().Add("Cookie", (&http.Cookie{Name: "session_id", Value: preSessionID}).String())
+	req.Header().Set("User-Agent", UA)
+	req.Header().Set(challengeSolutionHeader, "42")
+	req.Header().Set(captchaTokenHeader, "token")
+	ctx := withClientIP(context.Background(), netip.MustParseAddr(IP))
+
+	h.mockService.
+		On("CompleteRegistrationFlow", ctx, mock.MatchedBy(func(flow *registration.Flow) bool {
+			return flow.Solution == "42" && flow.CaptchaToken == "token"
+		}), mock.Anything, mock.Anything, UA).
+		Return(nil, registrationService.ErrChallengeFailed).Once()
+
+	_, err := h.handler.CompleteRegistrationFlow(ctx, req)
+	h.Require().Equal(connect.CodeFailedPrecondition, connect.CodeOf(toConnectError(ctx, err)))
+	h.mockService.AssertExpectations(h.T())
 }
 
 func TestHandlerTestSuite(t *testing.T) {
@@ -0,0 +1,108 @@
+package connect
+
+import (
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/logging"
+	"gitlab.mreg.io/my-registry/auth/service/devicealert"
+)
+
+// maxRevokeFormBytes bounds the body of the revocation form, which only
+// holds the token.
+const maxRevokeFormBytes = 4096
+
+var revokePage = template.Must(template.New("revoke").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign out device</title>
+</head>
+<body>
+<main>
+{{- if .Token }}
+<h1>Sign out this device?</h1>
+<p>The device will be signed out of your account right away.</p>
+<form method="post">
+<input type="hidden" name="token" value="{{ .Token }}">
+<button type="submit">Sign out device</button>
+</form>
+{{- else }}
+<h1>{{ .Title }}</h1>
+<p>{{ .Message }}</p>
+{{- end }}
+</main>
+</body>
+</html>
+`))
+
+type revokePageData struct {
+	Token   string
+	Title   string
+	Message string
+}
+
+// NewRevokeSessionHandler serves the page linked from new device notices.
+// GET only asks for confirmation, so that mail scanners following the link
+// do not sign anyone out, and POST revokes the session. The client address
+// recorded with the revocation is resolved like for the API.
+func NewRevokeSessionHandler(service devicealert.Service, resolver *ClientIPResolver) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The token is in the URL, and the page must not be framed
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; form-action 'self'; frame-ancestors 'none'")
+
+		switch r.Method {
+		case http.MethodGet:
+			token := r.URL.Query().Get("token")
+			if token == "" {
+				renderRevokePage(w, http.StatusBadRequest, invalidRevokeLink)
+				return
+			}
+			renderRevokePage(w, http.StatusOK, revokePageData{Token: token})
+		case http.MethodPost:
+			clientIP, err := resolver.Resolve(r.RemoteAddr, r.Header)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxRevokeFormBytes)
+			ctx := r.Context()
+			err = service.RevokeSession(ctx, r.PostFormValue("token"), clientIP, r.UserAgent())
+			switch {
+			case err == nil:
+				renderRevokePage(w, http.StatusOK, revokePageData{
+					Title:   "Device signed out",
+					Message: "The device has been signed out. Change your password if you do not recognize the sign-in.",
+				})
+			case errors.Is(err, session.ErrInvalidRevocationToken):
+				renderRevokePage(w, http.StatusBadRequest, invalidRevokeLink)
+			default:
+				slog.ErrorContext(ctx, "error revoking session", logging.Error(err))
+				renderRevokePage(w, http.StatusInternalServerError, revokePageData{
+					Title:   "Something went wrong",
+					Message: "The device could not be signed out. Please try again later.",
+				})
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+var invalidRevokeLink = revokePageData{
+	Title:   "Invalid link",
+	Message: "This link is invalid or has expired. Sessions end on their own once they expire.",
+}
+
+func renderRevokePage(w http.ResponseWriter, status int, data revokePageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = revokePage.Execute(w, data)
+}
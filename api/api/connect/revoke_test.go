@@ -0,0 +1,93 @@
+package connect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/service/devicealert"
+)
+
+type mockDeviceAlerts struct {
+	devicealert.Service
+	mock.Mock
+}
+
+func (m *mockDeviceAlerts) RevokeSession(ctx context.Context, token string, ipAddress netip.Addr, userAgent string) error {
+	return m.Called(ctx, token, ipAddress, userAgent).Error(0)
+}
+
+// The address httptest gives requests
+var revokeClientIP = netip.MustParseAddr("192.0.2.1")
+
+type revokeTestSuite struct {
+	suite.Suite
+	service *mockDeviceAlerts
+	handler http.Handler
+}
+
+func (r *revokeTestSuite) SetupTest() {
+	r.service = new(mockDeviceAlerts)
+	r.handler = NewRevokeSessionHandler(r.service, NewClientIPResolver(nil))
+}
+
+func (r *revokeTestSuite) post(token string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/sessions/revoke", strings.NewReader(url.Values{"token": {token}}.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("User-Agent", UA)
+	recorder := httptest.NewRecorder()
+	r.handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func (r *revokeTestSuite) TestConfirm() {
+	recorder := httptest.NewRecorder()
+	r.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/sessions/revoke?token=abc.def", nil))
+	r.Equal(http.StatusOK, recorder.Code)
+	r.Contains(recorder.Body.String(), `<input type="hidden" name="token" value="abc.def">`)
+	r.Equal("no-referrer", recorder.Header().Get("Referrer-Policy"))
+	r.Equal("no-store", recorder.Header().Get("Cache-Control"))
+
+	// Following the link never revokes
+	r.service.AssertNotCalled(r.T(), "RevokeSession", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	recorder = httptest.NewRecorder()
+	r.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/sessions/revoke", nil))
+	r.Equal(http.StatusBadRequest, recorder.Code)
+}
+
+func (r *revokeTestSuite) TestRevoke() {
+	r.service.On("RevokeSession", mock.Anything, "abc.def", revokeClientIP, UA).Return(nil).Once()
+	recorder := r.post("abc.def")
+	r.Equal(http.StatusOK, recorder.Code)
+	r.Contains(recorder.Body.String(), "Device signed out")
+	r.service.AssertExpectations(r.T())
+
+	r.service.On("RevokeSession", mock.Anything, "forged", revokeClientIP, UA).Return(session.ErrInvalidRevocationToken).Once()
+	r.Equal(http.StatusBadRequest, r.post("forged").Code)
+
+	r.service.On("RevokeSession", mock.Anything, "abc.def", revokeClientIP, UA).Return(errors.New("connection refused")).Once()
+	recorder = r.post("abc.def")
+	r.Equal(http.StatusInternalServerError, recorder.Code)
+	r.NotContains(recorder.Body.String(), "connection refused")
+}
+
+func (r *revokeTestSuite) TestMethodNotAllowed() {
+	recorder := httptest.NewRecorder()
+	r.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/sessions/revoke", nil))
+	r.Equal(http.StatusMethodNotAllowed, recorder.Code)
+	r.Equal("GET, POST", recorder.Header().Get("Allow"))
+}
+
+func TestRevokeTestSuite(t *testing.T) {
+	suite.Run(t, new(revokeTestSuite))
+}
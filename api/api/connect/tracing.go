@@ -0,0 +1,21 @@
+package connect
+
+import (
+	"connectrpc.com/connect"
+	"connectrpc.com/otelconnect"
+
+	"gitlab.mreg.io/my-registry/auth/tracing"
+)
+
+// NewTracingInterceptor starts a server span for every call, using the
+// global tracer provider. Trace context sent by clients is linked to rather
+// than continued, since anyone can send one. Client addresses are personal
+// data and are left out, and durations are already covered by
+// NewMetricsInterceptor.
+func NewTracingInterceptor() (connect.Interceptor, error) {
+	return otelconnect.NewInterceptor(
+		otelconnect.WithPropagator(tracing.Propagator()),
+		otelconnect.WithoutMetrics(),
+		otelconnect.WithoutServerPeerAttributes(),
+	)
+}
@@ -0,0 +1,79 @@
+package connect
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type tracingTestSuite struct {
+	suite.Suite
+	recorder         *tracetest.SpanRecorder
+	previousProvider trace.TracerProvider
+	handlerSpan      trace.SpanContext
+	server           *httptest.Server
+	client           *connect.Client[emptypb.Empty, emptypb.Empty]
+}
+
+func (t *tracingTestSuite) SetupTest() {
+	t.recorder = tracetest.NewSpanRecorder()
+	t.previousProvider = otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(t.recorder)))
+
+	interceptor, err := NewTracingInterceptor()
+	t.Require().NoError(err)
+
+	mux := http.NewServeMux()
+	mux.Handle(loggingTestProcedure, connect.NewUnaryHandler(
+		loggingTestProcedure,
+		func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			t.handlerSpan = trace.SpanContextFromContext(ctx)
+			return connect.NewResponse(&emptypb.Empty{}), nil
+		},
+		connect.WithInterceptors(interceptor),
+	))
+	t.server = httptest.NewServer(mux)
+	t.client = connect.NewClient[emptypb.Empty, emptypb.Empty](t.server.Client(), t.server.URL+loggingTestProcedure)
+}
+
+func (t *tracingTestSuite) TearDownTest() {
+	t.server.Close()
+	otel.SetTracerProvider(t.previousProvider)
+}
+
+func (t *tracingTestSuite) TestStartsServerSpan() {
+	_, err := t.client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	t.Require().NoError(err)
+
+	spans := t.recorder.Ended()
+	t.Require().Len(spans, 1)
+	t.Equal("test.v1.TestService/Ping", spans[0].Name())
+	t.Equal(trace.SpanKindServer, spans[0].SpanKind())
+	t.Equal(spans[0].SpanContext().SpanID(), t.handlerSpan.SpanID())
+}
+
+func (t *tracingTestSuite) TestLinksRemoteSpan() {
+	req := connect.NewRequest(&emptypb.Empty{})
+	req.Header().Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, err := t.client.CallUnary(context.Background(), req)
+	t.Require().NoError(err)
+
+	spans := t.recorder.Ended()
+	t.Require().Len(spans, 1)
+	t.False(spans[0].Parent().IsValid())
+	t.Require().Len(spans[0].Links(), 1)
+	t.Equal("4bf92f3577b34da6a3ce929d0e0e4736", spans[0].Links()[0].SpanContext.TraceID().String())
+}
+
+func TestTracingTestSuite(t *testing.T) {
+	suite.Run(t, new(tracingTestSuite))
+}
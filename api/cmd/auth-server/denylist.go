@@ -0,0 +1,17 @@
+package main
+
+import (
+	"os"
+
+	"gitlab.mreg.io/my-registry/auth/domain/risk"
+)
+
+// loadDenylist reads the networks devices are blocked from.
+func loadDenylist(path string) (risk.PrefixList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return risk.ParsePrefixList(f)
+}
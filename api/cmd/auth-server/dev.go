@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+)
+
+// devMailFrom is the sender of the emails logged in dev mode when
+// mail.from is not configured.
+const devMailFrom = "no-reply@auth.localhost"
+
+// devPassword is the password of every sample identity. It is printed at
+// startup, which is only acceptable because dev mode keeps nothing.
+const devPassword = "Dev-password-1"
+
+// devIdentities are the sample identities seeded in dev mode.
+var devIdentities = []identity.Identity{
+	{Emails: []identity.Email{{Value: "alice@example.com"}}, Timezone: "Europe/Paris"},
+	{Emails: []identity.Email{{Value: "bob@example.com"}}, Timezone: "America/New_York"},
+	{Emails: []identity.Email{{Value: "carol@example.com"}}, Timezone: "Asia/Taipei"},
+}
+
+// seedIdentities creates the sample identities in identities and lists them
+// on console. The list bypasses the logger, which redacts emails and
+// passwords.
+func seedIdentities(ctx context.Context, identities identity.Repository, hasher *identity.Hasher, console io.Writer) error {
+	passwordHash, err := hasher.CreateHash(ctx, devPassword)
+	if err != nil {
+		return err
+	}
+	for _, sample := range devIdentities {
+		sample.Emails = append([]identity.Email(nil), sample.Emails...)
+		sample.PasswordHash = passwordHash
+		if err := identities.CreateIdentity(ctx, &sample); err != nil {
+			return err
+		}
+		fmt.Fprintf(console, "Sample identity %s: %s / %s\n", sample.ID, sample.Emails[0].Value, devPassword)
+	}
+	return nil
+}
+
+// newDevRevocationSigner returns a signer with a random key, for the
+// revocation links of the notices logged in dev mode when
+// session.revoke_secret is not configured. The links only work until
+// restart, like the sessions they revoke.
+func newDevRevocationSigner() (*session.RevocationSigner, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return session.NewRevocationSigner(key), nil
+}
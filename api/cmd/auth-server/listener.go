@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/pires/go-proxyproto"
+
+	apiConnect "gitlab.mreg.io/my-registry/auth/api/connect"
+)
+
+// listen opens the API listener. With proxyProtocol, connections from
+// trusted proxies may start with a PROXY protocol v1 or v2 header giving the
+// client address; headers sent by anyone else are discarded.
+func listen(address string, proxyProtocol bool, resolver *apiConnect.ClientIPResolver, readHeaderTimeout time.Duration) (net.Listener, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil || !proxyProtocol {
+		return listener, err
+	}
+	return &proxyproto.Listener{
+		Listener: listener,
+		ConnPolicy: func(options proxyproto.ConnPolicyOptions) (proxyproto.Policy, error) {
+			upstream, err := netip.ParseAddrPort(options.Upstream.String())
+			if err != nil || !resolver.Trusted(upstream.Addr()) {
+				return proxyproto.IGNORE, nil
+			}
+			return proxyproto.USE, nil
+		},
+		ReadHeaderTimeout: readHeaderTimeout,
+	}, nil
+}
@@ -2,47 +2,363 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 	_ "time/tzdata"
 
 	authConnect "buf.build/gen/go/mreg/protobuf/connectrpc/go/mreg/auth/v1alpha1/authv1alpha1connect"
 	"connectrpc.com/connect"
+	"connectrpc.com/grpchealth"
 	"connectrpc.com/grpcreflect"
 	"connectrpc.com/validate"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel"
 
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 
 	apiConnect "gitlab.mreg.io/my-registry/auth/api/connect"
+	"gitlab.mreg.io/my-registry/auth/config"
+	domainAudit "gitlab.mreg.io/my-registry/auth/domain/audit"
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	domainLockout "gitlab.mreg.io/my-registry/auth/domain/lockout"
+	domainOutbox "gitlab.mreg.io/my-registry/auth/domain/outbox"
+	"gitlab.mreg.io/my-registry/auth/domain/ratelimit"
+	domainRegistration "gitlab.mreg.io/my-registry/auth/domain/registration"
+	domainRetention "gitlab.mreg.io/my-registry/auth/domain/retention"
+	domainRisk "gitlab.mreg.io/my-registry/auth/domain/risk"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/domain/transaction"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/captcha"
 	"gitlab.mreg.io/my-registry/auth/infrastructure/cockroachdb"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/geoip"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/memory"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/sqlite"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/ulid"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/webhook"
+	"gitlab.mreg.io/my-registry/auth/logging"
+	"gitlab.mreg.io/my-registry/auth/mail"
+	"gitlab.mreg.io/my-registry/auth/metrics"
+	"gitlab.mreg.io/my-registry/auth/poll"
+	serviceAudit "gitlab.mreg.io/my-registry/auth/service/audit"
+	"gitlab.mreg.io/my-registry/auth/service/devicealert"
+	serviceLockout "gitlab.mreg.io/my-registry/auth/service/lockout"
+	serviceOutbox "gitlab.mreg.io/my-registry/auth/service/outbox"
 	"gitlab.mreg.io/my-registry/auth/service/registration"
+	serviceRetention "gitlab.mreg.io/my-registry/auth/service/retention"
+	serviceRisk "gitlab.mreg.io/my-registry/auth/service/risk"
+	"gitlab.mreg.io/my-registry/auth/tracing"
 )
 
-const DatabaseURLEnvName string = "DATABASE_URL"
+const (
+	// outboxBatchSize is the number of identity events claimed at once.
+	outboxBatchSize = 10
+
+	// mailBatchSize is the number of queued emails claimed at once.
+	mailBatchSize    = 10
+	mailPollInterval = 5 * time.Second
+	mailSendTimeout  = 30 * time.Second
+)
 
 func main() {
-	// Application-wide context
-	ctx := context.Background()
+	configFile := flag.String("config", os.Getenv(config.FileEnvName), "path of a YAML or TOML configuration file")
+	healthcheck := flag.String("healthcheck", "", "probe the given health endpoint URL and exit, for container runtimes without curl")
+	dev := flag.Bool("dev", false, "run without a database on in-memory repositories seeded with sample identities, logging emails to the console")
+	flag.Parse()
+	if *healthcheck != "" {
+		os.Exit(probe(*healthcheck))
+	}
+
+	// Registered first so that it runs after every other deferred call
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
 
-	// Create CockroachDB pgx pool
-	connString, ok := os.LookupEnv(DatabaseURLEnvName)
-	if !ok {
-		log.Fatalf("Cannot find %s environement variable\n", DatabaseURLEnvName)
+	cfg, err := config.Load(*configFile, *dev)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v\n", err)
 	}
-	pool := cockroachdb.NewPgxPool(ctx, connString)
-	defer pool.Close()
 
-	// Initialize repositories
-	sessionRepository := cockroachdb.NewSessionRepository(pool)
-	registrationFlowRepository := cockroachdb.NewRegistrationRepository(pool)
-	identityRepository := cockroachdb.NewIdentityRepository(pool)
+	// Also routes the standard logger through slog
+	slog.SetDefault(logging.New(os.Stderr, cfg.Log.Level))
+
+	// Application-wide context, canceled on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Spans are only recorded once an exporter is configured
+	otel.SetTextMapPropagator(tracing.Propagator())
+	exporter, err := tracing.NewExporter(ctx, cfg.Tracing.Exporter, os.Stdout)
+	if err != nil {
+		log.Fatalf("Unable to create trace exporter: %v", err)
+	}
+	if exporter != nil {
+		tracerProvider := tracing.NewTracerProvider(exporter, cfg.Tracing.SampleRatio)
+		defer func() {
+			if err := tracerProvider.Shutdown(context.Background()); err != nil {
+				slog.Error("Tracer provider shutdown failed", logging.Error(err))
+			}
+		}()
+		otel.SetTracerProvider(tracerProvider)
+	}
+
+	m := metrics.New()
+
+	// Initialize repositories. Dev mode keeps everything in memory, and
+	// single-node installations in a SQLite file. Both have none of the
+	// features that need more than the identities, sessions and flows: risk
+	// history, lockouts, the mail queue and the outbox. Only dev mode has no
+	// audit log.
+	var (
+		pool                       *pgxpool.Pool
+		sessionRepository          session.Repository
+		registrationFlowRepository domainRegistration.Repository
+		identityRepository         identity.Repository
+		auditRepository            domainAudit.Repository
+		transactions               transaction.Runner
+		retentionRepository        domainRetention.Repository
+		readinessChecks            []apiConnect.ReadinessCheck
+	)
+	sqlitePath, embedded := cfg.Database.SQLitePath()
+	switch {
+	case cfg.Dev:
+		slog.Warn("Running in dev mode, nothing is stored")
+		store := memory.NewStore()
+		sessionRepository = m.SessionRepository(memory.NewSessionRepository(store))
+		registrationFlowRepository = m.RegistrationRepository(memory.NewRegistrationRepository(store))
+		identityRepository = m.IdentityRepository(memory.NewIdentityRepository(store))
+		transactions = memory.NewTransactionRunner(store)
+	case embedded:
+		db, err := sqlite.Open(ctx, sqlitePath)
+		if err != nil {
+			log.Fatalf("Unable to open database: %v", err)
+		}
+		defer db.Close()
+		slog.Info("Opened database", slog.String("dialect", "sqlite"), slog.String("path", sqlitePath))
+
+		sessionRepository = m.SessionRepository(sqlite.NewSessionRepository(db))
+		registrationFlowRepository = m.RegistrationRepository(sqlite.NewRegistrationRepository(db))
+		identityRepository = m.IdentityRepository(sqlite.NewIdentityRepository(db))
+		auditRepository = m.AuditRepository(sqlite.NewAuditRepository(db))
+		transactions = sqlite.NewTransactionRunner(db)
+		retentionRepository = sqlite.NewRetentionRepository(db)
+		readinessChecks = append(readinessChecks, apiConnect.ReadinessCheck{
+			Name:  "database",
+			Check: db.PingContext,
+		})
+	default:
+		var dialect cockroachdb.Dialect
+		pool, dialect = cockroachdb.NewPgxPool(ctx, cfg.Database.URL)
+		defer pool.Close()
+		slog.Info("Connected to database", slog.String("dialect", string(dialect)))
+		m.MustRegister(cockroachdb.NewPoolCollector(pool))
+
+		sessionRepository = m.SessionRepository(cockroachdb.NewSessionRepository(pool))
+		registrationFlowRepository = m.RegistrationRepository(cockroachdb.NewRegistrationRepository(pool))
+		identityRepository = m.IdentityRepository(cockroachdb.NewIdentityRepository(pool))
+		auditRepository = m.AuditRepository(cockroachdb.NewAuditRepository(pool))
+		transactions = cockroachdb.NewTransactionRunner(pool)
+		retentionRepository = cockroachdb.NewRetentionRepository(pool, dialect)
+		readinessChecks = append(readinessChecks, apiConnect.ReadinessCheck{
+			Name: "database",
+			Check: func(ctx context.Context) error {
+				return cockroachdb.CheckReadiness(ctx, pool)
+			},
+		})
+	}
+
+	// Rate limit buckets have to be shared when running more than one replica
+	var rateLimitRepository ratelimit.Repository
+	switch cfg.RateLimit.Store {
+	case config.RateLimitStoreMemory:
+		rateLimitRepository = memory.NewRateLimitRepository()
+	case config.RateLimitStoreCockroachDB:
+		rateLimitRepository = cockroachdb.NewRateLimitRepository(pool)
+	}
+	rateLimitRepository = m.RateLimitRepository(rateLimitRepository)
+
+	// Initialize password hasher
+	hasherConfig := identity.DefaultHasherConfig
+	hasherConfig.MemoryBudget = cfg.Hasher.MemoryBudgetMiB * 1024
+	hasherConfig.QueueDepth = cfg.Hasher.QueueDepth
+	hasherConfig.Observe = m.ObserveHash
+	hasher := identity.NewHasher(hasherConfig)
+	defer hasher.Close()
+	m.RegisterHasher(hasher)
+
+	if cfg.Dev {
+		if err := seedIdentities(ctx, identityRepository, hasher, os.Stderr); err != nil {
+			log.Fatalf("Unable to seed sample identities: %v", err)
+		}
+	}
+
+	// Devices are stored without a location when no database is configured
+	var geoResolver session.GeoResolver
+	if len(cfg.GeoIP.Databases) > 0 {
+		resolver, err := geoip.Open(cfg.GeoIP.Databases...)
+		if err != nil {
+			log.Fatalf("Unable to open GeoIP database: %v", err)
+		}
+		go resolver.Watch(ctx, cfg.GeoIP.ReloadInterval)
+		geoResolver = resolver
+	} else {
+		slog.Info("No GeoIP database configured, devices will not be located")
+	}
+
+	// New device notices are only sent when mail is configured, but the
+	// devices of every identity are remembered. Mail is queued in the
+	// database server and sent in the background, so that an outage of the
+	// relay does not fail sign-ins.
+	var notifier session.Notifier
+	var revocationSigner *session.RevocationSigner
+	if cfg.Session.RevokeSecret != "" {
+		revocationSigner = session.NewRevocationSigner([]byte(cfg.Session.RevokeSecret))
+	}
+	switch {
+	case cfg.Dev:
+		from := cfg.Mail.From
+		if from == "" {
+			from = devMailFrom
+		}
+		notifier = mail.NewNotifier(mail.NewConsoleSender(os.Stderr), mail.DefaultTemplates(), from)
+		if revocationSigner == nil {
+			if revocationSigner, err = newDevRevocationSigner(); err != nil {
+				log.Fatalf("Unable to create session revocation signer: %v", err)
+			}
+		}
+	case cfg.Mail.Enabled():
+		var transport mail.Sender
+		if cfg.Mail.SMTPAddress != "" {
+			transport, err = mail.NewSMTPSender(mail.SMTPConfig{
+				Address:    cfg.Mail.SMTPAddress,
+				Username:   cfg.Mail.SMTPUsername,
+				Password:   cfg.Mail.SMTPPassword,
+				RequireTLS: cfg.Mail.RequireTLS(),
+			})
+		} else {
+			transport, err = mail.NewFileSender(cfg.Mail.FileDirectory)
+		}
+		if err != nil {
+			log.Fatalf("Unable to create mail sender: %v", err)
+		}
+		if pool == nil {
+			// Without a queue, notices are sent as devices are added
+			notifier = mail.NewNotifier(transport, mail.DefaultTemplates(), cfg.Mail.From)
+			break
+		}
+		mailQueue := m.MailQueue(cockroachdb.NewMailQueue(pool))
+		mailDispatcher := mail.NewDispatcher(mailQueue, transport, mail.DispatcherConfig{
+			BatchSize:    mailBatchSize,
+			PollInterval: mailPollInterval,
+			Timeout:      mailSendTimeout,
+			Lease:        poll.Lease(mailSendTimeout, mailBatchSize),
+			Retry:        domainOutbox.DefaultRetryPolicy,
+		})
+		go mailDispatcher.Run(ctx)
+		notifier = mail.NewNotifier(mail.NewQueueSender(mailQueue), mail.DefaultTemplates(), cfg.Mail.From)
+	default:
+		slog.Info("No mail relay configured, new device notices will not be sent")
+	}
+	deviceAlertService := devicealert.NewService(sessionRepository, identityRepository, auditRepository, transactions, notifier, revocationSigner, devicealert.Config{RevokeURL: cfg.Session.RevokeURL})
+	defer deviceAlertService.Wait()
+
+	// Devices are only checked against a denylist when one is configured
+	var denylist domainRisk.Denylist
+	if cfg.Risk.DenylistFile != "" {
+		list, err := loadDenylist(cfg.Risk.DenylistFile)
+		if err != nil {
+			log.Fatalf("Unable to load risk denylist: %v", err)
+		}
+		denylist = list
+	}
+	// Failed logins are counted in the database server, for the lockout and
+	// as a risk signal
+	var (
+		riskService    serviceRisk.Service
+		lockoutService serviceLockout.Service
+	)
+	if pool != nil {
+		lockoutRepository := m.LockoutRepository(cockroachdb.NewLockoutRepository(pool))
+		identityPolicy := domainLockout.DefaultIdentityPolicy
+		identityPolicy.Threshold = cfg.Lockout.IdentityThreshold
+		identityPolicy.LockDuration = cfg.Lockout.Duration
+		ipPolicy := domainLockout.DefaultIPPolicy
+		ipPolicy.Threshold = cfg.Lockout.IPThreshold
+		ipPolicy.LockDuration = cfg.Lockout.Duration
+		lockoutService = serviceLockout.NewService(lockoutRepository, sessionRepository, auditRepository, transactions, identityPolicy, ipPolicy)
+
+		riskPolicy := domainRisk.DefaultPolicy
+		riskPolicy.StepUpThreshold = cfg.Risk.StepUpThreshold
+		riskPolicy.BlockThreshold = cfg.Risk.BlockThreshold
+		riskPolicy.MaxTravelSpeed = cfg.Risk.MaxTravelSpeed
+		riskService = serviceRisk.NewService(
+			m.RiskRepository(cockroachdb.NewRiskRepository(pool)),
+			lockoutRepository,
+			denylist,
+			riskPolicy,
+		)
+	}
+
+	// Identity events wait in the outbox until webhook endpoints are configured
+	if len(cfg.Webhook.Endpoints) > 0 {
+		dispatcher := serviceOutbox.NewService(
+			m.OutboxRepository(cockroachdb.NewOutboxRepository(pool)),
+			webhook.NewPublisher(&http.Client{Timeout: cfg.Webhook.Timeout}, []byte(cfg.Webhook.Secret)),
+			serviceOutbox.Config{
+				Endpoints:    cfg.Webhook.Endpoints,
+				BatchSize:    outboxBatchSize,
+				PollInterval: cfg.Webhook.PollInterval,
+				// every message is delivered to every endpoint
+				Lease: poll.Lease(cfg.Webhook.Timeout, outboxBatchSize*len(cfg.Webhook.Endpoints)),
+				Retry: domainOutbox.DefaultRetryPolicy,
+			},
+		)
+		go dispatcher.Run(ctx)
+	} else {
+		slog.Info("No webhook endpoints configured, identity events will not be delivered")
+	}
+
+	// Dev mode does not keep expired flows and sessions past a restart
+	if retentionRepository != nil {
+		reaper := serviceRetention.NewService(m.RetentionRepository(retentionRepository), serviceRetention.Config{
+			Holder:           ulid.New(),
+			Retention:        cfg.Retention.Period,
+			HistoryRetention: cfg.Retention.HistoryPeriod,
+			BatchSize:        cfg.Retention.BatchSize,
+			Interval:         cfg.Retention.Interval,
+			// kept between rounds by the replica reaping
+			Lease: 2 * cfg.Retention.Interval,
+		})
+		go reaper.Run(ctx)
+	}
+
+	// Initialize CAPTCHA verifier
+	var captchaVerifier domainRegistration.CaptchaVerifier
+	if cfg.Registration.CaptchaVerifier == config.CaptchaVerifierFake {
+		captchaVerifier = captcha.NewFakeVerifier()
+	}
+
+	registrationConfig := registration.Config{
+		SessionExpiryInterval: cfg.Session.ExpiryInterval,
+		FlowExpiryInterval:    cfg.Registration.ExpiryInterval,
+		Observer:              m,
+	}
+	if cfg.Registration.ChallengeEnabled {
+		registrationConfig.ChallengePolicy = domainRegistration.DefaultChallengePolicy
+	}
 
 	// Initialize services
-	registrationService := registration.NewService(sessionRepository, registrationFlowRepository, identityRepository)
+	registrationService := registration.NewService(sessionRepository, registrationFlowRepository, identityRepository, auditRepository, transactions, hasher, captchaVerifier, geoResolver, deviceAlertService, riskService, registrationConfig)
 
 	// Initialize handlers
 	registrationHandler := apiConnect.NewRegistrationHandler(registrationService)
@@ -50,7 +366,8 @@ func main() {
 	// Create ConnectRPC server
 	mux := http.NewServeMux()
 	reflector := grpcreflect.NewStaticReflector(
-		"mreg.auth.v1alpha1.RegistrationService",
+		authConnect.RegistrationServiceName,
+		grpchealth.HealthV1ServiceName,
 	)
 	mux.Handle(grpcreflect.NewHandlerV1(reflector))
 	mux.Handle(grpcreflect.NewHandlerV1Alpha(reflector))
@@ -59,18 +376,124 @@ func main() {
 	if err != nil {
 		panic(fmt.Sprintf("NewInterceptor failed: %v", err))
 	}
+	// Only proxies listed in the configuration may tell the client address
+	trustedProxies, _ := cfg.Server.TrustedProxyPrefixes() // Validated by config.Load
+	clientIPResolver := apiConnect.NewClientIPResolver(trustedProxies)
+	clientIPInterceptor := apiConnect.NewClientIPInterceptor(clientIPResolver)
+	tracingInterceptor, err := apiConnect.NewTracingInterceptor()
+	if err != nil {
+		panic(fmt.Sprintf("NewTracingInterceptor failed: %v", err))
+	}
+	localeInterceptor := apiConnect.NewLocaleInterceptor(sessionRepository)
+	loggingInterceptor := apiConnect.NewLoggingInterceptor()
+	metricsInterceptor := apiConnect.NewMetricsInterceptor(m)
+	errorInterceptor := apiConnect.NewErrorInterceptor()
+	rateLimitInterceptor := apiConnect.NewRateLimitInterceptor(rateLimitRepository, rateLimitRules(cfg.RateLimit.Rules))
+
+	mux.Handle(authConnect.NewRegistrationServiceHandler(registrationHandler, connect.WithInterceptors(tracingInterceptor, clientIPInterceptor, localeInterceptor, loggingInterceptor, metricsInterceptor, errorInterceptor, rateLimitInterceptor, interceptor)))
+
+	// Linked from new device notices
+	mux.Handle("/sessions/revoke", apiConnect.NewRevokeSessionHandler(deviceAlertService, clientIPResolver))
+	if lockoutService != nil {
+		mux.Handle("GET /identity/lockout-events", apiConnect.NewLockoutEventsHandler(lockoutService))
+	}
+
+	// Health probes are not rate limited
+	health := apiConnect.NewHealth([]string{authConnect.RegistrationServiceName}, readinessChecks...)
+	mux.Handle("GET /healthz", health.LivenessHandler())
+	mux.Handle("GET /readyz", health.ReadinessHandler())
+	mux.Handle(grpchealth.NewHandler(health))
 
-	mux.Handle(authConnect.NewRegistrationServiceHandler(registrationHandler, connect.WithInterceptors(interceptor)))
 	server := &http.Server{
-		Addr:           "0.0.0.0:8080",
+		Addr:           cfg.Server.Address,
 		Handler:        h2c.NewHandler(mux, &http2.Server{}), // Enable HTTP/2 over cleartext (H2C)
-		MaxHeaderBytes: 8192,                                 // Limit header size to 8 KB
-		ReadTimeout:    2 * time.Second,
-		WriteTimeout:   5 * time.Second,
+		MaxHeaderBytes: cfg.Server.MaxHeaderBytes,
+		ReadTimeout:    cfg.Server.ReadTimeout,
+		WriteTimeout:   cfg.Server.WriteTimeout,
 	}
 
 	// Start the server
-	if err := server.ListenAndServe(); err != nil {
-		panic(fmt.Sprintf("Server failed: %v", err))
+	listener, err := listen(cfg.Server.Address, cfg.Server.ProxyProtocol, clientIPResolver, cfg.Server.ReadTimeout)
+	if err != nil {
+		log.Fatalf("Unable to listen: %v", err)
+	}
+	slog.Info("Server listening", slog.String("address", cfg.Server.Address), slog.Bool("proxy_protocol", cfg.Server.ProxyProtocol))
+	serverErr := make(chan error, 3)
+	go func() {
+		serverErr <- server.Serve(listener)
+	}()
+
+	// Metrics are served on their own address so that they are not public
+	var metricsServer *http.Server
+	if cfg.Metrics.Address != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", m.Handler())
+		metricsServer = &http.Server{
+			Addr:              cfg.Metrics.Address,
+			Handler:           metricsMux,
+			ReadHeaderTimeout: cfg.Server.ReadTimeout,
+		}
+		slog.Info("Metrics server listening", slog.String("address", cfg.Metrics.Address))
+		go func() {
+			serverErr <- metricsServer.ListenAndServe()
+		}()
+	}
+
+	// The admin API is served on its own address, and every request has to
+	// carry the token of an administrator. Single-node installations have
+	// no lockouts to lift.
+	var adminServer *http.Server
+	if cfg.Admin.Address != "" {
+		actors, _ := cfg.Admin.ActorTokens() // Validated by config.Load
+		adminMux := http.NewServeMux()
+		adminMux.Handle("GET /admin/identities/{identity}/audit-events", apiConnect.NewAuditEventsHandler(serviceAudit.NewService(auditRepository)))
+		if lockoutService != nil {
+			adminMux.Handle("POST /admin/identities/{identity}/unlock", apiConnect.NewUnlockHandler(lockoutService))
+		}
+		adminServer = &http.Server{
+			Addr:              cfg.Admin.Address,
+			Handler:           apiConnect.NewAdminAuthenticator(actors, adminMux),
+			ReadHeaderTimeout: cfg.Server.ReadTimeout,
+		}
+		slog.Info("Admin server listening", slog.String("address", cfg.Admin.Address))
+		go func() {
+			serverErr <- adminServer.ListenAndServe()
+		}()
+	}
+
+	// A server that fails takes the others down through the same shutdown,
+	// and the process exits non-zero once the deferred calls have run
+	select {
+	case err := <-serverErr:
+		slog.Error("Server failed", logging.Error(err))
+		exitCode = 1
+	case <-ctx.Done():
+		// Keep serving while load balancers notice that we are not ready. A
+		// second signal kills the process right away.
+		stop()
+		health.Drain()
+		slog.Info("Draining server", slog.Duration("delay", cfg.Server.DrainDelay))
+		time.Sleep(cfg.Server.DrainDelay)
+	}
+
+	// Stop accepting requests and wait for in-flight ones; the hasher and the
+	// pool are closed by the deferred calls afterwards
+	stop()
+	health.Drain()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	slog.Info("Shutting down server")
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server shutdown failed", logging.Error(err))
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Metrics server shutdown failed", logging.Error(err))
+		}
+	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Admin server shutdown failed", logging.Error(err))
+		}
 	}
 }
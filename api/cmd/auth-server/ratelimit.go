@@ -0,0 +1,21 @@
+package main
+
+import (
+	apiConnect "gitlab.mreg.io/my-registry/auth/api/connect"
+	"gitlab.mreg.io/my-registry/auth/config"
+	"gitlab.mreg.io/my-registry/auth/domain/ratelimit"
+)
+
+// rateLimitRules converts the configured rules for the rate limit
+// interceptor.
+func rateLimitRules(rules map[string]config.RateLimitRule) map[string]apiConnect.RateLimitRule {
+	converted := make(map[string]apiConnect.RateLimitRule, len(rules))
+	for procedure, rule := range rules {
+		converted[procedure] = apiConnect.RateLimitRule{
+			PerIP:     ratelimit.Limit(rule.PerIP),
+			PerPrefix: ratelimit.Limit(rule.PerPrefix),
+			PerEmail:  ratelimit.Limit(rule.PerEmail),
+		}
+	}
+	return converted
+}
@@ -0,0 +1,64 @@
+// Command webhook-receiver logs the identity events the auth server
+// delivers, after checking their signature. It is meant for trying the
+// webhooks locally:
+//
+//	WEBHOOK_SECRET=... go run ./cmd/webhook-receiver -address localhost:8090
+//
+// with the auth server started with WEBHOOK_ENDPOINTS=http://localhost:8090
+// and the same WEBHOOK_SECRET. Setting -fail answers every delivery with a
+// 503, to watch the retries.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/infrastructure/webhook"
+	"gitlab.mreg.io/my-registry/auth/logging"
+)
+
+// maxBodyBytes bounds the events read; identity events are far smaller.
+const maxBodyBytes = 1 << 20
+
+func main() {
+	address := flag.String("address", "localhost:8090", "address to listen on")
+	tolerance := flag.Duration("tolerance", 5*time.Minute, "accepted age of the delivery timestamps")
+	fail := flag.Bool("fail", false, "reject every delivery")
+	flag.Parse()
+
+	secret := os.Getenv("WEBHOOK_SECRET")
+	if secret == "" {
+		log.Fatal("WEBHOOK_SECRET is required")
+	}
+	slog.SetDefault(logging.New(os.Stderr, slog.LevelInfo))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err := webhook.Verify([]byte(secret), r.Header, body, time.Now(), *tolerance); err != nil {
+			slog.Warn("rejected delivery", slog.String("id", r.Header.Get(webhook.IDHeader)), logging.Error(err))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if *fail {
+			slog.Info("failing delivery", slog.String("id", r.Header.Get(webhook.IDHeader)))
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		slog.Info("received event", slog.Any("event", json.RawMessage(body)))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	slog.Info("listening for webhooks", slog.String("address", *address))
+	server := &http.Server{Addr: *address, Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+	log.Fatal(server.ListenAndServe())
+}
@@ -0,0 +1,595 @@
+// Package config loads the auth server configuration.
+//
+// Values are read from an optional YAML or TOML file and then overlaid by
+// environment variables. Every variable also has a _FILE variant holding the
+// path of a file to read the value from, which is how container secrets are
+// usually mounted.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/tracing"
+)
+
+const (
+	RateLimitStoreMemory      = "memory"
+	RateLimitStoreCockroachDB = "cockroachdb"
+
+	CaptchaVerifierNone = "none"
+	CaptchaVerifierFake = "fake"
+)
+
+type Config struct {
+	Server       ServerConfig       `yaml:"server" toml:"server"`
+	Database     DatabaseConfig     `yaml:"database" toml:"database"`
+	Session      SessionConfig      `yaml:"session" toml:"session"`
+	Registration RegistrationConfig `yaml:"registration" toml:"registration"`
+	Hasher       HasherConfig       `yaml:"hasher" toml:"hasher"`
+	RateLimit    RateLimitConfig    `yaml:"rate_limit" toml:"rate_limit"`
+	Log          LogConfig          `yaml:"log" toml:"log"`
+	Metrics      MetricsConfig      `yaml:"metrics" toml:"metrics"`
+	Tracing      TracingConfig      `yaml:"tracing" toml:"tracing"`
+	GeoIP        GeoIPConfig        `yaml:"geoip" toml:"geoip"`
+	Mail         MailConfig         `yaml:"mail" toml:"mail"`
+	Risk         RiskConfig         `yaml:"risk" toml:"risk"`
+	Webhook      WebhookConfig      `yaml:"webhook" toml:"webhook"`
+	Retention    RetentionConfig    `yaml:"retention" toml:"retention"`
+	Lockout      LockoutConfig      `yaml:"lockout" toml:"lockout"`
+	Admin        AdminConfig        `yaml:"admin" toml:"admin"`
+
+	// Dev runs the server on in-memory repositories instead of the
+	// database. It is set by the --dev flag, never by files or the
+	// environment, so that it cannot be left on by accident.
+	Dev bool `yaml:"-" toml:"-"`
+}
+
+type ServerConfig struct {
+	Address        string        `yaml:"address" toml:"address"`
+	ReadTimeout    time.Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout   time.Duration `yaml:"write_timeout" toml:"write_timeout"`
+	MaxHeaderBytes int           `yaml:"max_header_bytes" toml:"max_header_bytes"`
+	// DrainDelay is how long the server keeps accepting requests after
+	// SIGTERM while reporting that it is not ready, so that load balancers
+	// notice before connections are refused. Zero shuts down immediately.
+	DrainDelay time.Duration `yaml:"drain_delay" toml:"drain_delay"`
+	// ShutdownTimeout is how long in-flight requests may take to complete
+	// after the drain delay.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	// TrustedProxies lists the addresses or CIDR networks of the proxies
+	// whose forwarding headers are believed.
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+	// ProxyProtocol accepts PROXY protocol v1 and v2 headers from trusted
+	// proxies on the listener.
+	ProxyProtocol bool `yaml:"proxy_protocol" toml:"proxy_protocol"`
+}
+
+// TrustedProxyPrefixes parses TrustedProxies. Single addresses are returned
+// as host prefixes.
+func (s *ServerConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(s.TrustedProxies))
+	var errs []error
+	for _, proxy := range s.TrustedProxies {
+		if addr, err := netip.ParseAddr(proxy); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, errors.Join(errs...)
+}
+
+type DatabaseConfig struct {
+	// URL picks the dialect by its scheme: cockroachdb:// for CockroachDB,
+	// postgres:// or postgresql:// for CockroachDB or PostgreSQL, as told by
+	// the server, and sqlite:// followed by the path of a file for an
+	// embedded SQLite database.
+	URL string `yaml:"url" toml:"url"`
+}
+
+// SQLitePath returns the path of the SQLite database file, and false when
+// URL names a database server.
+func (c DatabaseConfig) SQLitePath() (string, bool) {
+	return strings.CutPrefix(c.URL, "sqlite://")
+}
+
+type SessionConfig struct {
+	ExpiryInterval time.Duration `yaml:"expiry_interval" toml:"expiry_interval"`
+	// RevokeURL is the public address of /sessions/revoke, linked from new
+	// device notices.
+	RevokeURL string `yaml:"revoke_url" toml:"revoke_url"`
+	// RevokeSecret signs the revocation links. It must be at least 32
+	// bytes, and is required to send new device notices.
+	RevokeSecret string `yaml:"revoke_secret" toml:"revoke_secret"`
+}
+
+// minRevokeSecretLength is the size of the HMAC-SHA256 key revocation links
+// are signed with.
+const minRevokeSecretLength = 32
+
+// minWebhookSecretLength is the size of the HMAC-SHA256 key webhook
+// deliveries are signed with.
+const minWebhookSecretLength = 32
+
+type RegistrationConfig struct {
+	ExpiryInterval time.Duration `yaml:"expiry_interval" toml:"expiry_interval"`
+	// ChallengeEnabled attaches a proof of work challenge to new flows.
+	ChallengeEnabled bool `yaml:"challenge_enabled" toml:"challenge_enabled"`
+	// CaptchaVerifier is either "none" or "fake".
+	CaptchaVerifier string `yaml:"captcha_verifier" toml:"captcha_verifier"`
+}
+
+type HasherConfig struct {
+	MemoryBudgetMiB uint64 `yaml:"memory_budget_mib" toml:"memory_budget_mib"`
+	QueueDepth      int    `yaml:"queue_depth" toml:"queue_depth"`
+}
+
+type RateLimitConfig struct {
+	// Store is either "memory" or "cockroachdb". Buckets have to be stored
+	// in the database when running more than one replica.
+	Store string `yaml:"store" toml:"store"`
+	// Rules maps procedures, such as
+	// "/mreg.auth.v1alpha1.RegistrationService/CreateRegistrationFlow", to
+	// the buckets enforced for them. A rule set in the file replaces the
+	// default rule of its procedure as a whole. Rules cannot be set from the
+	// environment.
+	Rules map[string]RateLimitRule `yaml:"rules" toml:"rules"`
+}
+
+// RateLimitRule configures the token buckets of one procedure. A zero limit
+// disables the corresponding bucket.
+type RateLimitRule struct {
+	// PerIP is shared by requests from the same client address.
+	PerIP RateLimit `yaml:"per_ip" toml:"per_ip"`
+	// PerPrefix is shared by requests from the same /24 (IPv4) or /64 (IPv6).
+	PerPrefix RateLimit `yaml:"per_prefix" toml:"per_prefix"`
+	// PerEmail is shared by requests targeting the same email address.
+	PerEmail RateLimit `yaml:"per_email" toml:"per_email"`
+}
+
+// RateLimit is a token bucket holding at most Burst tokens and regaining one
+// every Period.
+type RateLimit struct {
+	Burst  int           `yaml:"burst" toml:"burst"`
+	Period time.Duration `yaml:"period" toml:"period"`
+}
+
+type LogConfig struct {
+	// Level is one of "debug", "info", "warn" or "error".
+	Level slog.Level `yaml:"level" toml:"level"`
+}
+
+type MetricsConfig struct {
+	// Address serves /metrics apart from the public API. Empty disables it.
+	Address string `yaml:"address" toml:"address"`
+}
+
+type TracingConfig struct {
+	// Exporter is one of "none", "otlp" or "stdout". The OTLP endpoint is
+	// set with the standard OTEL_EXPORTER_OTLP_ENDPOINT variable.
+	Exporter string `yaml:"exporter" toml:"exporter"`
+	// SampleRatio is the fraction of requests traced, between 0 and 1.
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio"`
+}
+
+type GeoIPConfig struct {
+	// Databases are MaxMind DB files, such as a City and an ASN database,
+	// used to locate devices. Empty disables locating devices.
+	Databases []string `yaml:"databases" toml:"databases"`
+	// ReloadInterval is how often the files are checked for updates.
+	ReloadInterval time.Duration `yaml:"reload_interval" toml:"reload_interval"`
+}
+
+type MailConfig struct {
+	// SMTPAddress is the host and port of the relay. Empty disables mail,
+	// and with it new device notices, unless FileDirectory is set.
+	SMTPAddress  string `yaml:"smtp_address" toml:"smtp_address"`
+	SMTPUsername string `yaml:"smtp_username" toml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password" toml:"smtp_password"`
+	// SMTPRequireTLS fails deliveries to a relay that does not offer
+	// STARTTLS. Unset, it is required unless the relay is on localhost; see
+	// RequireTLS.
+	SMTPRequireTLS *bool `yaml:"smtp_require_tls" toml:"smtp_require_tls"`
+	// From is the sender, such as "My Registry <no-reply@mreg.io>".
+	From string `yaml:"from" toml:"from"`
+	// FileDirectory is where mail is written as .eml files instead of being
+	// sent, for development. It excludes SMTPAddress.
+	FileDirectory string `yaml:"file_directory" toml:"file_directory"`
+}
+
+// Enabled reports whether mail is sent, through the relay or to files.
+func (m *MailConfig) Enabled() bool {
+	return m.SMTPAddress != "" || m.FileDirectory != ""
+}
+
+// RequireTLS returns SMTPRequireTLS, which defaults to true for relays not
+// on localhost, where nothing on the network can read the messages.
+func (m *MailConfig) RequireTLS() bool {
+	if m.SMTPRequireTLS != nil {
+		return *m.SMTPRequireTLS
+	}
+	host, _, err := net.SplitHostPort(m.SMTPAddress)
+	if err != nil {
+		return true
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return !addr.IsLoopback()
+	}
+	return host != "localhost"
+}
+
+type RiskConfig struct {
+	// DenylistFile lists the networks devices are blocked from, one address
+	// or CIDR prefix per line. Empty disables the denylist.
+	DenylistFile string `yaml:"denylist_file" toml:"denylist_file"`
+	// StepUpThreshold and BlockThreshold are the scores from which devices
+	// have to step up authentication or are blocked. Zero disables either.
+	StepUpThreshold int `yaml:"step_up_threshold" toml:"step_up_threshold"`
+	BlockThreshold  int `yaml:"block_threshold" toml:"block_threshold"`
+	// MaxTravelSpeed is the speed in km/h above which travel between two
+	// devices of an identity is impossible. Zero disables the check.
+	MaxTravelSpeed float64 `yaml:"max_travel_speed" toml:"max_travel_speed"`
+}
+
+type WebhookConfig struct {
+	// Endpoints are the URLs identity events are posted to. Empty disables
+	// delivery; events are kept in the outbox until endpoints are added.
+	Endpoints []string `yaml:"endpoints" toml:"endpoints"`
+	// Secret signs the deliveries, and every endpoint verifies with it. It
+	// must be at least 32 bytes.
+	Secret string `yaml:"secret" toml:"secret"`
+	// PollInterval is how often the outbox is checked for due events.
+	PollInterval time.Duration `yaml:"poll_interval" toml:"poll_interval"`
+	// Timeout bounds each delivery.
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+}
+
+// RetentionConfig sets up the reaper of expired registration flows and
+// sessions, and of the outbox messages delivered or given up on. On
+// CockroachDB, flows and sessions are deleted by the row-level TTL of the
+// migrations instead, whose periods have to follow these.
+type RetentionConfig struct {
+	// Period is how long flows and anonymous sessions are kept after they
+	// expire, and outbox messages after their last attempt.
+	Period time.Duration `yaml:"period" toml:"period"`
+	// HistoryPeriod is how long the sessions of identities are kept after
+	// they expire, with the devices the risk checks compare new ones with.
+	HistoryPeriod time.Duration `yaml:"history_period" toml:"history_period"`
+	// Interval is how often expired rows are looked for.
+	Interval time.Duration `yaml:"interval" toml:"interval"`
+	// BatchSize bounds the rows deleted by each statement.
+	BatchSize int `yaml:"batch_size" toml:"batch_size"`
+}
+
+// LockoutConfig throttles failed logins, per account and per client
+// address. Every failure delays the next attempt exponentially.
+type LockoutConfig struct {
+	// IdentityThreshold and IPThreshold are the numbers of consecutive
+	// failures that lock an account or an address. Zero disables the lock,
+	// leaving only the delay.
+	IdentityThreshold int `yaml:"identity_threshold" toml:"identity_threshold"`
+	IPThreshold       int `yaml:"ip_threshold" toml:"ip_threshold"`
+	// Duration is how long locks last, unless lifted by an administrator.
+	Duration time.Duration `yaml:"duration" toml:"duration"`
+}
+
+type AdminConfig struct {
+	// Address serves the admin API apart from the public API. Empty
+	// disables it.
+	Address string `yaml:"address" toml:"address"`
+	// Tokens authenticate administrators, as "name:token" pairs. The name
+	// is recorded as the actor of the changes made with the token, which
+	// must be at least 32 bytes.
+	Tokens []string `yaml:"tokens" toml:"tokens"`
+}
+
+// minAdminTokenLength keeps admin tokens out of reach of guessing.
+const minAdminTokenLength = 32
+
+// ActorTokens parses Tokens into the names of the administrators by token.
+func (a *AdminConfig) ActorTokens() (map[string]string, error) {
+	actors := make(map[string]string, len(a.Tokens))
+	names := make(map[string]bool, len(a.Tokens))
+	var errs []error
+	for i, pair := range a.Tokens {
+		name, token, ok := strings.Cut(pair, ":")
+		switch {
+		case !ok || name == "":
+			errs = append(errs, fmt.Errorf("token %d is not a name:token pair", i))
+		case len(token) < minAdminTokenLength:
+			errs = append(errs, fmt.Errorf("token of %s must be at least %d bytes", name, minAdminTokenLength))
+		case names[name]:
+			errs = append(errs, fmt.Errorf("%s has more than one token", name))
+		case actors[token] != "":
+			errs = append(errs, fmt.Errorf("token of %s is also the token of %s", name, actors[token]))
+		default:
+			actors[token] = name
+			names[name] = true
+		}
+	}
+	return actors, errors.Join(errs...)
+}
+
+// Default returns the configuration used for everything that is not set by
+// the file or the environment. The database URL has no default.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Address:         "0.0.0.0:8080",
+			ReadTimeout:     2 * time.Second,
+			WriteTimeout:    5 * time.Second,
+			MaxHeaderBytes:  8192,
+			DrainDelay:      5 * time.Second,
+			ShutdownTimeout: 15 * time.Second,
+		},
+		Session: SessionConfig{
+			ExpiryInterval: 2 * time.Hour,
+		},
+		Registration: RegistrationConfig{
+			ExpiryInterval:  2 * time.Hour,
+			CaptchaVerifier: CaptchaVerifierNone,
+		},
+		Hasher: HasherConfig{
+			MemoryBudgetMiB: 256,
+			QueueDepth:      64,
+		},
+		RateLimit: RateLimitConfig{
+			Store: RateLimitStoreMemory,
+			// The registration endpoints write to the database on every call
+			Rules: map[string]RateLimitRule{
+				"/mreg.auth.v1alpha1.RegistrationService/CreateRegistrationFlow": {
+					PerIP:     RateLimit{Burst: 20, Period: 30 * time.Second},
+					PerPrefix: RateLimit{Burst: 100, Period: 6 * time.Second},
+				},
+				"/mreg.auth.v1alpha1.RegistrationService/CompleteRegistrationFlow": {
+					PerIP:     RateLimit{Burst: 10, Period: time.Minute},
+					PerPrefix: RateLimit{Burst: 50, Period: 12 * time.Second},
+					PerEmail:  RateLimit{Burst: 5, Period: 10 * time.Minute},
+				},
+			},
+		},
+		Log: LogConfig{
+			Level: slog.LevelInfo,
+		},
+		Metrics: MetricsConfig{
+			Address: "0.0.0.0:9090",
+		},
+		Tracing: TracingConfig{
+			Exporter:    tracing.ExporterNone,
+			SampleRatio: 1,
+		},
+		GeoIP: GeoIPConfig{
+			ReloadInterval: 10 * time.Minute,
+		},
+		Risk: RiskConfig{
+			StepUpThreshold: 50,
+			BlockThreshold:  100,
+			MaxTravelSpeed:  1000,
+		},
+		Webhook: WebhookConfig{
+			PollInterval: time.Second,
+			Timeout:      10 * time.Second,
+		},
+		Retention: RetentionConfig{
+			Period:        7 * 24 * time.Hour,
+			HistoryPeriod: 90 * 24 * time.Hour,
+			Interval:      time.Minute,
+			BatchSize:     1000,
+		},
+		Lockout: LockoutConfig{
+			IdentityThreshold: 10,
+			IPThreshold:       50,
+			Duration:          15 * time.Minute,
+		},
+	}
+}
+
+// Validate reports every invalid value at once.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(name string, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", name, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Server.Address == "" {
+		invalid("server.address", "must not be empty")
+	}
+	if c.Server.ReadTimeout <= 0 {
+		invalid("server.read_timeout", "must be positive")
+	}
+	if c.Server.WriteTimeout <= 0 {
+		invalid("server.write_timeout", "must be positive")
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		invalid("server.max_header_bytes", "must be positive")
+	}
+	if c.Server.DrainDelay < 0 {
+		invalid("server.drain_delay", "must not be negative")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		invalid("server.shutdown_timeout", "must be positive")
+	}
+	if _, err := c.Server.TrustedProxyPrefixes(); err != nil {
+		invalid("server.trusted_proxies", "%v", err)
+	}
+	if c.Server.ProxyProtocol && len(c.Server.TrustedProxies) == 0 {
+		invalid("server.proxy_protocol", "requires server.trusted_proxies")
+	}
+	if c.Database.URL == "" && !c.Dev {
+		invalid("database.url", "is required")
+	} else if c.Database.URL != "" {
+		switch scheme, _, _ := strings.Cut(c.Database.URL, "://"); scheme {
+		case "cockroachdb", "postgres", "postgresql":
+		case "sqlite":
+			if path, _ := c.Database.SQLitePath(); path == "" {
+				invalid("database.url", "must name a file after sqlite://")
+			}
+		default:
+			invalid("database.url", "must start with cockroachdb://, postgres://, postgresql:// or sqlite://")
+		}
+	}
+	if c.Session.ExpiryInterval <= 0 {
+		invalid("session.expiry_interval", "must be positive")
+	}
+	if c.Session.RevokeSecret != "" && len(c.Session.RevokeSecret) < minRevokeSecretLength {
+		invalid("session.revoke_secret", "must be at least %d bytes", minRevokeSecretLength)
+	}
+	if c.Registration.ExpiryInterval <= 0 {
+		invalid("registration.expiry_interval", "must be positive")
+	}
+	switch c.Registration.CaptchaVerifier {
+	case CaptchaVerifierNone, CaptchaVerifierFake:
+	default:
+		invalid("registration.captcha_verifier", "unknown verifier %q", c.Registration.CaptchaVerifier)
+	}
+	if c.Hasher.MemoryBudgetMiB == 0 {
+		invalid("hasher.memory_budget_mib", "must be positive")
+	}
+	if c.Hasher.QueueDepth < 0 {
+		invalid("hasher.queue_depth", "must not be negative")
+	}
+	if c.Metrics.Address != "" && c.Metrics.Address == c.Server.Address {
+		invalid("metrics.address", "must differ from server.address")
+	}
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout:
+	default:
+		invalid("tracing.exporter", "unknown exporter %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		invalid("tracing.sample_ratio", "must be between 0 and 1")
+	}
+	if c.GeoIP.ReloadInterval <= 0 {
+		invalid("geoip.reload_interval", "must be positive")
+	}
+	if c.Mail.SMTPAddress != "" {
+		if _, _, err := net.SplitHostPort(c.Mail.SMTPAddress); err != nil {
+			invalid("mail.smtp_address", "%v", err)
+		}
+		if c.Mail.FileDirectory != "" {
+			invalid("mail.file_directory", "must not be set with mail.smtp_address")
+		}
+	}
+	if c.Mail.Enabled() {
+		if _, err := mail.ParseAddress(c.Mail.From); err != nil {
+			invalid("mail.from", "%v", err)
+		}
+		if c.Session.RevokeSecret == "" {
+			invalid("session.revoke_secret", "is required to send mail")
+		}
+		if u, err := url.Parse(c.Session.RevokeURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			invalid("session.revoke_url", "must be an absolute HTTP URL to send mail")
+		}
+	}
+	if c.Risk.StepUpThreshold < 0 {
+		invalid("risk.step_up_threshold", "must not be negative")
+	}
+	if c.Risk.BlockThreshold < 0 {
+		invalid("risk.block_threshold", "must not be negative")
+	}
+	if c.Risk.StepUpThreshold > 0 && c.Risk.BlockThreshold > 0 && c.Risk.StepUpThreshold > c.Risk.BlockThreshold {
+		invalid("risk.step_up_threshold", "must not exceed risk.block_threshold")
+	}
+	if c.Risk.MaxTravelSpeed < 0 {
+		invalid("risk.max_travel_speed", "must not be negative")
+	}
+	for _, endpoint := range c.Webhook.Endpoints {
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			invalid("webhook.endpoints", "%q must be an absolute HTTP URL", endpoint)
+		}
+	}
+	if len(c.Webhook.Endpoints) > 0 && len(c.Webhook.Secret) < minWebhookSecretLength {
+		invalid("webhook.secret", "must be at least %d bytes", minWebhookSecretLength)
+	}
+	if c.Webhook.PollInterval <= 0 {
+		invalid("webhook.poll_interval", "must be positive")
+	}
+	if c.Webhook.Timeout <= 0 {
+		invalid("webhook.timeout", "must be positive")
+	}
+	if c.Retention.Period < 0 {
+		invalid("retention.period", "must not be negative")
+	}
+	if c.Retention.HistoryPeriod < c.Retention.Period {
+		invalid("retention.history_period", "must not be shorter than retention.period")
+	}
+	if c.Retention.Interval <= 0 {
+		invalid("retention.interval", "must be positive")
+	}
+	if c.Retention.BatchSize <= 0 {
+		invalid("retention.batch_size", "must be positive")
+	}
+	if c.Lockout.IdentityThreshold < 0 {
+		invalid("lockout.identity_threshold", "must not be negative")
+	}
+	if c.Lockout.IPThreshold < 0 {
+		invalid("lockout.ip_threshold", "must not be negative")
+	}
+	if c.Lockout.Duration <= 0 {
+		invalid("lockout.duration", "must be positive")
+	}
+	if c.Admin.Address != "" {
+		if c.Admin.Address == c.Server.Address || c.Admin.Address == c.Metrics.Address {
+			invalid("admin.address", "must differ from server.address and metrics.address")
+		}
+		if len(c.Admin.Tokens) == 0 {
+			invalid("admin.tokens", "are required to serve the admin API")
+		}
+	}
+	if _, err := c.Admin.ActorTokens(); err != nil {
+		invalid("admin.tokens", "%v", err)
+	}
+	switch c.RateLimit.Store {
+	case RateLimitStoreMemory, RateLimitStoreCockroachDB:
+	default:
+		invalid("rate_limit.store", "unknown store %q", c.RateLimit.Store)
+	}
+	for _, procedure := range slices.Sorted(maps.Keys(c.RateLimit.Rules)) {
+		rule := c.RateLimit.Rules[procedure]
+		for _, limit := range []struct {
+			name string
+			RateLimit
+		}{{"per_ip", rule.PerIP}, {"per_prefix", rule.PerPrefix}, {"per_email", rule.PerEmail}} {
+			name := fmt.Sprintf("rate_limit.rules[%q].%s", procedure, limit.name)
+			if limit.Burst < 0 || limit.Period < 0 {
+				invalid(name, "must not be negative")
+			} else if (limit.Burst == 0) != (limit.Period == 0) {
+				invalid(name, "must set both burst and period, or neither")
+			}
+		}
+	}
+	if c.Dev && c.RateLimit.Store != RateLimitStoreMemory {
+		invalid("rate_limit.store", "must be %q in dev mode", RateLimitStoreMemory)
+	}
+	if c.Dev && len(c.Webhook.Endpoints) > 0 {
+		invalid("webhook.endpoints", "are not available in dev mode, which has no outbox")
+	}
+	if c.Dev && c.Admin.Address != "" {
+		invalid("admin.address", "is not available in dev mode, which has no audit log or lockouts")
+	}
+	if _, ok := c.Database.SQLitePath(); ok && !c.Dev {
+		if c.RateLimit.Store != RateLimitStoreMemory {
+			invalid("rate_limit.store", "must be %q with SQLite", RateLimitStoreMemory)
+		}
+		if len(c.Webhook.Endpoints) > 0 {
+			invalid("webhook.endpoints", "are not available with SQLite, which has no outbox")
+		}
+	}
+
+	return errors.Join(errs...)
+}
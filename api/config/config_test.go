@@ -0,0 +1,494 @@
+package config
+
+import (
+	"io/fs"
+	"log/slog"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type configTestSuite struct {
+	suite.Suite
+	env   map[string]string
+	files map[string]string
+	dev   bool
+}
+
+func (s *configTestSuite) SetupTest() {
+	s.env = map[string]string{"DATABASE_URL": "cockroachdb://root@localhost:26257/auth"}
+	s.files = map[string]string{}
+	s.dev = false
+}
+
+func (s *configTestSuite) load(path string) (*Config, error) {
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := s.env[name]
+		return value, ok
+	}
+	readFile := func(name string) ([]byte, error) {
+		data, ok := s.files[name]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		return []byte(data), nil
+	}
+	return load(path, s.dev, lookupEnv, readFile)
+}
+
+func (s *configTestSuite) TestDefault() {
+	c, err := s.load("")
+	s.Require().NoError(err)
+
+	expected := Default()
+	expected.Database.URL = s.env["DATABASE_URL"]
+	s.Equal(expected, c)
+}
+
+func (s *configTestSuite) TestYAML() {
+	s.files["auth.yaml"] = `
+server:
+  address: 127.0.0.1:9090
+  read_timeout: 3s
+session:
+  expiry_interval: 24h
+registration:
+  challenge_enabled: true
+rate_limit:
+  store: cockroachdb
+log:
+  level: debug
+`
+	c, err := s.load("auth.yaml")
+	s.Require().NoError(err)
+	s.Equal("127.0.0.1:9090", c.Server.Address)
+	s.Equal(3*time.Second, c.Server.ReadTimeout)
+	s.Equal(5*time.Second, c.Server.WriteTimeout)
+	s.Equal(24*time.Hour, c.Session.ExpiryInterval)
+	s.True(c.Registration.ChallengeEnabled)
+	s.Equal(RateLimitStoreCockroachDB, c.RateLimit.Store)
+	s.Equal(slog.LevelDebug, c.Log.Level)
+}
+
+func (s *configTestSuite) TestTOML() {
+	s.files["auth.toml"] = `
+[hasher]
+memory_budget_mib = 512
+queue_depth = 8
+
+[registration]
+expiry_interval = "30m"
+captcha_verifier = "fake"
+
+[log]
+level = "warn"
+
+[geoip]
+databases = ["/var/lib/GeoIP/GeoLite2-City.mmdb", "/var/lib/GeoIP/GeoLite2-ASN.mmdb"]
+reload_interval = "1h"
+`
+	c, err := s.load("auth.toml")
+	s.Require().NoError(err)
+	s.Equal(uint64(512), c.Hasher.MemoryBudgetMiB)
+	s.Equal(8, c.Hasher.QueueDepth)
+	s.Equal(30*time.Minute, c.Registration.ExpiryInterval)
+	s.Equal(CaptchaVerifierFake, c.Registration.CaptchaVerifier)
+	s.Equal(slog.LevelWarn, c.Log.Level)
+	s.Equal([]string{"/var/lib/GeoIP/GeoLite2-City.mmdb", "/var/lib/GeoIP/GeoLite2-ASN.mmdb"}, c.GeoIP.Databases)
+	s.Equal(time.Hour, c.GeoIP.ReloadInterval)
+}
+
+func (s *configTestSuite) TestUnknownField() {
+	s.files["auth.yaml"] = "sever:\n  address: 127.0.0.1:9090\n"
+	_, err := s.load("auth.yaml")
+	s.Error(err)
+
+	s.files["auth.toml"] = "[sever]\naddress = \"127.0.0.1:9090\"\n"
+	_, err = s.load("auth.toml")
+	s.Error(err)
+}
+
+func (s *configTestSuite) TestEnvironmentOverridesFile() {
+	s.files["auth.yaml"] = "session:\n  expiry_interval: 24h\n"
+	s.env["SESSION_EXPIRY_INTERVAL"] = "1h"
+	s.env["PASSWORD_HASHER_QUEUE_DEPTH"] = "0"
+	s.env["LOG_LEVEL"] = "error"
+
+	c, err := s.load("auth.yaml")
+	s.Require().NoError(err)
+	s.Equal(time.Hour, c.Session.ExpiryInterval)
+	s.Equal(0, c.Hasher.QueueDepth)
+	s.Equal(slog.LevelError, c.Log.Level)
+}
+
+func (s *configTestSuite) TestFileVariant() {
+	delete(s.env, "DATABASE_URL")
+	s.env["DATABASE_URL_FILE"] = "/run/secrets/database_url"
+	s.files["/run/secrets/database_url"] = "postgresql://auth:secret@db:26257/auth\n"
+
+	c, err := s.load("")
+	s.Require().NoError(err)
+	s.Equal("postgresql://auth:secret@db:26257/auth", c.Database.URL)
+
+	// Both variants at once are ambiguous
+	s.env["DATABASE_URL"] = "postgresql://root@localhost:26257/auth"
+	_, err = s.load("")
+	s.ErrorContains(err, "mutually exclusive")
+}
+
+func (s *configTestSuite) TestDatabaseDialect() {
+	for _, databaseURL := range []string{
+		"cockroachdb://root@localhost:26257/auth",
+		"postgres://auth@localhost:5432/auth",
+		"postgresql://auth@localhost:5432/auth?sslmode=disable",
+	} {
+		s.env["DATABASE_URL"] = databaseURL
+		_, err := s.load("")
+		s.NoError(err, databaseURL)
+	}
+
+	s.env["DATABASE_URL"] = "mysql://auth@localhost:3306/auth"
+	_, err := s.load("")
+	s.ErrorContains(err, "database.url")
+	s.NotContains(err.Error(), "mysql://auth@", "the URL may hold a password")
+}
+
+func (s *configTestSuite) TestReportsAllErrors() {
+	delete(s.env, "DATABASE_URL")
+	s.env["SESSION_EXPIRY_INTERVAL"] = "forever"
+	s.env["REGISTRATION_CHALLENGE_ENABLED"] = "maybe"
+	s.env["RATE_LIMIT_STORE"] = "redis"
+	s.env["CAPTCHA_VERIFIER_FILE"] = "/run/secrets/missing"
+
+	_, err := s.load("")
+	s.Require().Error(err)
+	s.ErrorContains(err, "SESSION_EXPIRY_INTERVAL")
+	s.ErrorContains(err, "REGISTRATION_CHALLENGE_ENABLED")
+	s.ErrorContains(err, "CAPTCHA_VERIFIER_FILE")
+	s.ErrorContains(err, "database.url")
+	s.ErrorContains(err, "rate_limit.store")
+}
+
+func (s *configTestSuite) TestMetricsAddress() {
+	s.env["METRICS_ADDRESS"] = ""
+	c, err := s.load("")
+	s.Require().NoError(err)
+	s.Empty(c.Metrics.Address)
+
+	// Metrics must not be served next to the public API
+	s.env["METRICS_ADDRESS"] = c.Server.Address
+	_, err = s.load("")
+	s.ErrorContains(err, "metrics.address")
+}
+
+func (s *configTestSuite) TestTracing() {
+	s.files["auth.toml"] = "[tracing]\nexporter = \"otlp\"\nsample_ratio = 0.25\n"
+	c, err := s.load("auth.toml")
+	s.Require().NoError(err)
+	s.Equal("otlp", c.Tracing.Exporter)
+	s.InDelta(0.25, c.Tracing.SampleRatio, 0)
+
+	s.env["TRACING_EXPORTER"] = "jaeger"
+	s.env["TRACING_SAMPLE_RATIO"] = "2"
+	_, err = s.load("auth.toml")
+	s.ErrorContains(err, "tracing.exporter")
+	s.ErrorContains(err, "tracing.sample_ratio")
+}
+
+func (s *configTestSuite) TestTrustedProxies() {
+	s.files["auth.yaml"] = "server:\n  trusted_proxies: [10.0.0.0/8, \"2001:db8::1\"]\n"
+	c, err := s.load("auth.yaml")
+	s.Require().NoError(err)
+	prefixes, err := c.Server.TrustedProxyPrefixes()
+	s.Require().NoError(err)
+	s.Equal([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8::1/128")}, prefixes)
+
+	s.env["SERVER_TRUSTED_PROXIES"] = "172.16.0.0/12, 192.168.1.1"
+	s.env["SERVER_PROXY_PROTOCOL"] = "true"
+	c, err = s.load("auth.yaml")
+	s.Require().NoError(err)
+	s.Equal([]string{"172.16.0.0/12", "192.168.1.1"}, c.Server.TrustedProxies)
+	s.True(c.Server.ProxyProtocol)
+
+	// The PROXY protocol would let any client pick its address
+	s.env["SERVER_TRUSTED_PROXIES"] = ""
+	_, err = s.load("auth.yaml")
+	s.ErrorContains(err, "server.proxy_protocol")
+
+	s.env["SERVER_TRUSTED_PROXIES"] = "10.0.0.0/33"
+	_, err = s.load("auth.yaml")
+	s.ErrorContains(err, "server.trusted_proxies")
+}
+
+func (s *configTestSuite) TestDrainDelay() {
+	s.env["SERVER_DRAIN_DELAY"] = "0s"
+	c, err := s.load("")
+	s.Require().NoError(err)
+	s.Zero(c.Server.DrainDelay)
+
+	s.env["SERVER_DRAIN_DELAY"] = "-1s"
+	_, err = s.load("")
+	s.ErrorContains(err, "server.drain_delay")
+}
+
+func (s *configTestSuite) TestMail() {
+	s.files["auth.yaml"] = `
+session:
+  revoke_url: https://auth.mreg.io/sessions/revoke
+mail:
+  smtp_address: smtp.example.com:587
+  smtp_username: auth
+  from: My Registry <no-reply@mreg.io>
+`
+	s.env["SESSION_REVOKE_SECRET_FILE"] = "/run/secrets/revoke_secret"
+	s.files["/run/secrets/revoke_secret"] = "0123456789abcdef0123456789abcdef\n"
+	s.env["MAIL_SMTP_PASSWORD"] = "secret"
+	c, err := s.load("auth.yaml")
+	s.Require().NoError(err)
+	s.Equal("smtp.example.com:587", c.Mail.SMTPAddress)
+	s.Equal("auth", c.Mail.SMTPUsername)
+	s.Equal("secret", c.Mail.SMTPPassword)
+	s.True(c.Mail.RequireTLS())
+	s.Equal("0123456789abcdef0123456789abcdef", c.Session.RevokeSecret)
+
+	// Notices cannot link to the revocation page without its URL and key
+	s.env["SESSION_REVOKE_URL"] = "/sessions/revoke"
+	s.files["/run/secrets/revoke_secret"] = "short"
+	s.env["MAIL_FROM"] = "no-reply"
+	_, err = s.load("auth.yaml")
+	s.ErrorContains(err, "session.revoke_url")
+	s.ErrorContains(err, "session.revoke_secret")
+	s.ErrorContains(err, "mail.from")
+
+	delete(s.env, "SESSION_REVOKE_SECRET_FILE")
+	_, err = s.load("auth.yaml")
+	s.ErrorContains(err, "session.revoke_secret: is required")
+
+	// Mail goes either to the relay or to files
+	s.env["MAIL_FILE_DIRECTORY"] = "/tmp/mail"
+	_, err = s.load("auth.yaml")
+	s.ErrorContains(err, "mail.file_directory")
+	s.env["MAIL_SMTP_ADDRESS"] = ""
+	_, err = s.load("auth.yaml")
+	s.NotContains(err.Error(), "mail.file_directory")
+	s.ErrorContains(err, "session.revoke_secret: is required")
+}
+
+func (s *configTestSuite) TestMailRequireTLS() {
+	for address, required := range map[string]bool{
+		"smtp.example.com:587": true,
+		"localhost:25":         false,
+		"127.0.0.1:25":         false,
+		"[::1]:25":             false,
+		"192.0.2.1:25":         true,
+	} {
+		mail := MailConfig{SMTPAddress: address}
+		s.Equal(required, mail.RequireTLS(), address)
+	}
+	required := false
+	mail := MailConfig{SMTPAddress: "smtp.example.com:587", SMTPRequireTLS: &required}
+	s.False(mail.RequireTLS())
+
+	s.env["MAIL_SMTP_REQUIRE_TLS"] = "true"
+	c, err := s.load("")
+	s.Require().NoError(err)
+	s.True(*c.Mail.SMTPRequireTLS)
+	s.env["MAIL_SMTP_REQUIRE_TLS"] = "sometimes"
+	_, err = s.load("")
+	s.ErrorContains(err, "MAIL_SMTP_REQUIRE_TLS")
+}
+
+func (s *configTestSuite) TestRisk() {
+	s.files["auth.toml"] = `
+[risk]
+denylist_file = "/etc/auth/denylist.txt"
+block_threshold = 80
+`
+	s.env["RISK_MAX_TRAVEL_SPEED"] = "900"
+	c, err := s.load("auth.toml")
+	s.Require().NoError(err)
+	s.Equal("/etc/auth/denylist.txt", c.Risk.DenylistFile)
+	s.Equal(50, c.Risk.StepUpThreshold)
+	s.Equal(80, c.Risk.BlockThreshold)
+	s.InDelta(900, c.Risk.MaxTravelSpeed, 0)
+
+	s.env["RISK_STEP_UP_THRESHOLD"] = "90"
+	s.env["RISK_MAX_TRAVEL_SPEED"] = "-1"
+	_, err = s.load("auth.toml")
+	s.ErrorContains(err, "risk.step_up_threshold")
+	s.ErrorContains(err, "risk.max_travel_speed")
+}
+
+func (s *configTestSuite) TestWebhook() {
+	s.files["auth.yaml"] = `
+webhook:
+  endpoints:
+    - https://billing.mreg.io/webhooks/auth
+    - mreg.io
+`
+	s.env["WEBHOOK_SECRET"] = "short"
+	_, err := s.load("auth.yaml")
+	s.ErrorContains(err, `webhook.endpoints: "mreg.io" must be an absolute HTTP URL`)
+	s.ErrorContains(err, "webhook.secret")
+
+	s.env["WEBHOOK_ENDPOINTS"] = "https://billing.mreg.io/webhooks/auth,http://localhost:8090"
+	s.env["WEBHOOK_SECRET"] = "0123456789abcdef0123456789abcdef"
+	c, err := s.load("auth.yaml")
+	s.Require().NoError(err)
+	s.Equal([]string{"https://billing.mreg.io/webhooks/auth", "http://localhost:8090"}, c.Webhook.Endpoints)
+	s.Equal(time.Second, c.Webhook.PollInterval)
+}
+
+func (s *configTestSuite) TestRetention() {
+	c, err := s.load("")
+	s.Require().NoError(err)
+	s.Equal(7*24*time.Hour, c.Retention.Period)
+	s.Equal(90*24*time.Hour, c.Retention.HistoryPeriod)
+
+	s.files["auth.toml"] = `
+[retention]
+period = "-1h"
+batch_size = 0
+`
+	_, err = s.load("auth.toml")
+	s.ErrorContains(err, "retention.period")
+	s.ErrorContains(err, "retention.batch_size")
+
+	s.env["RETENTION_PERIOD"] = "24h"
+	s.env["RETENTION_BATCH_SIZE"] = "500"
+	c, err = s.load("auth.toml")
+	s.Require().NoError(err)
+	s.Equal(24*time.Hour, c.Retention.Period)
+	s.Equal(500, c.Retention.BatchSize)
+	s.Equal(time.Minute, c.Retention.Interval)
+	s.Equal(90*24*time.Hour, c.Retention.HistoryPeriod)
+
+	s.env["RETENTION_HISTORY_PERIOD"] = "12h"
+	_, err = s.load("auth.toml")
+	s.ErrorContains(err, "retention.history_period")
+}
+
+func (s *configTestSuite) TestRateLimitRules() {
+	const create = "/mreg.auth.v1alpha1.RegistrationService/CreateRegistrationFlow"
+	const complete = "/mreg.auth.v1alpha1.RegistrationService/CompleteRegistrationFlow"
+	s.files["auth.yaml"] = `
+rate_limit:
+  rules:
+    /mreg.auth.v1alpha1.RegistrationService/CompleteRegistrationFlow:
+      per_ip:
+        burst: 3
+        period: 1m
+`
+	c, err := s.load("auth.yaml")
+	s.Require().NoError(err)
+	s.Equal(RateLimitRule{PerIP: RateLimit{Burst: 3, Period: time.Minute}}, c.RateLimit.Rules[complete])
+	s.Equal(Default().RateLimit.Rules[create], c.RateLimit.Rules[create])
+
+	s.files["auth.toml"] = `
+[rate_limit.rules."/mreg.auth.v1alpha1.RegistrationService/CreateRegistrationFlow"]
+per_ip = { burst = 5, period = "10s" }
+per_email = { burst = 5 }
+`
+	_, err = s.load("auth.toml")
+	s.ErrorContains(err, "per_email")
+	s.NotContains(err.Error(), "per_ip")
+}
+
+func (s *configTestSuite) TestLockout() {
+	s.env["LOCKOUT_IDENTITY_THRESHOLD"] = "5"
+	s.env["LOCKOUT_IP_THRESHOLD"] = "0"
+	s.env["LOCKOUT_DURATION"] = "1h"
+	c, err := s.load("")
+	s.Require().NoError(err)
+	s.Equal(LockoutConfig{IdentityThreshold: 5, Duration: time.Hour}, c.Lockout)
+
+	s.env["LOCKOUT_IDENTITY_THRESHOLD"] = "-1"
+	s.env["LOCKOUT_DURATION"] = "0s"
+	_, err = s.load("")
+	s.ErrorContains(err, "lockout.identity_threshold")
+	s.ErrorContains(err, "lockout.duration")
+}
+
+func (s *configTestSuite) TestAdmin() {
+	token := "0123456789abcdef0123456789abcdef"
+	s.env["ADMIN_ADDRESS"] = "127.0.0.1:9091"
+	_, err := s.load("")
+	s.ErrorContains(err, "admin.tokens")
+
+	s.env["ADMIN_TOKENS"] = "alice:" + token + ",bob:" + token + "x"
+	c, err := s.load("")
+	s.Require().NoError(err)
+	actors, err := c.Admin.ActorTokens()
+	s.Require().NoError(err)
+	s.Equal(map[string]string{token: "alice", token + "x": "bob"}, actors)
+
+	s.env["ADMIN_TOKENS"] = "alice:short,bob:" + token + ",carol:" + token + ",missing"
+	_, err = s.load("")
+	s.ErrorContains(err, "token of alice must be at least")
+	s.ErrorContains(err, "token of carol is also the token of bob")
+	s.ErrorContains(err, "token 3 is not a name:token pair")
+
+	s.env["ADMIN_ADDRESS"] = "0.0.0.0:8080"
+	_, err = s.load("")
+	s.ErrorContains(err, "admin.address")
+}
+
+func (s *configTestSuite) TestDev() {
+	delete(s.env, "DATABASE_URL")
+	s.dev = true
+	c, err := s.load("")
+	s.Require().NoError(err)
+	s.True(c.Dev)
+
+	s.env["RATE_LIMIT_STORE"] = RateLimitStoreCockroachDB
+	s.env["WEBHOOK_ENDPOINTS"] = "http://localhost:8090"
+	s.env["WEBHOOK_SECRET"] = "0123456789abcdef0123456789abcdef"
+	_, err = s.load("")
+	s.ErrorContains(err, "rate_limit.store")
+	s.ErrorContains(err, "webhook.endpoints")
+
+	// The database is only optional in dev mode
+	s.dev = false
+	_, err = s.load("")
+	s.ErrorContains(err, "database.url")
+}
+
+func (s *configTestSuite) TestSQLite() {
+	s.env["DATABASE_URL"] = "sqlite:///var/lib/auth/auth.db"
+	c, err := s.load("")
+	s.Require().NoError(err)
+	path, ok := c.Database.SQLitePath()
+	s.True(ok)
+	s.Equal("/var/lib/auth/auth.db", path)
+
+	s.env["RATE_LIMIT_STORE"] = RateLimitStoreCockroachDB
+	s.env["WEBHOOK_ENDPOINTS"] = "http://localhost:8090"
+	s.env["WEBHOOK_SECRET"] = "0123456789abcdef0123456789abcdef"
+	_, err = s.load("")
+	s.ErrorContains(err, "rate_limit.store")
+	s.ErrorContains(err, "webhook.endpoints")
+
+	// The audit log can be reviewed without lockouts
+	delete(s.env, "RATE_LIMIT_STORE")
+	delete(s.env, "WEBHOOK_ENDPOINTS")
+	s.env["ADMIN_ADDRESS"] = "127.0.0.1:9091"
+	s.env["ADMIN_TOKENS"] = "alice:0123456789abcdef0123456789abcdef"
+	_, err = s.load("")
+	s.Require().NoError(err)
+
+	s.env["DATABASE_URL"] = "sqlite://"
+	_, err = s.load("")
+	s.ErrorContains(err, "must name a file")
+}
+
+func (s *configTestSuite) TestUnsupportedExtension() {
+	s.files["auth.json"] = "{}"
+	_, err := s.load("auth.json")
+	s.ErrorContains(err, "unsupported")
+}
+
+func TestConfigTestSuite(t *testing.T) {
+	suite.Run(t, new(configTestSuite))
+}
@@ -0,0 +1,258 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnvName is the environment variable holding the path of the
+// configuration file, when none is given on the command line.
+const FileEnvName = "CONFIG_FILE"
+
+// fileSuffix is appended to a variable name to read its value from a file.
+const fileSuffix = "_FILE"
+
+type variable struct {
+	name string
+	set  func(c *Config, value string) error
+}
+
+func stringVar(name string, field func(*Config) *string) variable {
+	return variable{name, func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}}
+}
+
+// listVar reads a comma-separated list.
+func listVar(name string, field func(*Config) *[]string) variable {
+	return variable{name, func(c *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(c) = list
+		return nil
+	}}
+}
+
+func durationVar(name string, field func(*Config) *time.Duration) variable {
+	return variable{name, func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = d
+		return nil
+	}}
+}
+
+func intVar(name string, field func(*Config) *int) variable {
+	return variable{name, func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}}
+}
+
+func uintVar(name string, field func(*Config) *uint64) variable {
+	return variable{name, func(c *Config, value string) error {
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}}
+}
+
+func floatVar(name string, field func(*Config) *float64) variable {
+	return variable{name, func(c *Config, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = f
+		return nil
+	}}
+}
+
+func boolVar(name string, field func(*Config) *bool) variable {
+	return variable{name, func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}}
+}
+
+// optionalBoolVar reads a boolean that has a default of its own when unset.
+func optionalBoolVar(name string, field func(*Config) **bool) variable {
+	return variable{name, func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = &b
+		return nil
+	}}
+}
+
+func levelVar(name string, field func(*Config) *slog.Level) variable {
+	return variable{name, func(c *Config, value string) error {
+		return field(c).UnmarshalText([]byte(value))
+	}}
+}
+
+// variables lists the environment variables overriding the file.
+var variables = []variable{
+	stringVar("SERVER_ADDRESS", func(c *Config) *string { return &c.Server.Address }),
+	durationVar("SERVER_READ_TIMEOUT", func(c *Config) *time.Duration { return &c.Server.ReadTimeout }),
+	durationVar("SERVER_WRITE_TIMEOUT", func(c *Config) *time.Duration { return &c.Server.WriteTimeout }),
+	intVar("SERVER_MAX_HEADER_BYTES", func(c *Config) *int { return &c.Server.MaxHeaderBytes }),
+	durationVar("SERVER_DRAIN_DELAY", func(c *Config) *time.Duration { return &c.Server.DrainDelay }),
+	durationVar("SERVER_SHUTDOWN_TIMEOUT", func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout }),
+	listVar("SERVER_TRUSTED_PROXIES", func(c *Config) *[]string { return &c.Server.TrustedProxies }),
+	boolVar("SERVER_PROXY_PROTOCOL", func(c *Config) *bool { return &c.Server.ProxyProtocol }),
+	stringVar("DATABASE_URL", func(c *Config) *string { return &c.Database.URL }),
+	durationVar("SESSION_EXPIRY_INTERVAL", func(c *Config) *time.Duration { return &c.Session.ExpiryInterval }),
+	stringVar("SESSION_REVOKE_URL", func(c *Config) *string { return &c.Session.RevokeURL }),
+	stringVar("SESSION_REVOKE_SECRET", func(c *Config) *string { return &c.Session.RevokeSecret }),
+	durationVar("REGISTRATION_EXPIRY_INTERVAL", func(c *Config) *time.Duration { return &c.Registration.ExpiryInterval }),
+	boolVar("REGISTRATION_CHALLENGE_ENABLED", func(c *Config) *bool { return &c.Registration.ChallengeEnabled }),
+	stringVar("CAPTCHA_VERIFIER", func(c *Config) *string { return &c.Registration.CaptchaVerifier }),
+	uintVar("PASSWORD_HASHER_MEMORY_BUDGET_MIB", func(c *Config) *uint64 { return &c.Hasher.MemoryBudgetMiB }),
+	intVar("PASSWORD_HASHER_QUEUE_DEPTH", func(c *Config) *int { return &c.Hasher.QueueDepth }),
+	stringVar("RATE_LIMIT_STORE", func(c *Config) *string { return &c.RateLimit.Store }),
+	levelVar("LOG_LEVEL", func(c *Config) *slog.Level { return &c.Log.Level }),
+	stringVar("METRICS_ADDRESS", func(c *Config) *string { return &c.Metrics.Address }),
+	stringVar("TRACING_EXPORTER", func(c *Config) *string { return &c.Tracing.Exporter }),
+	floatVar("TRACING_SAMPLE_RATIO", func(c *Config) *float64 { return &c.Tracing.SampleRatio }),
+	listVar("GEOIP_DATABASES", func(c *Config) *[]string { return &c.GeoIP.Databases }),
+	durationVar("GEOIP_RELOAD_INTERVAL", func(c *Config) *time.Duration { return &c.GeoIP.ReloadInterval }),
+	stringVar("MAIL_SMTP_ADDRESS", func(c *Config) *string { return &c.Mail.SMTPAddress }),
+	stringVar("MAIL_SMTP_USERNAME", func(c *Config) *string { return &c.Mail.SMTPUsername }),
+	stringVar("MAIL_SMTP_PASSWORD", func(c *Config) *string { return &c.Mail.SMTPPassword }),
+	optionalBoolVar("MAIL_SMTP_REQUIRE_TLS", func(c *Config) **bool { return &c.Mail.SMTPRequireTLS }),
+	stringVar("MAIL_FROM", func(c *Config) *string { return &c.Mail.From }),
+	stringVar("MAIL_FILE_DIRECTORY", func(c *Config) *string { return &c.Mail.FileDirectory }),
+	stringVar("RISK_DENYLIST_FILE", func(c *Config) *string { return &c.Risk.DenylistFile }),
+	intVar("RISK_STEP_UP_THRESHOLD", func(c *Config) *int { return &c.Risk.StepUpThreshold }),
+	intVar("RISK_BLOCK_THRESHOLD", func(c *Config) *int { return &c.Risk.BlockThreshold }),
+	floatVar("RISK_MAX_TRAVEL_SPEED", func(c *Config) *float64 { return &c.Risk.MaxTravelSpeed }),
+	listVar("WEBHOOK_ENDPOINTS", func(c *Config) *[]string { return &c.Webhook.Endpoints }),
+	stringVar("WEBHOOK_SECRET", func(c *Config) *string { return &c.Webhook.Secret }),
+	durationVar("WEBHOOK_POLL_INTERVAL", func(c *Config) *time.Duration { return &c.Webhook.PollInterval }),
+	durationVar("WEBHOOK_TIMEOUT", func(c *Config) *time.Duration { return &c.Webhook.Timeout }),
+	durationVar("RETENTION_PERIOD", func(c *Config) *time.Duration { return &c.Retention.Period }),
+	durationVar("RETENTION_HISTORY_PERIOD", func(c *Config) *time.Duration { return &c.Retention.HistoryPeriod }),
+	durationVar("RETENTION_INTERVAL", func(c *Config) *time.Duration { return &c.Retention.Interval }),
+	intVar("RETENTION_BATCH_SIZE", func(c *Config) *int { return &c.Retention.BatchSize }),
+	intVar("LOCKOUT_IDENTITY_THRESHOLD", func(c *Config) *int { return &c.Lockout.IdentityThreshold }),
+	intVar("LOCKOUT_IP_THRESHOLD", func(c *Config) *int { return &c.Lockout.IPThreshold }),
+	durationVar("LOCKOUT_DURATION", func(c *Config) *time.Duration { return &c.Lockout.Duration }),
+	stringVar("ADMIN_ADDRESS", func(c *Config) *string { return &c.Admin.Address }),
+	listVar("ADMIN_TOKENS", func(c *Config) *[]string { return &c.Admin.Tokens }),
+}
+
+// Load reads the configuration file at path, if not empty, applies the
+// environment on top of it and validates the result, for dev mode if dev is
+// set. All problems found are returned together.
+func Load(path string, dev bool) (*Config, error) {
+	return load(path, dev, os.LookupEnv, os.ReadFile)
+}
+
+func load(path string, dev bool, lookupEnv func(string) (string, bool), readFile func(string) ([]byte, error)) (*Config, error) {
+	c := Default()
+	c.Dev = dev
+
+	if path != "" {
+		data, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := decodeFile(path, data, c); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	var errs []error
+	for _, v := range variables {
+		value, ok, err := lookupVariable(v.name, lookupEnv, readFile)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := v.set(c, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", v.name, err))
+		}
+	}
+	errs = append(errs, c.Validate())
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// lookupVariable returns the value of name, or the content of the file named
+// by name_FILE.
+func lookupVariable(name string, lookupEnv func(string) (string, bool), readFile func(string) ([]byte, error)) (string, bool, error) {
+	value, ok := lookupEnv(name)
+	file, fileOK := lookupEnv(name + fileSuffix)
+	if !fileOK {
+		return value, ok, nil
+	}
+	if ok {
+		return "", false, fmt.Errorf("%s and %s%s are mutually exclusive", name, name, fileSuffix)
+	}
+	data, err := readFile(file)
+	if err != nil {
+		return "", false, fmt.Errorf("%s%s: %w", name, fileSuffix, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), true, nil
+}
+
+func decodeFile(path string, data []byte, c *Config) error {
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		return nil
+	case ".toml":
+		metadata, err := toml.Decode(string(data), c)
+		if err != nil {
+			return err
+		}
+		if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown field %q", undecoded[0].String())
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported configuration file extension %q", ext)
+	}
+}
@@ -0,0 +1,88 @@
+// Package audit records the security relevant changes made to identities
+// and sessions, so that they can be reviewed later.
+package audit
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+const (
+	EventRegistration    EventType = "registration"
+	EventLogin           EventType = "login"
+	EventSessionRevoked  EventType = "session_revoked"
+	EventPasswordChanged EventType = "password_changed"
+	EventEmailVerified   EventType = "email_verified"
+	EventAdminAction     EventType = "admin_action"
+)
+
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	// OutcomeFailure is recorded for attempts that failed, such as a login
+	// with a wrong password.
+	OutcomeFailure Outcome = "failure"
+	// OutcomeDenied is recorded for attempts refused by a policy.
+	OutcomeDenied Outcome = "denied"
+)
+
+// Event is an entry of the audit log. Events are written by the service
+// making the change they record, in the same transaction, or on their own
+// for attempts that changed nothing, and are never changed afterwards.
+type Event struct {
+	ID   string
+	Type EventType
+	// Actor is the administrator who made the change. It is empty when the
+	// change was made by the client of the session.
+	Actor string
+	// IdentityID is the identity the change was made to. It is empty when
+	// no identity is known, such as for a login naming an unknown account.
+	IdentityID string
+	SessionID  string
+	IPAddress  netip.Addr
+	UserAgent  string
+	Outcome    Outcome
+	// Action names what an administrator did for EventAdminAction.
+	Action     string
+	CreateTime time.Time
+}
+
+// ErrInvalidPageToken is returned for page tokens that were not returned
+// by a previous query.
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// EncodePageToken returns the position after event, the last of a page.
+func EncodePageToken(event Event) string {
+	position := strconv.FormatInt(event.CreateTime.UnixMicro(), 10) + "." + event.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(position))
+}
+
+// DecodePageToken returns the creation time and ID of the last event of the
+// previous page.
+func DecodePageToken(token string) (time.Time, string, error) {
+	position, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidPageToken
+	}
+	micros, id, ok := strings.Cut(string(position), ".")
+	if !ok {
+		return time.Time{}, "", ErrInvalidPageToken
+	}
+	createTime, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidPageToken
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return time.Time{}, "", ErrInvalidPageToken
+	}
+	return time.UnixMicro(createTime), id, nil
+}
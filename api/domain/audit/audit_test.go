@@ -0,0 +1,23 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageToken(t *testing.T) {
+	event := Event{ID: uuid.New().String(), CreateTime: time.Date(2026, time.October, 19, 16, 4, 33, 123456000, time.UTC)}
+	createTime, id, err := DecodePageToken(EncodePageToken(event))
+	require.NoError(t, err)
+	assert.True(t, event.CreateTime.Equal(createTime))
+	assert.Equal(t, event.ID, id)
+
+	for _, token := range []string{"not a token", "MTIz", "YWJjLmRlZg"} {
+		_, _, err = DecodePageToken(token)
+		assert.ErrorIs(t, err, ErrInvalidPageToken, token)
+	}
+}
@@ -0,0 +1,13 @@
+package audit
+
+import "context"
+
+type Repository interface {
+	// CreateEvent records event. The services write the events of changes
+	// in the transaction making them, so that no change goes unaudited.
+	CreateEvent(ctx context.Context, event *Event) error
+	// QueryEvents returns up to pageSize events of identityID, newest first,
+	// continuing after the page that returned pageToken if it is not empty.
+	// The returned token is empty on the last page.
+	QueryEvents(ctx context.Context, identityID string, pageSize int, pageToken string) ([]Event, string, error)
+}
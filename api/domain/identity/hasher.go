@@ -0,0 +1,220 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrHasherBusy is returned by Hasher when its queue is full. Callers should
+// ask the client to retry later instead of waiting for a free worker.
+var ErrHasherBusy = errors.New("argon2id: hasher queue is full")
+
+// ErrHasherClosed is returned by Hasher after Close has been called.
+var ErrHasherClosed = errors.New("argon2id: hasher is closed")
+
+// HasherConfig describes the resources a Hasher is allowed to use.
+type HasherConfig struct {
+	// Params used by CreateHash.
+	Params *Params
+
+	// MemoryBudget is the total amount of memory (in kibibytes) that
+	// concurrent hash operations may use. The number of workers is derived
+	// from it by dividing by Params.Memory.
+	MemoryBudget uint64
+
+	// QueueDepth is the number of operations allowed to wait for a free
+	// worker. Operations beyond it fail fast with ErrHasherBusy.
+	QueueDepth int
+
+	// Observe, if not nil, is called with the time spent computing each
+	// hash, excluding the time spent in the queue.
+	Observe func(operation HashOperation, duration time.Duration)
+}
+
+// HashOperation names the work done by a Hasher.
+type HashOperation string
+
+const (
+	HashOperationCreate  HashOperation = "create"
+	HashOperationCompare HashOperation = "compare"
+)
+
+// DefaultHasherConfig runs four DefaultParams hashes at a time and lets
+// another 64 wait for a worker.
+var DefaultHasherConfig = HasherConfig{
+	Params:       DefaultParams,
+	MemoryBudget: 4 * uint64(DefaultParams.Memory),
+	QueueDepth:   64,
+}
+
+// HasherStats is a point-in-time snapshot of the Hasher queue.
+type HasherStats struct {
+	// Workers is the number of hash operations that may run at once.
+	Workers int
+	// QueueDepth is the configured queue capacity.
+	QueueDepth int
+	// Queued is the number of operations waiting for a worker.
+	Queued int
+	// InFlight is the number of operations currently being computed.
+	InFlight int64
+	// Completed is the total number of finished operations.
+	Completed uint64
+	// Rejected is the total number of operations refused with ErrHasherBusy.
+	Rejected uint64
+}
+
+type hashJob struct {
+	ctx context.Context
+	run func()
+	// done is closed once run has returned or the job has been skipped.
+	done chan struct{}
+}
+
+// Hasher runs argon2id hashing and verification on a bounded pool of
+// workers, so that a burst of requests cannot allocate more memory than
+// the configured budget.
+type Hasher struct {
+	params  *Params
+	workers int
+	jobs    chan hashJob
+	observe func(HashOperation, time.Duration)
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	wg        sync.WaitGroup
+
+	inFlight  atomic.Int64
+	completed atomic.Uint64
+	rejected  atomic.Uint64
+}
+
+// NewHasher starts the workers of a Hasher. Close must be called to stop
+// them.
+func NewHasher(config HasherConfig) *Hasher {
+	params := config.Params
+	if params == nil {
+		params = DefaultParams
+	}
+	workers := 1
+	if params.Memory > 0 && config.MemoryBudget/uint64(params.Memory) > 1 {
+		workers = int(config.MemoryBudget / uint64(params.Memory))
+	}
+	queueDepth := max(config.QueueDepth, 0)
+
+	h := &Hasher{
+		params:  params,
+		workers: workers,
+		jobs:    make(chan hashJob, queueDepth),
+		observe: config.Observe,
+		closed:  make(chan struct{}),
+	}
+	h.wg.Add(workers)
+	for range workers {
+		go h.work()
+	}
+	return h
+}
+
+func (h *Hasher) work() {
+	defer h.wg.Done()
+	for {
+		select {
+		case <-h.closed:
+			return
+		case job := <-h.jobs:
+			// Skip work whose caller has already given up
+			if job.ctx.Err() == nil {
+				h.inFlight.Add(1)
+				job.run()
+				h.inFlight.Add(-1)
+				h.completed.Add(1)
+			}
+			close(job.done)
+		}
+	}
+}
+
+// submit hands run to a worker and waits for it to finish. It never blocks
+// on a full queue.
+func (h *Hasher) submit(ctx context.Context, run func()) error {
+	select {
+	case <-h.closed:
+		return ErrHasherClosed
+	default:
+	}
+
+	job := hashJob{ctx: ctx, run: run, done: make(chan struct{})}
+	select {
+	case h.jobs <- job:
+	default:
+		h.rejected.Add(1)
+		return ErrHasherBusy
+	}
+
+	select {
+	case <-job.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-h.closed:
+		return ErrHasherClosed
+	}
+}
+
+// CreateHash is like the package level CreateHash, but runs on the worker
+// pool using the configured parameters.
+func (h *Hasher) CreateHash(ctx context.Context, password string) (string, error) {
+	var hash string
+	var err error
+	if submitErr := h.submit(ctx, func() {
+		defer h.observed(HashOperationCreate, time.Now())
+		hash, err = CreateHash(password, h.params)
+	}); submitErr != nil {
+		return "", submitErr
+	}
+	return hash, err
+}
+
+// ComparePasswordAndHash is like the package level ComparePasswordAndHash,
+// but runs on the worker pool.
+func (h *Hasher) ComparePasswordAndHash(ctx context.Context, password, hash string) (bool, error) {
+	var match bool
+	var err error
+	if submitErr := h.submit(ctx, func() {
+		defer h.observed(HashOperationCompare, time.Now())
+		match, err = ComparePasswordAndHash(password, hash)
+	}); submitErr != nil {
+		return false, submitErr
+	}
+	return match, err
+}
+
+func (h *Hasher) observed(operation HashOperation, start time.Time) {
+	if h.observe != nil {
+		h.observe(operation, time.Since(start))
+	}
+}
+
+// Stats returns the current queue metrics.
+func (h *Hasher) Stats() HasherStats {
+	return HasherStats{
+		Workers:    h.workers,
+		QueueDepth: cap(h.jobs),
+		Queued:     len(h.jobs),
+		InFlight:   h.inFlight.Load(),
+		Completed:  h.completed.Load(),
+		Rejected:   h.rejected.Load(),
+	}
+}
+
+// Close stops the workers. Operations still in the queue fail with
+// ErrHasherClosed.
+func (h *Hasher) Close() {
+	h.closeOnce.Do(func() {
+		close(h.closed)
+	})
+	h.wg.Wait()
+}
@@ -0,0 +1,145 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testHasherParams = &Params{
+	Memory:      8 * 1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestHasher_CreateAndCompare(t *testing.T) {
+	observed := make(map[HashOperation]int)
+	var mu sync.Mutex
+	hasher := NewHasher(HasherConfig{
+		Params:       testHasherParams,
+		MemoryBudget: 16 * 1024,
+		QueueDepth:   4,
+		Observe: func(operation HashOperation, _ time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			observed[operation]++
+		},
+	})
+	defer hasher.Close()
+	ctx := context.Background()
+
+	hash, err := hasher.CreateHash(ctx, "pa$$word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match, err := hasher.ComparePasswordAndHash(ctx, "pa$$word", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !match {
+		t.Error("expected password and hash to match")
+	}
+
+	match, err = hasher.ComparePasswordAndHash(ctx, "otherPa$$word", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match {
+		t.Error("expected password and hash to not match")
+	}
+
+	stats := hasher.Stats()
+	if stats.Workers != 2 {
+		t.Errorf("expected 2 workers, got %d", stats.Workers)
+	}
+	if stats.Completed != 3 {
+		t.Errorf("expected 3 completed operations, got %d", stats.Completed)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if observed[HashOperationCreate] != 1 || observed[HashOperationCompare] != 2 {
+		t.Errorf("expected 1 create and 2 compare observations, got %v", observed)
+	}
+}
+
+func TestHasher_RejectsWhenQueueIsFull(t *testing.T) {
+	hasher := NewHasher(HasherConfig{Params: testHasherParams, MemoryBudget: 8 * 1024, QueueDepth: 1})
+	defer hasher.Close()
+	ctx := context.Background()
+
+	// Occupy the only worker
+	started, release := make(chan struct{}), make(chan struct{})
+	busy := make(chan error)
+	go func() {
+		busy <- hasher.submit(ctx, func() {
+			close(started)
+			<-release
+		})
+	}()
+	<-started
+
+	// Fill the queue
+	queued := make(chan error)
+	go func() {
+		queued <- hasher.submit(ctx, func() {})
+	}()
+	for hasher.Stats().Queued != 1 {
+		runtime.Gosched()
+	}
+
+	if _, err := hasher.CreateHash(ctx, "pa$$word"); !errors.Is(err, ErrHasherBusy) {
+		t.Fatalf("expected error %s, got %v", ErrHasherBusy, err)
+	}
+	if rejected := hasher.Stats().Rejected; rejected != 1 {
+		t.Errorf("expected 1 rejected operation, got %d", rejected)
+	}
+
+	close(release)
+	if err := <-busy; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-queued; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHasher_CanceledWhileQueued(t *testing.T) {
+	hasher := NewHasher(HasherConfig{Params: testHasherParams, MemoryBudget: 8 * 1024, QueueDepth: 1})
+	defer hasher.Close()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	busy := make(chan error)
+	go func() {
+		busy <- hasher.submit(context.Background(), func() {
+			close(started)
+			<-release
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := hasher.CreateHash(ctx, "pa$$word"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected error %s, got %v", context.Canceled, err)
+	}
+
+	close(release)
+	if err := <-busy; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHasher_Closed(t *testing.T) {
+	hasher := NewHasher(HasherConfig{Params: testHasherParams, MemoryBudget: 8 * 1024})
+	hasher.Close()
+
+	if _, err := hasher.CreateHash(context.Background(), "pa$$word"); !errors.Is(err, ErrHasherClosed) {
+		t.Fatalf("expected error %s, got %v", ErrHasherClosed, err)
+	}
+}
@@ -16,14 +16,28 @@ const (
 	StateSuspended
 )
 
+// String returns the name of the state as stored in the database.
+func (s IDState) String() string {
+	switch s {
+	case StateActive:
+		return "active"
+	case StateSuspended:
+		return "suspended"
+	default:
+		return fmt.Sprintf("IDState(%d)", s)
+	}
+}
+
 type Identity struct {
-	ID              string  `cbor:"1, keyasint"`
-	State           IDState `cbor:"2, keyasint"`
-	FullName        string  `cbor:"3, keyasint, omitempty"`
-	DisplayName     string  `cbor:"4, keyasint, omitempty"`
-	AvatarURL       string  `cbor:"5, keyasint, omitempty"`
-	Emails          []Email `cbor:"6, keyasint, toarray"`
-	Timezone        string  `cbor:"7, keyasint, omitempty"`
+	ID          string  `cbor:"1, keyasint"`
+	State       IDState `cbor:"2, keyasint"`
+	FullName    string  `cbor:"3, keyasint, omitempty"`
+	DisplayName string  `cbor:"4, keyasint, omitempty"`
+	AvatarURL   string  `cbor:"5, keyasint, omitempty"`
+	Emails      []Email `cbor:"6, keyasint, toarray"`
+	Timezone    string  `cbor:"7, keyasint, omitempty"`
+	// Locale is the language the identity registered in, such as "fr".
+	Locale          string `cbor:"9, keyasint, omitempty"`
 	CreateTime      time.Time
 	UpdateTime      time.Time `cbor:"8, keyasint"`
 	StateUpdateTime time.Time
@@ -3,7 +3,11 @@ package identity
 import "context"
 
 type Repository interface {
+	// CreateIdentity creates an identity, and in the same transaction
+	// announces it in the outbox.
 	CreateIdentity(ctx context.Context, identity *Identity) error
 	QueryEmail(ctx context.Context, email *Email) error
 	EmailExists(ctx context.Context, email string) (bool, error)
+	// QueryEmails fills the emails of the identity with the given ID.
+	QueryEmails(ctx context.Context, identity *Identity) error
 }
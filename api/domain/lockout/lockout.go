@@ -0,0 +1,115 @@
+package lockout
+
+import (
+	"net/netip"
+	"time"
+)
+
+// Policy decides how failed logins of one subject are throttled.
+type Policy struct {
+	// Threshold is the number of consecutive failures that locks the subject.
+	Threshold int
+	// BaseDelay is the delay enforced after the first failure. It doubles
+	// with every further failure.
+	BaseDelay time.Duration
+	// MaxDelay caps the exponential delay.
+	MaxDelay time.Duration
+	// LockDuration is how long the subject stays locked.
+	LockDuration time.Duration
+	// Window is the period without failures after which the count restarts.
+	Window time.Duration
+}
+
+// DefaultIdentityPolicy locks an account for 15 minutes after 10 failures.
+var DefaultIdentityPolicy = Policy{
+	Threshold:    10,
+	BaseDelay:    time.Second,
+	MaxDelay:     time.Minute,
+	LockDuration: 15 * time.Minute,
+	Window:       time.Hour,
+}
+
+// DefaultIPPolicy is more lenient than DefaultIdentityPolicy because
+// several users can share one address.
+var DefaultIPPolicy = Policy{
+	Threshold:    50,
+	BaseDelay:    100 * time.Millisecond,
+	MaxDelay:     10 * time.Second,
+	LockDuration: 15 * time.Minute,
+	Window:       time.Hour,
+}
+
+// State is the failed login history of a subject.
+type State struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// IsLocked reports whether the subject is locked at now.
+func (s State) IsLocked(now time.Time) bool {
+	return now.Before(s.LockedUntil)
+}
+
+// Delay returns the time that has to pass after the last of the given
+// number of failures before another attempt is accepted.
+func (p Policy) Delay(failures int) time.Duration {
+	if failures <= 0 || p.BaseDelay <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for range failures - 1 {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// RetryAfter returns how long the subject has to wait before the next
+// attempt, or zero if it may try now.
+func (p Policy) RetryAfter(state State, now time.Time) time.Duration {
+	if state.IsLocked(now) {
+		return state.LockedUntil.Sub(now)
+	}
+	if state.Failures == 0 || now.Sub(state.LastFailureAt) > p.Window {
+		return 0
+	}
+	return max(state.LastFailureAt.Add(p.Delay(state.Failures)).Sub(now), 0)
+}
+
+// ShouldLock reports whether state has reached the threshold and is not
+// locked already.
+func (p Policy) ShouldLock(state State, now time.Time) bool {
+	return p.Threshold > 0 && state.Failures >= p.Threshold && !state.IsLocked(now)
+}
+
+// IdentityKey identifies the failure history of an identity.
+func IdentityKey(identityID string) string {
+	return "identity:" + identityID
+}
+
+// IPKey identifies the failure history of a client address.
+func IPKey(ipAddress netip.Addr) string {
+	return "ip:" + ipAddress.Unmap().String()
+}
+
+type EventType string
+
+const (
+	EventLocked   EventType = "locked"
+	EventUnlocked EventType = "unlocked"
+)
+
+// Event records a lock or unlock of an identity, so that its owner can see
+// when and why the account was locked.
+type Event struct {
+	ID          string
+	IdentityID  string
+	Type        EventType
+	IPAddress   netip.Addr
+	Actor       string
+	LockedUntil time.Time
+	CreateTime  time.Time
+}
@@ -0,0 +1,72 @@
+package lockout
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PolicyTestSuite struct {
+	suite.Suite
+	policy Policy
+}
+
+func (p *PolicyTestSuite) SetupTest() {
+	p.policy = Policy{
+		Threshold:    3,
+		BaseDelay:    time.Second,
+		MaxDelay:     5 * time.Second,
+		LockDuration: time.Minute,
+		Window:       time.Hour,
+	}
+}
+
+func (p *PolicyTestSuite) TestDelay() {
+	p.Equal(time.Duration(0), p.policy.Delay(0))
+	p.Equal(time.Second, p.policy.Delay(1))
+	p.Equal(2*time.Second, p.policy.Delay(2))
+	p.Equal(4*time.Second, p.policy.Delay(3))
+	p.Equal(5*time.Second, p.policy.Delay(4))
+	p.Equal(5*time.Second, p.policy.Delay(100))
+}
+
+func (p *PolicyTestSuite) TestRetryAfter() {
+	now := time.Now()
+
+	p.Zero(p.policy.RetryAfter(State{}, now))
+
+	// Exponential delay since the last failure
+	state := State{Failures: 2, LastFailureAt: now.Add(-500 * time.Millisecond)}
+	p.Equal(1500*time.Millisecond, p.policy.RetryAfter(state, now))
+
+	// Delay has passed
+	state = State{Failures: 2, LastFailureAt: now.Add(-3 * time.Second)}
+	p.Zero(p.policy.RetryAfter(state, now))
+
+	// Locked
+	state = State{Failures: 3, LastFailureAt: now, LockedUntil: now.Add(time.Minute)}
+	p.Equal(time.Minute, p.policy.RetryAfter(state, now))
+
+	// Failures outside of the window are forgotten
+	state = State{Failures: 2, LastFailureAt: now.Add(-2 * time.Hour)}
+	p.Zero(p.policy.RetryAfter(state, now))
+}
+
+func (p *PolicyTestSuite) TestShouldLock() {
+	now := time.Now()
+	p.False(p.policy.ShouldLock(State{Failures: 2}, now))
+	p.True(p.policy.ShouldLock(State{Failures: 3}, now))
+	p.False(p.policy.ShouldLock(State{Failures: 4, LockedUntil: now.Add(time.Second)}, now))
+	p.False(Policy{}.ShouldLock(State{Failures: 100}, now))
+}
+
+func (p *PolicyTestSuite) TestKeys() {
+	p.Equal("identity:c935b23d-6cb4-448a-814e-b42aec9ef6cf", IdentityKey("c935b23d-6cb4-448a-814e-b42aec9ef6cf"))
+	p.Equal("ip:192.0.2.1", IPKey(netip.MustParseAddr("::ffff:192.0.2.1")))
+}
+
+func TestPolicyTestSuite(t *testing.T) {
+	suite.Run(t, new(PolicyTestSuite))
+}
@@ -0,0 +1,18 @@
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	QueryState(ctx context.Context, key string) (State, error)
+	// IncrementFailures records a failure and restarts the count if the
+	// previous failure is older than window or the key was locked since, so
+	// that the first failure after a lock expires does not lock it again.
+	IncrementFailures(ctx context.Context, key string, window time.Duration) (State, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	ResetFailures(ctx context.Context, key string) error
+	CreateEvent(ctx context.Context, event *Event) error
+	ListEvents(ctx context.Context, identityID string, limit int) ([]Event, error)
+}
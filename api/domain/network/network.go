@@ -0,0 +1,24 @@
+package network
+
+import "net/netip"
+
+const (
+	ipv4PrefixBits = 24
+	ipv6PrefixBits = 64
+)
+
+// ClientNetwork returns the /24 (IPv4) or /64 (IPv6) network of addr, which
+// usually belongs to a single client. Rate limits and registration
+// challenges both group clients by it. An invalid addr is returned as is.
+func ClientNetwork(addr netip.Addr) netip.Prefix {
+	addr = addr.Unmap()
+	bits := ipv6PrefixBits
+	if addr.Is4() {
+		bits = ipv4PrefixBits
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.PrefixFrom(addr, addr.BitLen())
+	}
+	return prefix
+}
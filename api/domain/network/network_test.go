@@ -0,0 +1,22 @@
+package network
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type NetworkTestSuite struct {
+	suite.Suite
+}
+
+func (n *NetworkTestSuite) TestClientNetwork() {
+	n.Equal(netip.MustParsePrefix("192.0.2.0/24"), ClientNetwork(netip.MustParseAddr("::ffff:192.0.2.43")))
+	n.Equal(netip.MustParsePrefix("2001:db8:1:2::/64"), ClientNetwork(netip.MustParseAddr("2001:db8:1:2:3:4:5:6")))
+	n.Equal(netip.PrefixFrom(netip.Addr{}, 0), ClientNetwork(netip.Addr{}))
+}
+
+func TestNetworkTestSuite(t *testing.T) {
+	suite.Run(t, new(NetworkTestSuite))
+}
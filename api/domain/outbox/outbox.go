@@ -0,0 +1,111 @@
+// Package outbox carries the events other registry services subscribe to.
+// Messages are written to the outbox in the same transaction as the change
+// they announce, and delivered afterwards, so that no change is announced
+// without having been made, and none is made without being announced.
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+)
+
+// The types of the messages, in the reverse DNS form of CloudEvents.
+const (
+	TypeIdentityCreated   = "io.mreg.auth.identity.created.v1"
+	TypeIdentitySuspended = "io.mreg.auth.identity.suspended.v1"
+	TypeIdentityDeleted   = "io.mreg.auth.identity.deleted.v1"
+)
+
+type State string
+
+const (
+	StatePending   State = "pending"
+	StateDelivered State = "delivered"
+	// StateDead is reached once a message failed every attempt. It is kept
+	// for an operator to inspect and requeue.
+	StateDead State = "dead"
+)
+
+type Message struct {
+	ID string
+	// Type is one of the Type constants.
+	Type string
+	// Subject is the ID of the resource the message is about.
+	Subject    string
+	Data       json.RawMessage
+	CreateTime time.Time
+	State      State
+	// Attempts counts the deliveries tried so far.
+	Attempts      int
+	NextAttemptAt time.Time
+	// DeliveredTo lists the endpoints that accepted the message, which are
+	// skipped when it is retried.
+	DeliveredTo []string
+	LastError   string
+}
+
+// IdentityData is the data of the identity messages.
+type IdentityData struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+}
+
+// NewIdentityMessage returns a message of eventType about i. It carries no
+// personal data, subscribers ask for what they need.
+func NewIdentityMessage(eventType string, i *identity.Identity) (*Message, error) {
+	data, err := json.Marshal(IdentityData{ID: i.ID, State: i.State.String()})
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Type: eventType, Subject: i.ID, Data: data}, nil
+}
+
+// Delivered reports whether endpoint accepted the message.
+func (m *Message) Delivered(endpoint string) bool {
+	for _, delivered := range m.DeliveredTo {
+		if delivered == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryPolicy decides when a failed message is tried again.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts after which a message is dead.
+	MaxAttempts int
+	// BaseDelay is the delay after the first failure. It doubles with every
+	// further failure.
+	BaseDelay time.Duration
+	// MaxDelay caps the exponential delay.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy gives up after about a day.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 15,
+	BaseDelay:   10 * time.Second,
+	MaxDelay:    4 * time.Hour,
+}
+
+// Delay returns the time to wait after the given number of failed attempts.
+func (p RetryPolicy) Delay(attempts int) time.Duration {
+	if attempts <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for range attempts - 1 {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// Exhausted reports whether a message failed its last attempt.
+func (p RetryPolicy) Exhausted(attempts int) bool {
+	return attempts >= p.MaxAttempts
+}
@@ -0,0 +1,48 @@
+package outbox
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+)
+
+type OutboxTestSuite struct {
+	suite.Suite
+}
+
+func (s *OutboxTestSuite) TestNewIdentityMessage() {
+	message, err := NewIdentityMessage(TypeIdentityCreated, &identity.Identity{
+		ID:     "c935b23d-6cb4-448a-814e-b42aec9ef6cf",
+		State:  identity.StateActive,
+		Emails: []identity.Email{{Value: "user@example.com"}},
+	})
+	s.Require().NoError(err)
+	s.Equal(TypeIdentityCreated, message.Type)
+	s.Equal("c935b23d-6cb4-448a-814e-b42aec9ef6cf", message.Subject)
+	s.JSONEq(`{"id": "c935b23d-6cb4-448a-814e-b42aec9ef6cf", "state": "active"}`, string(message.Data))
+}
+
+func (s *OutboxTestSuite) TestRetryPolicy() {
+	policy := RetryPolicy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	s.Equal(time.Duration(0), policy.Delay(0))
+	s.Equal(time.Second, policy.Delay(1))
+	s.Equal(4*time.Second, policy.Delay(3))
+	s.Equal(5*time.Second, policy.Delay(4))
+	s.Equal(5*time.Second, policy.Delay(100))
+
+	s.False(policy.Exhausted(3))
+	s.True(policy.Exhausted(4))
+}
+
+func (s *OutboxTestSuite) TestDelivered() {
+	message := Message{DeliveredTo: []string{"https://a.example.com/hook"}}
+	s.True(message.Delivered("https://a.example.com/hook"))
+	s.False(message.Delivered("https://b.example.com/hook"))
+}
+
+func TestOutboxTestSuite(t *testing.T) {
+	suite.Run(t, new(OutboxTestSuite))
+}
@@ -0,0 +1,23 @@
+package outbox
+
+import (
+	"context"
+	"time"
+)
+
+// Messages are created by the repositories making the changes they
+// announce.
+type Repository interface {
+	// ClaimMessages returns up to limit pending messages due for delivery,
+	// counting an attempt for each, and keeps them from being claimed again
+	// for lease.
+	ClaimMessages(ctx context.Context, limit int, lease time.Duration) ([]Message, error)
+	// UpdateDelivery stores the state, next attempt, endpoints delivered to
+	// and last error of message.
+	UpdateDelivery(ctx context.Context, message *Message) error
+}
+
+// Publisher delivers a message to one endpoint.
+type Publisher interface {
+	Publish(ctx context.Context, endpoint string, message *Message) error
+}
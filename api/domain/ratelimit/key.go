@@ -0,0 +1,30 @@
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/netip"
+	"strings"
+
+	"gitlab.mreg.io/my-registry/auth/domain/network"
+)
+
+// IPKey identifies the bucket of a single client address.
+func IPKey(scope string, addr netip.Addr) string {
+	return scope + "|ip:" + addr.Unmap().String()
+}
+
+// PrefixKey identifies the bucket shared by the network.ClientNetwork of addr.
+func PrefixKey(scope string, addr netip.Addr) string {
+	if !addr.IsValid() {
+		return IPKey(scope, addr)
+	}
+	return scope + "|prefix:" + network.ClientNetwork(addr).String()
+}
+
+// EmailKey identifies the bucket of a target email address. The address is
+// hashed so that buckets do not store personal data.
+func EmailKey(scope string, email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return scope + "|email:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+// Limit describes a token bucket. A bucket holds at most Burst tokens and
+// regains one token every Period.
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// Enabled reports whether the limit should be enforced. The zero Limit
+// disables rate limiting.
+func (l Limit) Enabled() bool {
+	return l.Burst > 0 && l.Period > 0
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed bool
+	// RetryAfter is how long the caller has to wait for the next token when
+	// the request was not allowed.
+	RetryAfter time.Duration
+}
+
+// Bucket is the persisted state of a token bucket.
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// NewBucket returns a full bucket.
+func NewBucket(now time.Time, limit Limit) *Bucket {
+	return &Bucket{Tokens: float64(limit.Burst), UpdatedAt: now}
+}
+
+func (b *Bucket) refill(now time.Time, limit Limit) {
+	elapsed := now.Sub(b.UpdatedAt)
+	if elapsed <= 0 {
+		return
+	}
+	b.Tokens = min(float64(limit.Burst), b.Tokens+float64(elapsed)/float64(limit.Period))
+	b.UpdatedAt = now
+}
+
+// Take refills the bucket for the time elapsed since UpdatedAt and removes
+// one token if there is one.
+func (b *Bucket) Take(now time.Time, limit Limit) Result {
+	b.refill(now, limit)
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return Result{Allowed: true}
+	}
+	return Result{RetryAfter: time.Duration((1 - b.Tokens) * float64(limit.Period))}
+}
+
+// Refund refills the bucket for the time elapsed since UpdatedAt and gives
+// back one token taken for a request that was rejected by another bucket.
+func (b *Bucket) Refund(now time.Time, limit Limit) {
+	b.refill(now, limit)
+	b.Tokens = min(float64(limit.Burst), b.Tokens+1)
+}
+
+// FullAt returns the time at which the bucket will be full again. After
+// that it is indistinguishable from a new bucket and can be discarded.
+func (b *Bucket) FullAt(limit Limit) time.Time {
+	missing := float64(limit.Burst) - b.Tokens
+	return b.UpdatedAt.Add(time.Duration(missing * float64(limit.Period)))
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RateLimitTestSuite struct {
+	suite.Suite
+}
+
+func (r *RateLimitTestSuite) TestLimit_Enabled() {
+	r.False(Limit{}.Enabled())
+	r.False(Limit{Burst: 1}.Enabled())
+	r.True(Limit{Burst: 1, Period: time.Second}.Enabled())
+}
+
+func (r *RateLimitTestSuite) TestBucket_Take() {
+	now := time.Now()
+	limit := Limit{Burst: 2, Period: 10 * time.Second}
+	bucket := NewBucket(now, limit)
+
+	// Burst is allowed immediately
+	r.True(bucket.Take(now, limit).Allowed)
+	r.True(bucket.Take(now, limit).Allowed)
+
+	// Third request has to wait a full period
+	result := bucket.Take(now, limit)
+	r.False(result.Allowed)
+	r.Equal(10*time.Second, result.RetryAfter)
+
+	// Half a period later only half a token has been refilled
+	result = bucket.Take(now.Add(5*time.Second), limit)
+	r.False(result.Allowed)
+	r.Equal(5*time.Second, result.RetryAfter)
+
+	// A full period later the request is allowed again
+	r.True(bucket.Take(now.Add(10*time.Second), limit).Allowed)
+}
+
+func (r *RateLimitTestSuite) TestBucket_RefillIsCapped() {
+	now := time.Now()
+	limit := Limit{Burst: 2, Period: time.Second}
+	bucket := NewBucket(now, limit)
+	r.True(bucket.Take(now, limit).Allowed)
+
+	bucket.Take(now.Add(time.Hour), limit)
+	r.InDelta(1, bucket.Tokens, 0.0001)
+	r.Equal(now.Add(time.Hour+time.Second), bucket.FullAt(limit))
+}
+
+func (r *RateLimitTestSuite) TestBucket_Refund() {
+	now := time.Now()
+	limit := Limit{Burst: 2, Period: time.Minute}
+	bucket := NewBucket(now, limit)
+	r.True(bucket.Take(now, limit).Allowed)
+	r.True(bucket.Take(now, limit).Allowed)
+
+	bucket.Refund(now, limit)
+	r.True(bucket.Take(now, limit).Allowed)
+	r.False(bucket.Take(now, limit).Allowed)
+
+	// Refunds do not overfill the bucket
+	bucket.Refund(now.Add(time.Hour), limit)
+	r.InDelta(2, bucket.Tokens, 0.0001)
+}
+
+func (r *RateLimitTestSuite) TestPrefixKey() {
+	r.Equal("scope|prefix:192.0.2.0/24", PrefixKey("scope", netip.MustParseAddr("192.0.2.43")))
+	r.Equal("scope|prefix:192.0.2.0/24", PrefixKey("scope", netip.MustParseAddr("::ffff:192.0.2.43")))
+	r.Equal("scope|prefix:2001:db8:1:2::/64", PrefixKey("scope", netip.MustParseAddr("2001:db8:1:2:3:4:5:6")))
+}
+
+func (r *RateLimitTestSuite) TestEmailKey() {
+	key := EmailKey("scope", "Test@Example.com ")
+	r.Equal(EmailKey("scope", "test@example.com"), key)
+	r.NotContains(key, "example.com")
+}
+
+func TestRateLimitTestSuite(t *testing.T) {
+	suite.Run(t, new(RateLimitTestSuite))
+}
@@ -0,0 +1,12 @@
+package ratelimit
+
+import "context"
+
+type Repository interface {
+	// Take removes one token from the bucket identified by key, creating a
+	// full bucket if it does not exist yet.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Refund gives back a token taken from the bucket identified by key,
+	// when the request it was taken for is rejected by another bucket.
+	Refund(ctx context.Context, key string, limit Limit) error
+}
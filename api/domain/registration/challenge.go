@@ -0,0 +1,103 @@
+package registration
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"math/bits"
+	"net/netip"
+	"time"
+)
+
+// maxSolutionLength bounds the work spent on hashing a submitted solution.
+const maxSolutionLength = 64
+
+// Challenge is a Hashcash-style proof of work attached to a flow. The client
+// has to find a solution such that SHA-256("<flow ID>:<seed>:<solution>")
+// starts with Difficulty zero bits.
+type Challenge struct {
+	Seed       string
+	Difficulty uint8
+}
+
+// NewChallenge returns a challenge with a random seed.
+func NewChallenge(difficulty uint8) (*Challenge, error) {
+	seed := make([]byte, 16)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	return &Challenge{Seed: base64.RawURLEncoding.EncodeToString(seed), Difficulty: difficulty}, nil
+}
+
+// Verify checks a solution for the flow with the given ID. Binding the hash
+// to the flow ID prevents a solution from being reused for another flow.
+func (c *Challenge) Verify(flowID string, solution string) bool {
+	if solution == "" || len(solution) > maxSolutionLength {
+		return false
+	}
+	sum := sha256.Sum256([]byte(flowID + ":" + c.Seed + ":" + solution))
+	return leadingZeroBits(sum[:]) >= int(c.Difficulty)
+}
+
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, x := range b {
+		if x != 0 {
+			return n + bits.LeadingZeros8(x)
+		}
+		n += 8
+	}
+	return n
+}
+
+// ChallengePolicy adapts the proof of work difficulty to the number of flows
+// recently created from the client's network. The zero value disables
+// challenges.
+type ChallengePolicy struct {
+	// BaseDifficulty is required from every client.
+	BaseDifficulty uint8
+	// MaxDifficulty caps the adaptive difficulty.
+	MaxDifficulty uint8
+	// Threshold is the number of flows from one network within Window after
+	// which every doubling of the volume adds one bit of difficulty.
+	Threshold int
+	// Window is the period over which flows are counted.
+	Window time.Duration
+}
+
+// DefaultChallengePolicy requires about 65 thousand hashes, and up to
+// 16 million under heavy load from a single network.
+var DefaultChallengePolicy = ChallengePolicy{
+	BaseDifficulty: 16,
+	MaxDifficulty:  24,
+	Threshold:      20,
+	Window:         10 * time.Minute,
+}
+
+// Enabled reports whether flows should carry a challenge.
+func (p ChallengePolicy) Enabled() bool {
+	return p.MaxDifficulty > 0
+}
+
+// Difficulty returns the difficulty for a network that has created
+// recentFlows flows within Window.
+func (p ChallengePolicy) Difficulty(recentFlows int) uint8 {
+	difficulty := int(p.BaseDifficulty)
+	if p.Threshold > 0 {
+		for n := recentFlows / p.Threshold; n > 0; n /= 2 {
+			difficulty++
+		}
+	}
+	return uint8(min(difficulty, int(p.MaxDifficulty)))
+}
+
+// ErrCaptchaFailed is returned by a CaptchaVerifier when the token is
+// missing, invalid or expired.
+var ErrCaptchaFailed = errors.New("captcha verification failed")
+
+// CaptchaVerifier checks a CAPTCHA response token with its provider.
+type CaptchaVerifier interface {
+	Verify(ctx context.Context, token string, remoteIP netip.Addr) error
+}
@@ -0,0 +1,71 @@
+package registration
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ChallengeTestSuite struct {
+	suite.Suite
+}
+
+// solve finds a solution by brute force, as a client would.
+func solve(flowID string, challenge *Challenge) string {
+	for i := 0; ; i++ {
+		solution := strconv.Itoa(i)
+		if challenge.Verify(flowID, solution) {
+			return solution
+		}
+	}
+}
+
+func (c *ChallengeTestSuite) TestNewChallenge() {
+	challenge1, err := NewChallenge(8)
+	c.Require().NoError(err)
+	challenge2, err := NewChallenge(8)
+	c.Require().NoError(err)
+	c.Equal(uint8(8), challenge1.Difficulty)
+	c.NotEmpty(challenge1.Seed)
+	c.NotEqual(challenge1.Seed, challenge2.Seed, "seeds must be unique")
+}
+
+func (c *ChallengeTestSuite) TestVerify() {
+	flowID := "c935b23d-6cb4-448a-814e-b42aec9ef6cf"
+	challenge := &Challenge{Seed: "IFFnXxZ3ifNjthGGqgYmQw", Difficulty: 12}
+
+	solution := solve(flowID, challenge)
+	c.True(challenge.Verify(flowID, solution))
+
+	// Solutions are bound to the flow and the seed
+	c.False(challenge.Verify("269e873b-38ee-4904-bb4f-4207a33137df", solution))
+	c.False((&Challenge{Seed: "r6rFpcJ6D0zvkgQqM0Qwjw", Difficulty: 12}).Verify(flowID, solution))
+	c.False(challenge.Verify(flowID, ""))
+}
+
+func (c *ChallengeTestSuite) TestLeadingZeroBits() {
+	c.Equal(0, leadingZeroBits([]byte{0x80}))
+	c.Equal(3, leadingZeroBits([]byte{0x10, 0xff}))
+	c.Equal(12, leadingZeroBits([]byte{0x00, 0x08}))
+	c.Equal(16, leadingZeroBits([]byte{0x00, 0x00}))
+}
+
+func (c *ChallengeTestSuite) TestPolicy_Difficulty() {
+	policy := ChallengePolicy{BaseDifficulty: 16, MaxDifficulty: 20, Threshold: 10, Window: time.Minute}
+	c.True(policy.Enabled())
+	c.Equal(uint8(16), policy.Difficulty(0))
+	c.Equal(uint8(16), policy.Difficulty(9))
+	c.Equal(uint8(17), policy.Difficulty(10))
+	c.Equal(uint8(18), policy.Difficulty(20))
+	c.Equal(uint8(18), policy.Difficulty(39))
+	c.Equal(uint8(19), policy.Difficulty(40))
+	c.Equal(uint8(20), policy.Difficulty(10000))
+
+	c.False(ChallengePolicy{}.Enabled())
+}
+
+func TestChallengeTestSuite(t *testing.T) {
+	suite.Run(t, new(ChallengeTestSuite))
+}
@@ -19,6 +19,11 @@ type Flow struct {
 	Password  string
 	Interval  time.Duration
 	Identity  *identity.Identity
+	Challenge *Challenge
+
+	// Solution and CaptchaToken are submitted with the flow, like Password.
+	Solution     string
+	CaptchaToken string
 }
 
 var crcTable = crc32.MakeTable(crc32.IEEE)
@@ -34,12 +39,10 @@ func (f *Flow) ETag() (string, error) {
 	var buffer bytes.Buffer
 	encoder := gob.NewEncoder(&buffer)
 	if err := encoder.Encode(f.ExpiresAt); err != nil {
-		fmt.Println("Error encoding ExpiresAt:", err)
-		return "", err
+		return "", fmt.Errorf("encoding ExpiresAt: %w", err)
 	}
 	if err := encoder.Encode(f.SessionID); err != nil {
-		fmt.Println("Error encoding SessionID:", err)
-		return "", err
+		return "", fmt.Errorf("encoding SessionID: %w", err)
 	}
 	// Compute the CRC32 checksum
 	checksum := crc32.Checksum(buffer.Bytes(), crcTable)
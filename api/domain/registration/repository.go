@@ -1,8 +1,15 @@
 package registration
 
-import "context"
+import (
+	"context"
+	"net/netip"
+	"time"
+)
 
 type Repository interface {
 	CreateFlow(ctx context.Context, flow *Flow) error
 	QueryFlowByFlowID(ctx context.Context, flow *Flow) error
+	// CountRecentFlows counts flows issued since the given time to sessions
+	// with a device in network.
+	CountRecentFlows(ctx context.Context, network netip.Prefix, since time.Time) (int, error)
 }
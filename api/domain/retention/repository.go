@@ -0,0 +1,33 @@
+// Package retention removes the rows that are of no use once they have
+// expired: registration flows, sessions along with their devices, and the
+// outbox messages that were delivered or are dead.
+package retention
+
+import (
+	"context"
+	"time"
+)
+
+// LeaseName is the lease held by the replica deleting expired rows.
+const LeaseName = "retention"
+
+type Repository interface {
+	// AcquireLease takes or renews the lease name for holder until duration
+	// from now, and reports whether holder has it. A lease is only taken
+	// from another holder once it has expired.
+	AcquireLease(ctx context.Context, name string, holder string, duration time.Duration) (bool, error)
+	// DeleteExpiredFlows deletes up to limit registration flows that expired
+	// before before, and returns how many were deleted.
+	DeleteExpiredFlows(ctx context.Context, before time.Time, limit int) (int, error)
+	// DeleteExpiredSessions deletes up to limit anonymous sessions that
+	// expired before before, and sessions of identities that expired before
+	// historyBefore, together with their devices and flows, and returns how
+	// many sessions were deleted. historyBefore is not after before, as the
+	// devices of identities are the history the risk checks compare new
+	// devices with.
+	DeleteExpiredSessions(ctx context.Context, before time.Time, historyBefore time.Time, limit int) (int, error)
+	// DeleteFinishedMessages deletes up to limit outbox messages that were
+	// delivered or died before before, and returns how many were deleted.
+	// Pending messages are never deleted.
+	DeleteFinishedMessages(ctx context.Context, before time.Time, limit int) (int, error)
+}
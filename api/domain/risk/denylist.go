@@ -0,0 +1,52 @@
+package risk
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+)
+
+// Denylist holds addresses that are never trusted, such as known abusive
+// networks.
+type Denylist interface {
+	Contains(addr netip.Addr) bool
+}
+
+// PrefixList is a Denylist of networks.
+type PrefixList []netip.Prefix
+
+// ParsePrefixList reads one address or CIDR network per line. Empty lines
+// and text after # are ignored.
+func ParsePrefixList(r io.Reader) (PrefixList, error) {
+	var list PrefixList
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		entry, _, _ := strings.Cut(scanner.Text(), "#")
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			list = append(list, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		list = append(list, prefix.Masked())
+	}
+	return list, scanner.Err()
+}
+
+func (l PrefixList) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range l {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
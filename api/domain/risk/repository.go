@@ -0,0 +1,13 @@
+package risk
+
+import (
+	"context"
+
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+)
+
+type Repository interface {
+	// QueryHistory summarizes the devices of identityID, comparing them with
+	// location.
+	QueryHistory(ctx context.Context, identityID string, location session.Location) (History, error)
+}
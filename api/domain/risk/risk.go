@@ -0,0 +1,187 @@
+// Package risk scores the devices sessions are used from, and decides
+// whether they are allowed, have to step up authentication or are blocked.
+package risk
+
+import (
+	"math"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+)
+
+type Decision string
+
+const (
+	DecisionAllow  Decision = "allow"
+	DecisionStepUp Decision = "step_up"
+	DecisionBlock  Decision = "block"
+)
+
+// Reason is a signal that raised the score of a device.
+type Reason string
+
+const (
+	// ReasonImpossibleTravel is reported when the device is farther from the
+	// previous device of the identity than anyone could have traveled since.
+	ReasonImpossibleTravel Reason = "impossible_travel"
+	// ReasonNewCountry is reported for a country the identity never used.
+	ReasonNewCountry Reason = "new_country"
+	// ReasonNewASN is reported for a network the identity never used.
+	ReasonNewASN Reason = "new_asn"
+	// ReasonDenylistedIP is reported for addresses on the denylist.
+	ReasonDenylistedIP Reason = "denylisted_ip"
+	// ReasonFailedLogins is reported when the identity or the address has
+	// recently failed to log in repeatedly.
+	ReasonFailedLogins Reason = "failed_logins"
+)
+
+// Policy weighs the reasons and turns the score into a decision.
+type Policy struct {
+	// Weights is added to the score for every reason found.
+	Weights map[Reason]int
+	// StepUpThreshold is the score from which authentication has to be
+	// stepped up.
+	StepUpThreshold int
+	// BlockThreshold is the score from which the device is blocked.
+	BlockThreshold int
+	// MaxTravelSpeed is the speed in km/h above which travel between two
+	// devices is impossible.
+	MaxTravelSpeed float64
+	// FailedLogins is the number of failures within FailedLoginWindow
+	// that counts as ReasonFailedLogins.
+	FailedLogins      int
+	FailedLoginWindow time.Duration
+}
+
+// DefaultPolicy steps up on impossible travel, or on a new country together
+// with failed logins, and blocks denylisted addresses.
+var DefaultPolicy = Policy{
+	Weights: map[Reason]int{
+		ReasonImpossibleTravel: 60,
+		ReasonNewCountry:       25,
+		ReasonNewASN:           10,
+		ReasonDenylistedIP:     100,
+		ReasonFailedLogins:     30,
+	},
+	StepUpThreshold:   50,
+	BlockThreshold:    100,
+	MaxTravelSpeed:    1000,
+	FailedLogins:      5,
+	FailedLoginWindow: 24 * time.Hour,
+}
+
+// Decide returns the decision for score.
+func (p Policy) Decide(score int) Decision {
+	switch {
+	case p.BlockThreshold > 0 && score >= p.BlockThreshold:
+		return DecisionBlock
+	case p.StepUpThreshold > 0 && score >= p.StepUpThreshold:
+		return DecisionStepUp
+	default:
+		return DecisionAllow
+	}
+}
+
+// Assessment is the result of scoring a device.
+type Assessment struct {
+	Score    int
+	Reasons  []Reason
+	Decision Decision
+}
+
+// Risk returns the form of the assessment stored with a device.
+func (a Assessment) Risk() session.Risk {
+	reasons := make([]string, len(a.Reasons))
+	for i, reason := range a.Reasons {
+		reasons[i] = string(reason)
+	}
+	return session.Risk{Score: a.Score, Decision: string(a.Decision), Reasons: reasons}
+}
+
+// Sighting is a device of an identity seen at some time.
+type Sighting struct {
+	Location session.Location
+	SeenAt   time.Time
+}
+
+// History summarizes the devices an identity used before.
+type History struct {
+	// Devices is the number of devices of the identity. Nothing is new to
+	// an identity without devices.
+	Devices int
+	// Last is the most recent device, nil when Devices is zero.
+	Last *Sighting
+	// KnownCountry and KnownASN report whether a device of the identity was
+	// in the country or network of the assessed device.
+	KnownCountry bool
+	KnownASN     bool
+}
+
+// earthRadius is the mean radius of the Earth in km.
+const earthRadius = 6371.0
+
+// distance returns the great-circle distance in km between two locations,
+// less their accuracy radii, so that imprecise locations are never far
+// apart.
+func distance(a, b session.Location) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+	d := 2 * earthRadius * math.Asin(math.Sqrt(h))
+	return max(d-float64(a.AccuracyRadius)-float64(b.AccuracyRadius), 0)
+}
+
+// minTravelTime is the least time assumed between two sightings, so that
+// nearby devices seen at once are not moving at infinite speed.
+const minTravelTime = time.Minute
+
+// impossibleTravel reports whether going from last to location at now is
+// faster than maxSpeed.
+func impossibleTravel(last Sighting, location session.Location, now time.Time, maxSpeed float64) bool {
+	if maxSpeed <= 0 || !last.Location.HasCoordinates() || !location.HasCoordinates() {
+		return false
+	}
+	elapsed := max(now.Sub(last.SeenAt), minTravelTime)
+	return distance(last.Location, location)/elapsed.Hours() > maxSpeed
+}
+
+// Input holds the signals gathered about a device.
+type Input struct {
+	Location session.Location
+	// History is empty for devices of anonymous sessions.
+	History    History
+	Denylisted bool
+	// RecentFailures is the highest number of failed logins of the identity
+	// or of the address within FailedLoginWindow.
+	RecentFailures int
+	Now            time.Time
+}
+
+// Evaluate scores the device described by in.
+func (p Policy) Evaluate(in Input) Assessment {
+	var reasons []Reason
+	if in.Denylisted {
+		reasons = append(reasons, ReasonDenylistedIP)
+	}
+	if in.History.Devices > 0 {
+		if in.History.Last != nil && impossibleTravel(*in.History.Last, in.Location, in.Now, p.MaxTravelSpeed) {
+			reasons = append(reasons, ReasonImpossibleTravel)
+		}
+		if in.Location.CountryCode != "" && !in.History.KnownCountry {
+			reasons = append(reasons, ReasonNewCountry)
+		}
+		if in.Location.ASN != 0 && !in.History.KnownASN {
+			reasons = append(reasons, ReasonNewASN)
+		}
+	}
+	if p.FailedLogins > 0 && in.RecentFailures >= p.FailedLogins {
+		reasons = append(reasons, ReasonFailedLogins)
+	}
+
+	score := 0
+	for _, reason := range reasons {
+		score += p.Weights[reason]
+	}
+	return Assessment{Score: score, Reasons: reasons, Decision: p.Decide(score)}
+}
@@ -0,0 +1,103 @@
+package risk
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+)
+
+var (
+	london = session.Location{CountryCode: "GB", City: "London", ASN: 20712, Latitude: 51.5142, Longitude: -0.0931, AccuracyRadius: 20}
+	paris  = session.Location{CountryCode: "FR", City: "Paris", ASN: 3215, Latitude: 48.8582, Longitude: 2.3387, AccuracyRadius: 20}
+	taipei = session.Location{CountryCode: "TW", City: "Taipei", ASN: 3462, Latitude: 25.0478, Longitude: 121.5318, AccuracyRadius: 20}
+)
+
+type RiskTestSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func (s *RiskTestSuite) SetupTest() {
+	s.now = time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+}
+
+func (s *RiskTestSuite) history(last session.Location, ago time.Duration) History {
+	return History{Devices: 1, Last: &Sighting{Location: last, SeenAt: s.now.Add(-ago)}, KnownCountry: true, KnownASN: true}
+}
+
+func (s *RiskTestSuite) TestDistance() {
+	// About 9,800 km, less both accuracy radii
+	s.InDelta(9760, distance(london, taipei), 50)
+	s.InDelta(0, distance(london, london), 0)
+}
+
+func (s *RiskTestSuite) TestImpossibleTravel() {
+	// London to Taipei in two hours is impossible, in a day it is not
+	assessment := DefaultPolicy.Evaluate(Input{Location: taipei, History: s.history(london, 2*time.Hour), Now: s.now})
+	s.Contains(assessment.Reasons, ReasonImpossibleTravel)
+	s.Equal(DecisionStepUp, assessment.Decision)
+
+	assessment = DefaultPolicy.Evaluate(Input{Location: taipei, History: s.history(london, 24*time.Hour), Now: s.now})
+	s.NotContains(assessment.Reasons, ReasonImpossibleTravel)
+
+	// London to Paris in two hours is possible
+	assessment = DefaultPolicy.Evaluate(Input{Location: paris, History: s.history(london, 2*time.Hour), Now: s.now})
+	s.Equal(Assessment{Decision: DecisionAllow}, assessment)
+
+	// Locations without coordinates never move
+	assessment = DefaultPolicy.Evaluate(Input{Location: session.Location{CountryCode: "TW"}, History: s.history(london, time.Minute), Now: s.now})
+	s.NotContains(assessment.Reasons, ReasonImpossibleTravel)
+}
+
+func (s *RiskTestSuite) TestNewCountryAndASN() {
+	history := s.history(london, 24*time.Hour)
+	history.KnownCountry = false
+	history.KnownASN = false
+	assessment := DefaultPolicy.Evaluate(Input{Location: taipei, History: history, Now: s.now})
+	s.Equal([]Reason{ReasonNewCountry, ReasonNewASN}, assessment.Reasons)
+	s.Equal(35, assessment.Score)
+	s.Equal(DecisionAllow, assessment.Decision)
+
+	// Together with failed logins, a new country steps up
+	assessment = DefaultPolicy.Evaluate(Input{Location: taipei, History: history, RecentFailures: 5, Now: s.now})
+	s.Equal(DecisionStepUp, assessment.Decision)
+
+	// Nothing is new to an identity without devices
+	assessment = DefaultPolicy.Evaluate(Input{Location: taipei, Now: s.now})
+	s.Empty(assessment.Reasons)
+}
+
+func (s *RiskTestSuite) TestDenylist() {
+	list, err := ParsePrefixList(strings.NewReader("# Abusive networks\n192.0.2.0/24\n\n2001:db8::1 # single host\n"))
+	s.Require().NoError(err)
+	s.True(list.Contains(netip.MustParseAddr("192.0.2.77")))
+	s.True(list.Contains(netip.MustParseAddr("::ffff:192.0.2.77")))
+	s.True(list.Contains(netip.MustParseAddr("2001:db8::1")))
+	s.False(list.Contains(netip.MustParseAddr("2001:db8::2")))
+
+	_, err = ParsePrefixList(strings.NewReader("192.0.2.0/24\nnot an address\n"))
+	s.ErrorContains(err, "line 2")
+
+	assessment := DefaultPolicy.Evaluate(Input{Denylisted: true, Now: s.now})
+	s.Equal(DecisionBlock, assessment.Decision)
+	s.Equal(session.Risk{Score: 100, Decision: "block", Reasons: []string{"denylisted_ip"}}, assessment.Risk())
+}
+
+func (s *RiskTestSuite) TestDecide() {
+	policy := Policy{StepUpThreshold: 50, BlockThreshold: 100}
+	s.Equal(DecisionAllow, policy.Decide(49))
+	s.Equal(DecisionStepUp, policy.Decide(50))
+	s.Equal(DecisionBlock, policy.Decide(100))
+
+	// Thresholds left at zero are disabled
+	s.Equal(DecisionAllow, Policy{}.Decide(1000))
+}
+
+func TestRiskTestSuite(t *testing.T) {
+	suite.Run(t, new(RiskTestSuite))
+}
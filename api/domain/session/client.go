@@ -0,0 +1,91 @@
+package session
+
+import (
+	"strconv"
+
+	"github.com/mileusna/useragent"
+)
+
+// Device types reported by ParseUserAgent.
+const (
+	DeviceTypeDesktop = "desktop"
+	DeviceTypeMobile  = "mobile"
+	DeviceTypeTablet  = "tablet"
+	DeviceTypeBot     = "bot"
+	DeviceTypeUnknown = "unknown"
+)
+
+// Client is the software a device uses, as parsed from its User-Agent.
+type Client struct {
+	// Browser is the browser family, such as "Firefox".
+	Browser string
+	// BrowserVersion is the full browser version, such as "131.0".
+	BrowserVersion string
+	// OS is the operating system family, such as "Linux".
+	OS        string
+	OSVersion string
+	// DeviceType is one of the DeviceType constants.
+	DeviceType string
+}
+
+// ParseUserAgent extracts the browser, operating system and device type
+// from a User-Agent header. Unrecognized parts are left empty.
+func ParseUserAgent(userAgent string) Client {
+	ua := useragent.Parse(userAgent)
+	client := Client{
+		Browser:        ua.Name,
+		BrowserVersion: ua.Version,
+		OS:             ua.OS,
+		OSVersion:      ua.OSVersion,
+		DeviceType:     DeviceTypeUnknown,
+	}
+	switch {
+	case ua.Bot:
+		client.DeviceType = DeviceTypeBot
+	case ua.Tablet:
+		client.DeviceType = DeviceTypeTablet
+	case ua.Mobile:
+		client.DeviceType = DeviceTypeMobile
+	case ua.Desktop:
+		client.DeviceType = DeviceTypeDesktop
+	}
+	return client
+}
+
+// Known reports whether the browser family could be parsed.
+func (c Client) Known() bool {
+	return c.Browser != ""
+}
+
+// SameFamily reports whether c and other are the same browser on the same
+// operating system and kind of device, whatever their versions.
+func (c Client) SameFamily(other Client) bool {
+	return c.Browser == other.Browser && c.OS == other.OS && c.DeviceType == other.DeviceType
+}
+
+// String returns a readable name such as "Firefox 131 on Linux".
+func (c Client) String() string {
+	if !c.Known() {
+		if c.OS != "" {
+			return "Unknown browser on " + c.OS
+		}
+		return "Unknown browser"
+	}
+	name := c.Browser
+	if major := c.majorVersion(); major > 0 {
+		name += " " + strconv.Itoa(major)
+	}
+	if c.OS != "" {
+		name += " on " + c.OS
+	}
+	return name
+}
+
+func (c Client) majorVersion() int {
+	end := 0
+	for end < len(c.BrowserVersion) && c.BrowserVersion[end] >= '0' && c.BrowserVersion[end] <= '9' {
+		end++
+	}
+	major, _ := strconv.Atoi(c.BrowserVersion[:end])
+	return major
+}
@@ -5,9 +5,65 @@ import (
 )
 
 type Device struct {
-	ID          string
-	IPAddress   netip.Addr
+	ID        string
+	IPAddress netip.Addr
+	// GeoLocation is the display form of Location.
 	GeoLocation string
-	UserAgent   string
-	SessionID   string
+	Location    Location
+	// UserAgent is the raw header, and Client its parsed form.
+	UserAgent string
+	Client    Client
+	SessionID string
+	// Risk is the assessment of the device when it was added.
+	Risk Risk
+}
+
+// Risk is the stored form of a risk assessment, kept with the device for
+// audits.
+type Risk struct {
+	Score int
+	// Decision is "allow", "step_up" or "block", and empty for devices
+	// added before risks were assessed.
+	Decision string
+	Reasons  []string
+}
+
+// NewDevice describes a device from the request it sent.
+func NewDevice(ipAddress netip.Addr, userAgent string) Device {
+	return Device{IPAddress: ipAddress, UserAgent: userAgent, Client: ParseUserAgent(userAgent)}
+}
+
+// DisplayName returns a readable name for the device, such as
+// "Firefox 131 on Linux".
+func (d *Device) DisplayName() string {
+	return d.client().String()
+}
+
+// client returns Client, parsing UserAgent for devices stored before user
+// agents were parsed.
+func (d *Device) client() Client {
+	if d.Client.Known() || d.UserAgent == "" {
+		return d.Client
+	}
+	return ParseUserAgent(d.UserAgent)
+}
+
+// Matches reports whether other is the same device as d. Browser updates do
+// not make a new device: clients are compared by family, and only user
+// agents that cannot be parsed are compared as strings.
+func (d *Device) Matches(other *Device) bool {
+	if d.IPAddress != other.IPAddress {
+		return false
+	}
+	client, otherClient := d.client(), other.client()
+	if !client.Known() || !otherClient.Known() {
+		return d.UserAgent == other.UserAgent
+	}
+	return client.SameFamily(otherClient)
+}
+
+// SetLocation stores location and its display form.
+func (d *Device) SetLocation(location Location) {
+	d.Location = location
+	d.GeoLocation = location.String()
 }
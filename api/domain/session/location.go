@@ -0,0 +1,56 @@
+package session
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+)
+
+// maxGeoLocationLength is the size of the devices.geo_location column.
+const maxGeoLocationLength = 64
+
+// Location is the approximate position and network of a device address.
+// Fields the database does not know are left empty.
+type Location struct {
+	// CountryCode is the ISO 3166-1 alpha-2 code of the country.
+	CountryCode string
+	// Region is the name of the largest subdivision, such as a state.
+	Region string
+	City   string
+	// ASN is the number of the autonomous system announcing the address.
+	ASN uint32
+	// ASOrganization is the name of the organization owning ASN.
+	ASOrganization string
+	// Latitude and Longitude are the approximate position in degrees, within
+	// AccuracyRadius km. They are only known when AccuracyRadius is not zero.
+	Latitude       float64
+	Longitude      float64
+	AccuracyRadius uint16
+}
+
+// HasCoordinates reports whether Latitude and Longitude are known.
+func (l Location) HasCoordinates() bool {
+	return l.AccuracyRadius > 0
+}
+
+// String formats the location as "City, Region, CC", skipping unknown
+// parts, for display next to a device.
+func (l Location) String() string {
+	var parts []string
+	for _, part := range []string{l.City, l.Region, l.CountryCode} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	s := strings.Join(parts, ", ")
+	if len(s) > maxGeoLocationLength {
+		s = strings.ToValidUTF8(s[:maxGeoLocationLength], "")
+	}
+	return s
+}
+
+// GeoResolver looks up the location of an address. Addresses that are not
+// in its database resolve to the zero Location.
+type GeoResolver interface {
+	Lookup(ctx context.Context, addr netip.Addr) (Location, error)
+}
@@ -0,0 +1,30 @@
+package session
+
+import (
+	"context"
+	"time"
+)
+
+// NewDeviceNotice tells the owner of an identity that it signed in from a
+// device never seen before.
+type NewDeviceNotice struct {
+	// Email is the verified address the notice is sent to.
+	Email string
+	Time  time.Time
+	// Location is the display form of the device location, empty when
+	// unknown.
+	Location string
+	// Device is the display name of the device, such as "Firefox 131 on
+	// Linux".
+	Device string
+	// RevokeURL signs the session out in one click.
+	RevokeURL string
+	// Locale is the language the notice is written in, such as "fr". Empty
+	// for the default language.
+	Locale string
+}
+
+// Notifier delivers new device notices.
+type Notifier interface {
+	NotifyNewDevice(ctx context.Context, notice NewDeviceNotice) error
+}
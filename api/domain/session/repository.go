@@ -6,7 +6,11 @@ import (
 
 type Repository interface {
 	CreateSession(ctx context.Context, session *Session) error
+	DeleteSession(ctx context.Context, sessionID string) error
 	QuerySessionByID(ctx context.Context, session *Session) error
 	QuerySessionWithDevices(ctx context.Context, session *Session) error
 	InsertDevice(ctx context.Context, newDevice *Device) error
+	// RememberDevice records the browser family, OS and country of device as
+	// known for identityID, and reports whether they were new.
+	RememberDevice(ctx context.Context, identityID string, device *Device) (bool, error)
 }
@@ -0,0 +1,64 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRevocationToken is returned for tokens that were not signed with
+// the key, were altered or have expired.
+var ErrInvalidRevocationToken = errors.New("invalid session revocation token")
+
+// RevocationSigner signs the tokens of the links that revoke a session
+// without signing in. A token names one session and expires with it.
+type RevocationSigner struct {
+	key []byte
+}
+
+// NewRevocationSigner creates a signer using key for HMAC-SHA256. The key
+// should be at least 32 random bytes.
+func NewRevocationSigner(key []byte) *RevocationSigner {
+	return &RevocationSigner{key}
+}
+
+// Sign returns a token revoking sessionID until expiresAt.
+func (r *RevocationSigner) Sign(sessionID string, expiresAt time.Time) string {
+	payload := sessionID + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(r.mac(payload))
+}
+
+// Verify returns the session ID of token if it is valid at now.
+func (r *RevocationSigner) Verify(token string, now time.Time) (string, error) {
+	encodedPayload, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidRevocationToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", ErrInvalidRevocationToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, r.mac(string(payload))) {
+		return "", ErrInvalidRevocationToken
+	}
+	sessionID, expiry, ok := strings.Cut(string(payload), ".")
+	if !ok {
+		return "", ErrInvalidRevocationToken
+	}
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || now.Unix() >= expiresAt {
+		return "", ErrInvalidRevocationToken
+	}
+	return sessionID, nil
+}
+
+func (r *RevocationSigner) mac(payload string) []byte {
+	h := hmac.New(sha256.New, r.key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
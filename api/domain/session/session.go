@@ -58,7 +58,7 @@ func (s *Session) IsExpired() bool {
 
 func (s *Session) DeviceExists(userDevice *Device) bool {
 	for _, device := range s.Devices {
-		if device.IPAddress == userDevice.IPAddress && device.UserAgent == userDevice.UserAgent {
+		if device.Matches(userDevice) {
 			return true
 		}
 	}
@@ -2,8 +2,10 @@ package session
 
 import (
 	"net/netip"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/suite"
 	"gitlab.mreg.io/my-registry/auth/domain/identity"
@@ -161,6 +163,105 @@ func (s *SessionTestSuite) TestSessionETag_AddDevice() {
 	s.Require().Contains(etag2, "W/\"", "ETag should be in the weak format")
 }
 
+func (s *SessionTestSuite) TestDeviceLocation() {
+	device := Device{}
+	device.SetLocation(Location{CountryCode: "TW", Region: "Taipei City", City: "Taipei", ASN: 3462})
+	s.Equal("Taipei, Taipei City, TW", device.GeoLocation)
+
+	device.SetLocation(Location{CountryCode: "US"})
+	s.Equal("US", device.GeoLocation)
+
+	device.SetLocation(Location{})
+	s.Empty(device.GeoLocation)
+
+	// Long names are cut to the column size without splitting characters
+	device.SetLocation(Location{City: strings.Repeat("é", 40)})
+	s.Len(device.GeoLocation, 64)
+	s.True(utf8.ValidString(device.GeoLocation))
+}
+
+const (
+	firefox130 = "Mozilla/5.0 (X11; Linux x86_64; rv:130.0) Gecko/20100101 Firefox/130.0"
+	firefox131 = "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0"
+	iPhone     = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.6 Mobile/15E148 Safari/604.1"
+)
+
+func (s *SessionTestSuite) TestParseUserAgent() {
+	client := ParseUserAgent(firefox131)
+	s.Equal("Firefox", client.Browser)
+	s.Equal("131.0", client.BrowserVersion)
+	s.Equal("Linux", client.OS)
+	s.Equal(DeviceTypeDesktop, client.DeviceType)
+	s.Equal("Firefox 131 on Linux", client.String())
+
+	client = ParseUserAgent(iPhone)
+	s.Equal("iOS", client.OS)
+	s.Equal(DeviceTypeMobile, client.DeviceType)
+
+	// Other clients keep their product name
+	s.Equal("curl 8", ParseUserAgent("curl/8.9.1").String())
+
+	client = ParseUserAgent("")
+	s.False(client.Known())
+	s.Equal("Unknown browser", client.String())
+}
+
+func (s *SessionTestSuite) TestDeviceExists() {
+	ip := netip.MustParseAddr("192.0.2.1")
+	stored := NewDevice(ip, firefox130)
+	session := Session{Devices: []Device{stored}}
+
+	// Browser updates are the same device
+	updated := NewDevice(ip, firefox131)
+	s.True(session.DeviceExists(&updated))
+
+	other := NewDevice(ip, iPhone)
+	s.False(session.DeviceExists(&other))
+
+	moved := NewDevice(netip.MustParseAddr("192.0.2.2"), firefox131)
+	s.False(session.DeviceExists(&moved))
+
+	// Devices stored before user agents were parsed
+	legacy := Session{Devices: []Device{{IPAddress: ip, UserAgent: firefox130}}}
+	s.True(legacy.DeviceExists(&updated))
+	s.Equal("Firefox 130 on Linux", legacy.Devices[0].DisplayName())
+
+	// Unparsable user agents are compared as they are
+	unknown := Session{Devices: []Device{NewDevice(ip, "")}}
+	same := NewDevice(ip, "")
+	s.True(unknown.DeviceExists(&same))
+	different := NewDevice(ip, "curl/8.9.1")
+	s.False(unknown.DeviceExists(&different))
+}
+
+func (s *SessionTestSuite) TestRevocationToken() {
+	signer := NewRevocationSigner([]byte("0123456789abcdef0123456789abcdef"))
+	now := time.Now()
+	token := signer.Sign("01928f5e-7a3b-7c1d-9e2f-3a4b5c6d7e8f", now.Add(time.Hour))
+
+	sessionID, err := signer.Verify(token, now)
+	s.Require().NoError(err)
+	s.Equal("01928f5e-7a3b-7c1d-9e2f-3a4b5c6d7e8f", sessionID)
+
+	_, err = signer.Verify(token, now.Add(time.Hour))
+	s.ErrorIs(err, ErrInvalidRevocationToken, "expired")
+
+	other := NewRevocationSigner([]byte("fedcba9876543210fedcba9876543210"))
+	_, err = other.Verify(token, now)
+	s.ErrorIs(err, ErrInvalidRevocationToken, "signed with another key")
+
+	forged := signer.Sign("another-session", now.Add(time.Hour))
+	payload, _, _ := strings.Cut(forged, ".")
+	_, mac, _ := strings.Cut(token, ".")
+	_, err = signer.Verify(payload+"."+mac, now)
+	s.ErrorIs(err, ErrInvalidRevocationToken, "altered session ID")
+
+	for _, malformed := range []string{"", ".", "abc", "a.b.c", "!!!.!!!"} {
+		_, err = signer.Verify(malformed, now)
+		s.ErrorIs(err, ErrInvalidRevocationToken, malformed)
+	}
+}
+
 func TestEmailEtag(t *testing.T) {
 	suite.Run(t, new(SessionTestSuite))
 }
@@ -0,0 +1,37 @@
+// Package store declares the errors the repositories return when the
+// database refuses a query, whatever the database.
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotFound is returned when the queried entity does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists is returned when a created entity collides with an
+	// existing one.
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrInvalid is returned when a value is rejected by a check or a
+	// reference to an entity that does not exist.
+	ErrInvalid = errors.New("invalid value")
+)
+
+// ConstraintError is a change rejected by a constraint of the database. It
+// matches its Kind, ErrAlreadyExists or ErrInvalid, with errors.Is.
+type ConstraintError struct {
+	Kind       error
+	Table      string
+	Constraint string
+	// Err is the error of the database driver.
+	Err error
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%v: %s violates %s", e.Kind, e.Table, e.Constraint)
+}
+
+func (e *ConstraintError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
@@ -0,0 +1,16 @@
+// Package transaction lets the services run a unit of work that spans
+// several repositories atomically.
+package transaction
+
+import "context"
+
+// Runner runs units of work in a transaction. The repositories take part in
+// the transaction when they are called with the context given to the unit of
+// work.
+type Runner interface {
+	// Run runs fn in a transaction, committed when fn returns nil and rolled
+	// back otherwise. fn is called again when the transaction has to be
+	// retried, so it must not have effects outside the repositories. When ctx
+	// already carries a transaction, fn joins it.
+	Run(ctx context.Context, fn func(ctx context.Context) error) error
+}
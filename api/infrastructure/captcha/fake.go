@@ -0,0 +1,27 @@
+package captcha
+
+import (
+	"context"
+	"net/netip"
+
+	"gitlab.mreg.io/my-registry/auth/domain/registration"
+)
+
+// FakeToken is the only token accepted by the fake verifier, in the same
+// way as the test keys of hosted CAPTCHA providers.
+const FakeToken = "10000000-aaaa-bbbb-cccc-000000000001"
+
+type fakeVerifier struct{}
+
+// NewFakeVerifier returns a verifier for local development and tests that
+// does not call any provider.
+func NewFakeVerifier() registration.CaptchaVerifier {
+	return &fakeVerifier{}
+}
+
+func (f *fakeVerifier) Verify(_ context.Context, token string, _ netip.Addr) error {
+	if token != FakeToken {
+		return registration.ErrCaptchaFailed
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package captcha
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"gitlab.mreg.io/my-registry/auth/domain/registration"
+)
+
+func TestFakeVerifier(t *testing.T) {
+	verifier := NewFakeVerifier()
+	remoteIP := netip.MustParseAddr("192.0.2.1")
+
+	if err := verifier.Verify(context.Background(), FakeToken, remoteIP); err != nil {
+		t.Fatalf("expected token to be accepted, got %v", err)
+	}
+	for _, token := range []string{"", "invalid"} {
+		if err := verifier.Verify(context.Background(), token, remoteIP); err != registration.ErrCaptchaFailed {
+			t.Errorf("expected error %s for token %q, got %v", registration.ErrCaptchaFailed, token, err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package cockroachdb
+
+import (
+	"context"
+	_ "embed"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"gitlab.mreg.io/my-registry/auth/domain/audit"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/ulid"
+)
+
+//go:embed sql/createAuditEvent.sql
+var createAuditEventSQL string
+
+//go:embed sql/queryAuditEvents.sql
+var queryAuditEventsSQL string
+
+type auditRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewAuditRepository returns the audit log.
+func NewAuditRepository(db *pgxpool.Pool) audit.Repository {
+	return &auditRepository{db: db}
+}
+
+// CreateEvent records event on the connection of ctx.
+func (r *auditRepository) CreateEvent(ctx context.Context, event *audit.Event) error {
+	err := conn(ctx, r.db).
+		QueryRow(
+			ctx,
+			createAuditEventSQL,
+			ulid.New(), event.Type, event.Actor, event.IdentityID, event.SessionID, event.IPAddress, event.UserAgent, event.Outcome, event.Action,
+		).
+		Scan(&event.ID, &event.CreateTime)
+	return translateError(err)
+}
+
+// QueryEvents pages through the events by creation time, and by ID among
+// events created at once.
+func (r *auditRepository) QueryEvents(ctx context.Context, identityID string, pageSize int, pageToken string) ([]audit.Event, string, error) {
+	var afterTime, afterID any
+	if pageToken != "" {
+		createTime, id, err := audit.DecodePageToken(pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		afterTime, afterID = createTime, id
+	}
+
+	// One more row than asked for tells whether there is a next page
+	rows, err := conn(ctx, r.db).Query(ctx, queryAuditEventsSQL, identityID, afterTime, afterID, pageSize+1)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var events []audit.Event
+	for rows.Next() {
+		event := audit.Event{IdentityID: identityID}
+		if err := rows.Scan(
+			&event.ID, &event.Type, &event.Actor, &event.SessionID, &event.IPAddress, &event.UserAgent, &event.Outcome, &event.Action, &event.CreateTime,
+		); err != nil {
+			return nil, "", err
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(events) <= pageSize {
+		return events, "", nil
+	}
+	events = events[:pageSize]
+	return events, audit.EncodePageToken(events[pageSize-1]), nil
+}
@@ -0,0 +1,86 @@
+package cockroachdb
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/audit"
+)
+
+type AuditRepositorySuite struct {
+	suite.Suite
+	pool       *pgxpool.Pool
+	repository audit.Repository
+}
+
+var auditIdentityID = uuid.New().String()
+
+func (s *AuditRepositorySuite) SetupSuite() {
+	config, _, err := ParseConfig(os.Getenv("DATABASE_URL"))
+	s.Require().NoError(err)
+	s.pool, err = pgxpool.NewWithConfig(context.Background(), config)
+	s.Require().NoError(err)
+	s.repository = NewAuditRepository(s.pool)
+}
+
+func (s *AuditRepositorySuite) TestQueryEvents() {
+	ctx := context.Background()
+	var created []audit.Event
+	for _, outcome := range []audit.Outcome{audit.OutcomeFailure, audit.OutcomeFailure, audit.OutcomeSuccess} {
+		event := audit.Event{
+			Type:       audit.EventLogin,
+			IdentityID: auditIdentityID,
+			IPAddress:  netip.MustParseAddr("192.0.2.1"),
+			UserAgent:  "Mozilla",
+			Outcome:    outcome,
+		}
+		s.Require().NoError(s.repository.CreateEvent(ctx, &event))
+		created = append(created, event)
+	}
+
+	// Newest first
+	events, next, err := s.repository.QueryEvents(ctx, auditIdentityID, 2, "")
+	s.Require().NoError(err)
+	s.Require().Len(events, 2)
+	s.Equal(created[2].ID, events[0].ID)
+	s.Equal(audit.OutcomeSuccess, events[0].Outcome)
+	s.Equal("Mozilla", events[0].UserAgent)
+	s.Equal(created[1].ID, events[1].ID)
+	s.NotEmpty(next)
+
+	events, next, err = s.repository.QueryEvents(ctx, auditIdentityID, 2, next)
+	s.Require().NoError(err)
+	s.Require().Len(events, 1)
+	s.Equal(created[0].ID, events[0].ID)
+	s.Empty(next)
+
+	_, _, err = s.repository.QueryEvents(ctx, auditIdentityID, 2, "not a token")
+	s.ErrorIs(err, audit.ErrInvalidPageToken)
+}
+
+func (s *AuditRepositorySuite) TestCreateEvent() {
+	ctx := context.Background()
+	denied := &audit.Event{
+		Type:      audit.EventRegistration,
+		SessionID: uuid.New().String(),
+		IPAddress: netip.MustParseAddr("192.0.2.1"),
+		Outcome:   audit.OutcomeDenied,
+	}
+	s.Require().NoError(s.repository.CreateEvent(ctx, denied))
+	s.NotEmpty(denied.ID)
+	s.False(denied.CreateTime.IsZero())
+}
+
+func (s *AuditRepositorySuite) TearDownSuite() {
+	s.pool.Close()
+}
+
+func TestAuditRepositorySuite(t *testing.T) {
+	suite.Run(t, new(AuditRepositorySuite))
+}
@@ -0,0 +1,31 @@
+package cockroachdb
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.mreg.io/my-registry/auth/infrastructure/storetest"
+)
+
+// TestConformance runs the repository conformance suite on the database at
+// DATABASE_URL, whichever its dialect.
+func TestConformance(t *testing.T) {
+	config, _, err := ParseConfig(os.Getenv("DATABASE_URL"))
+	require.NoError(t, err)
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	storetest.Run(t, func() storetest.Repositories {
+		return storetest.Repositories{
+			Identities:    NewIdentityRepository(pool),
+			Registrations: NewRegistrationRepository(pool),
+			Sessions:      NewSessionRepository(pool),
+			Transactions:  NewTransactionRunner(pool),
+		}
+	})
+}
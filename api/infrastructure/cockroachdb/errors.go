@@ -1,5 +1,49 @@
 package cockroachdb
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
 
-var ErrConstraint = errors.New("input values failed to meet schema constraint")
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+)
+
+// The SQLSTATE codes translated into store errors.
+const (
+	sqlStateUniqueViolation     = "23505"
+	sqlStateForeignKeyViolation = "23503"
+	sqlStateCheckViolation      = "23514"
+	sqlStateNotNullViolation    = "23502"
+	// sqlStateClassDataException covers the values that cannot be stored
+	// or compared, such as a malformed UUID.
+	sqlStateClassDataException = "22"
+)
+
+// translateError returns err as a store error when it is one the callers
+// can act on, wrapping the driver error so that it can still be inspected.
+// The identity, registration and session repositories return their errors
+// through it, as their queries carry the input of clients.
+func translateError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %w", store.ErrNotFound, err)
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	var kind error
+	switch {
+	case pgErr.Code == sqlStateUniqueViolation:
+		kind = store.ErrAlreadyExists
+	case pgErr.Code == sqlStateForeignKeyViolation, pgErr.Code == sqlStateCheckViolation, pgErr.Code == sqlStateNotNullViolation:
+		kind = store.ErrInvalid
+	case strings.HasPrefix(pgErr.Code, sqlStateClassDataException):
+		return fmt.Errorf("%w: %w", store.ErrInvalid, err)
+	default:
+		return err
+	}
+	return &store.ConstraintError{Kind: kind, Table: pgErr.TableName, Constraint: pgErr.ConstraintName, Err: err}
+}
@@ -0,0 +1,48 @@
+package cockroachdb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+)
+
+func TestTranslateError(t *testing.T) {
+	assert.NoError(t, translateError(nil))
+
+	err := translateError(pgx.ErrNoRows)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	duplicate := &pgconn.PgError{Code: sqlStateUniqueViolation, TableName: "emails", ConstraintName: "emails_pkey"}
+	err = translateError(fmt.Errorf("creating identity: %w", duplicate))
+	assert.ErrorIs(t, err, store.ErrAlreadyExists)
+	var constraintErr *store.ConstraintError
+	require.ErrorAs(t, err, &constraintErr)
+	assert.Equal(t, "emails", constraintErr.Table)
+	assert.Equal(t, "emails_pkey", constraintErr.Constraint)
+	var pgErr *pgconn.PgError
+	assert.ErrorAs(t, err, &pgErr)
+
+	for _, code := range []string{sqlStateForeignKeyViolation, sqlStateCheckViolation, sqlStateNotNullViolation, "22P02"} {
+		err = translateError(&pgconn.PgError{Code: code})
+		assert.ErrorIs(t, err, store.ErrInvalid, code)
+		assert.NotErrorIs(t, err, store.ErrAlreadyExists, code)
+	}
+
+	// Serialization failures and deadlocks are left for the transaction runner
+	for _, code := range []string{sqlStateSerializationFailure, sqlStateDeadlockDetected} {
+		err = translateError(&pgconn.PgError{Code: code})
+		assert.True(t, isRetryable(err), code)
+		assert.NotErrorIs(t, err, store.ErrInvalid, code)
+	}
+
+	other := errors.New("connection refused")
+	assert.Same(t, other, translateError(other))
+}
@@ -0,0 +1,38 @@
+package cockroachdb
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SchemaVersion is the latest Flyway migration the repositories rely on.
+// Bump it whenever a migration is added to migrations/sql, and its
+// PostgreSQL dialect to migrations/postgres with the same version.
+const SchemaVersion = "2026.10.19.20.31.52"
+
+// ErrPendingMigrations is returned by CheckReadiness when SchemaVersion has
+// not been applied to the database yet.
+var ErrPendingMigrations = errors.New("pending database migrations")
+
+//go:embed sql/querySchemaVersion.sql
+var querySchemaVersionSQL string
+
+// CheckReadiness verifies that the database is reachable and that every
+// migration up to SchemaVersion has been applied.
+func CheckReadiness(ctx context.Context, db *pgxpool.Pool) error {
+	if err := db.Ping(ctx); err != nil {
+		return err
+	}
+	var applied bool
+	if err := db.QueryRow(ctx, querySchemaVersionSQL, SchemaVersion).Scan(&applied); err != nil {
+		return err
+	}
+	if !applied {
+		return fmt.Errorf("%w: version %s has not been applied", ErrPendingMigrations, SchemaVersion)
+	}
+	return nil
+}
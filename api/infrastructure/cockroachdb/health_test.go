@@ -0,0 +1,50 @@
+package cockroachdb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaVersionIsLatestMigration(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "..", "migrations", "sql", "V*__*.sql"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	latest := ""
+	for _, file := range files {
+		version, _, _ := strings.Cut(strings.TrimPrefix(filepath.Base(file), "V"), "__")
+		latest = max(latest, version)
+	}
+	require.Equal(t, latest, SchemaVersion, "SchemaVersion has to be bumped along with new migrations")
+}
+
+func TestPostgreSQLMigrations(t *testing.T) {
+	migrations := filepath.Join("..", "..", "..", "migrations")
+	versions := func(dir string) []string {
+		files, err := filepath.Glob(filepath.Join(migrations, dir, "V*__*.sql"))
+		require.NoError(t, err)
+		names := make([]string, 0, len(files))
+		for _, file := range files {
+			names = append(names, filepath.Base(file))
+		}
+		return names
+	}
+	require.Equal(t, versions("sql"), versions("postgres"), "every migration needs a PostgreSQL dialect")
+}
+
+func TestCheckReadiness(t *testing.T) {
+	ctx := context.Background()
+	config, _, err := ParseConfig(os.Getenv("DATABASE_URL"))
+	require.NoError(t, err)
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	require.NoError(t, CheckReadiness(ctx, pool))
+}
@@ -5,8 +5,12 @@ import (
 	_ "embed"
 	"errors"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+
 	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/outbox"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/ulid"
 )
 
 type IdentityRepository struct {
@@ -23,6 +27,9 @@ var createIdentitySQL string
 //go:embed sql/queryEmail.sql
 var queryEmailSQL string
 
+//go:embed sql/queryEmailsByIdentity.sql
+var queryEmailsByIdentitySQL string
+
 func createIdentityField(identity *identity.Identity) []interface{} {
 	return []interface{}{
 		&identity.ID,
@@ -38,22 +45,34 @@ func (i *IdentityRepository) CreateIdentity(ctx context.Context, identityData *i
 	if len(identityData.Emails) == 0 {
 		return errors.New("identity must have at least one email")
 	}
-	return i.db.
-		QueryRow(
-			ctx,
-			createIdentitySQL,
-			identityData.Timezone, identityData.Emails[0].Value, identityData.PasswordHash,
-		).
-		Scan(createIdentityField(identityData)...)
+	// The identity is announced to the other services in any case
+	err := pgx.BeginFunc(ctx, conn(ctx, i.db), func(tx pgx.Tx) error {
+		err := tx.
+			QueryRow(
+				ctx,
+				createIdentitySQL,
+				ulid.New(), identityData.Timezone, identityData.Emails[0].Value, identityData.PasswordHash, identityData.Locale,
+			).
+			Scan(createIdentityField(identityData)...)
+		if err != nil {
+			return err
+		}
+		message, err := outbox.NewIdentityMessage(outbox.TypeIdentityCreated, identityData)
+		if err != nil {
+			return err
+		}
+		return createOutboxMessage(ctx, tx, message)
+	})
+	return translateError(err)
 }
 
 func (i *IdentityRepository) EmailExists(ctx context.Context, emailAddress string) (bool, error) {
 	const query = `SELECT EXISTS(SELECT 1 FROM emails WHERE address = $1)`
 
 	var exists bool
-	err := i.db.QueryRow(ctx, query, emailAddress).Scan(&exists)
+	err := conn(ctx, i.db).QueryRow(ctx, query, emailAddress).Scan(&exists)
 	if err != nil {
-		return false, err
+		return false, translateError(err)
 	}
 
 	return exists, nil
@@ -69,11 +88,35 @@ func QueryEmailField(email *identity.Email) []interface{} {
 }
 
 func (i *IdentityRepository) QueryEmail(ctx context.Context, email *identity.Email) error {
-	return i.db.
+	err := conn(ctx, i.db).
 		QueryRow(
 			ctx,
 			queryEmailSQL,
 			email.Value,
 		).
 		Scan(QueryEmailField(email)...)
+	return translateError(err)
+}
+
+func (i *IdentityRepository) QueryEmails(ctx context.Context, identityData *identity.Identity) error {
+	rows, err := conn(ctx, i.db).Query(ctx, queryEmailsByIdentitySQL, identityData.ID)
+	if err != nil {
+		return translateError(err)
+	}
+	defer rows.Close()
+
+	var emails []identity.Email
+	for rows.Next() {
+		var email identity.Email
+		if err := rows.Scan(append([]interface{}{&email.Value}, QueryEmailField(&email)...)...); err != nil {
+			return err
+		}
+		emails = append(emails, email)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	identityData.Emails = emails
+	return nil
 }
@@ -9,7 +9,10 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/suite"
+
 	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/outbox"
+	"gitlab.mreg.io/my-registry/auth/domain/store"
 )
 
 type IdentityRepositorySuite struct {
@@ -35,7 +38,7 @@ var (
 )
 
 func (i *IdentityRepositorySuite) SetupSuite() {
-	config, err := pgxpool.ParseConfig(os.Getenv("DATABASE_URL"))
+	config, _, err := ParseConfig(os.Getenv("DATABASE_URL"))
 	i.Require().NoError(err)
 	i.pool, err = pgxpool.NewWithConfig(context.Background(), config)
 	i.Require().NoError(err)
@@ -77,6 +80,12 @@ func (i *IdentityRepositorySuite) TestCreateIdentity_WithoutErr() {
 	err = i.repository.CreateIdentity(ctx, newIdentity)
 	i.Require().NoError(err)
 
+	// the identity is announced in the outbox
+	var messageType string
+	err = i.pool.QueryRow(ctx, "SELECT type FROM outbox_messages WHERE subject = $1", newIdentity.ID).Scan(&messageType)
+	i.Require().NoError(err)
+	i.Equal(outbox.TypeIdentityCreated, messageType)
+
 	// check the data integrity of the newIdentity
 	i.Require().Equal(originalIdentity.Emails[0].Value, newIdentity.Emails[0].Value)
 	i.Require().Equal(originalIdentity.Emails[0].Verified, newIdentity.Emails[0].Verified)
@@ -157,6 +166,20 @@ func (i *IdentityRepositorySuite) TestCreateIdentity_NoEmail_Err() {
 	i.Require().Error(err)
 }
 
+func (i *IdentityRepositorySuite) TestCreateIdentity_EmailExists() {
+	ctx := context.Background()
+	newIdentity := &identity.Identity{
+		Emails:       []identity.Email{{Value: identityEmail1}},
+		PasswordHash: password1,
+		State:        identity.StateActive,
+	}
+	err := i.repository.CreateIdentity(ctx, newIdentity)
+	i.Require().ErrorIs(err, store.ErrAlreadyExists)
+	var constraintErr *store.ConstraintError
+	i.Require().ErrorAs(err, &constraintErr)
+	i.Equal("emails", constraintErr.Table)
+}
+
 func (i *IdentityRepositorySuite) TestEmailExist() {
 	ctx := context.Background()
 	exist, err := i.repository.EmailExists(ctx, identityEmail1)
@@ -211,6 +234,20 @@ func (i *IdentityRepositorySuite) TestQueryEmail_NotExistEmail_Err() {
 	i.Require().Error(err)
 }
 
+func (i *IdentityRepositorySuite) TestQueryEmails_NoErr() {
+	ctx := context.Background()
+	identityData := &identity.Identity{ID: identityIdentityID2.String()}
+	i.Require().NoError(i.repository.QueryEmails(ctx, identityData))
+	i.Require().Len(identityData.Emails, 1)
+	i.Equal(identityEmail2, identityData.Emails[0].Value)
+	i.True(identityData.Emails[0].Verified)
+	i.Equal(verifiedAt1, identityData.Emails[0].VerifiedAt.UTC())
+
+	unknown := &identity.Identity{ID: uuid.New().String()}
+	i.Require().NoError(i.repository.QueryEmails(ctx, unknown))
+	i.Empty(unknown.Emails)
+}
+
 func (i *IdentityRepositorySuite) TearDownSuite() {
 	i.pool.Close()
 }
@@ -0,0 +1,107 @@
+package cockroachdb
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype/zeronull"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"gitlab.mreg.io/my-registry/auth/domain/lockout"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/ulid"
+)
+
+//go:embed sql/queryLoginFailure.sql
+var queryLoginFailureSQL string
+
+//go:embed sql/incrementLoginFailure.sql
+var incrementLoginFailureSQL string
+
+//go:embed sql/lockLoginFailure.sql
+var lockLoginFailureSQL string
+
+//go:embed sql/deleteLoginFailure.sql
+var deleteLoginFailureSQL string
+
+//go:embed sql/createLockoutEvent.sql
+var createLockoutEventSQL string
+
+//go:embed sql/queryLockoutEvents.sql
+var queryLockoutEventsSQL string
+
+type lockoutRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewLockoutRepository(db *pgxpool.Pool) lockout.Repository {
+	return &lockoutRepository{db: db}
+}
+
+func stateFields(state *lockout.State) []interface{} {
+	return []interface{}{
+		&state.Failures,
+		&state.LastFailureAt,
+		(*zeronull.Timestamptz)(&state.LockedUntil),
+	}
+}
+
+func (r *lockoutRepository) QueryState(ctx context.Context, key string) (lockout.State, error) {
+	var state lockout.State
+	err := conn(ctx, r.db).QueryRow(ctx, queryLoginFailureSQL, key).Scan(stateFields(&state)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return lockout.State{}, nil
+	}
+	return state, err
+}
+
+func (r *lockoutRepository) IncrementFailures(ctx context.Context, key string, window time.Duration) (lockout.State, error) {
+	var state lockout.State
+	err := conn(ctx, r.db).QueryRow(ctx, incrementLoginFailureSQL, key, window).Scan(stateFields(&state)...)
+	return state, err
+}
+
+func (r *lockoutRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := conn(ctx, r.db).Exec(ctx, lockLoginFailureSQL, key, until)
+	return err
+}
+
+func (r *lockoutRepository) ResetFailures(ctx context.Context, key string) error {
+	_, err := conn(ctx, r.db).Exec(ctx, deleteLoginFailureSQL, key)
+	return err
+}
+
+// CreateEvent returns store.ErrInvalid when the identity does not exist, as
+// the identity IDs of unlocks are given by administrators.
+func (r *lockoutRepository) CreateEvent(ctx context.Context, event *lockout.Event) error {
+	err := conn(ctx, r.db).
+		QueryRow(
+			ctx,
+			createLockoutEventSQL,
+			ulid.New(), event.IdentityID, event.Type, event.IPAddress, event.Actor, zeronull.Timestamptz(event.LockedUntil),
+		).
+		Scan(&event.ID, &event.CreateTime)
+	return translateError(err)
+}
+
+func (r *lockoutRepository) ListEvents(ctx context.Context, identityID string, limit int) ([]lockout.Event, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, queryLockoutEventsSQL, identityID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []lockout.Event
+	for rows.Next() {
+		event := lockout.Event{IdentityID: identityID}
+		if err := rows.Scan(
+			&event.ID, &event.Type, &event.IPAddress, &event.Actor, (*zeronull.Timestamptz)(&event.LockedUntil), &event.CreateTime,
+		); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
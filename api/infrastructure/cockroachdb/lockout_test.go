@@ -0,0 +1,136 @@
+package cockroachdb
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/lockout"
+)
+
+type LockoutRepositorySuite struct {
+	suite.Suite
+	pool       *pgxpool.Pool
+	repository lockout.Repository
+}
+
+var lockoutIdentityID = uuid.New()
+
+func (s *LockoutRepositorySuite) SetupSuite() {
+	config, _, err := ParseConfig(os.Getenv("DATABASE_URL"))
+	s.Require().NoError(err)
+	s.pool, err = pgxpool.NewWithConfig(context.Background(), config)
+	s.Require().NoError(err)
+	s.repository = NewLockoutRepository(s.pool)
+
+	_, err = s.pool.Exec(context.Background(), `INSERT INTO identities (id, timezone) VALUES ($1, 'Asia/Taipei')`, lockoutIdentityID)
+	s.Require().NoError(err)
+}
+
+func (s *LockoutRepositorySuite) TestQueryState_Unknown() {
+	state, err := s.repository.QueryState(context.Background(), lockout.IdentityKey(uuid.New().String()))
+	s.Require().NoError(err)
+	s.Equal(lockout.State{}, state)
+}
+
+func (s *LockoutRepositorySuite) TestIncrementLockAndReset() {
+	ctx := context.Background()
+	key := lockout.IdentityKey(uuid.New().String())
+
+	state, err := s.repository.IncrementFailures(ctx, key, time.Hour)
+	s.Require().NoError(err)
+	s.Equal(1, state.Failures)
+	s.Require().True(state.LockedUntil.IsZero())
+
+	state, err = s.repository.IncrementFailures(ctx, key, time.Hour)
+	s.Require().NoError(err)
+	s.Equal(2, state.Failures)
+
+	lockedUntil := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+	s.Require().NoError(s.repository.Lock(ctx, key, lockedUntil))
+	state, err = s.repository.QueryState(ctx, key)
+	s.Require().NoError(err)
+	s.Equal(2, state.Failures)
+	s.True(lockedUntil.Equal(state.LockedUntil))
+
+	s.Require().NoError(s.repository.ResetFailures(ctx, key))
+	state, err = s.repository.QueryState(ctx, key)
+	s.Require().NoError(err)
+	s.Equal(lockout.State{}, state)
+}
+
+func (s *LockoutRepositorySuite) TestIncrementFailures_OutsideWindow() {
+	ctx := context.Background()
+	key := lockout.IPKey(netip.MustParseAddr("192.0.2.1"))
+	_, err := s.pool.Exec(ctx, `INSERT INTO login_failures (key, failures, last_failure_at)
+		VALUES ($1, 5, current_timestamp - interval '2 hours')
+		ON CONFLICT (key) DO UPDATE SET failures = excluded.failures, last_failure_at = excluded.last_failure_at`, key)
+	s.Require().NoError(err)
+
+	state, err := s.repository.IncrementFailures(ctx, key, time.Hour)
+	s.Require().NoError(err)
+	s.Equal(1, state.Failures)
+}
+
+func (s *LockoutRepositorySuite) TestIncrementFailures_AfterLockExpired() {
+	ctx := context.Background()
+	key := lockout.IdentityKey(uuid.New().String())
+	for range 3 {
+		_, err := s.repository.IncrementFailures(ctx, key, time.Hour)
+		s.Require().NoError(err)
+	}
+	s.Require().NoError(s.repository.Lock(ctx, key, time.Now().Add(-time.Second)))
+
+	state, err := s.repository.IncrementFailures(ctx, key, time.Hour)
+	s.Require().NoError(err)
+	s.Equal(1, state.Failures)
+	s.True(state.LockedUntil.IsZero())
+}
+
+func (s *LockoutRepositorySuite) TestEvents() {
+	ctx := context.Background()
+	locked := &lockout.Event{
+		IdentityID:  lockoutIdentityID.String(),
+		Type:        lockout.EventLocked,
+		IPAddress:   netip.MustParseAddr("192.0.2.1"),
+		LockedUntil: time.Now().Add(time.Hour),
+	}
+	s.Require().NoError(s.repository.CreateEvent(ctx, locked))
+	s.NotEmpty(locked.ID)
+	s.NotEmpty(locked.CreateTime)
+
+	unlocked := &lockout.Event{
+		IdentityID: lockoutIdentityID.String(),
+		Type:       lockout.EventUnlocked,
+		Actor:      "admin",
+	}
+	s.Require().NoError(s.repository.CreateEvent(ctx, unlocked))
+
+	events, err := s.repository.ListEvents(ctx, lockoutIdentityID.String(), 10)
+	s.Require().NoError(err)
+	s.Require().Len(events, 2)
+	s.Equal(lockout.EventUnlocked, events[0].Type)
+	s.Equal("admin", events[0].Actor)
+	s.False(events[0].IPAddress.IsValid())
+	s.True(events[0].LockedUntil.IsZero())
+	s.Equal(lockout.EventLocked, events[1].Type)
+	s.Equal(locked.IPAddress, events[1].IPAddress)
+
+	events, err = s.repository.ListEvents(ctx, lockoutIdentityID.String(), 1)
+	s.Require().NoError(err)
+	s.Len(events, 1)
+}
+
+func (s *LockoutRepositorySuite) TearDownSuite() {
+	s.pool.Close()
+}
+
+func TestLockoutRepositorySuite(t *testing.T) {
+	suite.Run(t, new(LockoutRepositorySuite))
+}
@@ -0,0 +1,80 @@
+package cockroachdb
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"gitlab.mreg.io/my-registry/auth/infrastructure/ulid"
+	"gitlab.mreg.io/my-registry/auth/mail"
+)
+
+//go:embed sql/createMailMessage.sql
+var createMailMessageSQL string
+
+//go:embed sql/claimMailMessages.sql
+var claimMailMessagesSQL string
+
+//go:embed sql/deleteMailMessage.sql
+var deleteMailMessageSQL string
+
+//go:embed sql/retryMailMessage.sql
+var retryMailMessageSQL string
+
+//go:embed sql/markMailMessageDead.sql
+var markMailMessageDeadSQL string
+
+type mailQueue struct {
+	db *pgxpool.Pool
+}
+
+func NewMailQueue(db *pgxpool.Pool) mail.Queue {
+	return &mailQueue{db: db}
+}
+
+func (q *mailQueue) Enqueue(ctx context.Context, message *mail.Message) error {
+	_, err := conn(ctx, q.db).Exec(
+		ctx,
+		createMailMessageSQL,
+		ulid.New(), message.From, message.To, message.Subject, message.Text, message.HTML, message.Date,
+	)
+	return err
+}
+
+func (q *mailQueue) Claim(ctx context.Context, limit int, lease time.Duration) ([]mail.QueuedMessage, error) {
+	rows, err := conn(ctx, q.db).Query(ctx, claimMailMessagesSQL, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []mail.QueuedMessage
+	for rows.Next() {
+		var message mail.QueuedMessage
+		if err := rows.Scan(
+			&message.ID, &message.From, &message.To, &message.Subject, &message.Text, &message.HTML, &message.Date,
+			&message.Attempts, &message.LastError,
+		); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+func (q *mailQueue) Delete(ctx context.Context, id string) error {
+	_, err := conn(ctx, q.db).Exec(ctx, deleteMailMessageSQL, id)
+	return err
+}
+
+func (q *mailQueue) Retry(ctx context.Context, id string, next time.Time, lastError string) error {
+	_, err := conn(ctx, q.db).Exec(ctx, retryMailMessageSQL, id, next, lastError)
+	return err
+}
+
+func (q *mailQueue) MarkDead(ctx context.Context, id string, lastError string) error {
+	_, err := conn(ctx, q.db).Exec(ctx, markMailMessageDeadSQL, id, lastError)
+	return err
+}
@@ -0,0 +1,85 @@
+package cockroachdb
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/mail"
+)
+
+type MailQueueSuite struct {
+	suite.Suite
+	pool  *pgxpool.Pool
+	queue mail.Queue
+}
+
+func (s *MailQueueSuite) SetupSuite() {
+	config, _, err := ParseConfig(os.Getenv("DATABASE_URL"))
+	s.Require().NoError(err)
+	s.pool, err = pgxpool.NewWithConfig(context.Background(), config)
+	s.Require().NoError(err)
+	s.queue = NewMailQueue(s.pool)
+}
+
+// claim claims every due message and returns the one sent to to, if any.
+func (s *MailQueueSuite) claim(to string) *mail.QueuedMessage {
+	messages, err := s.queue.Claim(context.Background(), 1000, time.Minute)
+	s.Require().NoError(err)
+	for _, message := range messages {
+		if message.To == to {
+			return &message
+		}
+	}
+	return nil
+}
+
+func (s *MailQueueSuite) TestQueue() {
+	ctx := context.Background()
+	to := uuid.New().String() + "@example.com"
+	s.Require().NoError(s.queue.Enqueue(ctx, &mail.Message{
+		From:    "no-reply@mreg.io",
+		To:      to,
+		Subject: "Hello",
+		Text:    "Hello\n",
+		HTML:    "<p>Hello</p>\n",
+		Date:    time.Now(),
+	}))
+
+	claimed := s.claim(to)
+	s.Require().NotNil(claimed)
+	s.Equal(1, claimed.Attempts)
+	s.Equal("Hello", claimed.Subject)
+	s.Equal("<p>Hello</p>\n", claimed.HTML)
+
+	// Leased messages are not claimed again
+	s.Nil(s.claim(to))
+
+	s.Require().NoError(s.queue.Retry(ctx, claimed.ID, time.Now().Add(-time.Second), "421 Service not available"))
+	claimed = s.claim(to)
+	s.Require().NotNil(claimed)
+	s.Equal(2, claimed.Attempts)
+	s.Equal("421 Service not available", claimed.LastError)
+
+	s.Require().NoError(s.queue.MarkDead(ctx, claimed.ID, "550 No such user"))
+	s.Require().NoError(s.queue.Retry(ctx, claimed.ID, time.Now().Add(-time.Second), "550 No such user"))
+	s.Nil(s.claim(to))
+
+	s.Require().NoError(s.queue.Delete(ctx, claimed.ID))
+	var count int
+	s.Require().NoError(s.pool.QueryRow(ctx, "SELECT count(*) FROM mail_messages WHERE id = $1", claimed.ID).Scan(&count))
+	s.Zero(count)
+}
+
+func (s *MailQueueSuite) TearDownSuite() {
+	s.pool.Close()
+}
+
+func TestMailQueueSuite(t *testing.T) {
+	suite.Run(t, new(MailQueueSuite))
+}
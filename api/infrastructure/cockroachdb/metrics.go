@@ -0,0 +1,60 @@
+package cockroachdb
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type poolStatDesc struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	value     func(*pgxpool.Stat) float64
+}
+
+// poolCollector reports pgxpool.Stat at scrape time.
+type poolCollector struct {
+	pool  *pgxpool.Pool
+	stats []poolStatDesc
+}
+
+// NewPoolCollector exposes the statistics of a pgx pool to Prometheus.
+func NewPoolCollector(pool *pgxpool.Pool) prometheus.Collector {
+	stat := func(name string, help string, valueType prometheus.ValueType, value func(*pgxpool.Stat) float64) poolStatDesc {
+		return poolStatDesc{prometheus.NewDesc("auth_pgxpool_"+name, help, nil, nil), valueType, value}
+	}
+	return &poolCollector{pool, []poolStatDesc{
+		stat("acquired_conns", "Connections currently acquired.", prometheus.GaugeValue,
+			func(s *pgxpool.Stat) float64 { return float64(s.AcquiredConns()) }),
+		stat("idle_conns", "Connections currently idle.", prometheus.GaugeValue,
+			func(s *pgxpool.Stat) float64 { return float64(s.IdleConns()) }),
+		stat("constructing_conns", "Connections being established.", prometheus.GaugeValue,
+			func(s *pgxpool.Stat) float64 { return float64(s.ConstructingConns()) }),
+		stat("total_conns", "Connections in the pool.", prometheus.GaugeValue,
+			func(s *pgxpool.Stat) float64 { return float64(s.TotalConns()) }),
+		stat("max_conns", "Maximum size of the pool.", prometheus.GaugeValue,
+			func(s *pgxpool.Stat) float64 { return float64(s.MaxConns()) }),
+		stat("acquires_total", "Successful acquires from the pool.", prometheus.CounterValue,
+			func(s *pgxpool.Stat) float64 { return float64(s.AcquireCount()) }),
+		stat("acquire_duration_seconds_total", "Time spent acquiring connections.", prometheus.CounterValue,
+			func(s *pgxpool.Stat) float64 { return s.AcquireDuration().Seconds() }),
+		stat("empty_acquires_total", "Acquires that had to wait for a connection.", prometheus.CounterValue,
+			func(s *pgxpool.Stat) float64 { return float64(s.EmptyAcquireCount()) }),
+		stat("canceled_acquires_total", "Acquires canceled by their context.", prometheus.CounterValue,
+			func(s *pgxpool.Stat) float64 { return float64(s.CanceledAcquireCount()) }),
+		stat("new_conns_total", "Connections opened.", prometheus.CounterValue,
+			func(s *pgxpool.Stat) float64 { return float64(s.NewConnsCount()) }),
+	}}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, stat := range c.stats {
+		ch <- stat.desc
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	for _, stat := range c.stats {
+		ch <- prometheus.MustNewConstMetric(stat.desc, stat.valueType, stat.value(s))
+	}
+}
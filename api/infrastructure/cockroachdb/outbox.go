@@ -0,0 +1,71 @@
+package cockroachdb
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"gitlab.mreg.io/my-registry/auth/domain/outbox"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/ulid"
+)
+
+//go:embed sql/createOutboxMessage.sql
+var createOutboxMessageSQL string
+
+//go:embed sql/claimOutboxMessages.sql
+var claimOutboxMessagesSQL string
+
+//go:embed sql/updateOutboxDelivery.sql
+var updateOutboxDeliverySQL string
+
+// createOutboxMessage adds message to the outbox. It is called within the
+// transaction of the change the message announces.
+func createOutboxMessage(ctx context.Context, q querier, message *outbox.Message) error {
+	return q.
+		QueryRow(ctx, createOutboxMessageSQL, ulid.New(), message.Type, message.Subject, message.Data).
+		Scan(&message.ID, &message.CreateTime, &message.State, &message.NextAttemptAt)
+}
+
+type outboxRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOutboxRepository(db *pgxpool.Pool) outbox.Repository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) ClaimMessages(ctx context.Context, limit int, lease time.Duration) ([]outbox.Message, error) {
+	rows, err := conn(ctx, r.db).Query(ctx, claimOutboxMessagesSQL, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []outbox.Message
+	for rows.Next() {
+		var message outbox.Message
+		if err := rows.Scan(
+			&message.ID, &message.Type, &message.Subject, &message.Data, &message.CreateTime, &message.State,
+			&message.Attempts, &message.NextAttemptAt, &message.DeliveredTo, &message.LastError,
+		); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+func (r *outboxRepository) UpdateDelivery(ctx context.Context, message *outbox.Message) error {
+	deliveredTo := message.DeliveredTo
+	if deliveredTo == nil {
+		deliveredTo = []string{}
+	}
+	_, err := conn(ctx, r.db).Exec(
+		ctx,
+		updateOutboxDeliverySQL,
+		message.ID, message.State, message.NextAttemptAt, deliveredTo, message.LastError,
+	)
+	return err
+}
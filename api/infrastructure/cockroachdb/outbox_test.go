@@ -0,0 +1,87 @@
+package cockroachdb
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/outbox"
+)
+
+type OutboxRepositorySuite struct {
+	suite.Suite
+	pool       *pgxpool.Pool
+	repository outbox.Repository
+}
+
+func (s *OutboxRepositorySuite) SetupSuite() {
+	config, _, err := ParseConfig(os.Getenv("DATABASE_URL"))
+	s.Require().NoError(err)
+	s.pool, err = pgxpool.NewWithConfig(context.Background(), config)
+	s.Require().NoError(err)
+	s.repository = NewOutboxRepository(s.pool)
+}
+
+// claim claims every due message and returns the one with id, if any.
+func (s *OutboxRepositorySuite) claim(id string) *outbox.Message {
+	messages, err := s.repository.ClaimMessages(context.Background(), 1000, time.Minute)
+	s.Require().NoError(err)
+	for _, message := range messages {
+		if message.ID == id {
+			return &message
+		}
+	}
+	return nil
+}
+
+func (s *OutboxRepositorySuite) TestDelivery() {
+	ctx := context.Background()
+	subject := uuid.New().String()
+	message := &outbox.Message{Type: outbox.TypeIdentityCreated, Subject: subject, Data: json.RawMessage(`{"id":"` + subject + `"}`)}
+	s.Require().NoError(createOutboxMessage(ctx, s.pool, message))
+	s.Require().NotEmpty(message.ID)
+	s.Equal(outbox.StatePending, message.State)
+
+	claimed := s.claim(message.ID)
+	s.Require().NotNil(claimed)
+	s.Equal(1, claimed.Attempts)
+	s.Equal(subject, claimed.Subject)
+	s.JSONEq(string(message.Data), string(claimed.Data))
+	s.Empty(claimed.DeliveredTo)
+	s.Empty(claimed.LastError)
+
+	// Leased messages are not claimed again
+	s.Nil(s.claim(message.ID))
+
+	// A failed attempt is retried once due
+	claimed.DeliveredTo = []string{"http://localhost:8090"}
+	claimed.NextAttemptAt = time.Now().Add(-time.Second)
+	claimed.LastError = "503 Service Unavailable"
+	s.Require().NoError(s.repository.UpdateDelivery(ctx, claimed))
+	claimed = s.claim(message.ID)
+	s.Require().NotNil(claimed)
+	s.Equal(2, claimed.Attempts)
+	s.Equal([]string{"http://localhost:8090"}, claimed.DeliveredTo)
+	s.Equal("503 Service Unavailable", claimed.LastError)
+
+	// Delivered messages are done
+	claimed.State = outbox.StateDelivered
+	claimed.NextAttemptAt = time.Now().Add(-time.Second)
+	claimed.LastError = ""
+	s.Require().NoError(s.repository.UpdateDelivery(ctx, claimed))
+	s.Nil(s.claim(message.ID))
+}
+
+func (s *OutboxRepositorySuite) TearDownSuite() {
+	s.pool.Close()
+}
+
+func TestOutboxRepositorySuite(t *testing.T) {
+	suite.Run(t, new(OutboxRepositorySuite))
+}
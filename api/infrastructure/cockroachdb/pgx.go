@@ -2,15 +2,104 @@ package cockroachdb
 
 import (
 	"context"
+	_ "embed"
+	"fmt"
 	"log"
+	"net/url"
+	"strings"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func NewPgxPool(ctx context.Context, connString string) *pgxpool.Pool {
-	conn, err := pgxpool.New(ctx, connString)
+// Dialect is the database the repositories run on. Their queries are the
+// same on both, while the schema is migrated from migrations/sql on
+// CockroachDB and from migrations/postgres on PostgreSQL.
+type Dialect string
+
+const (
+	CockroachDB Dialect = "cockroachdb"
+	PostgreSQL  Dialect = "postgresql"
+)
+
+//go:embed sql/queryServerVersion.sql
+var queryServerVersionSQL string
+
+// ParseURL returns the dialect named by the scheme of a database URL, and
+// the URL pgx connects to. cockroachdb:// URLs are rewritten to
+// postgresql://, as CockroachDB speaks the PostgreSQL wire protocol, which
+// is also why postgres:// and postgresql:// URLs name no dialect: it is
+// detected from the server with DetectDialect.
+func ParseURL(rawURL string) (Dialect, string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	switch parsed.Scheme {
+	case "cockroachdb":
+		parsed.Scheme = "postgresql"
+		return CockroachDB, parsed.String(), nil
+	case "postgres", "postgresql":
+		return "", rawURL, nil
+	default:
+		return "", "", fmt.Errorf("unsupported database URL scheme %q", parsed.Scheme)
+	}
+}
+
+// ParseConfig returns the pool configuration for a database URL of either
+// dialect, and the dialect named by its scheme, if any.
+func ParseConfig(rawURL string) (*pgxpool.Config, Dialect, error) {
+	dialect, connString, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	config, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, "", err
+	}
+	return config, dialect, nil
+}
+
+// DetectDialect asks the server of config which dialect it speaks.
+func DetectDialect(ctx context.Context, config *pgx.ConnConfig) (Dialect, error) {
+	conn, err := pgx.ConnectConfig(ctx, config)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close(ctx)
+	var version string
+	if err := conn.QueryRow(ctx, queryServerVersionSQL).Scan(&version); err != nil {
+		return "", err
+	}
+	return dialectOf(version), nil
+}
+
+// dialectOf returns the dialect of a server from its version string, such
+// as "CockroachDB CCL v24.2.4 (...)" or "PostgreSQL 17.0 on ...".
+func dialectOf(version string) Dialect {
+	if strings.HasPrefix(version, "CockroachDB") {
+		return CockroachDB
+	}
+	return PostgreSQL
+}
+
+// NewPgxPool connects to the database of databaseURL, detecting its dialect
+// from the server when the URL does not name it.
+func NewPgxPool(ctx context.Context, databaseURL string) (*pgxpool.Pool, Dialect) {
+	config, dialect, err := ParseConfig(databaseURL)
+	if err != nil {
+		log.Fatalf("Unable to parse database URL: %v", err)
+	}
+	if dialect == "" {
+		if dialect, err = DetectDialect(ctx, config.ConnConfig.Copy()); err != nil {
+			log.Fatalf("Unable to connect to database: %v", err)
+		}
+	}
+	config.ConnConfig.Tracer = NewQueryTracer(dialect)
+
+	conn, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
 		log.Fatalf("Unable to connect to database: %v", err)
 	}
-	return conn
+	return conn, dialect
 }
@@ -0,0 +1,33 @@
+package cockroachdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseURL(t *testing.T) {
+	dialect, connString, err := ParseURL("cockroachdb://root@localhost:26257/auth?sslmode=disable")
+	require.NoError(t, err)
+	assert.Equal(t, CockroachDB, dialect)
+	assert.Equal(t, "postgresql://root@localhost:26257/auth?sslmode=disable", connString)
+
+	for _, rawURL := range []string{"postgres://auth@localhost/auth", "postgresql://auth@localhost/auth"} {
+		dialect, connString, err = ParseURL(rawURL)
+		require.NoError(t, err)
+		// CockroachDB speaks the same protocol, so the server is asked
+		assert.Empty(t, dialect)
+		assert.Equal(t, rawURL, connString)
+	}
+
+	_, _, err = ParseURL("mysql://auth@localhost/auth")
+	assert.ErrorContains(t, err, "mysql")
+	_, _, err = ParseURL("host=localhost dbname=auth")
+	assert.Error(t, err)
+}
+
+func TestDialectOf(t *testing.T) {
+	assert.Equal(t, CockroachDB, dialectOf("CockroachDB CCL v24.2.4 (x86_64-pc-linux-gnu, built 2024/10/01 00:00:00, go1.22.5 X:nocoverageredesign)"))
+	assert.Equal(t, PostgreSQL, dialectOf("PostgreSQL 17.0 on x86_64-pc-linux-musl, compiled by gcc (Alpine 13.2.1_git20240309) 13.2.1 20240309, 64-bit"))
+}
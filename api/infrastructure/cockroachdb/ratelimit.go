@@ -0,0 +1,65 @@
+package cockroachdb
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"gitlab.mreg.io/my-registry/auth/domain/ratelimit"
+)
+
+//go:embed sql/queryRateLimitBucket.sql
+var queryRateLimitBucketSQL string
+
+//go:embed sql/upsertRateLimitBucket.sql
+var upsertRateLimitBucketSQL string
+
+type rateLimitRepository struct {
+	db *pgxpool.Pool
+}
+
+// NewRateLimitRepository returns a rate limit store shared by every replica
+// connected to the same database.
+func NewRateLimitRepository(db *pgxpool.Pool) ratelimit.Repository {
+	return &rateLimitRepository{db: db}
+}
+
+func (r *rateLimitRepository) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	var result ratelimit.Result
+	err := pgx.BeginFunc(ctx, conn(ctx, r.db), func(tx pgx.Tx) error {
+		now := time.Now()
+		bucket := &ratelimit.Bucket{}
+		err := tx.QueryRow(ctx, queryRateLimitBucketSQL, key).Scan(&bucket.Tokens, &bucket.UpdatedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			bucket = ratelimit.NewBucket(now, limit)
+		} else if err != nil {
+			return err
+		}
+
+		result = bucket.Take(now, limit)
+		_, err = tx.Exec(ctx, upsertRateLimitBucketSQL, key, bucket.Tokens, bucket.UpdatedAt, bucket.FullAt(limit))
+		return err
+	})
+	return result, err
+}
+
+func (r *rateLimitRepository) Refund(ctx context.Context, key string, limit ratelimit.Limit) error {
+	return pgx.BeginFunc(ctx, conn(ctx, r.db), func(tx pgx.Tx) error {
+		bucket := &ratelimit.Bucket{}
+		err := tx.QueryRow(ctx, queryRateLimitBucketSQL, key).Scan(&bucket.Tokens, &bucket.UpdatedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// expired since, and full again
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		bucket.Refund(time.Now(), limit)
+		_, err = tx.Exec(ctx, upsertRateLimitBucketSQL, key, bucket.Tokens, bucket.UpdatedAt, bucket.FullAt(limit))
+		return err
+	})
+}
@@ -0,0 +1,93 @@
+package cockroachdb
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/ratelimit"
+)
+
+type RateLimitRepositorySuite struct {
+	suite.Suite
+	pool       *pgxpool.Pool
+	repository ratelimit.Repository
+}
+
+func (s *RateLimitRepositorySuite) SetupSuite() {
+	config, _, err := ParseConfig(os.Getenv("DATABASE_URL"))
+	s.Require().NoError(err)
+	s.pool, err = pgxpool.NewWithConfig(context.Background(), config)
+	s.Require().NoError(err)
+	s.repository = NewRateLimitRepository(s.pool)
+}
+
+func (s *RateLimitRepositorySuite) TestTake() {
+	ctx := context.Background()
+	key := "test|ip:" + uuid.New().String()
+	limit := ratelimit.Limit{Burst: 2, Period: time.Hour}
+
+	result, err := s.repository.Take(ctx, key, limit)
+	s.Require().NoError(err)
+	s.True(result.Allowed)
+
+	result, err = s.repository.Take(ctx, key, limit)
+	s.Require().NoError(err)
+	s.True(result.Allowed)
+
+	result, err = s.repository.Take(ctx, key, limit)
+	s.Require().NoError(err)
+	s.False(result.Allowed)
+	s.Greater(result.RetryAfter, 59*time.Minute)
+
+	var tokens float64
+	var expiresAt time.Time
+	err = s.pool.
+		QueryRow(ctx, `SELECT tokens, expires_at FROM rate_limit_buckets WHERE key = $1`, key).
+		Scan(&tokens, &expiresAt)
+	s.Require().NoError(err)
+	s.Less(tokens, 1.0)
+	s.Greater(expiresAt, time.Now().Add(time.Hour))
+}
+
+func (s *RateLimitRepositorySuite) TestRefund() {
+	ctx := context.Background()
+	key := "test|ip:" + uuid.New().String()
+	limit := ratelimit.Limit{Burst: 1, Period: time.Hour}
+
+	result, err := s.repository.Take(ctx, key, limit)
+	s.Require().NoError(err)
+	s.True(result.Allowed)
+	s.Require().NoError(s.repository.Refund(ctx, key, limit))
+	result, err = s.repository.Take(ctx, key, limit)
+	s.Require().NoError(err)
+	s.True(result.Allowed)
+
+	s.Require().NoError(s.repository.Refund(ctx, "test|ip:"+uuid.New().String(), limit))
+}
+
+func (s *RateLimitRepositorySuite) TestTake_IndependentKeys() {
+	ctx := context.Background()
+	limit := ratelimit.Limit{Burst: 1, Period: time.Hour}
+
+	result, err := s.repository.Take(ctx, "test|ip:"+uuid.New().String(), limit)
+	s.Require().NoError(err)
+	s.True(result.Allowed)
+
+	result, err = s.repository.Take(ctx, "test|ip:"+uuid.New().String(), limit)
+	s.Require().NoError(err)
+	s.True(result.Allowed)
+}
+
+func (s *RateLimitRepositorySuite) TearDownSuite() {
+	s.pool.Close()
+}
+
+func TestRateLimitRepositorySuite(t *testing.T) {
+	suite.Run(t, new(RateLimitRepositorySuite))
+}
@@ -3,9 +3,12 @@ package cockroachdb
 import (
 	"context"
 	_ "embed"
+	"net/netip"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"gitlab.mreg.io/my-registry/auth/domain/registration"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/ulid"
 )
 
 //go:embed sql/createRegistrationFlow.sql
@@ -14,6 +17,9 @@ var insertRegistrationFlowSQL string
 //go:embed sql/queryRegistrationFlow.sql
 var queryRegistrationFlowSQL string
 
+//go:embed sql/countRecentRegistrationFlows.sql
+var countRecentRegistrationFlowsSQL string
+
 type RegistrationRepository struct {
 	db *pgxpool.Pool
 }
@@ -23,21 +29,45 @@ func NewRegistrationRepository(db *pgxpool.Pool) registration.Repository {
 }
 
 func (r *RegistrationRepository) CreateFlow(ctx context.Context, flow *registration.Flow) error {
-	return r.db.
+	var challengeSeed string
+	var challengeDifficulty int16
+	if flow.Challenge != nil {
+		challengeSeed = flow.Challenge.Seed
+		challengeDifficulty = int16(flow.Challenge.Difficulty)
+	}
+	err := conn(ctx, r.db).
 		QueryRow(
 			ctx,
 			insertRegistrationFlowSQL,
-			flow.Interval, flow.SessionID,
+			ulid.New(), flow.Interval, flow.SessionID, challengeSeed, challengeDifficulty,
 		).
 		Scan(&flow.FlowID, &flow.IssuedAt, &flow.ExpiresAt)
+	return translateError(err)
 }
 
 func (r *RegistrationRepository) QueryFlowByFlowID(ctx context.Context, flow *registration.Flow) error {
-	return r.db.
+	var challengeSeed string
+	var challengeDifficulty int16
+	err := conn(ctx, r.db).
 		QueryRow(
 			ctx,
 			queryRegistrationFlowSQL,
 			flow.FlowID,
 		).
-		Scan(&flow.IssuedAt, &flow.ExpiresAt, &flow.SessionID)
+		Scan(&flow.IssuedAt, &flow.ExpiresAt, &flow.SessionID, &challengeSeed, &challengeDifficulty)
+	if err != nil {
+		return translateError(err)
+	}
+
+	flow.Challenge = nil
+	if challengeSeed != "" {
+		flow.Challenge = &registration.Challenge{Seed: challengeSeed, Difficulty: uint8(challengeDifficulty)}
+	}
+	return nil
+}
+
+func (r *RegistrationRepository) CountRecentFlows(ctx context.Context, network netip.Prefix, since time.Time) (int, error) {
+	var count int
+	err := conn(ctx, r.db).QueryRow(ctx, countRecentRegistrationFlowsSQL, network.Masked(), since).Scan(&count)
+	return count, translateError(err)
 }
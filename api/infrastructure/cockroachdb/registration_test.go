@@ -2,6 +2,8 @@ package cockroachdb
 
 import (
 	"context"
+	"math/rand/v2"
+	"net/netip"
 	"os"
 	"testing"
 	"time"
@@ -12,6 +14,8 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/suite"
 	"gitlab.mreg.io/my-registry/auth/domain/registration"
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/ulid"
 )
 
 type RegistrationRepositorySuite struct {
@@ -30,7 +34,7 @@ var (
 )
 
 func (s *RegistrationRepositorySuite) SetupSuite() {
-	config, err := pgxpool.ParseConfig(os.Getenv("DATABASE_URL"))
+	config, _, err := ParseConfig(os.Getenv("DATABASE_URL"))
 	s.Require().NoError(err)
 	s.pool, err = pgxpool.NewWithConfig(context.Background(), config)
 	s.Require().NoError(err)
@@ -68,8 +72,8 @@ func (s *RegistrationRepositorySuite) TestCreateFlow_WithoutErr() {
 	var savedSessionID string
 	var savedExpiry time.Time
 	err = s.pool.
-		QueryRow(ctx, `INSERT INTO sessions (active, authenticator_assurance_level, expires_at)
-        VALUES (true, 1, current_timestamp + $1)RETURNING id, expires_at`, SessionInterval).
+		QueryRow(ctx, `INSERT INTO sessions (id, active, authenticator_assurance_level, expires_at)
+        VALUES ($1, true, 1, current_timestamp + $2::INTERVAL) RETURNING id, expires_at`, ulid.New(), SessionInterval).
 		Scan(&savedSessionID, &savedExpiry)
 	s.Require().NoError(err)
 	testCase := &registration.Flow{
@@ -100,8 +104,8 @@ func (s *RegistrationRepositorySuite) TestCreateFlow_WithUnknownSessionID() {
 	var savedSessionID string
 	var savedExpiry time.Time
 	err = s.pool.
-		QueryRow(ctx, `INSERT INTO sessions (active, authenticator_assurance_level, expires_at)
-        VALUES (true, 1, current_timestamp + $1)RETURNING id, expires_at`, SessionInterval).
+		QueryRow(ctx, `INSERT INTO sessions (id, active, authenticator_assurance_level, expires_at)
+        VALUES ($1, true, 1, current_timestamp + $2::INTERVAL) RETURNING id, expires_at`, ulid.New(), SessionInterval).
 		Scan(&savedSessionID, &savedExpiry)
 	s.Require().NoError(err)
 	testCase := &registration.Flow{
@@ -110,7 +114,7 @@ func (s *RegistrationRepositorySuite) TestCreateFlow_WithUnknownSessionID() {
 	}
 
 	err = s.repository.CreateFlow(ctx, testCase)
-	s.Require().Error(err)
+	s.Require().ErrorIs(err, store.ErrInvalid)
 
 	var savedFlow registration.Flow
 	err = s.pool.
@@ -134,6 +138,15 @@ func (s *RegistrationRepositorySuite) TestQueryFlow1() {
 	s.Greater(flow.ExpiresAt, flow.IssuedAt)
 }
 
+func (s *RegistrationRepositorySuite) TestQueryFlow_Unknown() {
+	ctx := context.Background()
+	err := s.repository.QueryFlowByFlowID(ctx, &registration.Flow{FlowID: uuid.New().String()})
+	s.ErrorIs(err, store.ErrNotFound)
+
+	err = s.repository.QueryFlowByFlowID(ctx, &registration.Flow{FlowID: "not a flow ID"})
+	s.ErrorIs(err, store.ErrInvalid)
+}
+
 func (s *RegistrationRepositorySuite) TestQueryFlow2_SetUpAllField_ShouldOverWrite() {
 	issuedAt, err := time.Parse(time.UnixDate, "Wed Feb 25 11:06:39 PST 5069")
 	s.Require().NoError(err)
@@ -158,6 +171,66 @@ func (s *RegistrationRepositorySuite) TestQueryFlow2_SetUpAllField_ShouldOverWri
 	s.Greater(flow.ExpiresAt, flow.IssuedAt)
 }
 
+func (s *RegistrationRepositorySuite) TestCreateFlow_WithChallenge() {
+	ctx := context.Background()
+	var savedSessionID string
+	err := s.pool.
+		QueryRow(ctx, `INSERT INTO sessions (id, active, expires_at)
+        VALUES ($1, true, current_timestamp + interval '1 hour') RETURNING id`, ulid.New()).
+		Scan(&savedSessionID)
+	s.Require().NoError(err)
+	challenge, err := registration.NewChallenge(16)
+	s.Require().NoError(err)
+	created := &registration.Flow{
+		Interval:  time.Hour,
+		SessionID: savedSessionID,
+		Challenge: challenge,
+	}
+	s.Require().NoError(s.repository.CreateFlow(ctx, created))
+
+	queried := &registration.Flow{FlowID: created.FlowID}
+	s.Require().NoError(s.repository.QueryFlowByFlowID(ctx, queried))
+	s.Require().NotNil(queried.Challenge)
+	s.Equal(*challenge, *queried.Challenge)
+
+	// Flows without a challenge
+	queried = &registration.Flow{FlowID: registrationFlowID1.String(), Challenge: challenge}
+	s.Require().NoError(s.repository.QueryFlowByFlowID(ctx, queried))
+	s.Nil(queried.Challenge)
+}
+
+func (s *RegistrationRepositorySuite) TestCountRecentFlows() {
+	ctx := context.Background()
+	// Use a network no other test writes to
+	network := netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(rand.IntN(256)), byte(rand.IntN(256)), 0}), 24)
+	since := time.Now().Add(-time.Minute)
+
+	count, err := s.repository.CountRecentFlows(ctx, network, since)
+	s.Require().NoError(err)
+	s.Zero(count)
+
+	for i := range 3 {
+		var sessionID string
+		err = s.pool.
+			QueryRow(ctx, `INSERT INTO sessions (id, active, expires_at)
+            VALUES ($1, true, current_timestamp + interval '1 hour') RETURNING id`, ulid.New()).
+			Scan(&sessionID)
+		s.Require().NoError(err)
+		_, err = s.pool.Exec(ctx, `INSERT INTO devices (id, ip_address, geo_location, user_agent, session_id)
+            VALUES ($1, $2, '', 'test', $3)`, ulid.New(), network.Addr().Next(), sessionID)
+		s.Require().NoError(err)
+		s.Require().NoError(s.repository.CreateFlow(ctx, &registration.Flow{Interval: time.Hour, SessionID: sessionID}))
+
+		count, err = s.repository.CountRecentFlows(ctx, network, since)
+		s.Require().NoError(err)
+		s.Equal(i+1, count)
+	}
+
+	count, err = s.repository.CountRecentFlows(ctx, network, time.Now().Add(time.Minute))
+	s.Require().NoError(err)
+	s.Zero(count)
+}
+
 func (s *RegistrationRepositorySuite) TestQueryFlow3_SearchNotExistFlowID() {
 	ctx := context.Background()
 
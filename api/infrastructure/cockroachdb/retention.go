@@ -0,0 +1,78 @@
+package cockroachdb
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"gitlab.mreg.io/my-registry/auth/domain/retention"
+)
+
+//go:embed sql/acquireLease.sql
+var acquireLeaseSQL string
+
+//go:embed sql/deleteExpiredRegistrationFlows.sql
+var deleteExpiredRegistrationFlowsSQL string
+
+//go:embed sql/deleteExpiredSessions.sql
+var deleteExpiredSessionsSQL string
+
+//go:embed sql/deleteFinishedOutboxMessages.sql
+var deleteFinishedOutboxMessagesSQL string
+
+type retentionRepository struct {
+	db      *pgxpool.Pool
+	dialect Dialect
+}
+
+// NewRetentionRepository returns the repository of the reaper, on both
+// dialects. On CockroachDB, expired flows and sessions are left to the
+// row-level TTL set up by the migrations.
+func NewRetentionRepository(db *pgxpool.Pool, dialect Dialect) retention.Repository {
+	return &retentionRepository{db: db, dialect: dialect}
+}
+
+func (r *retentionRepository) AcquireLease(ctx context.Context, name string, holder string, duration time.Duration) (bool, error) {
+	err := conn(ctx, r.db).QueryRow(ctx, acquireLeaseSQL, name, holder, duration).Scan(&holder)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// held by another holder
+		return false, nil
+	}
+	return err == nil, translateError(err)
+}
+
+func (r *retentionRepository) DeleteExpiredFlows(ctx context.Context, before time.Time, limit int) (int, error) {
+	if r.dialect == CockroachDB {
+		return 0, nil
+	}
+	tag, err := conn(ctx, r.db).Exec(ctx, deleteExpiredRegistrationFlowsSQL, before, limit)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (r *retentionRepository) DeleteExpiredSessions(ctx context.Context, before time.Time, historyBefore time.Time, limit int) (int, error) {
+	if r.dialect == CockroachDB {
+		return 0, nil
+	}
+	tag, err := conn(ctx, r.db).Exec(ctx, deleteExpiredSessionsSQL, before, historyBefore, limit)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// DeleteFinishedMessages ages the messages by their last attempt, which is
+// when they were delivered or died.
+func (r *retentionRepository) DeleteFinishedMessages(ctx context.Context, before time.Time, limit int) (int, error) {
+	tag, err := conn(ctx, r.db).Exec(ctx, deleteFinishedOutboxMessagesSQL, before, limit)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return int(tag.RowsAffected()), nil
+}
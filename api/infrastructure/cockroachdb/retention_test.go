@@ -0,0 +1,188 @@
+package cockroachdb
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/retention"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/ulid"
+)
+
+type RetentionRepositorySuite struct {
+	suite.Suite
+	pool       *pgxpool.Pool
+	repository retention.Repository
+	dialect    Dialect
+}
+
+func (s *RetentionRepositorySuite) SetupSuite() {
+	config, dialect, err := ParseConfig(os.Getenv("DATABASE_URL"))
+	s.Require().NoError(err)
+	if dialect == "" {
+		dialect, err = DetectDialect(context.Background(), config.ConnConfig.Copy())
+		s.Require().NoError(err)
+	}
+	s.dialect = dialect
+	s.pool, err = pgxpool.NewWithConfig(context.Background(), config)
+	s.Require().NoError(err)
+	s.repository = NewRetentionRepository(s.pool, dialect)
+}
+
+// createSession creates a session with a device and a flow, expiring at
+// expiresAt, and returns the IDs of the session and the flow.
+func (s *RetentionRepositorySuite) createSession(expiresAt time.Time) (string, string) {
+	ctx := context.Background()
+	sessionID, flowID := ulid.New(), ulid.New()
+	issuedAt := expiresAt.Add(-time.Hour)
+	_, err := s.pool.Exec(ctx, `INSERT INTO sessions (id, active, issued_at, expires_at) VALUES ($1, true, $2, $3)`, sessionID, issuedAt, expiresAt)
+	s.Require().NoError(err)
+	_, err = s.pool.Exec(ctx, `INSERT INTO devices (id, ip_address, geo_location, user_agent, session_id)
+        VALUES ($1, '192.0.2.1', '', 'test', $2)`, ulid.New(), sessionID)
+	s.Require().NoError(err)
+	_, err = s.pool.Exec(ctx, `INSERT INTO registration_flows (id, issued_at, expires_at, session_id) VALUES ($1, $2, $3, $4)`, flowID, issuedAt, expiresAt, sessionID)
+	s.Require().NoError(err)
+	return sessionID, flowID
+}
+
+// identify gives the session of sessionID to a new identity.
+func (s *RetentionRepositorySuite) identify(sessionID string) {
+	ctx := context.Background()
+	identityID := ulid.New()
+	_, err := s.pool.Exec(ctx, `INSERT INTO identities (id, timezone) VALUES ($1, 'UTC')`, identityID)
+	s.Require().NoError(err)
+	_, err = s.pool.Exec(ctx, `UPDATE sessions SET identity_id = $1 WHERE id = $2`, identityID, sessionID)
+	s.Require().NoError(err)
+}
+
+func (s *RetentionRepositorySuite) exists(table string, id string) bool {
+	var exists bool
+	err := s.pool.QueryRow(context.Background(), `SELECT EXISTS(SELECT 1 FROM `+table+` WHERE id = $1)`, id).Scan(&exists)
+	s.Require().NoError(err)
+	return exists
+}
+
+func (s *RetentionRepositorySuite) TestAcquireLease() {
+	ctx := context.Background()
+	name := uuid.NewString()
+
+	held, err := s.repository.AcquireLease(ctx, name, "first", time.Minute)
+	s.Require().NoError(err)
+	s.True(held)
+
+	// Renewed by its holder, refused to others until it expires
+	held, err = s.repository.AcquireLease(ctx, name, "first", time.Millisecond)
+	s.Require().NoError(err)
+	s.True(held)
+	time.Sleep(10 * time.Millisecond)
+	held, err = s.repository.AcquireLease(ctx, name, "second", time.Minute)
+	s.Require().NoError(err)
+	s.True(held)
+	held, err = s.repository.AcquireLease(ctx, name, "first", time.Minute)
+	s.Require().NoError(err)
+	s.False(held)
+}
+
+func (s *RetentionRepositorySuite) TestDeleteExpired() {
+	ctx := context.Background()
+	// Far enough in the past not to reap the rows of other tests
+	expiresAt := time.Date(1971, time.January, 1, 0, 0, 0, 0, time.UTC)
+	before := expiresAt.Add(time.Hour)
+	expiredSessionID, expiredFlowID := s.createSession(expiresAt)
+	otherSessionID, _ := s.createSession(expiresAt)
+	liveSessionID, liveFlowID := s.createSession(before.Add(time.Hour))
+	identifiedSessionID, identifiedFlowID := s.createSession(expiresAt)
+	s.identify(identifiedSessionID)
+	historyBefore := expiresAt.Add(-time.Hour)
+	forgottenSessionID, _ := s.createSession(historyBefore.Add(-time.Hour))
+	s.identify(forgottenSessionID)
+
+	if s.dialect == CockroachDB {
+		// Left to the row-level TTL
+		deleted, err := s.repository.DeleteExpiredFlows(ctx, before, 10)
+		s.Require().NoError(err)
+		s.Zero(deleted)
+		deleted, err = s.repository.DeleteExpiredSessions(ctx, before, historyBefore, 10)
+		s.Require().NoError(err)
+		s.Zero(deleted)
+		s.True(s.exists("sessions", expiredSessionID))
+		return
+	}
+
+	deleted, err := s.repository.DeleteExpiredFlows(ctx, before, 1)
+	s.Require().NoError(err)
+	s.Equal(1, deleted)
+	deleted, err = s.repository.DeleteExpiredFlows(ctx, before, 10)
+	s.Require().NoError(err)
+	s.Equal(3, deleted)
+	s.False(s.exists("registration_flows", expiredFlowID))
+	s.False(s.exists("registration_flows", identifiedFlowID))
+	s.True(s.exists("sessions", expiredSessionID))
+
+	deleted, err = s.repository.DeleteExpiredSessions(ctx, before, historyBefore, 10)
+	s.Require().NoError(err)
+	s.Equal(3, deleted)
+	s.False(s.exists("sessions", expiredSessionID))
+	s.False(s.exists("sessions", otherSessionID))
+	var devices int
+	s.Require().NoError(s.pool.QueryRow(ctx, `SELECT count(*) FROM devices WHERE session_id = $1`, expiredSessionID).Scan(&devices))
+	s.Zero(devices)
+
+	s.True(s.exists("sessions", liveSessionID))
+	s.True(s.exists("registration_flows", liveFlowID))
+	// The sessions of identities keep their devices for the risk checks
+	// until the history period is over
+	s.True(s.exists("sessions", identifiedSessionID))
+	s.False(s.exists("sessions", forgottenSessionID))
+	s.Require().NoError(s.pool.QueryRow(ctx, `SELECT count(*) FROM devices WHERE session_id = $1`, identifiedSessionID).Scan(&devices))
+	s.Equal(1, devices)
+}
+
+// createMessage creates an outbox message in state, last attempted at
+// attemptedAt, and returns its ID.
+func (s *RetentionRepositorySuite) createMessage(state string, attemptedAt time.Time) string {
+	id := ulid.New()
+	_, err := s.pool.Exec(context.Background(), `INSERT INTO outbox_messages (id, type, subject, data, state, next_attempt_at)
+        VALUES ($1, 'test', $1, '{}', $2, $3)`, id, state, attemptedAt)
+	s.Require().NoError(err)
+	return id
+}
+
+func (s *RetentionRepositorySuite) TestDeleteFinishedMessages() {
+	ctx := context.Background()
+	// Far enough in the past not to reap the rows of other tests
+	attemptedAt := time.Date(1971, time.January, 1, 0, 0, 0, 0, time.UTC)
+	before := attemptedAt.Add(time.Hour)
+	deliveredID := s.createMessage("delivered", attemptedAt)
+	deadID := s.createMessage("dead", attemptedAt)
+	pendingID := s.createMessage("pending", attemptedAt)
+	recentID := s.createMessage("delivered", before.Add(time.Hour))
+
+	deleted, err := s.repository.DeleteFinishedMessages(ctx, before, 1)
+	s.Require().NoError(err)
+	s.Equal(1, deleted)
+	deleted, err = s.repository.DeleteFinishedMessages(ctx, before, 10)
+	s.Require().NoError(err)
+	s.Equal(1, deleted)
+	s.False(s.exists("outbox_messages", deliveredID))
+	s.False(s.exists("outbox_messages", deadID))
+	// Pending messages are still to be delivered
+	s.True(s.exists("outbox_messages", pendingID))
+	s.True(s.exists("outbox_messages", recentID))
+
+	_, err = s.pool.Exec(ctx, `DELETE FROM outbox_messages WHERE id = $1`, pendingID)
+	s.Require().NoError(err)
+}
+
+func (s *RetentionRepositorySuite) TearDownSuite() {
+	s.pool.Close()
+}
+
+func TestRetentionRepositorySuite(t *testing.T) {
+	suite.Run(t, new(RetentionRepositorySuite))
+}
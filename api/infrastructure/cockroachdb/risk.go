@@ -0,0 +1,61 @@
+package cockroachdb
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"gitlab.mreg.io/my-registry/auth/domain/risk"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+)
+
+//go:embed sql/queryDeviceHistory.sql
+var queryDeviceHistorySQL string
+
+//go:embed sql/queryLastDevice.sql
+var queryLastDeviceSQL string
+
+type riskRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRiskRepository(db *pgxpool.Pool) risk.Repository {
+	return &riskRepository{db: db}
+}
+
+func (r *riskRepository) QueryHistory(ctx context.Context, identityID string, location session.Location) (risk.History, error) {
+	var history risk.History
+	err := conn(ctx, r.db).
+		QueryRow(ctx, queryDeviceHistorySQL, identityID, location.CountryCode, location.ASN).
+		Scan(&history.Devices, &history.KnownCountry, &history.KnownASN)
+	if err != nil || history.Devices == 0 {
+		return history, err
+	}
+
+	var last risk.Sighting
+	err = conn(ctx, r.db).
+		QueryRow(ctx, queryLastDeviceSQL, identityID).
+		Scan(
+			&last.Location.CountryCode,
+			&last.Location.Region,
+			&last.Location.City,
+			&last.Location.ASN,
+			&last.Location.ASOrganization,
+			&last.Location.Latitude,
+			&last.Location.Longitude,
+			&last.Location.AccuracyRadius,
+			&last.SeenAt,
+		)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// The devices were deleted in between
+		return history, nil
+	}
+	if err != nil {
+		return risk.History{}, err
+	}
+	history.Last = &last
+	return history, nil
+}
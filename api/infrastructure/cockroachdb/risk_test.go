@@ -0,0 +1,86 @@
+package cockroachdb
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/risk"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+)
+
+type RiskRepositorySuite struct {
+	suite.Suite
+	pool       *pgxpool.Pool
+	repository risk.Repository
+	sessions   session.Repository
+}
+
+var (
+	riskIdentityID = uuid.New()
+	riskSessionID  = uuid.New()
+	riskLondon     = session.Location{CountryCode: "GB", City: "London", ASN: 20712, Latitude: 51.5142, Longitude: -0.0931, AccuracyRadius: 20}
+	riskParis      = session.Location{CountryCode: "FR", City: "Paris", ASN: 3215, Latitude: 48.8582, Longitude: 2.3387, AccuracyRadius: 20}
+)
+
+func (s *RiskRepositorySuite) SetupSuite() {
+	config, _, err := ParseConfig(os.Getenv("DATABASE_URL"))
+	s.Require().NoError(err)
+	s.pool, err = pgxpool.NewWithConfig(context.Background(), config)
+	s.Require().NoError(err)
+	s.repository = NewRiskRepository(s.pool)
+	s.sessions = NewSessionRepository(s.pool)
+
+	ctx := context.Background()
+	_, err = s.pool.Exec(ctx, `INSERT INTO identities (id, timezone) VALUES ($1, 'Europe/London')`, riskIdentityID)
+	s.Require().NoError(err)
+	_, err = s.pool.Exec(ctx, `
+        INSERT INTO sessions (id, active, authenticator_assurance_level, issued_at, expires_at, identity_id)
+        VALUES ($1, true, 1, current_timestamp, current_timestamp + interval '2 hours', $2)
+    `, riskSessionID, riskIdentityID)
+	s.Require().NoError(err)
+
+	for _, location := range []session.Location{riskLondon, riskParis} {
+		device := session.NewDevice(netip.MustParseAddr("81.2.69.142"), "Mozilla")
+		device.SessionID = riskSessionID.String()
+		device.SetLocation(location)
+		s.Require().NoError(s.sessions.InsertDevice(ctx, &device))
+	}
+}
+
+func (s *RiskRepositorySuite) TestQueryHistory() {
+	ctx := context.Background()
+	history, err := s.repository.QueryHistory(ctx, riskIdentityID.String(), riskLondon)
+	s.Require().NoError(err)
+	s.Equal(2, history.Devices)
+	s.True(history.KnownCountry)
+	s.True(history.KnownASN)
+	s.Require().NotNil(history.Last)
+	s.Equal(riskParis, history.Last.Location)
+	s.WithinDuration(time.Now(), history.Last.SeenAt, time.Minute)
+
+	history, err = s.repository.QueryHistory(ctx, riskIdentityID.String(), session.Location{CountryCode: "TW", ASN: 3462})
+	s.Require().NoError(err)
+	s.False(history.KnownCountry)
+	s.False(history.KnownASN)
+}
+
+func (s *RiskRepositorySuite) TestQueryHistory_NoDevices() {
+	history, err := s.repository.QueryHistory(context.Background(), uuid.New().String(), riskLondon)
+	s.Require().NoError(err)
+	s.Equal(risk.History{}, history)
+}
+
+func (s *RiskRepositorySuite) TearDownSuite() {
+	s.pool.Close()
+}
+
+func TestRiskRepositorySuite(t *testing.T) {
+	suite.Run(t, new(RiskRepositorySuite))
+}
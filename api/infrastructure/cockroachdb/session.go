@@ -10,6 +10,7 @@ import (
 
 	"gitlab.mreg.io/my-registry/auth/domain/identity"
 	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/ulid"
 )
 
 //go:embed sql/createSession.sql
@@ -24,6 +25,12 @@ var querySessionWithDevicesSQL string
 //go:embed sql/updateDevice.sql
 var updateDeviceSQL string
 
+//go:embed sql/deleteSession.sql
+var deleteSessionSQL string
+
+//go:embed sql/rememberDevice.sql
+var rememberDeviceSQL string
+
 type sessionRepository struct {
 	db *pgxpool.Pool
 }
@@ -39,15 +46,24 @@ func (r *sessionRepository) CreateSession(ctx context.Context, session *session.
 
 	device := session.Devices[0]
 
-	return r.db.
+	err := conn(ctx, r.db).
 		QueryRow(
 			ctx,
 			createSessionSQL,
-			session.Active, zeronull.Int2(session.AuthenticatorAssuranceLevel), session.ExpiryInterval, zeronull.Timestamptz(session.AuthenticatedAt),
+			ulid.New(), ulid.New(), session.Active, zeronull.Int2(session.AuthenticatorAssuranceLevel), session.ExpiryInterval, zeronull.Timestamptz(session.AuthenticatedAt),
 			session.Identity,
 			device.IPAddress, device.GeoLocation, device.UserAgent,
+			device.Location.CountryCode, device.Location.Region, device.Location.City, device.Location.ASN, device.Location.ASOrganization,
+			device.Client.Browser, device.Client.BrowserVersion, device.Client.OS, device.Client.OSVersion, device.Client.DeviceType,
+			device.Location.Latitude, device.Location.Longitude, device.Location.AccuracyRadius, device.Risk.Score, device.Risk.Decision, riskReasons(device.Risk),
 		).
 		Scan(&session.ID, &session.IssuedAt, &session.ExpiresAt, &session.Devices[0].ID)
+	return translateError(err)
+}
+
+func (r *sessionRepository) DeleteSession(ctx context.Context, sessionID string) error {
+	_, err := conn(ctx, r.db).Exec(ctx, deleteSessionSQL, sessionID)
+	return translateError(err)
 }
 
 func sessionFields(session *session.Session) []interface{} {
@@ -58,6 +74,7 @@ func sessionFields(session *session.Session) []interface{} {
 		&session.ExpiresAt,
 		&session.AuthenticatedAt,
 		&session.Identity.ID,
+		&session.Identity.Locale,
 	}
 }
 
@@ -68,27 +85,53 @@ func deviceFields(device *session.Device) []interface{} {
 		&device.GeoLocation,
 		&device.UserAgent,
 		&device.SessionID,
+		&device.Location.CountryCode,
+		&device.Location.Region,
+		&device.Location.City,
+		&device.Location.ASN,
+		&device.Location.ASOrganization,
+		&device.Client.Browser,
+		&device.Client.BrowserVersion,
+		&device.Client.OS,
+		&device.Client.OSVersion,
+		&device.Client.DeviceType,
+		&device.Location.Latitude,
+		&device.Location.Longitude,
+		&device.Location.AccuracyRadius,
+		&device.Risk.Score,
+		&device.Risk.Decision,
+		&device.Risk.Reasons,
+	}
+}
+
+// riskReasons returns the reasons of risk for the NOT NULL risk_reasons
+// column.
+func riskReasons(risk session.Risk) []string {
+	if risk.Reasons == nil {
+		return []string{}
 	}
+	return risk.Reasons
 }
 
 func (r *sessionRepository) QuerySessionByID(ctx context.Context, session *session.Session) error {
 	if session.Identity == nil {
 		session.Identity = &identity.Identity{}
 	}
-	return r.db.
+	err := conn(ctx, r.db).
 		QueryRow(
 			ctx,
 			querySessionByIDSQL,
 			session.ID,
 		).
 		Scan(sessionFields(session)...)
+	return translateError(err)
 }
 
 func (r *sessionRepository) QuerySessionWithDevices(ctx context.Context, sessionData *session.Session) error {
 	if sessionData.Identity == nil {
 		sessionData.Identity = &identity.Identity{}
 	}
-	err := r.db.
+	err := conn(ctx, r.db).
 		QueryRow(
 			ctx,
 			querySessionByIDSQL,
@@ -96,11 +139,11 @@ func (r *sessionRepository) QuerySessionWithDevices(ctx context.Context, session
 		).
 		Scan(sessionFields(sessionData)...)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
-	rows, err := r.db.Query(ctx, querySessionWithDevicesSQL, sessionData.ID)
+	rows, err := conn(ctx, r.db).Query(ctx, querySessionWithDevicesSQL, sessionData.ID)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
 	var devices []session.Device
@@ -123,11 +166,27 @@ func (r *sessionRepository) QuerySessionWithDevices(ctx context.Context, session
 }
 
 func (r *sessionRepository) InsertDevice(ctx context.Context, device *session.Device) error {
-	return r.db.
+	err := conn(ctx, r.db).
 		QueryRow(
 			ctx,
 			updateDeviceSQL,
-			device.IPAddress, device.GeoLocation, device.UserAgent, device.SessionID,
+			ulid.New(), device.IPAddress, device.GeoLocation, device.UserAgent, device.SessionID,
+			device.Location.CountryCode, device.Location.Region, device.Location.City, device.Location.ASN, device.Location.ASOrganization,
+			device.Client.Browser, device.Client.BrowserVersion, device.Client.OS, device.Client.OSVersion, device.Client.DeviceType,
+			device.Location.Latitude, device.Location.Longitude, device.Location.AccuracyRadius, device.Risk.Score, device.Risk.Decision, riskReasons(device.Risk),
 		).
 		Scan(&device.ID)
+	return translateError(err)
+}
+
+func (r *sessionRepository) RememberDevice(ctx context.Context, identityID string, device *session.Device) (bool, error) {
+	var isNew bool
+	client := device.Client
+	if !client.Known() {
+		client = session.ParseUserAgent(device.UserAgent)
+	}
+	err := conn(ctx, r.db).
+		QueryRow(ctx, rememberDeviceSQL, identityID, client.Browser, client.OS, device.Location.CountryCode).
+		Scan(&isNew)
+	return isNew, translateError(err)
 }
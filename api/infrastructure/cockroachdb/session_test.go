@@ -35,7 +35,7 @@ var (
 )
 
 func (s *SessionRepositorySuite) SetupSuite() {
-	config, err := pgxpool.ParseConfig(os.Getenv("DATABASE_URL"))
+	config, _, err := ParseConfig(os.Getenv("DATABASE_URL"))
 	s.Require().NoError(err)
 	s.pool, err = pgxpool.NewWithConfig(context.Background(), config)
 	s.Require().NoError(err)
@@ -327,6 +327,12 @@ func (s *SessionRepositorySuite) TestUpdateDevice_NoErr() {
 		IPAddress:   netip.MustParseAddr("192.168.69.2"),
 		UserAgent:   "Chrome",
 		GeoLocation: "USA",
+		Location: session.Location{
+			CountryCode: "US", Region: "California", City: "San Jose", ASN: 64500, ASOrganization: "Example",
+			Latitude: 37.3388, Longitude: -121.8916, AccuracyRadius: 20,
+		},
+		Client: session.Client{Browser: "Chrome", BrowserVersion: "129.0.0.0", OS: "Windows", OSVersion: "10.0", DeviceType: session.DeviceTypeDesktop},
+		Risk:   session.Risk{Score: 35, Decision: "allow", Reasons: []string{"new_country", "new_asn"}},
 	}
 	err := s.repository.InsertDevice(ctx, device)
 	s.Require().NoError(err)
@@ -348,6 +354,9 @@ func (s *SessionRepositorySuite) TestUpdateDevice_NoErr() {
 	s.Require().Equal("192.168.69.2", sessionData.Devices[1].IPAddress.String())
 	s.Require().Equal("Chrome", sessionData.Devices[1].UserAgent)
 	s.Require().Equal("USA", sessionData.Devices[1].GeoLocation)
+	s.Require().Equal(device.Location, sessionData.Devices[1].Location)
+	s.Require().Equal(device.Client, sessionData.Devices[1].Client)
+	s.Require().Equal(device.Risk, sessionData.Devices[1].Risk)
 }
 
 func (s *SessionRepositorySuite) TestUpdateDevice_NotExistSession_Err() {
@@ -377,6 +386,50 @@ func (s *SessionRepositorySuite) TestUpdateDevice_NotProvideSessionID_Err() {
 	s.Require().Error(err)
 }
 
+func (s *SessionRepositorySuite) TestRememberDevice() {
+	ctx := context.Background()
+	identityID := sessionIdentityID1.String()
+	device := session.NewDevice(netip.MustParseAddr("192.0.2.1"), "Mozilla/5.0 (X11; Linux x86_64; rv:130.0) Gecko/20100101 Firefox/130.0")
+	device.SetLocation(session.Location{CountryCode: "TW"})
+
+	isNew, err := s.repository.RememberDevice(ctx, identityID, &device)
+	s.Require().NoError(err)
+	s.True(isNew)
+
+	// Browser updates and other addresses in the same country are known
+	updated := session.NewDevice(netip.MustParseAddr("192.0.2.2"), "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0")
+	updated.SetLocation(session.Location{CountryCode: "TW"})
+	isNew, err = s.repository.RememberDevice(ctx, identityID, &updated)
+	s.Require().NoError(err)
+	s.False(isNew)
+
+	updated.SetLocation(session.Location{CountryCode: "JP"})
+	isNew, err = s.repository.RememberDevice(ctx, identityID, &updated)
+	s.Require().NoError(err)
+	s.True(isNew)
+
+	// Devices are known per identity
+	isNew, err = s.repository.RememberDevice(ctx, sessionIdentityID2.String(), &device)
+	s.Require().NoError(err)
+	s.True(isNew)
+}
+
+func (s *SessionRepositorySuite) TestDeleteSession() {
+	ctx := context.Background()
+	sessionData := &session.Session{
+		Active:         true,
+		ExpiryInterval: time.Hour,
+		Devices:        []session.Device{session.NewDevice(netip.MustParseAddr("192.0.2.1"), "Mozilla")},
+	}
+	s.Require().NoError(s.repository.CreateSession(ctx, sessionData))
+
+	s.Require().NoError(s.repository.DeleteSession(ctx, sessionData.ID))
+	s.Require().Error(s.repository.QuerySessionByID(ctx, &session.Session{ID: sessionData.ID}))
+
+	// Deleting twice is not an error
+	s.Require().NoError(s.repository.DeleteSession(ctx, sessionData.ID))
+}
+
 func (s *SessionRepositorySuite) TearDownSuite() {
 	s.pool.Close()
 }
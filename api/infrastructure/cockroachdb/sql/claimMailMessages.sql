@@ -0,0 +1,13 @@
+-- noinspection SqlResolveForFile
+UPDATE mail_messages
+SET attempts        = attempts + 1,
+    next_attempt_at = current_timestamp + $2::INTERVAL
+WHERE id IN (
+    SELECT id
+    FROM mail_messages
+    WHERE NOT dead
+      AND next_attempt_at <= current_timestamp
+    ORDER BY next_attempt_at
+    LIMIT $1
+)
+RETURNING id, from_address, to_address, subject, text_body, html_body, date, attempts, COALESCE(last_error, '') AS last_error;
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- noinspection SqlResolveForFile
+UPDATE outbox_messages
+SET attempts        = attempts + 1,
+    next_attempt_at = current_timestamp + $2::INTERVAL
+WHERE id IN (
+    SELECT id
+    FROM outbox_messages
+    WHERE state = 'pending'
+      AND next_attempt_at <= current_timestamp
+    ORDER BY next_attempt_at
+    LIMIT $1
+)
+RETURNING id, type, subject, data, create_time, state, attempts, next_attempt_at, delivered_to, COALESCE(last_error, '') AS last_error;
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- noinspection SqlResolveForFile
+SELECT count(DISTINCT registration_flows.id)
+FROM registration_flows
+    JOIN devices ON registration_flows.session_id = devices.session_id
+WHERE devices.ip_address <<= $1
+  AND registration_flows.issued_at >= $2;
@@ -0,0 +1,4 @@
+-- noinspection SqlResolveForFile
+INSERT INTO audit_events (id, type, actor, identity_id, session_id, ip_address, user_agent, outcome, action)
+VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, '')::UUID, NULLIF($5, '')::UUID, $6, NULLIF($7, ''), $8, NULLIF($9, ''))
+RETURNING id, create_time;
\ No newline at end of file
@@ -1,19 +1,19 @@
 -- noinspection SqlResolveForFile
 WITH
     identity AS (
-        INSERT INTO identities(timezone)
-        VALUES ($1)
+        INSERT INTO identities (id, timezone, locale)
+        VALUES ($1, $2, $5)
         RETURNING *
     ),
     email AS (
         INSERT INTO emails (address, identity_id)
-        SELECT  $2, identity.id
+        SELECT  $3, identity.id
         FROM identity
         RETURNING *
     ),
     password AS (
         INSERT INTO passwords (identity_id, password_hash)
-        SELECT identity.id, $3
+        SELECT identity.id, $4
         FROM identity
         RETURNING *
     )
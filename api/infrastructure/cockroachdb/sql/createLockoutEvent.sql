@@ -0,0 +1,4 @@
+-- noinspection SqlResolveForFile
+INSERT INTO lockout_events (id, identity_id, type, ip_address, actor, locked_until)
+VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+RETURNING id, create_time;
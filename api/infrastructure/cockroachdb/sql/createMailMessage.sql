@@ -0,0 +1,3 @@
+-- noinspection SqlResolveForFile
+INSERT INTO mail_messages (id, from_address, to_address, subject, text_body, html_body, date)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
\ No newline at end of file
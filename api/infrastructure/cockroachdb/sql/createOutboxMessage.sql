@@ -0,0 +1,4 @@
+-- noinspection SqlResolveForFile
+INSERT INTO outbox_messages (id, type, subject, data)
+VALUES ($1, $2, $3, $4)
+RETURNING id, create_time, state, next_attempt_at;
\ No newline at end of file
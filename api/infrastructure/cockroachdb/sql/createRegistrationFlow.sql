@@ -1,7 +1,7 @@
 -- noinspection SqlResolveForFile
 WITH inserted_flow AS (
-    INSERT INTO registration_flows (expires_at, session_id)
-        VALUES (current_timestamp + $1, $2)
+    INSERT INTO registration_flows (id, expires_at, session_id, challenge_seed, challenge_difficulty)
+        VALUES ($1, current_timestamp + $2::INTERVAL, $3, NULLIF($4, ''), NULLIF($5, 0))
         RETURNING id, issued_at, expires_at
 )
 SELECT id, issued_at, expires_at
@@ -1,12 +1,14 @@
 -- noinspection SqlResolveForFile
 WITH session AS (
-    INSERT INTO sessions (active, authenticator_assurance_level, expires_at, authenticated_at, identity_id)
-        VALUES ($1, $2, current_timestamp + $3, $4, NULLIF($5::TEXT, '')::UUID)
+    INSERT INTO sessions (id, active, authenticator_assurance_level, expires_at, authenticated_at, identity_id)
+        VALUES ($1, $3, $4, current_timestamp + $5::INTERVAL, $6, NULLIF($7::TEXT, '')::UUID)
         RETURNING *
 ),
 device AS (
-    INSERT INTO devices (ip_address, geo_location, user_agent, session_id)
-        SELECT $6, $7, $8, session.id
+    INSERT INTO devices (id, ip_address, geo_location, user_agent, session_id, country_code, region, city, asn, as_organization,
+                         browser, browser_version, os, os_version, device_type,
+                         latitude, longitude, accuracy_radius, risk_score, risk_decision, risk_reasons)
+        SELECT $2, $8, $9, $10, session.id, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26
         FROM session
     RETURNING *
 )
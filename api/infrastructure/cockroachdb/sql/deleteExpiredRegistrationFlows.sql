@@ -0,0 +1,8 @@
+-- noinspection SqlResolveForFile
+DELETE FROM registration_flows
+WHERE id IN (
+    SELECT id
+    FROM registration_flows
+    WHERE expires_at < $1
+    LIMIT $2
+);
\ No newline at end of file
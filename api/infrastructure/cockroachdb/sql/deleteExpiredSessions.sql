@@ -0,0 +1,8 @@
+-- noinspection SqlResolveForFile
+DELETE FROM sessions
+WHERE id IN (
+    SELECT id
+    FROM sessions
+    WHERE expires_at < $1 AND (identity_id IS NULL OR expires_at < $2)
+    LIMIT $3
+);
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- noinspection SqlResolveForFile
+DELETE FROM outbox_messages
+WHERE id IN (
+    SELECT id
+    FROM outbox_messages
+    WHERE state IN ('delivered', 'dead') AND next_attempt_at < $1
+    LIMIT $2
+);
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- noinspection SqlResolveForFile
+DELETE FROM login_failures
+WHERE key = $1;
@@ -0,0 +1,3 @@
+-- noinspection SqlResolveForFile
+DELETE FROM mail_messages
+WHERE id = $1;
\ No newline at end of file
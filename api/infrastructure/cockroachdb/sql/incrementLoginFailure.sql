@@ -0,0 +1,15 @@
+-- noinspection SqlResolveForFile
+INSERT INTO login_failures (key, failures, last_failure_at)
+VALUES ($1, 1, current_timestamp)
+ON CONFLICT (key) DO UPDATE SET
+    failures        = CASE
+                          WHEN login_failures.last_failure_at < current_timestamp - $2::INTERVAL THEN 1
+                          WHEN login_failures.locked_until <= current_timestamp THEN 1
+                          ELSE login_failures.failures + 1
+                      END,
+    locked_until    = CASE
+                          WHEN login_failures.locked_until <= current_timestamp THEN NULL
+                          ELSE login_failures.locked_until
+                      END,
+    last_failure_at = current_timestamp
+RETURNING failures, last_failure_at, locked_until;
@@ -0,0 +1,4 @@
+-- noinspection SqlResolveForFile
+UPDATE login_failures
+SET locked_until = $2
+WHERE key = $1;
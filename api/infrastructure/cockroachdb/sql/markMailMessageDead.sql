@@ -0,0 +1,5 @@
+-- noinspection SqlResolveForFile
+UPDATE mail_messages
+SET dead       = true,
+    last_error = $2
+WHERE id = $1;
\ No newline at end of file
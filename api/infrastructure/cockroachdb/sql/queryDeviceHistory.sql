@@ -0,0 +1,7 @@
+-- noinspection SqlResolveForFile
+SELECT count(*),
+       COALESCE(bool_or(devices.country_code = $2), false),
+       COALESCE(bool_or(devices.asn = $3), false)
+FROM sessions
+    JOIN devices ON devices.session_id = sessions.id
+WHERE sessions.identity_id = $1;
\ No newline at end of file
@@ -1,4 +1,4 @@
 -- noinspection SqlResolveForFile
-SELECT verified, create_time, COALESCE(verified_at, 0::timestamptz), update_time
+SELECT verified, create_time, COALESCE(verified_at, to_timestamp(0)), update_time
 FROM emails
 WHERE address = $1;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- noinspection SqlResolveForFile
+SELECT devices.country_code, devices.region, devices.city, devices.asn, devices.as_organization,
+       devices.latitude, devices.longitude, devices.accuracy_radius, devices.created_at
+FROM sessions
+    JOIN devices ON devices.session_id = sessions.id
+WHERE sessions.identity_id = $1
+ORDER BY devices.created_at DESC
+LIMIT 1;
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- noinspection SqlResolveForFile
+SELECT
+    id,
+    type,
+    ip_address,
+    COALESCE(actor, '') AS actor,
+    locked_until,
+    create_time
+FROM lockout_events
+WHERE identity_id = $1
+ORDER BY create_time DESC
+LIMIT $2;
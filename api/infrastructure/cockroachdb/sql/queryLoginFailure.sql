@@ -0,0 +1,4 @@
+-- noinspection SqlResolveForFile
+SELECT failures, last_failure_at, locked_until
+FROM login_failures
+WHERE key = $1;
@@ -0,0 +1,5 @@
+-- noinspection SqlResolveForFile
+SELECT tokens, updated_at
+FROM rate_limit_buckets
+WHERE key = $1
+FOR UPDATE;
@@ -1,4 +1,4 @@
 -- noinspection SqlResolveForFile
-SELECT issued_at, expires_at, session_id
+SELECT issued_at, expires_at, session_id, COALESCE(challenge_seed, ''), COALESCE(challenge_difficulty, 0)
 FROM registration_flows
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
@@ -0,0 +1,5 @@
+-- noinspection SqlResolveForFile
+SELECT EXISTS (SELECT 1
+               FROM flyway_schema_history
+               WHERE version = $1
+                 AND success);
@@ -4,7 +4,9 @@ SELECT
     COALESCE(authenticator_assurance_level, 0) AS authenticator_assurance_level,
     issued_at,
     expires_at,
-    COALESCE(authenticated_at, 0::timestamptz) as authenticated_at,
-    COALESCE(identity_id::text, '')  as identity_id
+    COALESCE(authenticated_at, to_timestamp(0)) as authenticated_at,
+    COALESCE(identity_id::text, '')  as identity_id,
+    COALESCE(identities.locale, '')  as locale
 FROM sessions
-WHERE id = $1;
\ No newline at end of file
+LEFT JOIN identities ON identities.id = sessions.identity_id
+WHERE sessions.id = $1;
\ No newline at end of file
@@ -1,4 +1,6 @@
 -- noinspection SqlResolveForFile
-SELECT *
+SELECT id, ip_address, geo_location, user_agent, session_id, country_code, region, city, asn, as_organization,
+       browser, browser_version, os, os_version, device_type,
+       latitude, longitude, accuracy_radius, risk_score, risk_decision, risk_reasons
 FROM devices
 WHERE session_id = $1;
\ No newline at end of file
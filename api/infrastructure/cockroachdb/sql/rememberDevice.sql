@@ -0,0 +1,13 @@
+-- noinspection SqlResolveForFile
+WITH known AS (
+    SELECT 1
+    FROM known_devices
+    WHERE identity_id = $1 AND browser = $2 AND os = $3 AND country_code = $4
+),
+seen AS (
+    INSERT INTO known_devices (identity_id, browser, os, country_code)
+        VALUES ($1, $2, $3, $4)
+    ON CONFLICT (identity_id, browser, os, country_code) DO UPDATE SET last_seen_at = current_timestamp
+    RETURNING 1
+)
+SELECT NOT EXISTS (SELECT 1 FROM known) FROM seen;
\ No newline at end of file
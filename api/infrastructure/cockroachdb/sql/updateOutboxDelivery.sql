@@ -0,0 +1,7 @@
+-- noinspection SqlResolveForFile
+UPDATE outbox_messages
+SET state           = $2,
+    next_attempt_at = $3,
+    delivered_to    = $4,
+    last_error      = NULLIF($5, '')
+WHERE id = $1;
\ No newline at end of file
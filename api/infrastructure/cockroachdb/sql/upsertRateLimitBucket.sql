@@ -0,0 +1,7 @@
+-- noinspection SqlResolveForFile
+INSERT INTO rate_limit_buckets (key, tokens, updated_at, expires_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (key) DO UPDATE SET
+    tokens     = excluded.tokens,
+    updated_at = excluded.updated_at,
+    expires_at = excluded.expires_at;
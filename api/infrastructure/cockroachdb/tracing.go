@@ -0,0 +1,97 @@
+package cockroachdb
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "gitlab.mreg.io/my-registry/auth/infrastructure/cockroachdb"
+
+//go:embed sql/*.sql
+var sqlFiles embed.FS
+
+// queryNames maps the text of every embedded query to its file name, which
+// is what spans are named after.
+var queryNames = func() map[string]string {
+	names := make(map[string]string)
+	entries, err := fs.ReadDir(sqlFiles, "sql")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		data, err := fs.ReadFile(sqlFiles, path.Join("sql", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		names[string(data)] = strings.TrimSuffix(entry.Name(), ".sql")
+	}
+	return names
+}()
+
+// queryName returns the name of the SQL file sql was embedded from.
+func queryName(sql string) string {
+	if name, ok := queryNames[sql]; ok {
+		return name
+	}
+	return "query"
+}
+
+// queryTracer creates a span for every query run on behalf of a traced
+// request. Queries without a parent span, such as the readiness probe, are
+// not traced.
+type queryTracer struct {
+	tracer trace.Tracer
+	system string
+}
+
+// querySpanKey holds the span started by TraceQueryStart, so that
+// TraceQueryEnd does not end the span of the caller.
+type querySpanKey struct{}
+
+// NewQueryTracer returns a pgx tracer reporting queries to the global
+// OpenTelemetry tracer provider, as run on a database of dialect.
+func NewQueryTracer(dialect Dialect) pgx.QueryTracer {
+	return &queryTracer{otel.Tracer(tracerName), string(dialect)}
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	config := conn.Config()
+	ctx, span := t.tracer.Start(ctx, queryName(data.SQL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", t.system),
+			attribute.String("db.namespace", config.Database),
+			attribute.String("db.query.text", data.SQL),
+			attribute.String("server.address", config.Host),
+			attribute.Int("server.port", int(config.Port)),
+		),
+	)
+	return context.WithValue(ctx, querySpanKey{}, span)
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span, ok := ctx.Value(querySpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	// No rows is an expected outcome of lookups
+	if data.Err != nil && !errors.Is(data.Err, pgx.ErrNoRows) {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+	}
+	span.SetAttributes(attribute.Int64("db.response.rows_affected", data.CommandTag.RowsAffected()))
+	span.End()
+}
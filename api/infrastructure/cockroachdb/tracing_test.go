@@ -0,0 +1,13 @@
+package cockroachdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryName(t *testing.T) {
+	assert.Equal(t, "createIdentity", queryName(createIdentitySQL))
+	assert.Equal(t, "querySchemaVersion", queryName(querySchemaVersionSQL))
+	assert.Equal(t, "query", queryName("SELECT 1"))
+}
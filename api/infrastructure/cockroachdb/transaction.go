@@ -0,0 +1,91 @@
+package cockroachdb
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"gitlab.mreg.io/my-registry/auth/domain/transaction"
+)
+
+const (
+	// maxTransactionAttempts bounds the runs of a unit of work that keeps
+	// conflicting with concurrent transactions.
+	maxTransactionAttempts = 5
+	// transactionRetryDelay is the delay before the first retry, doubled for
+	// every further one.
+	transactionRetryDelay = 20 * time.Millisecond
+)
+
+const (
+	// sqlStateSerializationFailure is returned when a transaction conflicts
+	// with a concurrent one and has to be retried by the client.
+	sqlStateSerializationFailure = "40001"
+	// sqlStateDeadlockDetected is returned by PostgreSQL when it aborts one of
+	// the transactions waiting on each other's rows.
+	sqlStateDeadlockDetected = "40P01"
+)
+
+type txKey struct{}
+
+// querier is implemented by both the pool and its transactions.
+type querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// conn returns the transaction carried by ctx, or db outside of a unit of
+// work. Every repository query goes through it.
+func conn(ctx context.Context, db *pgxpool.Pool) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+type transactionRunner struct {
+	db *pgxpool.Pool
+}
+
+// NewTransactionRunner returns a runner of serializable transactions on db,
+// retried when CockroachDB reports a serialization failure.
+func NewTransactionRunner(db *pgxpool.Pool) transaction.Runner {
+	return &transactionRunner{db: db}
+}
+
+func (r *transactionRunner) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+	delay := transactionRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := pgx.BeginTxFunc(ctx, r.db, pgx.TxOptions{IsoLevel: pgx.Serializable}, func(tx pgx.Tx) error {
+			return fn(context.WithValue(ctx, txKey{}, tx))
+		})
+		if !isRetryable(err) || attempt == maxTransactionAttempts {
+			return err
+		}
+		// jitter keeps the conflicting transactions from meeting again
+		select {
+		case <-time.After(delay/2 + rand.N(delay)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+// isRetryable reports whether err is a serialization failure, after which
+// the whole transaction can be run again.
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) &&
+		(pgErr.Code == sqlStateSerializationFailure || pgErr.Code == sqlStateDeadlockDetected)
+}
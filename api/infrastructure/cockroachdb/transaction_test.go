@@ -0,0 +1,100 @@
+package cockroachdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/transaction"
+)
+
+type TransactionRunnerSuite struct {
+	suite.Suite
+	pool       *pgxpool.Pool
+	runner     transaction.Runner
+	identities identity.Repository
+}
+
+func (s *TransactionRunnerSuite) SetupSuite() {
+	config, _, err := ParseConfig(os.Getenv("DATABASE_URL"))
+	s.Require().NoError(err)
+	s.pool, err = pgxpool.NewWithConfig(context.Background(), config)
+	s.Require().NoError(err)
+	s.runner = NewTransactionRunner(s.pool)
+	s.identities = NewIdentityRepository(s.pool)
+}
+
+func (s *TransactionRunnerSuite) TestRollback() {
+	ctx := context.Background()
+	email := generateRandomEmail()
+	failure := errors.New("failure")
+	err := s.runner.Run(ctx, func(ctx context.Context) error {
+		created := &identity.Identity{Timezone: "UTC", Emails: []identity.Email{{Value: email}}}
+		if err := s.identities.CreateIdentity(ctx, created); err != nil {
+			return err
+		}
+		// the identity is seen inside the transaction only
+		exists, err := s.identities.EmailExists(ctx, email)
+		s.Require().NoError(err)
+		s.True(exists)
+		return failure
+	})
+	s.ErrorIs(err, failure)
+
+	exists, err := s.identities.EmailExists(ctx, email)
+	s.Require().NoError(err)
+	s.False(exists)
+}
+
+func (s *TransactionRunnerSuite) TestCommit() {
+	ctx := context.Background()
+	email := generateRandomEmail()
+	err := s.runner.Run(ctx, func(ctx context.Context) error {
+		// nested units of work join the transaction
+		return s.runner.Run(ctx, func(ctx context.Context) error {
+			created := &identity.Identity{Timezone: "UTC", Emails: []identity.Email{{Value: email}}}
+			return s.identities.CreateIdentity(ctx, created)
+		})
+	})
+	s.Require().NoError(err)
+
+	exists, err := s.identities.EmailExists(ctx, email)
+	s.Require().NoError(err)
+	s.True(exists)
+}
+
+func (s *TransactionRunnerSuite) TestRetry() {
+	runs := 0
+	err := s.runner.Run(context.Background(), func(ctx context.Context) error {
+		runs++
+		if runs < 3 {
+			return fmt.Errorf("creating identity: %w", &pgconn.PgError{Code: sqlStateSerializationFailure})
+		}
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal(3, runs)
+
+	runs = 0
+	err = s.runner.Run(context.Background(), func(ctx context.Context) error {
+		runs++
+		return &pgconn.PgError{Code: sqlStateSerializationFailure}
+	})
+	s.True(isRetryable(err))
+	s.Equal(maxTransactionAttempts, runs)
+}
+
+func (s *TransactionRunnerSuite) TearDownSuite() {
+	s.pool.Close()
+}
+
+func TestTransactionRunnerSuite(t *testing.T) {
+	suite.Run(t, new(TransactionRunnerSuite))
+}
@@ -0,0 +1,179 @@
+// Package geoip resolves device locations from local MaxMind DB files.
+package geoip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/logging"
+)
+
+// nameLanguage is the language of the place names returned.
+const nameLanguage = "en"
+
+// record holds the fields read from GeoIP2/GeoLite2 City, Country and ASN
+// databases, and from other databases using the same layout.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		Latitude       float64 `maxminddb:"latitude"`
+		Longitude      float64 `maxminddb:"longitude"`
+		AccuracyRadius uint16  `maxminddb:"accuracy_radius"`
+	} `maxminddb:"location"`
+	ASN            uint32 `maxminddb:"autonomous_system_number"`
+	ASOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+type database struct {
+	path    string
+	modTime time.Time
+	size    int64
+	reader  *maxminddb.Reader
+}
+
+// Resolver looks addresses up in one or more .mmdb files, such as a City
+// and an ASN database, and merges the results. Files are read into memory,
+// so that they can be replaced on disk while the server is running.
+type Resolver struct {
+	paths     []string
+	databases atomic.Pointer[[]*database]
+	reloadMu  sync.Mutex
+}
+
+// Open reads the databases at paths.
+func Open(paths ...string) (*Resolver, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("geoip: no database")
+	}
+	r := &Resolver{paths: paths}
+	databases := make([]*database, 0, len(paths))
+	for _, path := range paths {
+		db, err := openDatabase(path)
+		if err != nil {
+			return nil, err
+		}
+		databases = append(databases, db)
+	}
+	r.databases.Store(&databases)
+	return r, nil
+}
+
+func openDatabase(path string) (*database, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("geoip: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("geoip: %w", err)
+	}
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("geoip: %s: %w", path, err)
+	}
+	return &database{path, info.ModTime(), info.Size(), reader}, nil
+}
+
+// Lookup returns the location of addr. Private and unknown addresses
+// resolve to the zero Location.
+func (r *Resolver) Lookup(_ context.Context, addr netip.Addr) (session.Location, error) {
+	var location session.Location
+	ip := net.IP(addr.Unmap().AsSlice())
+	for _, db := range *r.databases.Load() {
+		var rec record
+		if err := db.reader.Lookup(ip, &rec); err != nil {
+			return session.Location{}, fmt.Errorf("geoip: %s: %w", db.path, err)
+		}
+		if rec.Country.ISOCode != "" {
+			location.CountryCode = rec.Country.ISOCode
+		}
+		if len(rec.Subdivisions) > 0 && rec.Subdivisions[0].Names[nameLanguage] != "" {
+			location.Region = rec.Subdivisions[0].Names[nameLanguage]
+		}
+		if rec.City.Names[nameLanguage] != "" {
+			location.City = rec.City.Names[nameLanguage]
+		}
+		if rec.Location.AccuracyRadius != 0 {
+			location.Latitude = rec.Location.Latitude
+			location.Longitude = rec.Location.Longitude
+			location.AccuracyRadius = rec.Location.AccuracyRadius
+		}
+		if rec.ASN != 0 {
+			location.ASN = rec.ASN
+			location.ASOrganization = rec.ASOrganization
+		}
+	}
+	return location, nil
+}
+
+// Reload reads the files that changed since they were last read. A file
+// that cannot be read keeps its previous version in use.
+func (r *Resolver) Reload() (reloaded bool, err error) {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	current := *r.databases.Load()
+	next := make([]*database, len(current))
+	var errs []error
+	for i, db := range current {
+		next[i] = db
+		info, err := os.Stat(db.path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("geoip: %w", err))
+			continue
+		}
+		if info.ModTime().Equal(db.modTime) && info.Size() == db.size {
+			continue
+		}
+		updated, err := openDatabase(db.path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		next[i] = updated
+		reloaded = true
+	}
+	if reloaded {
+		r.databases.Store(&next)
+	}
+	return reloaded, errors.Join(errs...)
+}
+
+// Watch calls Reload every interval until ctx is canceled.
+func (r *Resolver) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if err != nil {
+				slog.WarnContext(ctx, "error reloading GeoIP database", logging.Error(err))
+			}
+			if reloaded {
+				slog.InfoContext(ctx, "GeoIP database reloaded")
+			}
+		}
+	}
+}
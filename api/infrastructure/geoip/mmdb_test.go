@@ -0,0 +1,128 @@
+package geoip
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+)
+
+type resolverTestSuite struct {
+	suite.Suite
+	cityPath string
+	asnPath  string
+}
+
+func (s *resolverTestSuite) SetupTest() {
+	dir := s.T().TempDir()
+	s.cityPath = filepath.Join(dir, "City.mmdb")
+	s.asnPath = filepath.Join(dir, "ASN.mmdb")
+	s.writeCity("London")
+	s.writeDatabase(s.asnPath, "GeoLite2-ASN", mmdbtype.Map{
+		"autonomous_system_number":       mmdbtype.Uint32(20712),
+		"autonomous_system_organization": mmdbtype.String("Andrews & Arnold Ltd"),
+	})
+}
+
+func (s *resolverTestSuite) writeCity(city string) {
+	s.writeDatabase(s.cityPath, "GeoLite2-City", mmdbtype.Map{
+		"country": mmdbtype.Map{"iso_code": mmdbtype.String("GB")},
+		"subdivisions": mmdbtype.Slice{
+			mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String("England")}},
+		},
+		"city": mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String(city)}},
+		"location": mmdbtype.Map{
+			"latitude":        mmdbtype.Float64(51.5142),
+			"longitude":       mmdbtype.Float64(-0.0931),
+			"accuracy_radius": mmdbtype.Uint16(10),
+		},
+	})
+}
+
+func (s *resolverTestSuite) writeDatabase(path string, databaseType string, value mmdbtype.Map) {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{DatabaseType: databaseType, RecordSize: 24})
+	s.Require().NoError(err)
+	_, network, err := net.ParseCIDR("81.2.69.0/24")
+	s.Require().NoError(err)
+	s.Require().NoError(tree.Insert(network, value))
+
+	file, err := os.Create(path)
+	s.Require().NoError(err)
+	defer file.Close()
+	_, err = tree.WriteTo(file)
+	s.Require().NoError(err)
+}
+
+func (s *resolverTestSuite) TestLookup() {
+	resolver, err := Open(s.cityPath, s.asnPath)
+	s.Require().NoError(err)
+
+	location, err := resolver.Lookup(context.Background(), netip.MustParseAddr("81.2.69.142"))
+	s.Require().NoError(err)
+	s.Equal(session.Location{
+		CountryCode:    "GB",
+		Region:         "England",
+		City:           "London",
+		ASN:            20712,
+		ASOrganization: "Andrews & Arnold Ltd",
+		Latitude:       51.5142,
+		Longitude:      -0.0931,
+		AccuracyRadius: 10,
+	}, location)
+
+	// IPv4-mapped addresses are looked up as IPv4
+	location, err = resolver.Lookup(context.Background(), netip.MustParseAddr("::ffff:81.2.69.142"))
+	s.Require().NoError(err)
+	s.Equal("London", location.City)
+
+	location, err = resolver.Lookup(context.Background(), netip.MustParseAddr("10.0.0.1"))
+	s.Require().NoError(err)
+	s.Zero(location)
+}
+
+func (s *resolverTestSuite) TestReload() {
+	resolver, err := Open(s.cityPath)
+	s.Require().NoError(err)
+
+	reloaded, err := resolver.Reload()
+	s.Require().NoError(err)
+	s.False(reloaded)
+
+	s.writeCity("Londinium")
+	s.Require().NoError(os.Chtimes(s.cityPath, time.Now(), time.Now().Add(time.Minute)))
+	reloaded, err = resolver.Reload()
+	s.Require().NoError(err)
+	s.True(reloaded)
+	location, err := resolver.Lookup(context.Background(), netip.MustParseAddr("81.2.69.142"))
+	s.Require().NoError(err)
+	s.Equal("Londinium", location.City)
+
+	// A broken file keeps the previous version in use
+	s.Require().NoError(os.WriteFile(s.cityPath, []byte("not a database"), 0o600))
+	reloaded, err = resolver.Reload()
+	s.Error(err)
+	s.False(reloaded)
+	location, err = resolver.Lookup(context.Background(), netip.MustParseAddr("81.2.69.142"))
+	s.Require().NoError(err)
+	s.Equal("Londinium", location.City)
+}
+
+func (s *resolverTestSuite) TestOpenMissing() {
+	_, err := Open(filepath.Join(s.T().TempDir(), "missing.mmdb"))
+	s.Error(err)
+	_, err = Open()
+	s.Error(err)
+}
+
+func TestResolverTestSuite(t *testing.T) {
+	suite.Run(t, new(resolverTestSuite))
+}
@@ -0,0 +1,19 @@
+package memory
+
+import (
+	"testing"
+
+	"gitlab.mreg.io/my-registry/auth/infrastructure/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func() storetest.Repositories {
+		store := NewStore()
+		return storetest.Repositories{
+			Identities:    NewIdentityRepository(store),
+			Registrations: NewRegistrationRepository(store),
+			Sessions:      NewSessionRepository(store),
+			Transactions:  NewTransactionRunner(store),
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+)
+
+type identityRepository struct {
+	store *Store
+}
+
+// NewIdentityRepository returns the identities of store. Identities are not
+// announced in an outbox, as there is none in memory.
+func NewIdentityRepository(store *Store) identity.Repository {
+	return &identityRepository{store: store}
+}
+
+func (r *identityRepository) CreateIdentity(ctx context.Context, identityData *identity.Identity) error {
+	if len(identityData.Emails) == 0 {
+		return errors.New("identity must have at least one email")
+	}
+	defer r.store.lock(ctx)()
+
+	address := identityData.Emails[0].Value
+	if _, ok := r.store.emails[address]; ok {
+		return &store.ConstraintError{Kind: store.ErrAlreadyExists, Table: "emails", Constraint: "emails_pkey"}
+	}
+	now := r.store.now()
+	row := identityRow{identity.Identity{
+		ID:              r.store.newID(),
+		State:           identity.StateActive,
+		Timezone:        identityData.Timezone,
+		Locale:          identityData.Locale,
+		CreateTime:      now,
+		UpdateTime:      now,
+		StateUpdateTime: now,
+		PasswordHash:    identityData.PasswordHash,
+	}}
+	r.store.identities[row.ID] = row
+	r.store.emails[address] = emailRow{
+		Email:      identity.Email{Value: address, CreateTime: now, UpdateTime: now},
+		identityID: row.ID,
+	}
+
+	identityData.ID = row.ID
+	identityData.CreateTime = now
+	identityData.UpdateTime = now
+	identityData.StateUpdateTime = now
+	identityData.Emails[0].CreateTime = now
+	identityData.Emails[0].UpdateTime = now
+	return nil
+}
+
+func (r *identityRepository) EmailExists(ctx context.Context, emailAddress string) (bool, error) {
+	defer r.store.lock(ctx)()
+	_, ok := r.store.emails[emailAddress]
+	return ok, nil
+}
+
+func (r *identityRepository) QueryEmail(ctx context.Context, email *identity.Email) error {
+	defer r.store.lock(ctx)()
+	row, ok := r.store.emails[email.Value]
+	if !ok {
+		return store.ErrNotFound
+	}
+	*email = row.Email
+	return nil
+}
+
+func (r *identityRepository) QueryEmails(ctx context.Context, identityData *identity.Identity) error {
+	defer r.store.lock(ctx)()
+	var emails []identity.Email
+	for _, row := range r.store.emails {
+		if row.identityID == identityData.ID {
+			emails = append(emails, row.Email)
+		}
+	}
+	slices.SortFunc(emails, func(a, b identity.Email) int {
+		return cmp.Or(a.CreateTime.Compare(b.CreateTime), cmp.Compare(a.Value, b.Value))
+	})
+	identityData.Emails = emails
+	return nil
+}
@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/domain/ratelimit"
+)
+
+// sweepInterval is how often buckets that have refilled completely are
+// removed from memory.
+const sweepInterval = time.Minute
+
+type rateLimitBucket struct {
+	ratelimit.Bucket
+	fullAt time.Time
+}
+
+type RateLimitRepository struct {
+	mu        sync.Mutex
+	buckets   map[string]*rateLimitBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewRateLimitRepository returns a rate limit store that keeps buckets in
+// process memory. It is only accurate for a single replica.
+func NewRateLimitRepository() ratelimit.Repository {
+	return &RateLimitRepository{
+		buckets: make(map[string]*rateLimitBucket),
+		now:     time.Now,
+	}
+}
+
+func (r *RateLimitRepository) Take(_ context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.sweep(now)
+
+	bucket, ok := r.buckets[key]
+	if !ok {
+		bucket = &rateLimitBucket{Bucket: *ratelimit.NewBucket(now, limit)}
+		r.buckets[key] = bucket
+	}
+	result := bucket.Take(now, limit)
+	bucket.fullAt = bucket.FullAt(limit)
+	return result, nil
+}
+
+func (r *RateLimitRepository) Refund(_ context.Context, key string, limit ratelimit.Limit) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// a bucket swept since it was taken from is already full
+	if bucket, ok := r.buckets[key]; ok {
+		bucket.Refund(r.now(), limit)
+		bucket.fullAt = bucket.FullAt(limit)
+	}
+	return nil
+}
+
+func (r *RateLimitRepository) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < sweepInterval {
+		return
+	}
+	r.lastSweep = now
+	for key, bucket := range r.buckets {
+		if !now.Before(bucket.fullAt) {
+			delete(r.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/ratelimit"
+)
+
+type RateLimitRepositorySuite struct {
+	suite.Suite
+	now        time.Time
+	repository *RateLimitRepository
+}
+
+func (s *RateLimitRepositorySuite) SetupTest() {
+	s.now = time.Now()
+	s.repository = NewRateLimitRepository().(*RateLimitRepository)
+	s.repository.now = func() time.Time { return s.now }
+}
+
+func (s *RateLimitRepositorySuite) TestTake() {
+	ctx := context.Background()
+	limit := ratelimit.Limit{Burst: 1, Period: time.Minute}
+
+	result, err := s.repository.Take(ctx, "a", limit)
+	s.Require().NoError(err)
+	s.True(result.Allowed)
+
+	result, err = s.repository.Take(ctx, "a", limit)
+	s.Require().NoError(err)
+	s.False(result.Allowed)
+	s.Equal(time.Minute, result.RetryAfter)
+
+	// Buckets are independent
+	result, err = s.repository.Take(ctx, "b", limit)
+	s.Require().NoError(err)
+	s.True(result.Allowed)
+}
+
+func (s *RateLimitRepositorySuite) TestRefund() {
+	ctx := context.Background()
+	limit := ratelimit.Limit{Burst: 1, Period: time.Minute}
+
+	_, err := s.repository.Take(ctx, "a", limit)
+	s.Require().NoError(err)
+	s.Require().NoError(s.repository.Refund(ctx, "a", limit))
+	result, err := s.repository.Take(ctx, "a", limit)
+	s.Require().NoError(err)
+	s.True(result.Allowed)
+
+	// Unknown buckets are full already
+	s.Require().NoError(s.repository.Refund(ctx, "b", limit))
+	s.NotContains(s.repository.buckets, "b")
+}
+
+func (s *RateLimitRepositorySuite) TestSweep() {
+	ctx := context.Background()
+	_, err := s.repository.Take(ctx, "a", ratelimit.Limit{Burst: 1, Period: time.Minute})
+	s.Require().NoError(err)
+	_, err = s.repository.Take(ctx, "b", ratelimit.Limit{Burst: 1, Period: time.Hour})
+	s.Require().NoError(err)
+
+	s.now = s.now.Add(2 * time.Minute)
+	_, err = s.repository.Take(ctx, "c", ratelimit.Limit{Burst: 1, Period: time.Minute})
+	s.Require().NoError(err)
+
+	s.NotContains(s.repository.buckets, "a")
+	s.Contains(s.repository.buckets, "b")
+	s.Contains(s.repository.buckets, "c")
+}
+
+func TestRateLimitRepositorySuite(t *testing.T) {
+	suite.Run(t, new(RateLimitRepositorySuite))
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/google/uuid"
+
+	"gitlab.mreg.io/my-registry/auth/domain/registration"
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+)
+
+type registrationRepository struct {
+	store *Store
+}
+
+// NewRegistrationRepository returns the registration flows of store.
+func NewRegistrationRepository(store *Store) registration.Repository {
+	return &registrationRepository{store: store}
+}
+
+func (r *registrationRepository) CreateFlow(ctx context.Context, flow *registration.Flow) error {
+	defer r.store.lock(ctx)()
+
+	if _, ok := r.store.sessions[flow.SessionID]; !ok {
+		return &store.ConstraintError{Kind: store.ErrInvalid, Table: "registration_flows", Constraint: "registration_flows_session_id_fkey"}
+	}
+	if flow.Interval < 0 {
+		return &store.ConstraintError{Kind: store.ErrInvalid, Table: "registration_flows", Constraint: "check_expires_at_issued_at"}
+	}
+	now := r.store.now()
+	row := flowRow{
+		issuedAt:  now,
+		expiresAt: now.Add(flow.Interval),
+		sessionID: flow.SessionID,
+	}
+	if flow.Challenge != nil {
+		challenge := *flow.Challenge
+		row.challenge = &challenge
+	}
+	flow.FlowID = r.store.newID()
+	r.store.flows[flow.FlowID] = row
+
+	flow.IssuedAt = row.issuedAt
+	flow.ExpiresAt = row.expiresAt
+	return nil
+}
+
+func (r *registrationRepository) QueryFlowByFlowID(ctx context.Context, flow *registration.Flow) error {
+	if err := uuid.Validate(flow.FlowID); err != nil {
+		return fmt.Errorf("%w: %w", store.ErrInvalid, err)
+	}
+	defer r.store.lock(ctx)()
+
+	row, ok := r.store.flows[flow.FlowID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	flow.IssuedAt = row.issuedAt
+	flow.ExpiresAt = row.expiresAt
+	flow.SessionID = row.sessionID
+	flow.Challenge = nil
+	if row.challenge != nil {
+		challenge := *row.challenge
+		flow.Challenge = &challenge
+	}
+	return nil
+}
+
+func (r *registrationRepository) CountRecentFlows(ctx context.Context, network netip.Prefix, since time.Time) (int, error) {
+	defer r.store.lock(ctx)()
+
+	network = network.Masked()
+	inNetwork := make(map[string]bool)
+	for _, device := range r.store.devices {
+		if network.Contains(device.IPAddress) {
+			inNetwork[device.SessionID] = true
+		}
+	}
+	count := 0
+	for _, flow := range r.store.flows {
+		if inNetwork[flow.sessionID] && !flow.issuedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+)
+
+type sessionRepository struct {
+	store *Store
+}
+
+// NewSessionRepository returns the sessions of store.
+func NewSessionRepository(store *Store) session.Repository {
+	return &sessionRepository{store: store}
+}
+
+func (r *sessionRepository) CreateSession(ctx context.Context, sessionData *session.Session) error {
+	if len(sessionData.Devices) != 1 {
+		return errors.New("only one device is allowed when creating a session")
+	}
+	defer r.store.lock(ctx)()
+
+	var identityID string
+	if sessionData.Identity != nil && sessionData.Identity.ID != "" {
+		identityID = sessionData.Identity.ID
+		if _, ok := r.store.identities[identityID]; !ok {
+			return &store.ConstraintError{Kind: store.ErrInvalid, Table: "sessions", Constraint: "sessions_identity_id_fkey"}
+		}
+	}
+	now := r.store.now()
+	row := sessionRow{
+		active:                      sessionData.Active,
+		authenticatorAssuranceLevel: sessionData.AuthenticatorAssuranceLevel,
+		issuedAt:                    now,
+		expiresAt:                   now.Add(sessionData.ExpiryInterval),
+		authenticatedAt:             sessionData.AuthenticatedAt,
+		identityID:                  identityID,
+	}
+	if row.expiresAt.Before(row.issuedAt) ||
+		!row.authenticatedAt.IsZero() && (row.authenticatedAt.Before(row.issuedAt) || row.authenticatedAt.After(row.expiresAt)) {
+		return &store.ConstraintError{Kind: store.ErrInvalid, Table: "sessions", Constraint: "check_expires_at_issued_at"}
+	}
+	sessionData.ID = r.store.newID()
+	r.store.sessions[sessionData.ID] = row
+
+	device := &sessionData.Devices[0]
+	device.SessionID = sessionData.ID
+	r.insertDevice(device)
+
+	sessionData.IssuedAt = row.issuedAt
+	sessionData.ExpiresAt = row.expiresAt
+	return nil
+}
+
+func (r *sessionRepository) DeleteSession(ctx context.Context, sessionID string) error {
+	defer r.store.lock(ctx)()
+
+	delete(r.store.sessions, sessionID)
+	// the devices and flows of the session cascade
+	for id, device := range r.store.devices {
+		if device.SessionID == sessionID {
+			delete(r.store.devices, id)
+		}
+	}
+	for id, flow := range r.store.flows {
+		if flow.sessionID == sessionID {
+			delete(r.store.flows, id)
+		}
+	}
+	return nil
+}
+
+// querySession fills sessionData from its row.
+func (r *sessionRepository) querySession(sessionData *session.Session) error {
+	if err := uuid.Validate(sessionData.ID); err != nil {
+		return fmt.Errorf("%w: %w", store.ErrInvalid, err)
+	}
+	row, ok := r.store.sessions[sessionData.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if sessionData.Identity == nil {
+		sessionData.Identity = &identity.Identity{}
+	}
+	sessionData.Active = row.active
+	sessionData.AuthenticatorAssuranceLevel = row.authenticatorAssuranceLevel
+	sessionData.IssuedAt = row.issuedAt
+	sessionData.ExpiresAt = row.expiresAt
+	sessionData.AuthenticatedAt = row.authenticatedAt
+	sessionData.Identity.ID = row.identityID
+	sessionData.Identity.Locale = r.store.identities[row.identityID].Locale
+	return nil
+}
+
+func (r *sessionRepository) QuerySessionByID(ctx context.Context, sessionData *session.Session) error {
+	defer r.store.lock(ctx)()
+	return r.querySession(sessionData)
+}
+
+func (r *sessionRepository) QuerySessionWithDevices(ctx context.Context, sessionData *session.Session) error {
+	defer r.store.lock(ctx)()
+	if err := r.querySession(sessionData); err != nil {
+		return err
+	}
+
+	var devices []session.Device
+	for _, device := range r.store.devices {
+		if device.SessionID == sessionData.ID {
+			devices = append(devices, device)
+		}
+	}
+	// IDs are ULIDs, sorting them sorts by creation
+	slices.SortFunc(devices, func(a, b session.Device) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	sessionData.Devices = devices
+	return nil
+}
+
+func (r *sessionRepository) InsertDevice(ctx context.Context, device *session.Device) error {
+	defer r.store.lock(ctx)()
+	if _, ok := r.store.sessions[device.SessionID]; !ok {
+		return &store.ConstraintError{Kind: store.ErrInvalid, Table: "devices", Constraint: "devices_session_id_fkey"}
+	}
+	r.insertDevice(device)
+	return nil
+}
+
+// insertDevice stores device, with a new ID, in its session.
+func (r *sessionRepository) insertDevice(device *session.Device) {
+	device.ID = r.store.newID()
+	row := *device
+	row.Risk.Reasons = slices.Clone(device.Risk.Reasons)
+	r.store.devices[device.ID] = row
+}
+
+func (r *sessionRepository) RememberDevice(ctx context.Context, identityID string, device *session.Device) (bool, error) {
+	client := device.Client
+	if !client.Known() {
+		client = session.ParseUserAgent(device.UserAgent)
+	}
+	defer r.store.lock(ctx)()
+
+	if _, ok := r.store.identities[identityID]; !ok {
+		return false, &store.ConstraintError{Kind: store.ErrInvalid, Table: "known_devices", Constraint: "known_devices_identity_id_fkey"}
+	}
+	key := knownDevice{identityID: identityID, browser: client.Browser, os: client.OS, countryCode: device.Location.CountryCode}
+	_, known := r.store.knownDevices[key]
+	r.store.knownDevices[key] = r.store.now()
+	return !known, nil
+}
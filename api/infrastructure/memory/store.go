@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/registration"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/domain/transaction"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/ulid"
+)
+
+type identityRow struct {
+	identity.Identity
+}
+
+type emailRow struct {
+	identity.Email
+	identityID string
+}
+
+type sessionRow struct {
+	active                      bool
+	authenticatorAssuranceLevel uint8
+	issuedAt                    time.Time
+	expiresAt                   time.Time
+	authenticatedAt             time.Time
+	identityID                  string
+}
+
+type flowRow struct {
+	issuedAt  time.Time
+	expiresAt time.Time
+	sessionID string
+	challenge *registration.Challenge
+}
+
+type knownDevice struct {
+	identityID, browser, os, countryCode string
+}
+
+// tables are the rows of a Store. Rows are values and their slices are
+// never modified in place, so that a shallow copy is a snapshot.
+type tables struct {
+	identities   map[string]identityRow
+	emails       map[string]emailRow
+	sessions     map[string]sessionRow
+	devices      map[string]session.Device
+	flows        map[string]flowRow
+	knownDevices map[knownDevice]time.Time
+}
+
+func (t *tables) clone() tables {
+	return tables{
+		identities:   maps.Clone(t.identities),
+		emails:       maps.Clone(t.emails),
+		sessions:     maps.Clone(t.sessions),
+		devices:      maps.Clone(t.devices),
+		flows:        maps.Clone(t.flows),
+		knownDevices: maps.Clone(t.knownDevices),
+	}
+}
+
+// Store holds the identities, sessions and registration flows shared by
+// the in-memory repositories, with the constraints of the database schema:
+// ULIDs, unique emails, references between rows and cascading
+// deletes. It is meant for development and tests, and loses everything on
+// restart.
+type Store struct {
+	// unit is held by the running unit of work. Repository calls outside
+	// of it wait, as they would on the rows locked by a transaction.
+	unit sync.Mutex
+	mu   sync.Mutex
+	tables
+	ids *ulid.Generator
+	now func() time.Time
+}
+
+// NewStore returns an empty store.
+func NewStore() *Store {
+	s := &Store{
+		tables: tables{
+			identities:   make(map[string]identityRow),
+			emails:       make(map[string]emailRow),
+			sessions:     make(map[string]sessionRow),
+			devices:      make(map[string]session.Device),
+			flows:        make(map[string]flowRow),
+			knownDevices: make(map[knownDevice]time.Time),
+		},
+		now: time.Now,
+	}
+	s.ids = ulid.NewGenerator(func() time.Time { return s.now() })
+	return s
+}
+
+type unitKey struct{}
+
+// lock locks the rows for a repository call and returns the function
+// unlocking them.
+func (s *Store) lock(ctx context.Context) func() {
+	if ctx.Value(unitKey{}) == s {
+		s.mu.Lock()
+		return s.mu.Unlock
+	}
+	s.unit.Lock()
+	s.mu.Lock()
+	return func() {
+		s.mu.Unlock()
+		s.unit.Unlock()
+	}
+}
+
+// newID returns a ULID, as the SQL repositories generate them. It is
+// called with mu locked.
+func (s *Store) newID() string {
+	return s.ids.New()
+}
+
+type transactionRunner struct {
+	store *Store
+}
+
+// NewTransactionRunner returns a runner of units of work on store. They run
+// one at a time, and their changes are rolled back when they fail.
+func NewTransactionRunner(store *Store) transaction.Runner {
+	return &transactionRunner{store: store}
+}
+
+func (r *transactionRunner) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(unitKey{}) == r.store {
+		return fn(ctx)
+	}
+	r.store.unit.Lock()
+	defer r.store.unit.Unlock()
+
+	r.store.mu.Lock()
+	snapshot := r.store.clone()
+	r.store.mu.Unlock()
+
+	err := fn(context.WithValue(ctx, unitKey{}, r.store))
+	if err != nil {
+		r.store.mu.Lock()
+		r.store.tables = snapshot
+		r.store.mu.Unlock()
+	}
+	return err
+}
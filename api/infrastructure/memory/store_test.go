@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/registration"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/domain/transaction"
+)
+
+type StoreSuite struct {
+	suite.Suite
+	now           time.Time
+	store         *Store
+	identities    identity.Repository
+	registrations registration.Repository
+	sessions      session.Repository
+	runner        transaction.Runner
+}
+
+func (s *StoreSuite) SetupTest() {
+	s.now = time.Date(2026, time.October, 19, 18, 0, 0, 0, time.UTC)
+	s.store = NewStore()
+	s.store.now = func() time.Time { return s.now }
+	s.identities = NewIdentityRepository(s.store)
+	s.registrations = NewRegistrationRepository(s.store)
+	s.sessions = NewSessionRepository(s.store)
+	s.runner = NewTransactionRunner(s.store)
+}
+
+func (s *StoreSuite) createIdentity(email string) *identity.Identity {
+	created := &identity.Identity{Timezone: "UTC", Emails: []identity.Email{{Value: email}}, PasswordHash: "hash"}
+	s.Require().NoError(s.identities.CreateIdentity(context.Background(), created))
+	return created
+}
+
+func (s *StoreSuite) createSession(identityData *identity.Identity) *session.Session {
+	created := &session.Session{
+		Active:         true,
+		ExpiryInterval: time.Hour,
+		Devices:        []session.Device{session.NewDevice(netip.MustParseAddr("192.0.2.1"), "Mozilla/5.0")},
+		Identity:       identityData,
+	}
+	s.Require().NoError(s.sessions.CreateSession(context.Background(), created))
+	return created
+}
+
+func (s *StoreSuite) TestNewID() {
+	first := s.store.newID()
+	second := s.store.newID()
+	s.Less(first, second)
+	s.Equal(first[:13], second[:13], "same millisecond")
+	s.Require().NoError(uuid.Validate(first))
+
+	s.now = s.now.Add(time.Millisecond)
+	third := s.store.newID()
+	s.Less(second, third)
+	s.NotEqual(second[:13], third[:13])
+}
+
+// The conformance suite covers the repositories; these tests cover what it
+// cannot see from outside: the clock and the rows left behind.
+func (s *StoreSuite) TestClock() {
+	ctx := context.Background()
+	created := s.createIdentity("user@example.com")
+	s.Equal(s.now, created.CreateTime)
+	s.Equal(s.now, created.Emails[0].CreateTime)
+
+	owner := s.createSession(created)
+	s.Equal(s.now, owner.IssuedAt)
+	s.Equal(s.now.Add(time.Hour), owner.ExpiresAt)
+
+	flow := &registration.Flow{SessionID: owner.ID, Interval: time.Hour}
+	s.Require().NoError(s.registrations.CreateFlow(ctx, flow))
+	s.Equal(s.now.Add(time.Hour), flow.ExpiresAt)
+}
+
+func (s *StoreSuite) TestDeleteSession() {
+	ctx := context.Background()
+	owner := s.createSession(nil)
+	device := session.NewDevice(netip.MustParseAddr("198.51.100.1"), "curl/8.0")
+	device.SessionID = owner.ID
+	s.Require().NoError(s.sessions.InsertDevice(ctx, &device))
+	s.Require().NoError(s.registrations.CreateFlow(ctx, &registration.Flow{SessionID: owner.ID, Interval: time.Hour}))
+
+	s.Require().NoError(s.sessions.DeleteSession(ctx, owner.ID))
+	s.Empty(s.store.sessions)
+	s.Empty(s.store.devices)
+	s.Empty(s.store.flows)
+}
+
+func (s *StoreSuite) TestTransactionRunner_RestoresSnapshot() {
+	ctx := context.Background()
+	s.createIdentity("kept@example.com")
+	failure := errors.New("failure")
+	err := s.runner.Run(ctx, func(ctx context.Context) error {
+		created := &session.Session{
+			ExpiryInterval: time.Hour,
+			Devices:        []session.Device{session.NewDevice(netip.MustParseAddr("192.0.2.1"), "Mozilla/5.0")},
+		}
+		s.Require().NoError(s.sessions.CreateSession(ctx, created))
+		return failure
+	})
+	s.ErrorIs(err, failure)
+	s.Len(s.store.identities, 1)
+	s.Empty(s.store.sessions)
+	s.Empty(s.store.devices)
+}
+
+func TestStoreSuite(t *testing.T) {
+	suite.Run(t, new(StoreSuite))
+}
@@ -0,0 +1,85 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+
+	"gitlab.mreg.io/my-registry/auth/domain/audit"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/ulid"
+)
+
+//go:embed sql/createAuditEvent.sql
+var createAuditEventSQL string
+
+//go:embed sql/queryAuditEvents.sql
+var queryAuditEventsSQL string
+
+type auditRepository struct {
+	db *sql.DB
+}
+
+// NewAuditRepository returns the audit log of db.
+func NewAuditRepository(db *sql.DB) audit.Repository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) CreateEvent(ctx context.Context, event *audit.Event) error {
+	id := ulid.New()
+	createTime := now()
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		createAuditEventSQL,
+		id, event.Type, event.Actor, event.IdentityID, event.SessionID, encodeOptionalAddr(event.IPAddress), event.UserAgent, event.Outcome, event.Action, encodeTime(createTime),
+	)
+	if err != nil {
+		return translateError(err)
+	}
+	event.ID = id
+	event.CreateTime = createTime
+	return nil
+}
+
+// QueryEvents pages through the events by creation time, and by ID among
+// events created at once.
+func (r *auditRepository) QueryEvents(ctx context.Context, identityID string, pageSize int, pageToken string) ([]audit.Event, string, error) {
+	var afterTime, afterID any
+	if pageToken != "" {
+		createTime, id, err := audit.DecodePageToken(pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		afterTime, afterID = encodeTime(createTime), id
+	}
+
+	// One more row than asked for tells whether there is a next page
+	rows, err := conn(ctx, r.db).QueryContext(ctx, queryAuditEventsSQL, identityID, afterTime, afterID, pageSize+1)
+	if err != nil {
+		return nil, "", translateError(err)
+	}
+	defer rows.Close()
+
+	var events []audit.Event
+	for rows.Next() {
+		event := audit.Event{IdentityID: identityID}
+		var ipAddress []byte
+		var createTime int64
+		if err := rows.Scan(
+			&event.ID, &event.Type, &event.Actor, &event.SessionID, &ipAddress, &event.UserAgent, &event.Outcome, &event.Action, &createTime,
+		); err != nil {
+			return nil, "", err
+		}
+		event.IPAddress = decodeAddr(ipAddress)
+		event.CreateTime = decodeTime(createTime)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(events) <= pageSize {
+		return events, "", nil
+	}
+	events = events[:pageSize]
+	return events, audit.EncodePageToken(events[pageSize-1]), nil
+}
@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/audit"
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+)
+
+type AuditRepositorySuite struct {
+	suite.Suite
+	db         *sql.DB
+	repository audit.Repository
+}
+
+func (s *AuditRepositorySuite) SetupTest() {
+	var err error
+	s.db, err = Open(context.Background(), filepath.Join(s.T().TempDir(), "auth.db"))
+	s.Require().NoError(err)
+	s.repository = NewAuditRepository(s.db)
+}
+
+func (s *AuditRepositorySuite) TestQueryEvents() {
+	ctx := context.Background()
+	identityID := "01928f5e-8c4d-7e5f-a06b-1c2d3e4f5a6b"
+	var created []audit.Event
+	for _, outcome := range []audit.Outcome{audit.OutcomeFailure, audit.OutcomeFailure, audit.OutcomeSuccess} {
+		event := audit.Event{
+			Type:       audit.EventLogin,
+			IdentityID: identityID,
+			IPAddress:  netip.MustParseAddr("192.0.2.1"),
+			UserAgent:  "Mozilla",
+			Outcome:    outcome,
+		}
+		s.Require().NoError(s.repository.CreateEvent(ctx, &event))
+		created = append(created, event)
+	}
+
+	// Newest first
+	events, next, err := s.repository.QueryEvents(ctx, identityID, 2, "")
+	s.Require().NoError(err)
+	s.Require().Len(events, 2)
+	s.Equal(created[2], events[0])
+	s.Equal(created[1].ID, events[1].ID)
+	s.NotEmpty(next)
+
+	events, next, err = s.repository.QueryEvents(ctx, identityID, 2, next)
+	s.Require().NoError(err)
+	s.Require().Len(events, 1)
+	s.Equal(created[0].ID, events[0].ID)
+	s.Empty(next)
+
+	_, _, err = s.repository.QueryEvents(ctx, identityID, 2, "not a token")
+	s.ErrorIs(err, audit.ErrInvalidPageToken)
+}
+
+func (s *AuditRepositorySuite) TestEventsFollowTheirTransaction() {
+	ctx := context.Background()
+	identities := NewIdentityRepository(s.db)
+	transactions := NewTransactionRunner(s.db)
+	register := func(identityData *identity.Identity, result error) error {
+		return transactions.Run(ctx, func(ctx context.Context) error {
+			if err := identities.CreateIdentity(ctx, identityData); err != nil {
+				return err
+			}
+			registered := &audit.Event{Type: audit.EventRegistration, IdentityID: identityData.ID, Outcome: audit.OutcomeSuccess}
+			if err := s.repository.CreateEvent(ctx, registered); err != nil {
+				return err
+			}
+			return result
+		})
+	}
+
+	// The event of a change rolled back is rolled back with it
+	rolledBack := &identity.Identity{Emails: []identity.Email{{Value: "rollback@example.com"}}, Timezone: "Asia/Taipei"}
+	errRollback := errors.New("rollback")
+	s.Require().ErrorIs(register(rolledBack, errRollback), errRollback)
+	events, _, err := s.repository.QueryEvents(ctx, rolledBack.ID, 10, "")
+	s.Require().NoError(err)
+	s.Empty(events)
+
+	identityData := &identity.Identity{Emails: []identity.Email{{Value: "audit@example.com"}}, Timezone: "Asia/Taipei"}
+	s.Require().NoError(register(identityData, nil))
+	events, _, err = s.repository.QueryEvents(ctx, identityData.ID, 10, "")
+	s.Require().NoError(err)
+	s.Require().Len(events, 1)
+	s.Equal(audit.EventRegistration, events[0].Type)
+	s.False(events[0].IPAddress.IsValid())
+}
+
+func (s *AuditRepositorySuite) TearDownTest() {
+	s.db.Close()
+}
+
+func TestAuditRepositorySuite(t *testing.T) {
+	suite.Run(t, new(AuditRepositorySuite))
+}
@@ -0,0 +1,26 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.mreg.io/my-registry/auth/infrastructure/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	db, err := Open(context.Background(), filepath.Join(t.TempDir(), "auth.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	storetest.Run(t, func() storetest.Repositories {
+		return storetest.Repositories{
+			Identities:    NewIdentityRepository(db),
+			Registrations: NewRegistrationRepository(db),
+			Sessions:      NewSessionRepository(db),
+			Transactions:  NewTransactionRunner(db),
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+)
+
+// translateError returns err as a store error when it is one the callers
+// can act on, wrapping the driver error so that it can still be inspected.
+// SQLite does not name the constraints it checks, so unique constraints are
+// named after the PostgreSQL convention the other repositories report.
+func translateError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %w", store.ErrNotFound, err)
+	}
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+	constraintErr := &store.ConstraintError{Kind: store.ErrInvalid, Err: err}
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		constraintErr.Kind = store.ErrAlreadyExists
+		constraintErr.Table, _ = failedColumn(sqliteErr)
+		constraintErr.Constraint = constraintErr.Table + "_pkey"
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE:
+		constraintErr.Kind = store.ErrAlreadyExists
+		table, column := failedColumn(sqliteErr)
+		constraintErr.Table = table
+		constraintErr.Constraint = table + "_" + column + "_key"
+	case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+		constraintErr.Table, _ = failedColumn(sqliteErr)
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY, sqlite3.SQLITE_CONSTRAINT_CHECK:
+	default:
+		return err
+	}
+	return constraintErr
+}
+
+// failedColumn returns the first column named by a constraint error, such
+// as "UNIQUE constraint failed: emails.address".
+func failedColumn(err *sqlite.Error) (table, column string) {
+	const failed = "constraint failed: "
+	message := err.Error()
+	i := strings.LastIndex(message, failed)
+	if i < 0 {
+		return "", ""
+	}
+	columns := message[i+len(failed):]
+	columns, _, _ = strings.Cut(columns, ",")
+	columns, _, _ = strings.Cut(columns, " ")
+	table, column, _ = strings.Cut(columns, ".")
+	return table, column
+}
+
+// validateID returns store.ErrInvalid for an ID that is not a UUID, which
+// SQLite would otherwise look up as any other text.
+func validateID(id string) error {
+	if err := uuid.Validate(id); err != nil {
+		return fmt.Errorf("%w: %w", store.ErrInvalid, err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/ulid"
+)
+
+//go:embed sql/createIdentity.sql
+var createIdentitySQL string
+
+//go:embed sql/createEmail.sql
+var createEmailSQL string
+
+//go:embed sql/createPassword.sql
+var createPasswordSQL string
+
+//go:embed sql/queryEmail.sql
+var queryEmailSQL string
+
+//go:embed sql/queryEmailsByIdentity.sql
+var queryEmailsByIdentitySQL string
+
+type identityRepository struct {
+	db *sql.DB
+}
+
+// NewIdentityRepository returns the identities of db. Identities are not
+// announced in an outbox, as single-node installations have none.
+func NewIdentityRepository(db *sql.DB) identity.Repository {
+	return &identityRepository{db: db}
+}
+
+func (r *identityRepository) CreateIdentity(ctx context.Context, identityData *identity.Identity) error {
+	if len(identityData.Emails) == 0 {
+		return errors.New("identity must have at least one email")
+	}
+	id := ulid.New()
+	createTime := now()
+	err := withTx(ctx, r.db, func(q querier) error {
+		if _, err := q.ExecContext(ctx, createIdentitySQL, id, identityData.Timezone, encodeTime(createTime), identityData.Locale); err != nil {
+			return err
+		}
+		if _, err := q.ExecContext(ctx, createEmailSQL, identityData.Emails[0].Value, encodeTime(createTime), id); err != nil {
+			return err
+		}
+		_, err := q.ExecContext(ctx, createPasswordSQL, id, identityData.PasswordHash)
+		return err
+	})
+	if err != nil {
+		return translateError(err)
+	}
+
+	identityData.ID = id
+	identityData.CreateTime = createTime
+	identityData.UpdateTime = createTime
+	identityData.StateUpdateTime = createTime
+	identityData.Emails[0].CreateTime = createTime
+	identityData.Emails[0].UpdateTime = createTime
+	return nil
+}
+
+func (r *identityRepository) EmailExists(ctx context.Context, emailAddress string) (bool, error) {
+	const query = `SELECT EXISTS(SELECT 1 FROM emails WHERE address = $1)`
+
+	var exists bool
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, emailAddress).Scan(&exists)
+	return exists, translateError(err)
+}
+
+func (r *identityRepository) QueryEmail(ctx context.Context, email *identity.Email) error {
+	var createTime, verifiedAt, updateTime int64
+	err := conn(ctx, r.db).
+		QueryRowContext(ctx, queryEmailSQL, email.Value).
+		Scan(&email.Verified, &createTime, &verifiedAt, &updateTime)
+	if err != nil {
+		return translateError(err)
+	}
+	email.CreateTime = decodeTime(createTime)
+	email.VerifiedAt = decodeTime(verifiedAt)
+	email.UpdateTime = decodeTime(updateTime)
+	return nil
+}
+
+func (r *identityRepository) QueryEmails(ctx context.Context, identityData *identity.Identity) error {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, queryEmailsByIdentitySQL, identityData.ID)
+	if err != nil {
+		return translateError(err)
+	}
+	defer rows.Close()
+
+	var emails []identity.Email
+	for rows.Next() {
+		var email identity.Email
+		var createTime, verifiedAt, updateTime int64
+		if err := rows.Scan(&email.Value, &email.Verified, &createTime, &verifiedAt, &updateTime); err != nil {
+			return err
+		}
+		email.CreateTime = decodeTime(createTime)
+		email.VerifiedAt = decodeTime(verifiedAt)
+		email.UpdateTime = decodeTime(updateTime)
+		emails = append(emails, email)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	identityData.Emails = emails
+	return nil
+}
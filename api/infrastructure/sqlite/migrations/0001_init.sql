@@ -0,0 +1,93 @@
+-- Times are microseconds since the Unix epoch and addresses their 16-byte
+-- form, so that both compare in SQL. IDs are ULIDs generated by the
+-- repositories.
+CREATE TABLE identities
+(
+    id                TEXT PRIMARY KEY,
+    state             TEXT    NOT NULL DEFAULT 'active' CHECK (state IN ('active', 'suspended')),
+    timezone          TEXT    NOT NULL CHECK (length(timezone) <= 64),
+    create_time       INTEGER NOT NULL,
+    update_time       INTEGER NOT NULL CHECK (update_time >= create_time),
+    state_update_time INTEGER NOT NULL CHECK (state_update_time >= create_time)
+) STRICT;
+
+CREATE TABLE emails
+(
+    address     TEXT PRIMARY KEY CHECK (length(address) <= 320),
+    verified    INTEGER NOT NULL DEFAULT 0,
+    create_time INTEGER NOT NULL,
+    verified_at INTEGER CHECK (verified_at >= create_time),
+    update_time INTEGER NOT NULL CHECK (update_time >= create_time),
+    identity_id TEXT    NOT NULL REFERENCES identities (id) ON DELETE CASCADE
+) STRICT;
+CREATE INDEX emails_identity_id_idx ON emails (identity_id);
+
+CREATE TABLE passwords
+(
+    identity_id   TEXT PRIMARY KEY REFERENCES identities (id) ON DELETE CASCADE,
+    password_hash TEXT NOT NULL CHECK (length(password_hash) <= 256)
+) STRICT;
+
+CREATE TABLE sessions
+(
+    id                            TEXT PRIMARY KEY,
+    active                        INTEGER NOT NULL DEFAULT 1,
+    authenticator_assurance_level INTEGER CHECK (authenticator_assurance_level BETWEEN 1 AND 3),
+    issued_at                     INTEGER NOT NULL,
+    expires_at                    INTEGER NOT NULL CHECK (expires_at >= issued_at),
+    authenticated_at              INTEGER CHECK (authenticated_at >= issued_at AND authenticated_at <= expires_at),
+    identity_id                   TEXT REFERENCES identities (id) ON DELETE CASCADE
+) STRICT;
+CREATE INDEX sessions_identity_id_idx ON sessions (identity_id);
+
+CREATE TABLE devices
+(
+    id              TEXT PRIMARY KEY,
+    ip_address      BLOB    NOT NULL CHECK (length(ip_address) = 16),
+    geo_location    TEXT    NOT NULL CHECK (length(geo_location) <= 64),
+    user_agent      TEXT    NOT NULL CHECK (length(user_agent) <= 256),
+    session_id      TEXT    NOT NULL REFERENCES sessions (id) ON DELETE CASCADE,
+    country_code    TEXT    NOT NULL DEFAULT '',
+    region          TEXT    NOT NULL DEFAULT '',
+    city            TEXT    NOT NULL DEFAULT '',
+    asn             INTEGER NOT NULL DEFAULT 0,
+    as_organization TEXT    NOT NULL DEFAULT '',
+    browser         TEXT    NOT NULL DEFAULT '',
+    browser_version TEXT    NOT NULL DEFAULT '',
+    os              TEXT    NOT NULL DEFAULT '',
+    os_version      TEXT    NOT NULL DEFAULT '',
+    device_type     TEXT    NOT NULL DEFAULT '',
+    latitude        REAL    NOT NULL DEFAULT 0,
+    longitude       REAL    NOT NULL DEFAULT 0,
+    accuracy_radius INTEGER NOT NULL DEFAULT 0,
+    created_at      INTEGER NOT NULL,
+    risk_score      INTEGER NOT NULL DEFAULT 0,
+    risk_decision   TEXT    NOT NULL DEFAULT '',
+    -- a JSON array
+    risk_reasons    TEXT    NOT NULL DEFAULT '[]'
+) STRICT;
+CREATE INDEX devices_session_id_idx ON devices (session_id);
+CREATE INDEX devices_ip_address_idx ON devices (ip_address);
+
+CREATE TABLE registration_flows
+(
+    id                   TEXT PRIMARY KEY,
+    issued_at            INTEGER NOT NULL,
+    expires_at           INTEGER NOT NULL CHECK (expires_at >= issued_at),
+    session_id           TEXT REFERENCES sessions (id) ON DELETE CASCADE,
+    challenge_seed       TEXT CHECK (length(challenge_seed) <= 64),
+    challenge_difficulty INTEGER CHECK (challenge_difficulty BETWEEN 1 AND 255)
+) STRICT;
+CREATE INDEX registration_flows_session_id_idx ON registration_flows (session_id);
+CREATE INDEX registration_flows_issued_at_idx ON registration_flows (issued_at);
+
+CREATE TABLE known_devices
+(
+    identity_id   TEXT    NOT NULL REFERENCES identities (id) ON DELETE CASCADE,
+    browser       TEXT    NOT NULL,
+    os            TEXT    NOT NULL,
+    country_code  TEXT    NOT NULL,
+    first_seen_at INTEGER NOT NULL,
+    last_seen_at  INTEGER NOT NULL,
+    PRIMARY KEY (identity_id, browser, os, country_code)
+) STRICT;
@@ -0,0 +1,12 @@
+-- The reaper deletes expired flows and sessions in batches found by these
+-- indexes, and holds a lease so that two servers sharing the file do not
+-- reap at once.
+CREATE INDEX registration_flows_expires_at_idx ON registration_flows (expires_at);
+CREATE INDEX sessions_expires_at_idx ON sessions (expires_at);
+
+CREATE TABLE leases
+(
+    name       TEXT PRIMARY KEY CHECK (length(name) <= 64),
+    holder     TEXT    NOT NULL CHECK (length(holder) <= 64),
+    expires_at INTEGER NOT NULL
+) STRICT;
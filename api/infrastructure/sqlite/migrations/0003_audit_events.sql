@@ -0,0 +1,17 @@
+-- Events outlive the identities and sessions they name, so there are no
+-- foreign keys, and rows are only ever inserted.
+CREATE TABLE audit_events
+(
+    id          TEXT PRIMARY KEY,
+    type        TEXT    NOT NULL CHECK (type IN ('registration', 'login', 'session_revoked', 'password_changed',
+                                                 'email_verified', 'admin_action')),
+    actor       TEXT CHECK (length(actor) <= 64),
+    identity_id TEXT,
+    session_id  TEXT,
+    ip_address  BLOB CHECK (length(ip_address) = 16),
+    user_agent  TEXT CHECK (length(user_agent) <= 256),
+    outcome     TEXT    NOT NULL CHECK (outcome IN ('success', 'failure', 'denied')),
+    action      TEXT CHECK (length(action) <= 64),
+    create_time INTEGER NOT NULL
+) STRICT;
+CREATE INDEX audit_events_identity_id_create_time_idx ON audit_events (identity_id, create_time DESC, id DESC);
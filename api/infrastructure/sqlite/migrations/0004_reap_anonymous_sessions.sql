@@ -0,0 +1,4 @@
+-- Only anonymous sessions are reaped, so that the sessions of identities
+-- are kept with the devices the risk checks compare new ones with.
+DROP INDEX sessions_expires_at_idx;
+CREATE INDEX sessions_anonymous_expires_at_idx ON sessions (expires_at) WHERE identity_id IS NULL;
@@ -0,0 +1,3 @@
+-- The language the identity registered in, such as "fr", which its emails
+-- and error messages are written in.
+ALTER TABLE identities ADD COLUMN locale TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"net/netip"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/domain/registration"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/ulid"
+)
+
+//go:embed sql/createRegistrationFlow.sql
+var createRegistrationFlowSQL string
+
+//go:embed sql/queryRegistrationFlow.sql
+var queryRegistrationFlowSQL string
+
+//go:embed sql/countRecentRegistrationFlows.sql
+var countRecentRegistrationFlowsSQL string
+
+type registrationRepository struct {
+	db *sql.DB
+}
+
+// NewRegistrationRepository returns the registration flows of db.
+func NewRegistrationRepository(db *sql.DB) registration.Repository {
+	return &registrationRepository{db: db}
+}
+
+func (r *registrationRepository) CreateFlow(ctx context.Context, flow *registration.Flow) error {
+	var challengeSeed string
+	var challengeDifficulty uint8
+	if flow.Challenge != nil {
+		challengeSeed = flow.Challenge.Seed
+		challengeDifficulty = flow.Challenge.Difficulty
+	}
+	id := ulid.New()
+	issuedAt := now()
+	expiresAt := issuedAt.Add(flow.Interval)
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		createRegistrationFlowSQL,
+		id, encodeTime(issuedAt), encodeTime(expiresAt), flow.SessionID, challengeSeed, challengeDifficulty,
+	)
+	if err != nil {
+		return translateError(err)
+	}
+	flow.FlowID = id
+	flow.IssuedAt = issuedAt
+	flow.ExpiresAt = expiresAt
+	return nil
+}
+
+func (r *registrationRepository) QueryFlowByFlowID(ctx context.Context, flow *registration.Flow) error {
+	if err := validateID(flow.FlowID); err != nil {
+		return err
+	}
+	var issuedAt, expiresAt int64
+	var challengeSeed string
+	var challengeDifficulty uint8
+	err := conn(ctx, r.db).
+		QueryRowContext(ctx, queryRegistrationFlowSQL, flow.FlowID).
+		Scan(&issuedAt, &expiresAt, &flow.SessionID, &challengeSeed, &challengeDifficulty)
+	if err != nil {
+		return translateError(err)
+	}
+	flow.IssuedAt = decodeTime(issuedAt)
+	flow.ExpiresAt = decodeTime(expiresAt)
+	flow.Challenge = nil
+	if challengeSeed != "" {
+		flow.Challenge = &registration.Challenge{Seed: challengeSeed, Difficulty: challengeDifficulty}
+	}
+	return nil
+}
+
+func (r *registrationRepository) CountRecentFlows(ctx context.Context, network netip.Prefix, since time.Time) (int, error) {
+	first, last := encodePrefix(network)
+	var count int
+	err := conn(ctx, r.db).QueryRowContext(ctx, countRecentRegistrationFlowsSQL, first, last, encodeTime(since)).Scan(&count)
+	return count, translateError(err)
+}
@@ -0,0 +1,66 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/domain/retention"
+)
+
+//go:embed sql/acquireLease.sql
+var acquireLeaseSQL string
+
+//go:embed sql/deleteExpiredRegistrationFlows.sql
+var deleteExpiredRegistrationFlowsSQL string
+
+//go:embed sql/deleteExpiredSessions.sql
+var deleteExpiredSessionsSQL string
+
+type retentionRepository struct {
+	db *sql.DB
+}
+
+// NewRetentionRepository returns the repository of the reaper on db.
+func NewRetentionRepository(db *sql.DB) retention.Repository {
+	return &retentionRepository{db: db}
+}
+
+func (r *retentionRepository) AcquireLease(ctx context.Context, name string, holder string, duration time.Duration) (bool, error) {
+	current := now()
+	err := conn(ctx, r.db).
+		QueryRowContext(ctx, acquireLeaseSQL, name, holder, encodeTime(current.Add(duration)), encodeTime(current)).
+		Scan(&holder)
+	if errors.Is(err, sql.ErrNoRows) {
+		// held by another holder
+		return false, nil
+	}
+	return err == nil, translateError(err)
+}
+
+func (r *retentionRepository) DeleteExpiredFlows(ctx context.Context, before time.Time, limit int) (int, error) {
+	return r.delete(ctx, deleteExpiredRegistrationFlowsSQL, encodeTime(before), limit)
+}
+
+func (r *retentionRepository) DeleteExpiredSessions(ctx context.Context, before time.Time, historyBefore time.Time, limit int) (int, error) {
+	return r.delete(ctx, deleteExpiredSessionsSQL, encodeTime(before), encodeTime(historyBefore), limit)
+}
+
+// DeleteFinishedMessages deletes nothing, as single-node installations have
+// no outbox.
+func (r *retentionRepository) DeleteFinishedMessages(ctx context.Context, before time.Time, limit int) (int, error) {
+	return 0, nil
+}
+
+// delete runs one of the batched deletes with args and returns how many
+// rows it deleted.
+func (r *retentionRepository) delete(ctx context.Context, query string, args ...any) (int, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
@@ -0,0 +1,121 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/retention"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/ulid"
+)
+
+type RetentionRepositorySuite struct {
+	suite.Suite
+	db         *sql.DB
+	repository retention.Repository
+}
+
+func (s *RetentionRepositorySuite) SetupTest() {
+	var err error
+	s.db, err = Open(context.Background(), filepath.Join(s.T().TempDir(), "auth.db"))
+	s.Require().NoError(err)
+	s.repository = NewRetentionRepository(s.db)
+}
+
+// createSession creates a session with a device and a flow, expiring at
+// expiresAt, and returns the IDs of the session and the flow.
+func (s *RetentionRepositorySuite) createSession(expiresAt time.Time) (string, string) {
+	ctx := context.Background()
+	sessionID, flowID := ulid.New(), ulid.New()
+	issuedAt := encodeTime(expiresAt.Add(-time.Hour))
+	_, err := s.db.ExecContext(ctx, `INSERT INTO sessions (id, issued_at, expires_at) VALUES ($1, $2, $3)`, sessionID, issuedAt, encodeTime(expiresAt))
+	s.Require().NoError(err)
+	_, err = s.db.ExecContext(ctx, `INSERT INTO devices (id, ip_address, geo_location, user_agent, session_id, created_at)
+        VALUES ($1, $2, '', 'test', $3, $4)`, ulid.New(), make([]byte, 16), sessionID, issuedAt)
+	s.Require().NoError(err)
+	_, err = s.db.ExecContext(ctx, `INSERT INTO registration_flows (id, issued_at, expires_at, session_id) VALUES ($1, $2, $3, $4)`, flowID, issuedAt, encodeTime(expiresAt), sessionID)
+	s.Require().NoError(err)
+	return sessionID, flowID
+}
+
+// identify gives the session of sessionID to a new identity.
+func (s *RetentionRepositorySuite) identify(sessionID string) {
+	ctx := context.Background()
+	identityID := ulid.New()
+	createTime := encodeTime(now())
+	_, err := s.db.ExecContext(ctx, `INSERT INTO identities (id, timezone, create_time, update_time, state_update_time) VALUES ($1, 'UTC', $2, $2, $2)`, identityID, createTime)
+	s.Require().NoError(err)
+	_, err = s.db.ExecContext(ctx, `UPDATE sessions SET identity_id = $1 WHERE id = $2`, identityID, sessionID)
+	s.Require().NoError(err)
+}
+
+func (s *RetentionRepositorySuite) count(query string, args ...any) int {
+	var count int
+	s.Require().NoError(s.db.QueryRowContext(context.Background(), query, args...).Scan(&count))
+	return count
+}
+
+func (s *RetentionRepositorySuite) TestAcquireLease() {
+	ctx := context.Background()
+
+	held, err := s.repository.AcquireLease(ctx, retention.LeaseName, "first", time.Minute)
+	s.Require().NoError(err)
+	s.True(held)
+
+	// Renewed by its holder, refused to others until it expires
+	held, err = s.repository.AcquireLease(ctx, retention.LeaseName, "first", time.Millisecond)
+	s.Require().NoError(err)
+	s.True(held)
+	time.Sleep(10 * time.Millisecond)
+	held, err = s.repository.AcquireLease(ctx, retention.LeaseName, "second", time.Minute)
+	s.Require().NoError(err)
+	s.True(held)
+	held, err = s.repository.AcquireLease(ctx, retention.LeaseName, "first", time.Minute)
+	s.Require().NoError(err)
+	s.False(held)
+}
+
+func (s *RetentionRepositorySuite) TestDeleteExpired() {
+	ctx := context.Background()
+	before := now()
+	expiredSessionID, _ := s.createSession(before.Add(-time.Hour))
+	s.createSession(before.Add(-time.Hour))
+	liveSessionID, liveFlowID := s.createSession(before.Add(time.Hour))
+	identifiedSessionID, _ := s.createSession(before.Add(-time.Hour))
+	s.identify(identifiedSessionID)
+	historyBefore := before.Add(-24 * time.Hour)
+	forgottenSessionID, _ := s.createSession(historyBefore.Add(-time.Hour))
+	s.identify(forgottenSessionID)
+
+	deleted, err := s.repository.DeleteExpiredFlows(ctx, before, 1)
+	s.Require().NoError(err)
+	s.Equal(1, deleted)
+	deleted, err = s.repository.DeleteExpiredFlows(ctx, before, 10)
+	s.Require().NoError(err)
+	s.Equal(3, deleted)
+	s.Equal(5, s.count(`SELECT count(*) FROM sessions`))
+
+	deleted, err = s.repository.DeleteExpiredSessions(ctx, before, historyBefore, 10)
+	s.Require().NoError(err)
+	s.Equal(3, deleted)
+	s.Zero(s.count(`SELECT count(*) FROM devices WHERE session_id = $1`, expiredSessionID))
+	s.Equal(1, s.count(`SELECT count(*) FROM sessions WHERE id = $1`, liveSessionID))
+	s.Equal(1, s.count(`SELECT count(*) FROM registration_flows WHERE id = $1`, liveFlowID))
+	// The sessions of identities keep their devices for the risk checks
+	// until the history period is over
+	s.Equal(1, s.count(`SELECT count(*) FROM sessions WHERE id = $1`, identifiedSessionID))
+	s.Zero(s.count(`SELECT count(*) FROM sessions WHERE id = $1`, forgottenSessionID))
+	s.Equal(2, s.count(`SELECT count(*) FROM devices`))
+}
+
+func (s *RetentionRepositorySuite) TearDownTest() {
+	s.db.Close()
+}
+
+func TestRetentionRepositorySuite(t *testing.T) {
+	suite.Run(t, new(RetentionRepositorySuite))
+}
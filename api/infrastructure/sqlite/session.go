@@ -0,0 +1,203 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/infrastructure/ulid"
+)
+
+//go:embed sql/createSession.sql
+var createSessionSQL string
+
+//go:embed sql/createDevice.sql
+var createDeviceSQL string
+
+//go:embed sql/querySessionByID.sql
+var querySessionByIDSQL string
+
+//go:embed sql/querySessionWithDevices.sql
+var querySessionWithDevicesSQL string
+
+//go:embed sql/deleteSession.sql
+var deleteSessionSQL string
+
+//go:embed sql/queryKnownDevice.sql
+var queryKnownDeviceSQL string
+
+//go:embed sql/rememberDevice.sql
+var rememberDeviceSQL string
+
+type sessionRepository struct {
+	db *sql.DB
+}
+
+// NewSessionRepository returns the sessions of db.
+func NewSessionRepository(db *sql.DB) session.Repository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) CreateSession(ctx context.Context, sessionData *session.Session) error {
+	if len(sessionData.Devices) != 1 {
+		return errors.New("only one device is allowed when creating a session")
+	}
+	var identityID any
+	if sessionData.Identity != nil && sessionData.Identity.ID != "" {
+		identityID = sessionData.Identity.ID
+	}
+	var authenticatorAssuranceLevel any
+	if sessionData.AuthenticatorAssuranceLevel != 0 {
+		authenticatorAssuranceLevel = sessionData.AuthenticatorAssuranceLevel
+	}
+	id := ulid.New()
+	issuedAt := now()
+	expiresAt := issuedAt.Add(sessionData.ExpiryInterval)
+	device := sessionData.Devices[0]
+	device.SessionID = id
+	err := withTx(ctx, r.db, func(q querier) error {
+		_, err := q.ExecContext(
+			ctx,
+			createSessionSQL,
+			id, sessionData.Active, authenticatorAssuranceLevel, encodeTime(issuedAt), encodeTime(expiresAt),
+			encodeOptionalTime(sessionData.AuthenticatedAt), identityID,
+		)
+		if err != nil {
+			return err
+		}
+		return insertDevice(ctx, q, &device, issuedAt)
+	})
+	if err != nil {
+		return translateError(err)
+	}
+
+	sessionData.ID = id
+	sessionData.IssuedAt = issuedAt
+	sessionData.ExpiresAt = expiresAt
+	sessionData.Devices[0] = device
+	return nil
+}
+
+func (r *sessionRepository) DeleteSession(ctx context.Context, sessionID string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, deleteSessionSQL, sessionID)
+	return translateError(err)
+}
+
+// querySession fills sessionData from its row.
+func querySession(ctx context.Context, q querier, sessionData *session.Session) error {
+	if err := validateID(sessionData.ID); err != nil {
+		return err
+	}
+	if sessionData.Identity == nil {
+		sessionData.Identity = &identity.Identity{}
+	}
+	var issuedAt, expiresAt, authenticatedAt int64
+	err := q.
+		QueryRowContext(ctx, querySessionByIDSQL, sessionData.ID).
+		Scan(&sessionData.Active, &sessionData.AuthenticatorAssuranceLevel, &issuedAt, &expiresAt, &authenticatedAt, &sessionData.Identity.ID, &sessionData.Identity.Locale)
+	if err != nil {
+		return translateError(err)
+	}
+	sessionData.IssuedAt = decodeTime(issuedAt)
+	sessionData.ExpiresAt = decodeTime(expiresAt)
+	sessionData.AuthenticatedAt = decodeTime(authenticatedAt)
+	return nil
+}
+
+func (r *sessionRepository) QuerySessionByID(ctx context.Context, sessionData *session.Session) error {
+	return querySession(ctx, conn(ctx, r.db), sessionData)
+}
+
+func (r *sessionRepository) QuerySessionWithDevices(ctx context.Context, sessionData *session.Session) error {
+	q := conn(ctx, r.db)
+	if err := querySession(ctx, q, sessionData); err != nil {
+		return err
+	}
+	rows, err := q.QueryContext(ctx, querySessionWithDevicesSQL, sessionData.ID)
+	if err != nil {
+		return translateError(err)
+	}
+	defer rows.Close()
+
+	var devices []session.Device
+	for rows.Next() {
+		var device session.Device
+		var ipAddress []byte
+		var riskReasons string
+		err := rows.Scan(
+			&device.ID, &ipAddress, &device.GeoLocation, &device.UserAgent, &device.SessionID,
+			&device.Location.CountryCode, &device.Location.Region, &device.Location.City, &device.Location.ASN, &device.Location.ASOrganization,
+			&device.Client.Browser, &device.Client.BrowserVersion, &device.Client.OS, &device.Client.OSVersion, &device.Client.DeviceType,
+			&device.Location.Latitude, &device.Location.Longitude, &device.Location.AccuracyRadius,
+			&device.Risk.Score, &device.Risk.Decision, &riskReasons,
+		)
+		if err != nil {
+			return err
+		}
+		device.IPAddress = decodeAddr(ipAddress)
+		if err := json.Unmarshal([]byte(riskReasons), &device.Risk.Reasons); err != nil {
+			return err
+		}
+		devices = append(devices, device)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	sessionData.Devices = devices
+	return nil
+}
+
+func (r *sessionRepository) InsertDevice(ctx context.Context, device *session.Device) error {
+	return translateError(insertDevice(ctx, conn(ctx, r.db), device, now()))
+}
+
+// insertDevice stores device, with a new ID, in its session.
+func insertDevice(ctx context.Context, q querier, device *session.Device, createdAt time.Time) error {
+	reasons := device.Risk.Reasons
+	if reasons == nil {
+		reasons = []string{}
+	}
+	riskReasons, err := json.Marshal(reasons)
+	if err != nil {
+		return err
+	}
+	id := ulid.New()
+	_, err = q.ExecContext(
+		ctx,
+		createDeviceSQL,
+		id, encodeAddr(device.IPAddress), device.GeoLocation, device.UserAgent, device.SessionID,
+		device.Location.CountryCode, device.Location.Region, device.Location.City, device.Location.ASN, device.Location.ASOrganization,
+		device.Client.Browser, device.Client.BrowserVersion, device.Client.OS, device.Client.OSVersion, device.Client.DeviceType,
+		device.Location.Latitude, device.Location.Longitude, device.Location.AccuracyRadius, encodeTime(createdAt),
+		device.Risk.Score, device.Risk.Decision, string(riskReasons),
+	)
+	if err != nil {
+		return err
+	}
+	device.ID = id
+	return nil
+}
+
+func (r *sessionRepository) RememberDevice(ctx context.Context, identityID string, device *session.Device) (bool, error) {
+	client := device.Client
+	if !client.Known() {
+		client = session.ParseUserAgent(device.UserAgent)
+	}
+	var known bool
+	err := withTx(ctx, r.db, func(q querier) error {
+		err := q.
+			QueryRowContext(ctx, queryKnownDeviceSQL, identityID, client.Browser, client.OS, device.Location.CountryCode).
+			Scan(&known)
+		if err != nil {
+			return err
+		}
+		_, err = q.ExecContext(ctx, rememberDeviceSQL, identityID, client.Browser, client.OS, device.Location.CountryCode, encodeTime(now()))
+		return err
+	})
+	return !known, translateError(err)
+}
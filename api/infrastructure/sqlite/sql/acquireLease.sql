@@ -0,0 +1,9 @@
+-- noinspection SqlResolveForFile
+INSERT INTO leases (name, holder, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (name) DO UPDATE SET
+    holder     = excluded.holder,
+    expires_at = excluded.expires_at
+WHERE leases.holder = excluded.holder
+   OR leases.expires_at <= $4
+RETURNING holder;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- noinspection SqlResolveForFile
+INSERT INTO audit_events (id, type, actor, identity_id, session_id, ip_address, user_agent, outcome, action, create_time)
+VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6, NULLIF($7, ''), $8, NULLIF($9, ''), $10);
\ No newline at end of file
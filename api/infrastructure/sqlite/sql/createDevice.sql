@@ -0,0 +1,5 @@
+-- noinspection SqlResolveForFile
+INSERT INTO devices (id, ip_address, geo_location, user_agent, session_id, country_code, region, city, asn, as_organization,
+                     browser, browser_version, os, os_version, device_type,
+                     latitude, longitude, accuracy_radius, created_at, risk_score, risk_decision, risk_reasons)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22);
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- noinspection SqlResolveForFile
+INSERT INTO emails (address, create_time, update_time, identity_id)
+VALUES ($1, $2, $2, $3);
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- noinspection SqlResolveForFile
+INSERT INTO identities (id, timezone, locale, create_time, update_time, state_update_time)
+VALUES ($1, $2, $4, $3, $3, $3);
\ No newline at end of file
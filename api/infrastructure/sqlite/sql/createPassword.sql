@@ -0,0 +1,3 @@
+-- noinspection SqlResolveForFile
+INSERT INTO passwords (identity_id, password_hash)
+VALUES ($1, $2);
\ No newline at end of file
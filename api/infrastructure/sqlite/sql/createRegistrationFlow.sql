@@ -0,0 +1,3 @@
+-- noinspection SqlResolveForFile
+INSERT INTO registration_flows (id, issued_at, expires_at, session_id, challenge_seed, challenge_difficulty)
+VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, 0));
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- noinspection SqlResolveForFile
+INSERT INTO sessions (id, active, authenticator_assurance_level, issued_at, expires_at, authenticated_at, identity_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
\ No newline at end of file
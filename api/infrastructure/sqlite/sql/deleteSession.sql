@@ -0,0 +1,3 @@
+-- noinspection SqlResolveForFile
+DELETE FROM sessions
+WHERE id = $1;
\ No newline at end of file
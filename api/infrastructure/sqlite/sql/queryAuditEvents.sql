@@ -0,0 +1,16 @@
+-- noinspection SqlResolveForFile
+SELECT
+    id,
+    type,
+    COALESCE(actor, '')      AS actor,
+    COALESCE(session_id, '') AS session_id,
+    ip_address,
+    COALESCE(user_agent, '') AS user_agent,
+    outcome,
+    COALESCE(action, '')     AS action,
+    create_time
+FROM audit_events
+WHERE identity_id = $1
+  AND ($2 IS NULL OR (create_time, id) < ($2, $3))
+ORDER BY create_time DESC, id DESC
+LIMIT $4;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- noinspection SqlResolveForFile
+SELECT address, verified, create_time, COALESCE(verified_at, 0), update_time
+FROM emails
+WHERE identity_id = $1
+ORDER BY create_time, address;
\ No newline at end of file
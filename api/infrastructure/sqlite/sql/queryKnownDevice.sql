@@ -0,0 +1,4 @@
+-- noinspection SqlResolveForFile
+SELECT EXISTS (SELECT 1
+               FROM known_devices
+               WHERE identity_id = $1 AND browser = $2 AND os = $3 AND country_code = $4);
\ No newline at end of file
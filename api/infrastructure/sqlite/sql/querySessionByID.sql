@@ -0,0 +1,12 @@
+-- noinspection SqlResolveForFile
+SELECT
+    active,
+    COALESCE(authenticator_assurance_level, 0) AS authenticator_assurance_level,
+    issued_at,
+    expires_at,
+    COALESCE(authenticated_at, 0)              AS authenticated_at,
+    COALESCE(identity_id, '')                  AS identity_id,
+    COALESCE(identities.locale, '')            AS locale
+FROM sessions
+LEFT JOIN identities ON identities.id = sessions.identity_id
+WHERE sessions.id = $1;
\ No newline at end of file
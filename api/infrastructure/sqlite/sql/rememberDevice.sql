@@ -0,0 +1,4 @@
+-- noinspection SqlResolveForFile
+INSERT INTO known_devices (identity_id, browser, os, country_code, first_seen_at, last_seen_at)
+VALUES ($1, $2, $3, $4, $5, $5)
+ON CONFLICT (identity_id, browser, os, country_code) DO UPDATE SET last_seen_at = excluded.last_seen_at;
\ No newline at end of file
@@ -0,0 +1,80 @@
+// Package sqlite implements the identity, registration, session and retention
+// repositories on an embedded SQLite database, for single-node installations
+// without a database server. Its schema is migrated when it is opened.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"slices"
+
+	// registers the pure-Go "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// busyTimeout is how long, in milliseconds, a writer waits for the one
+// running before giving up.
+const busyTimeout = 5000
+
+// Open opens the database file at path, creating it if needed, and applies
+// the pending migrations. The database is in WAL mode, so that reads go on
+// while a transaction writes, and transactions take the write lock when
+// they begin, so that they wait for each other instead of failing to
+// upgrade their lock.
+func Open(ctx context.Context, path string) (*sql.DB, error) {
+	query := url.Values{}
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "synchronous(NORMAL)")
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout))
+	query.Set("_txlock", "immediate")
+	db, err := sql.Open("sqlite", "file:"+path+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if err := migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// migrate applies the embedded migrations newer than the user_version of
+// db, each in its own transaction, and counts them in user_version.
+func migrate(ctx context.Context, db *sql.DB) error {
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	slices.Sort(names)
+
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	for i := version; i < len(names); i++ {
+		script, err := migrations.ReadFile(names[i])
+		if err != nil {
+			return err
+		}
+		err = withTx(ctx, db, func(q querier) error {
+			if _, err := q.ExecContext(ctx, string(script)); err != nil {
+				return err
+			}
+			// PRAGMA takes no parameters
+			_, err := q.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", names[i], err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package sqlite
+
+import (
+	"context"
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "auth.db")
+	db, err := Open(ctx, path)
+	require.NoError(t, err)
+
+	var journalMode string
+	require.NoError(t, db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journalMode))
+	assert.Equal(t, "wal", journalMode)
+	var foreignKeys bool
+	require.NoError(t, db.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys))
+	assert.True(t, foreignKeys)
+	require.NoError(t, db.Close())
+
+	// Opening it again applies no migration twice
+	db, err = Open(ctx, path)
+	require.NoError(t, err)
+	defer db.Close()
+	var version int
+	require.NoError(t, db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version))
+	names, err := migrations.ReadDir("migrations")
+	require.NoError(t, err)
+	assert.Equal(t, len(names), version)
+}
+
+func TestEncodeAddr(t *testing.T) {
+	for _, addr := range []string{"192.0.2.1", "2001:db8::1", "::ffff:192.0.2.1"} {
+		parsed := netip.MustParseAddr(addr)
+		assert.Equal(t, parsed.Unmap(), decodeAddr(encodeAddr(parsed)), addr)
+	}
+
+	first, last := encodePrefix(netip.MustParsePrefix("192.0.2.77/24"))
+	assert.Equal(t, netip.MustParseAddr("192.0.2.0"), decodeAddr(first))
+	assert.Equal(t, netip.MustParseAddr("192.0.2.255"), decodeAddr(last))
+
+	first, last = encodePrefix(netip.MustParsePrefix("2001:db8::/30"))
+	assert.Equal(t, netip.MustParseAddr("2001:db8::"), decodeAddr(first))
+	assert.Equal(t, netip.MustParseAddr("2001:dbb:ffff:ffff:ffff:ffff:ffff:ffff"), decodeAddr(last))
+}
@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"gitlab.mreg.io/my-registry/auth/domain/transaction"
+)
+
+// querier is implemented by both the database and its transactions.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// conn returns the transaction carried by ctx, or db outside of a unit of
+// work. Every repository query goes through it.
+func conn(ctx context.Context, db *sql.DB) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// withTx runs fn in a transaction, or in a savepoint of the transaction
+// carried by ctx, so that the statements of fn are applied together.
+func withTx(ctx context.Context, db *sql.DB, fn func(q querier) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT repository"); err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			_, rollbackErr := tx.ExecContext(ctx, "ROLLBACK TO repository")
+			return errors.Join(err, rollbackErr)
+		}
+		_, err := tx.ExecContext(ctx, "RELEASE repository")
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
+
+type transactionRunner struct {
+	db *sql.DB
+}
+
+// NewTransactionRunner returns a runner of transactions on db. SQLite runs
+// one writing transaction at a time, so they never conflict and are not
+// retried.
+func NewTransactionRunner(db *sql.DB) transaction.Runner {
+	return &transactionRunner{db: db}
+}
+
+func (r *transactionRunner) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
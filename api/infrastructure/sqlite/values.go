@@ -0,0 +1,70 @@
+package sqlite
+
+import (
+	"net/netip"
+	"time"
+)
+
+// The columns of times hold microseconds since the Unix epoch, the
+// precision of the other databases, with 0 for a missing time.
+
+// now returns the current time as it reads back from the database.
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+func encodeTime(t time.Time) int64 {
+	return t.UnixMicro()
+}
+
+// encodeOptionalTime returns nil for the zero time, stored as NULL.
+func encodeOptionalTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UnixMicro()
+}
+
+func decodeTime(micros int64) time.Time {
+	if micros == 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(micros)
+}
+
+// encodeAddr returns the 16-byte form of addr, in which IPv4 addresses are
+// mapped into IPv6 so that every address sorts and compares as bytes.
+func encodeAddr(addr netip.Addr) []byte {
+	b := addr.As16()
+	return b[:]
+}
+
+// encodeOptionalAddr returns nil for the zero address, stored as NULL.
+func encodeOptionalAddr(addr netip.Addr) any {
+	if !addr.IsValid() {
+		return nil
+	}
+	return encodeAddr(addr)
+}
+
+func decodeAddr(b []byte) netip.Addr {
+	if len(b) != 16 {
+		return netip.Addr{}
+	}
+	return netip.AddrFrom16([16]byte(b)).Unmap()
+}
+
+// encodePrefix returns the first and last addresses of network in the form
+// of encodeAddr, the bounds of a BETWEEN.
+func encodePrefix(network netip.Prefix) (first, last []byte) {
+	network = network.Masked()
+	first = encodeAddr(network.Addr())
+	last = encodeAddr(network.Addr())
+	hostBits := network.Addr().BitLen() - network.Bits()
+	for i := len(last) - 1; hostBits > 0; i-- {
+		bits := min(hostBits, 8)
+		last[i] |= byte(1<<bits - 1)
+		hostBits -= bits
+	}
+	return first, last
+}
@@ -0,0 +1,270 @@
+// Package storetest is the conformance suite of the identity, registration
+// and session repositories and their transaction runner. Every backend runs
+// it from its own tests, so that the services behave the same on all of
+// them.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/registration"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+	"gitlab.mreg.io/my-registry/auth/domain/transaction"
+)
+
+// Repositories are the backend under test.
+type Repositories struct {
+	Identities    identity.Repository
+	Registrations registration.Repository
+	Sessions      session.Repository
+	Transactions  transaction.Runner
+}
+
+// Run runs the suite on the repositories returned by open, which is called
+// before every test. Backends may share their rows between tests, so the
+// suite only relies on the rows it creates.
+func Run(t *testing.T, open func() Repositories) {
+	suite.Run(t, &Suite{open: open})
+}
+
+type Suite struct {
+	suite.Suite
+	open func() Repositories
+	Repositories
+}
+
+func (s *Suite) SetupTest() {
+	s.Repositories = s.open()
+}
+
+func randomEmail() string {
+	return "user-" + uuid.NewString() + "@example.com"
+}
+
+// randomNetwork returns a network no other test adds devices to.
+func randomNetwork() netip.Prefix {
+	return netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(rand.IntN(256)), byte(rand.IntN(256)), 0}), 24)
+}
+
+func (s *Suite) createIdentity(email string) *identity.Identity {
+	created := &identity.Identity{Timezone: "UTC", Locale: "fr", Emails: []identity.Email{{Value: email}}, PasswordHash: "hash"}
+	s.Require().NoError(s.Identities.CreateIdentity(context.Background(), created))
+	return created
+}
+
+func (s *Suite) createSession(owner *identity.Identity, address netip.Addr) *session.Session {
+	created := &session.Session{
+		Active:         true,
+		ExpiryInterval: time.Hour,
+		Devices:        []session.Device{session.NewDevice(address, "Mozilla/5.0")},
+		Identity:       owner,
+	}
+	s.Require().NoError(s.Sessions.CreateSession(context.Background(), created))
+	return created
+}
+
+func (s *Suite) TestIdentity() {
+	ctx := context.Background()
+	address := randomEmail()
+	created := s.createIdentity(address)
+	s.Require().NoError(uuid.Validate(created.ID))
+	s.False(created.CreateTime.IsZero())
+	s.Equal(created.CreateTime, created.Emails[0].CreateTime)
+
+	exists, err := s.Identities.EmailExists(ctx, address)
+	s.Require().NoError(err)
+	s.True(exists)
+	exists, err = s.Identities.EmailExists(ctx, randomEmail())
+	s.Require().NoError(err)
+	s.False(exists)
+
+	email := &identity.Email{Value: address}
+	s.Require().NoError(s.Identities.QueryEmail(ctx, email))
+	s.False(email.Verified)
+	s.ErrorIs(s.Identities.QueryEmail(ctx, &identity.Email{Value: randomEmail()}), store.ErrNotFound)
+
+	queried := &identity.Identity{ID: created.ID}
+	s.Require().NoError(s.Identities.QueryEmails(ctx, queried))
+	s.Require().Len(queried.Emails, 1)
+	s.Equal(address, queried.Emails[0].Value)
+}
+
+func (s *Suite) TestIdentity_EmailExists() {
+	address := randomEmail()
+	s.createIdentity(address)
+
+	duplicate := &identity.Identity{Timezone: "UTC", Emails: []identity.Email{{Value: address}}, PasswordHash: "hash"}
+	err := s.Identities.CreateIdentity(context.Background(), duplicate)
+	s.ErrorIs(err, store.ErrAlreadyExists)
+	var constraintErr *store.ConstraintError
+	s.Require().ErrorAs(err, &constraintErr)
+	s.Equal("emails_pkey", constraintErr.Constraint)
+
+	s.Error(s.Identities.CreateIdentity(context.Background(), &identity.Identity{}), "no email")
+}
+
+func (s *Suite) TestSession() {
+	ctx := context.Background()
+	owner := s.createIdentity(randomEmail())
+	created := s.createSession(owner, netip.MustParseAddr("192.0.2.1"))
+	s.Require().NoError(uuid.Validate(created.ID))
+	s.Require().NoError(uuid.Validate(created.Devices[0].ID))
+	s.WithinDuration(created.IssuedAt.Add(time.Hour), created.ExpiresAt, 0)
+
+	queried := &session.Session{ID: created.ID}
+	s.Require().NoError(s.Sessions.QuerySessionByID(ctx, queried))
+	s.True(queried.Active)
+	s.Equal(owner.ID, queried.Identity.ID)
+	// The locale of the identity is loaded with the session
+	s.Equal("fr", queried.Identity.Locale)
+	s.WithinDuration(created.ExpiresAt, queried.ExpiresAt, 0)
+
+	// Sessions are created before the identity they are for
+	anonymous := s.createSession(nil, netip.MustParseAddr("192.0.2.1"))
+	queried = &session.Session{ID: anonymous.ID}
+	s.Require().NoError(s.Sessions.QuerySessionByID(ctx, queried))
+	s.Empty(queried.Identity.ID)
+	s.Empty(queried.Identity.Locale)
+
+	s.ErrorIs(s.Sessions.QuerySessionByID(ctx, &session.Session{ID: uuid.NewString()}), store.ErrNotFound)
+	s.ErrorIs(s.Sessions.QuerySessionByID(ctx, &session.Session{ID: "not a session ID"}), store.ErrInvalid)
+}
+
+func (s *Suite) TestDevices() {
+	ctx := context.Background()
+	created := s.createSession(nil, netip.MustParseAddr("192.0.2.1"))
+
+	device := session.NewDevice(netip.MustParseAddr("198.51.100.1"), "curl/8.0")
+	device.SessionID = created.ID
+	s.Require().NoError(s.Sessions.InsertDevice(ctx, &device))
+	s.Require().NoError(uuid.Validate(device.ID))
+
+	queried := &session.Session{ID: created.ID}
+	s.Require().NoError(s.Sessions.QuerySessionWithDevices(ctx, queried))
+	s.Require().Len(queried.Devices, 2)
+	s.ElementsMatch(
+		[]string{created.Devices[0].ID, device.ID},
+		[]string{queried.Devices[0].ID, queried.Devices[1].ID},
+	)
+	for _, queriedDevice := range queried.Devices {
+		s.Equal(created.ID, queriedDevice.SessionID)
+	}
+
+	orphan := session.NewDevice(netip.MustParseAddr("198.51.100.1"), "curl/8.0")
+	orphan.SessionID = uuid.NewString()
+	s.ErrorIs(s.Sessions.InsertDevice(ctx, &orphan), store.ErrInvalid)
+}
+
+func (s *Suite) TestRememberDevice() {
+	ctx := context.Background()
+	owner := s.createIdentity(randomEmail())
+	device := session.NewDevice(netip.MustParseAddr("198.51.100.1"), "curl/8.0")
+
+	isNew, err := s.Sessions.RememberDevice(ctx, owner.ID, &device)
+	s.Require().NoError(err)
+	s.True(isNew)
+	isNew, err = s.Sessions.RememberDevice(ctx, owner.ID, &device)
+	s.Require().NoError(err)
+	s.False(isNew)
+
+	_, err = s.Sessions.RememberDevice(ctx, uuid.NewString(), &device)
+	s.ErrorIs(err, store.ErrInvalid)
+}
+
+func (s *Suite) TestFlow() {
+	ctx := context.Background()
+	owner := s.createSession(nil, netip.MustParseAddr("192.0.2.1"))
+	flow := &registration.Flow{
+		SessionID: owner.ID,
+		Interval:  time.Hour,
+		Challenge: &registration.Challenge{Seed: "seed", Difficulty: 8},
+	}
+	s.Require().NoError(s.Registrations.CreateFlow(ctx, flow))
+	s.Require().NoError(uuid.Validate(flow.FlowID))
+	s.WithinDuration(flow.IssuedAt.Add(time.Hour), flow.ExpiresAt, 0)
+
+	queried := &registration.Flow{FlowID: flow.FlowID}
+	s.Require().NoError(s.Registrations.QueryFlowByFlowID(ctx, queried))
+	s.Equal(owner.ID, queried.SessionID)
+	s.Equal(flow.Challenge, queried.Challenge)
+
+	withoutChallenge := &registration.Flow{SessionID: owner.ID, Interval: time.Hour}
+	s.Require().NoError(s.Registrations.CreateFlow(ctx, withoutChallenge))
+	queried = &registration.Flow{FlowID: withoutChallenge.FlowID, Challenge: flow.Challenge}
+	s.Require().NoError(s.Registrations.QueryFlowByFlowID(ctx, queried))
+	s.Nil(queried.Challenge)
+
+	s.ErrorIs(s.Registrations.QueryFlowByFlowID(ctx, &registration.Flow{FlowID: uuid.NewString()}), store.ErrNotFound)
+	s.ErrorIs(s.Registrations.CreateFlow(ctx, &registration.Flow{SessionID: uuid.NewString(), Interval: time.Hour}), store.ErrInvalid)
+}
+
+func (s *Suite) TestCountRecentFlows() {
+	ctx := context.Background()
+	network := randomNetwork()
+	owner := s.createSession(nil, network.Addr().Next())
+	flow := &registration.Flow{SessionID: owner.ID, Interval: time.Hour}
+	s.Require().NoError(s.Registrations.CreateFlow(ctx, flow))
+
+	count, err := s.Registrations.CountRecentFlows(ctx, network, flow.IssuedAt.Add(-time.Minute))
+	s.Require().NoError(err)
+	s.Equal(1, count)
+	count, err = s.Registrations.CountRecentFlows(ctx, network, flow.IssuedAt.Add(time.Minute))
+	s.Require().NoError(err)
+	s.Zero(count)
+	count, err = s.Registrations.CountRecentFlows(ctx, randomNetwork(), flow.IssuedAt.Add(-time.Minute))
+	s.Require().NoError(err)
+	s.Zero(count)
+}
+
+func (s *Suite) TestDeleteSession() {
+	ctx := context.Background()
+	owner := s.createSession(nil, netip.MustParseAddr("192.0.2.1"))
+	flow := &registration.Flow{SessionID: owner.ID, Interval: time.Hour}
+	s.Require().NoError(s.Registrations.CreateFlow(ctx, flow))
+
+	// The devices and flows of the session cascade
+	s.Require().NoError(s.Sessions.DeleteSession(ctx, owner.ID))
+	s.ErrorIs(s.Sessions.QuerySessionWithDevices(ctx, &session.Session{ID: owner.ID}), store.ErrNotFound)
+	s.ErrorIs(s.Registrations.QueryFlowByFlowID(ctx, &registration.Flow{FlowID: flow.FlowID}), store.ErrNotFound)
+
+	// Deleting it again is not an error
+	s.NoError(s.Sessions.DeleteSession(ctx, owner.ID))
+}
+
+func (s *Suite) TestTransactions() {
+	ctx := context.Background()
+	failure := errors.New("failure")
+	rolledBack := randomEmail()
+	err := s.Transactions.Run(ctx, func(ctx context.Context) error {
+		created := &identity.Identity{Timezone: "UTC", Emails: []identity.Email{{Value: rolledBack}}}
+		s.Require().NoError(s.Identities.CreateIdentity(ctx, created))
+		return failure
+	})
+	s.ErrorIs(err, failure)
+	exists, err := s.Identities.EmailExists(ctx, rolledBack)
+	s.Require().NoError(err)
+	s.False(exists)
+
+	committed := randomEmail()
+	err = s.Transactions.Run(ctx, func(ctx context.Context) error {
+		// Nested units of work join the running one
+		return s.Transactions.Run(ctx, func(ctx context.Context) error {
+			created := &identity.Identity{Timezone: "UTC", Emails: []identity.Email{{Value: committed}}}
+			return s.Identities.CreateIdentity(ctx, created)
+		})
+	})
+	s.Require().NoError(err)
+	exists, err = s.Identities.EmailExists(ctx, committed)
+	s.Require().NoError(err)
+	s.True(exists)
+}
@@ -0,0 +1,58 @@
+// Package ulid generates the IDs of database rows: ULIDs in the UUID form
+// that gen_random_ulid gives CockroachDB, so that rows created by any
+// backend sort in creation order.
+package ulid
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Generator generates monotonic ULIDs: 48 bits of milliseconds followed by
+// 80 random bits. Within a millisecond the random bits of the previous ID
+// are incremented instead, so that IDs sort in creation order.
+type Generator struct {
+	mu   sync.Mutex
+	last uuid.UUID
+	now  func() time.Time
+}
+
+// NewGenerator returns a generator reading the time from now.
+func NewGenerator(now func() time.Time) *Generator {
+	return &Generator{now: now}
+}
+
+var generator = NewGenerator(time.Now)
+
+// New returns a ULID from the process-wide generator.
+func New() string {
+	return generator.New()
+}
+
+// New returns a ULID in UUID form.
+func (g *Generator) New() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var id uuid.UUID
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(g.now().UnixMilli()))
+	copy(id[:6], ms[2:])
+	if [6]byte(id[:6]) == [6]byte(g.last[:6]) {
+		id = g.last
+		for i := len(id) - 1; i >= 6; i-- {
+			id[i]++
+			if id[i] != 0 {
+				break
+			}
+		}
+	} else if _, err := rand.Read(id[6:]); err != nil {
+		panic(err)
+	}
+	g.last = id
+	return id.String()
+}
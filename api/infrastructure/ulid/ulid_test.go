@@ -0,0 +1,33 @@
+package ulid
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 18, 0, 0, 0, time.UTC)
+	generator := NewGenerator(func() time.Time { return now })
+
+	first := generator.New()
+	second := generator.New()
+	assert.Less(t, first, second)
+	assert.Equal(t, first[:13], second[:13], "same millisecond")
+	require.NoError(t, uuid.Validate(first))
+	assert.Equal(t, fmt.Sprintf("%012x", now.UnixMilli()), strings.ReplaceAll(first[:13], "-", ""), "milliseconds first")
+
+	now = now.Add(time.Millisecond)
+	third := generator.New()
+	assert.Less(t, second, third)
+	assert.NotEqual(t, second[:13], third[:13])
+}
+
+func TestNew(t *testing.T) {
+	assert.NotEqual(t, New(), New())
+}
@@ -0,0 +1,145 @@
+// Package webhook delivers outbox messages to HTTP endpoints as CloudEvents
+// in structured JSON mode. Deliveries are signed the way Standard Webhooks
+// are: an HMAC-SHA256 of the message ID, the timestamp and the body.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/domain/outbox"
+)
+
+const (
+	// Source is the CloudEvents source of every message.
+	Source = "//auth.mreg.io"
+
+	ContentType     = "application/cloudevents+json"
+	IDHeader        = "Webhook-Id"
+	TimestampHeader = "Webhook-Timestamp"
+	SignatureHeader = "Webhook-Signature"
+
+	// signatureVersion prefixes the signatures of the current scheme.
+	signatureVersion = "v1"
+	// maxResponseBytes is read from responses, so that connections can be
+	// reused without reading arbitrary bodies.
+	maxResponseBytes = 4096
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrStaleTimestamp   = errors.New("webhook timestamp outside tolerance")
+)
+
+// StatusError is returned for deliveries the endpoint did not accept.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook endpoint responded %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// Publisher posts messages to webhook endpoints. It implements
+// outbox.Publisher.
+type Publisher struct {
+	client *http.Client
+	secret []byte
+	now    func() time.Time
+}
+
+// NewPublisher creates a publisher signing with secret. The client should
+// have a timeout.
+func NewPublisher(client *http.Client, secret []byte) *Publisher {
+	return &Publisher{client: client, secret: secret, now: time.Now}
+}
+
+// Publish delivers message to endpoint. Any response but a 2xx is an error.
+func (p *Publisher) Publish(ctx context.Context, endpoint string, message *outbox.Message) error {
+	body, err := json.Marshal(cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              message.ID,
+		Source:          Source,
+		Type:            message.Type,
+		Subject:         message.Subject,
+		Time:            message.CreateTime,
+		DataContentType: "application/json",
+		Data:            message.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(p.now().Unix(), 10)
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set(IDHeader, message.ID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, signatureVersion+","+Sign(p.secret, message.ID, timestamp, body))
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, maxResponseBytes))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return &StatusError{StatusCode: res.StatusCode}
+	}
+	return nil
+}
+
+// Sign returns the base64 HMAC-SHA256 of a delivery.
+func Sign(secret []byte, id string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(body)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a delivery received at now, for
+// receivers. The signature header may hold several space separated
+// signatures while secrets are rotated; one valid signature is enough.
+func Verify(secret []byte, header http.Header, body []byte, now time.Time, tolerance time.Duration) error {
+	unix, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if diff := now.Sub(time.Unix(unix, 0)); diff > tolerance || diff < -tolerance {
+		return ErrStaleTimestamp
+	}
+
+	expected := Sign(secret, header.Get(IDHeader), header.Get(TimestampHeader), body)
+	for _, signature := range strings.Fields(header.Get(SignatureHeader)) {
+		version, value, ok := strings.Cut(signature, ",")
+		if ok && version == signatureVersion && hmac.Equal([]byte(value), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/outbox"
+)
+
+var secret = []byte("0123456789abcdef0123456789abcdef")
+
+type webhookTestSuite struct {
+	suite.Suite
+	now       time.Time
+	publisher *Publisher
+	message   *outbox.Message
+}
+
+func (s *webhookTestSuite) SetupTest() {
+	s.now = time.Date(2026, time.October, 19, 16, 47, 12, 0, time.UTC)
+	s.publisher = NewPublisher(http.DefaultClient, secret)
+	s.publisher.now = func() time.Time { return s.now }
+	s.message = &outbox.Message{
+		ID:         "01928f5e-8c4d-7e5f-a06b-1c2d3e4f5a6b",
+		Type:       outbox.TypeIdentityCreated,
+		Subject:    "c935b23d-6cb4-448a-814e-b42aec9ef6cf",
+		Data:       json.RawMessage(`{"id":"c935b23d-6cb4-448a-814e-b42aec9ef6cf","state":"active"}`),
+		CreateTime: s.now.Add(-time.Second),
+	}
+}
+
+func (s *webhookTestSuite) TestPublish() {
+	var received *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	s.Require().NoError(s.publisher.Publish(context.Background(), receiver.URL, s.message))
+	s.Equal(http.MethodPost, received.Method)
+	s.Equal(ContentType, received.Header.Get("Content-Type"))
+	s.Equal(s.message.ID, received.Header.Get(IDHeader))
+	s.JSONEq(`{
+		"specversion": "1.0",
+		"id": "01928f5e-8c4d-7e5f-a06b-1c2d3e4f5a6b",
+		"source": "//auth.mreg.io",
+		"type": "io.mreg.auth.identity.created.v1",
+		"subject": "c935b23d-6cb4-448a-814e-b42aec9ef6cf",
+		"time": "2026-10-19T16:47:11Z",
+		"datacontenttype": "application/json",
+		"data": {"id": "c935b23d-6cb4-448a-814e-b42aec9ef6cf", "state": "active"}
+	}`, string(body))
+	s.NoError(Verify(secret, received.Header, body, s.now, time.Minute))
+}
+
+func (s *webhookTestSuite) TestPublish_Rejected() {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	err := s.publisher.Publish(context.Background(), receiver.URL, s.message)
+	var statusErr *StatusError
+	s.Require().ErrorAs(err, &statusErr)
+	s.Equal(http.StatusServiceUnavailable, statusErr.StatusCode)
+}
+
+func (s *webhookTestSuite) TestVerify() {
+	body := []byte(`{"id":"1"}`)
+	header := http.Header{}
+	header.Set(IDHeader, "1")
+	header.Set(TimestampHeader, "1792428432")
+	now := time.Unix(1792428432, 0)
+
+	// Any of the signatures of a rotation may match
+	header.Set(SignatureHeader, "v1,bm90IGl0 v1,"+Sign(secret, "1", "1792428432", body))
+	s.NoError(Verify(secret, header, body, now, time.Minute))
+
+	s.ErrorIs(Verify([]byte("other secret"), header, body, now, time.Minute), ErrInvalidSignature)
+	s.ErrorIs(Verify(secret, header, []byte(`{"id":"2"}`), now, time.Minute), ErrInvalidSignature)
+	s.ErrorIs(Verify(secret, header, body, now.Add(time.Hour), time.Minute), ErrStaleTimestamp)
+
+	header.Set(SignatureHeader, "v2,"+Sign(secret, "1", "1792428432", body))
+	s.ErrorIs(Verify(secret, header, body, now, time.Minute), ErrInvalidSignature)
+}
+
+func TestWebhookTestSuite(t *testing.T) {
+	suite.Run(t, new(webhookTestSuite))
+}
@@ -0,0 +1,116 @@
+// Package logging configures log/slog for the auth server.
+//
+// Records are written as JSON. Attributes stored in the context with With are
+// added to every record logged with that context, and personal data is
+// redacted before it reaches the output.
+package logging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/netip"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Keys of the attributes shared by all log records.
+const (
+	RequestIDKey  = "request_id"
+	ProcedureKey  = "procedure"
+	ClientIPKey   = "client_ip"
+	FlowIDKey     = "flow_id"
+	SessionIDKey  = "session_id"
+	IdentityIDKey = "identity_id"
+	ErrorKey      = "error"
+	TraceIDKey    = "trace_id"
+	SpanIDKey     = "span_id"
+)
+
+// New returns a logger writing JSON records of at least level to w.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	})
+	return slog.New(&contextHandler{handler})
+}
+
+type contextKey struct{}
+
+// With returns a context whose log records carry attrs in addition to the
+// attributes already stored in ctx.
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	combined := make([]slog.Attr, 0, len(existing)+len(attrs))
+	combined = append(combined, existing...)
+	combined = append(combined, attrs...)
+	return context.WithValue(ctx, contextKey{}, combined)
+}
+
+// contextHandler adds the attributes stored by With and the current trace
+// to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(contextKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String(TraceIDKey, spanContext.TraceID().String()),
+			slog.String(SpanIDKey, spanContext.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
+}
+
+// RequestID returns the request correlation ID attribute.
+func RequestID(id string) slog.Attr {
+	return slog.String(RequestIDKey, id)
+}
+
+// Procedure returns the connect procedure attribute.
+func Procedure(procedure string) slog.Attr {
+	return slog.String(ProcedureKey, procedure)
+}
+
+// ClientIP returns the client address attribute.
+func ClientIP(addr netip.Addr) slog.Attr {
+	return slog.String(ClientIPKey, addr.String())
+}
+
+// FlowID returns the registration flow attribute.
+func FlowID(id string) slog.Attr {
+	return slog.String(FlowIDKey, id)
+}
+
+// SessionID returns the session attribute. Session IDs are bearer
+// credentials, so only a fingerprint is logged; it is enough to correlate
+// the records of one session.
+func SessionID(id string) slog.Attr {
+	sum := sha256.Sum256([]byte(id))
+	return slog.String(SessionIDKey, hex.EncodeToString(sum[:8]))
+}
+
+// IdentityID returns the identity attribute.
+func IdentityID(id string) slog.Attr {
+	return slog.String(IdentityIDKey, id)
+}
+
+// Error returns the error attribute.
+func Error(err error) slog.Attr {
+	return slog.Any(ErrorKey, err)
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type loggingTestSuite struct {
+	suite.Suite
+	output *bytes.Buffer
+	logger *slog.Logger
+}
+
+func (s *loggingTestSuite) SetupTest() {
+	s.output = new(bytes.Buffer)
+	s.logger = New(s.output, slog.LevelInfo)
+}
+
+func (s *loggingTestSuite) record() map[string]any {
+	var record map[string]any
+	s.Require().NoError(json.Unmarshal(s.output.Bytes(), &record))
+	s.output.Reset()
+	return record
+}
+
+func (s *loggingTestSuite) TestContextAttributes() {
+	ctx := With(context.Background(), RequestID("abc"), Procedure("/test.v1.TestService/Ping"))
+	ctx = With(ctx, ClientIP(netip.MustParseAddr("192.0.2.1")), FlowID("flow"))
+
+	s.logger.InfoContext(ctx, "hello")
+	record := s.record()
+	s.Equal("hello", record["msg"])
+	s.Equal("abc", record[RequestIDKey])
+	s.Equal("/test.v1.TestService/Ping", record[ProcedureKey])
+	s.Equal("192.0.2.1", record[ClientIPKey])
+	s.Equal("flow", record[FlowIDKey])
+
+	// Attributes of a derived context do not leak into the parent
+	With(ctx, SessionID("session"))
+	s.logger.InfoContext(ctx, "hello")
+	s.NotContains(s.record(), SessionIDKey)
+}
+
+func (s *loggingTestSuite) TestTraceContext() {
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+
+	s.logger.InfoContext(ctx, "hello")
+	record := s.record()
+	s.Equal("4bf92f3577b34da6a3ce929d0e0e4736", record[TraceIDKey])
+	s.Equal("00f067aa0ba902b7", record[SpanIDKey])
+
+	s.logger.Info("hello")
+	s.NotContains(s.record(), TraceIDKey)
+}
+
+func (s *loggingTestSuite) TestSessionIDFingerprint() {
+	s.logger.Info("hello", SessionID("c2e577de-2fbc-4fa4-8dcd-321a960ebb36"))
+	record := s.record()
+	s.Len(record[SessionIDKey], 16)
+	s.NotContains(s.output.String(), "c2e577de")
+}
+
+func (s *loggingTestSuite) TestRedactSensitiveKeys() {
+	s.logger.Info("hello", "password", "pa$$word", "Email", "test@example.com", slog.Group("request", "cookie", "session_id=abc"))
+	record := s.record()
+	s.Equal(redacted, record["password"])
+	s.Equal(redacted, record["Email"])
+	s.Equal(map[string]any{"cookie": redacted}, record["request"])
+}
+
+func (s *loggingTestSuite) TestRedactEmailsInText() {
+	err := errors.New(`duplicate key value violates unique constraint: Key (value)=(test@example.com) already exists`)
+	s.logger.Error("cannot register test@example.com", Error(err))
+	record := s.record()
+	s.Equal("cannot register "+redacted, record["msg"])
+	s.Equal("duplicate key value violates unique constraint: Key (value)=("+redacted+") already exists", record[ErrorKey])
+}
+
+func (s *loggingTestSuite) TestLevel() {
+	s.logger.Debug("hidden")
+	s.Empty(s.output.String())
+}
+
+func TestLoggingTestSuite(t *testing.T) {
+	suite.Run(t, new(loggingTestSuite))
+}
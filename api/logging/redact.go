@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are never logged.
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"password_hash": true,
+	"email":         true,
+	"emails":        true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"authorization": true,
+	"token":         true,
+	"captcha_token": true,
+	"solution":      true,
+}
+
+// emailPattern finds email addresses inside free text such as error messages
+// returned by the database.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// redact is used as slog.HandlerOptions.ReplaceAttr.
+func redact(_ []string, attr slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redacted)
+	}
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, redactText(attr.Value.String()))
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, redactText(err.Error()))
+		}
+	default:
+	}
+	return attr
+}
+
+func redactText(s string) string {
+	if !strings.Contains(s, "@") {
+		return s
+	}
+	return emailPattern.ReplaceAllString(s, redacted)
+}
@@ -0,0 +1,193 @@
+// Package mail composes and sends the emails of the auth server.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"time"
+)
+
+// Message is an email to a single recipient, in plain text and optionally
+// in HTML.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	// HTML is the alternative to Text for the clients that display it.
+	// Empty sends Text alone.
+	HTML string
+	Date time.Time
+}
+
+// WriteTo writes the message in RFC 5322 format, with the bodies quoted
+// printable so that long lines and non-ASCII text survive any relay.
+func (m *Message) WriteTo(w io.Writer) (int64, error) {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "From: %s\r\n", m.From)
+	fmt.Fprintf(&buffer, "To: %s\r\n", m.To)
+	fmt.Fprintf(&buffer, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buffer, "Date: %s\r\n", m.Date.Format(time.RFC1123Z))
+	buffer.WriteString("MIME-Version: 1.0\r\n")
+
+	if m.HTML == "" {
+		buffer.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buffer.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(&buffer, m.Text); err != nil {
+			return 0, err
+		}
+		return buffer.WriteTo(w)
+	}
+
+	// Clients display the last alternative they support, so HTML goes last
+	parts := multipart.NewWriter(&buffer)
+	fmt.Fprintf(&buffer, "Content-Type: %s\r\n\r\n", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": parts.Boundary()}))
+	for _, alternative := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alternative.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return 0, err
+		}
+		if err := writeQuotedPrintable(part, alternative.body); err != nil {
+			return 0, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return 0, err
+	}
+	return buffer.WriteTo(w)
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	encoder := quotedprintable.NewWriter(w)
+	if _, err := encoder.Write(bytes.ReplaceAll([]byte(body), []byte("\n"), []byte("\r\n"))); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// Sender delivers messages.
+type Sender interface {
+	Send(ctx context.Context, message *Message) error
+}
+
+// SMTPConfig holds the settings of an SMTP relay.
+type SMTPConfig struct {
+	// Address is the host and port of the relay, such as
+	// "smtp.example.com:587".
+	Address string
+	// Username and Password authenticate with PLAIN, which is only
+	// attempted over TLS or to localhost. An empty Username skips it.
+	Username string
+	Password string
+	// RequireTLS fails deliveries to relays that do not offer STARTTLS,
+	// instead of sending messages and credentials in the clear.
+	RequireTLS bool
+}
+
+// errNoTLS is returned by relays without STARTTLS when TLS is required.
+var errNoTLS = errors.New("SMTP relay does not offer STARTTLS")
+
+// SMTPSender sends messages through an SMTP relay, upgrading the connection
+// with STARTTLS whenever the relay offers it, and refusing to go on without
+// it when TLS is required.
+type SMTPSender struct {
+	config SMTPConfig
+	host   string
+}
+
+// NewSMTPSender creates a sender for the relay at config.Address.
+func NewSMTPSender(config SMTPConfig) (*SMTPSender, error) {
+	host, _, err := net.SplitHostPort(config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("SMTP address: %w", err)
+	}
+	return &SMTPSender{config, host}, nil
+}
+
+// Send delivers message within the deadline of ctx.
+func (s *SMTPSender) Send(ctx context.Context, message *Message) (err error) {
+	from, err := mail.ParseAddress(message.From)
+	if err != nil {
+		return fmt.Errorf("from: %w", err)
+	}
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return fmt.Errorf("to: %w", err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.config.Address)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			_ = conn.Close()
+			return err
+		}
+	}
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, ignoreClosed(client.Close()))
+	}()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	} else if s.config.RequireTLS {
+		return errNoTLS
+	}
+	if s.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := message.WriteTo(data); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// ignoreClosed drops the error of closing a connection already closed by
+// QUIT.
+func ignoreClosed(err error) error {
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
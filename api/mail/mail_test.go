@@ -0,0 +1,304 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+)
+
+type MailTestSuite struct {
+	suite.Suite
+}
+
+func (s *MailTestSuite) TestMessage() {
+	message := &Message{
+		From:    "My Registry <no-reply@mreg.io>",
+		To:      "user@example.com",
+		Subject: "Nouvelle connexion à votre compte",
+		Text:    "Bonjour,\n" + strings.Repeat("x", 100) + "\n",
+		Date:    time.Date(2026, time.October, 19, 14, 0, 0, 0, time.UTC),
+	}
+	var raw strings.Builder
+	_, err := message.WriteTo(&raw)
+	s.Require().NoError(err)
+
+	parsed, err := mail.ReadMessage(strings.NewReader(raw.String()))
+	s.Require().NoError(err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	s.Require().NoError(err)
+	s.Equal(message.Subject, subject)
+	date, err := parsed.Header.Date()
+	s.Require().NoError(err)
+	s.True(message.Date.Equal(date))
+
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	s.Require().NoError(err)
+	s.Equal("Bonjour,\r\n"+strings.Repeat("x", 100)+"\r\n", string(body))
+	for _, line := range strings.Split(raw.String(), "\r\n") {
+		s.LessOrEqual(len(line), 78)
+	}
+}
+
+func (s *MailTestSuite) TestNotifyNewDevice() {
+	sender := NewMemorySender()
+	notifier := NewNotifier(sender, DefaultTemplates(), "no-reply@mreg.io")
+	err := notifier.NotifyNewDevice(context.Background(), session.NewDeviceNotice{
+		Email:     "user@example.com",
+		Time:      time.Date(2026, time.October, 19, 14, 0, 0, 0, time.UTC),
+		Location:  "Taipei, Taipei City, TW",
+		Device:    "Firefox 131 on Linux",
+		RevokeURL: "https://auth.mreg.io/sessions/revoke?token=abc",
+	})
+	s.Require().NoError(err)
+
+	s.Require().Len(sender.Messages(), 1)
+	message := sender.Messages()[0]
+	s.Equal("user@example.com", message.To)
+	s.Equal("New sign-in to your account", message.Subject)
+	s.Contains(message.Text, "Monday, 19 October 2026 14:00 UTC")
+	s.Contains(message.Text, "Taipei, Taipei City, TW")
+	s.Contains(message.Text, "Firefox 131 on Linux")
+	s.Contains(message.Text, "https://auth.mreg.io/sessions/revoke?token=abc")
+
+	s.Require().NoError(notifier.NotifyNewDevice(context.Background(), session.NewDeviceNotice{Email: "user@example.com"}))
+	s.Contains(message.HTML, `href="https://auth.mreg.io/sessions/revoke?token=abc"`)
+	s.Contains(sender.Messages()[1].Text, "Location: Unknown")
+}
+
+func (s *MailTestSuite) TestNotifyNewDevice_Locale() {
+	sender := NewMemorySender()
+	notifier := NewNotifier(sender, DefaultTemplates(), "no-reply@mreg.io")
+	err := notifier.NotifyNewDevice(context.Background(), session.NewDeviceNotice{
+		Email:     "user@example.com",
+		Time:      time.Date(2026, time.October, 19, 14, 0, 0, 0, time.UTC),
+		Device:    "Firefox 131 on Linux",
+		RevokeURL: "https://auth.mreg.io/sessions/revoke?token=abc",
+		Locale:    "fr",
+	})
+	s.Require().NoError(err)
+
+	s.Require().Len(sender.Messages(), 1)
+	message := sender.Messages()[0]
+	s.Equal("Nouvelle connexion à votre compte", message.Subject)
+	s.Contains(message.Text, "19/10/2026 14:00 UTC")
+	s.Contains(message.Text, "Localisation : Inconnue")
+	s.Contains(message.Text, "https://auth.mreg.io/sessions/revoke?token=abc")
+}
+
+func (s *MailTestSuite) TestMessage_Alternative() {
+	message := &Message{
+		From:    "My Registry <no-reply@mreg.io>",
+		To:      "user@example.com",
+		Subject: "Hello",
+		Text:    "Hello\n",
+		HTML:    "<p>Hello</p>\n",
+		Date:    time.Now(),
+	}
+	var raw strings.Builder
+	_, err := message.WriteTo(&raw)
+	s.Require().NoError(err)
+
+	parsed, err := mail.ReadMessage(strings.NewReader(raw.String()))
+	s.Require().NoError(err)
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	s.Require().NoError(err)
+	s.Equal("multipart/alternative", mediaType)
+
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, expected := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", "Hello\r\n"},
+		{"text/html; charset=utf-8", "<p>Hello</p>\r\n"},
+	} {
+		part, err := parts.NextPart()
+		s.Require().NoError(err)
+		s.Equal(expected.contentType, part.Header.Get("Content-Type"))
+		// The reader decodes quoted-printable parts itself
+		body, err := io.ReadAll(part)
+		s.Require().NoError(err)
+		s.Equal(expected.body, string(body))
+	}
+	_, err = parts.NextPart()
+	s.ErrorIs(err, io.EOF)
+}
+
+func (s *MailTestSuite) TestTemplates() {
+	templates, err := ParseTemplates(fstest.MapFS{
+		"layouts/base.txt.tmpl":    {Data: []byte(`{{ define "layout" }}{{ template "content" . }}|{{ template "footer" . }}{{ end }}`)},
+		"layouts/base.html.tmpl":   {Data: []byte(`{{ define "layout" }}<html lang="{{ locale }}">{{ template "content" . }}</html>{{ end }}`)},
+		"en/_footer.txt.tmpl":      {Data: []byte(`{{ define "footer" }}Bye{{ end }}`)},
+		"en/hello.txt.tmpl":        {Data: []byte(`{{ define "subject" }} Hello {{ end }}{{ define "content" }}Hello {{ . }}{{ end }}`)},
+		"en/hello.html.tmpl":       {Data: []byte(`{{ define "content" }}<b>Hello {{ . }}</b>{{ end }}`)},
+		"fr/_footer.txt.tmpl":      {Data: []byte(`{{ define "footer" }}Au revoir{{ end }}`)},
+		"fr/hello.txt.tmpl":        {Data: []byte(`{{ define "subject" }}Bonjour{{ end }}{{ define "content" }}Bonjour {{ . }}{{ end }}`)},
+		"partials/unused.txt.tmpl": {Data: []byte(`{{ define "unused" }}{{ end }}`)},
+	})
+	s.Require().NoError(err)
+
+	content, err := templates.Render("hello", "en", "<Ana>")
+	s.Require().NoError(err)
+	s.Equal(&Content{Subject: "Hello", Text: "Hello <Ana>|Bye", HTML: `<html lang="en"><b>Hello &lt;Ana&gt;</b></html>`}, content)
+
+	// Regional locales fall back to their language, then to the default
+	content, err = templates.Render("hello", "fr-CA", "Ana")
+	s.Require().NoError(err)
+	s.Equal(&Content{Subject: "Bonjour", Text: "Bonjour Ana|Au revoir"}, content)
+	content, err = templates.Render("hello", "de", "Ana")
+	s.Require().NoError(err)
+	s.Equal("Hello", content.Subject)
+
+	_, err = templates.Render("goodbye", "en", nil)
+	s.Error(err)
+
+	// Every message needs a default
+	_, err = ParseTemplates(fstest.MapFS{
+		"layouts/base.txt.tmpl": {Data: []byte(`{{ define "layout" }}{{ end }}`)},
+		"fr/hello.txt.tmpl":     {Data: []byte(`{{ define "subject" }}{{ end }}`)},
+	})
+	s.ErrorContains(err, "fr: hello has no en template")
+}
+
+func (s *MailTestSuite) TestDefaultTemplates() {
+	notice := session.NewDeviceNotice{RevokeURL: "https://auth.mreg.io/sessions/revoke?token=a&b"}
+	for _, locale := range []string{"en", "fr"} {
+		content, err := DefaultTemplates().Render("new_device", locale, notice)
+		s.Require().NoError(err, locale)
+		s.NotEmpty(content.Subject, locale)
+		s.Contains(content.Text, notice.RevokeURL, locale)
+		s.Contains(content.HTML, `href="https://auth.mreg.io/sessions/revoke?token=a&amp;b"`, locale)
+		s.Contains(content.HTML, `lang="`+locale+`"`, locale)
+	}
+}
+
+func (s *MailTestSuite) TestFileSender() {
+	directory := filepath.Join(s.T().TempDir(), "mail")
+	sender, err := NewFileSender(directory)
+	s.Require().NoError(err)
+	s.Require().NoError(sender.Send(context.Background(), &Message{From: "no-reply@mreg.io", To: "user@example.com", Subject: "Hello", Text: "Hello\n"}))
+
+	files, err := filepath.Glob(filepath.Join(directory, "*.eml"))
+	s.Require().NoError(err)
+	s.Require().Len(files, 1)
+	file, err := os.Open(files[0])
+	s.Require().NoError(err)
+	defer file.Close()
+	parsed, err := mail.ReadMessage(file)
+	s.Require().NoError(err)
+	s.Equal("user@example.com", parsed.Header.Get("To"))
+}
+
+func (s *MailTestSuite) TestConsoleSender() {
+	var console strings.Builder
+	sender := NewConsoleSender(&console)
+	s.Require().NoError(sender.Send(context.Background(), &Message{From: "no-reply@mreg.io", To: "user@example.com", Subject: "Hello", Text: "Hello\n"}))
+
+	s.True(strings.HasPrefix(console.String(), "----- BEGIN MESSAGE -----\r\n"))
+	s.True(strings.HasSuffix(console.String(), "----- END MESSAGE -----\r\n"))
+	s.Contains(console.String(), "To: user@example.com\r\n")
+	s.Contains(console.String(), "Subject: Hello\r\n")
+}
+
+// serveSMTP answers one SMTP session without extensions and returns the
+// envelope and data received.
+func serveSMTP(listener net.Listener, received chan<- []string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		close(received)
+		return
+	}
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	var lines []string
+	_ = text.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			break
+		}
+		switch command := strings.ToUpper(strings.Fields(line)[0]); command {
+		case "EHLO", "HELO":
+			_ = text.PrintfLine("250 localhost")
+		case "MAIL", "RCPT":
+			lines = append(lines, line)
+			_ = text.PrintfLine("250 OK")
+		case "DATA":
+			_ = text.PrintfLine("354 Go ahead")
+			data, _ := io.ReadAll(bufio.NewReader(text.DotReader()))
+			lines = append(lines, string(data))
+			_ = text.PrintfLine("250 OK")
+		case "QUIT":
+			_ = text.PrintfLine("221 Bye")
+			received <- lines
+			return
+		default:
+			_ = text.PrintfLine("502 Not implemented")
+		}
+	}
+	received <- lines
+}
+
+func (s *MailTestSuite) TestSMTPSender() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer listener.Close()
+	received := make(chan []string, 1)
+	go serveSMTP(listener, received)
+
+	sender, err := NewSMTPSender(SMTPConfig{Address: listener.Addr().String()})
+	s.Require().NoError(err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = sender.Send(ctx, &Message{
+		From:    "My Registry <no-reply@mreg.io>",
+		To:      "user@example.com",
+		Subject: "Hello",
+		Text:    "Hello\n",
+		Date:    time.Now(),
+	})
+	s.Require().NoError(err)
+
+	lines := <-received
+	s.Require().Len(lines, 3)
+	s.Equal("MAIL FROM:<no-reply@mreg.io>", lines[0])
+	s.Equal("RCPT TO:<user@example.com>", lines[1])
+	s.Contains(lines[2], "Subject: Hello\n")
+
+	_, err = NewSMTPSender(SMTPConfig{Address: "localhost"})
+	s.Error(err)
+}
+
+func (s *MailTestSuite) TestSMTPSender_RequireTLS() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer listener.Close()
+	received := make(chan []string, 1)
+	go serveSMTP(listener, received)
+
+	sender, err := NewSMTPSender(SMTPConfig{Address: listener.Addr().String(), RequireTLS: true})
+	s.Require().NoError(err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = sender.Send(ctx, &Message{From: "no-reply@mreg.io", To: "user@example.com", Subject: "Hello", Date: time.Now()})
+	s.ErrorIs(err, errNoTLS)
+	// Nothing was sent in the clear
+	s.Empty(<-received)
+}
+
+func TestMailTestSuite(t *testing.T) {
+	suite.Run(t, new(MailTestSuite))
+}
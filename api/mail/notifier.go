@@ -0,0 +1,39 @@
+package mail
+
+import (
+	"context"
+
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+)
+
+// Notifier sends the notices of the session domain as emails.
+type Notifier struct {
+	sender    Sender
+	templates *Templates
+	from      string
+}
+
+// NewNotifier creates a notifier sending from the given address, such as
+// "My Registry <no-reply@mreg.io>".
+func NewNotifier(sender Sender, templates *Templates, from string) *Notifier {
+	return &Notifier{sender, templates, from}
+}
+
+func (n *Notifier) NotifyNewDevice(ctx context.Context, notice session.NewDeviceNotice) error {
+	locale := notice.Locale
+	if locale == "" {
+		locale = DefaultLocale
+	}
+	content, err := n.templates.Render("new_device", locale, notice)
+	if err != nil {
+		return err
+	}
+	return n.sender.Send(ctx, &Message{
+		From:    n.from,
+		To:      notice.Email,
+		Subject: content.Subject,
+		Text:    content.Text,
+		HTML:    content.HTML,
+		Date:    notice.Time,
+	})
+}
@@ -0,0 +1,107 @@
+package mail
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/domain/outbox"
+	"gitlab.mreg.io/my-registry/auth/logging"
+	"gitlab.mreg.io/my-registry/auth/poll"
+)
+
+// QueuedMessage is a message waiting in a Queue.
+type QueuedMessage struct {
+	Message
+	ID string
+	// Attempts counts the deliveries tried so far, including the current
+	// one.
+	Attempts  int
+	LastError string
+}
+
+// Queue keeps messages until they are sent, so that an outage of the relay
+// does not fail the requests sending mail.
+type Queue interface {
+	Enqueue(ctx context.Context, message *Message) error
+	// Claim returns up to limit messages due for delivery, counting an
+	// attempt for each, and keeps them from being claimed again for lease.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]QueuedMessage, error)
+	// Delete removes a sent message. Messages are not kept once sent, as
+	// they hold links that act on the account.
+	Delete(ctx context.Context, id string) error
+	// Retry schedules the next attempt of a failed message.
+	Retry(ctx context.Context, id string, next time.Time, lastError string) error
+	// MarkDead stops the attempts of a message. It is kept for an operator
+	// to inspect.
+	MarkDead(ctx context.Context, id string, lastError string) error
+}
+
+// QueueSender sends messages by queuing them for a Dispatcher.
+type QueueSender struct {
+	queue Queue
+}
+
+func NewQueueSender(queue Queue) *QueueSender {
+	return &QueueSender{queue}
+}
+
+func (s *QueueSender) Send(ctx context.Context, message *Message) error {
+	return s.queue.Enqueue(ctx, message)
+}
+
+// DispatcherConfig holds the settings of a Dispatcher.
+type DispatcherConfig struct {
+	BatchSize    int
+	PollInterval time.Duration
+	// Timeout bounds the delivery of each message.
+	Timeout time.Duration
+	// Lease keeps claimed messages from other dispatchers. It must outlast
+	// the delivery of a batch, or messages are sent twice.
+	Lease time.Duration
+	Retry outbox.RetryPolicy
+}
+
+// Dispatcher sends the messages of a queue.
+type Dispatcher struct {
+	queue  Queue
+	sender Sender
+	config DispatcherConfig
+	now    func() time.Time
+}
+
+func NewDispatcher(queue Queue, sender Sender, config DispatcherConfig) *Dispatcher {
+	return &Dispatcher{queue, sender, config, time.Now}
+}
+
+// Dispatch sends a batch of due messages and returns how many were claimed.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	return poll.Process(ctx, func(ctx context.Context) ([]QueuedMessage, error) {
+		return d.queue.Claim(ctx, d.config.BatchSize, d.config.Lease)
+	}, d.send)
+}
+
+func (d *Dispatcher) send(ctx context.Context, message *QueuedMessage) error {
+	sendCtx, cancel := context.WithTimeout(ctx, d.config.Timeout)
+	err := d.sender.Send(sendCtx, &message.Message)
+	cancel()
+	switch {
+	case err == nil:
+		return d.queue.Delete(ctx, message.ID)
+	case d.config.Retry.Exhausted(message.Attempts):
+		slog.ErrorContext(ctx, "giving up sending mail", slog.String("id", message.ID), logging.Error(err))
+		return d.queue.MarkDead(ctx, message.ID, err.Error())
+	default:
+		slog.WarnContext(ctx, "error sending mail", slog.String("id", message.ID), slog.Int("attempts", message.Attempts), logging.Error(err))
+		return d.queue.Retry(ctx, message.ID, d.now().Add(d.config.Retry.Delay(message.Attempts)), err.Error())
+	}
+}
+
+// Run dispatches until ctx is done, waiting for PollInterval whenever the
+// queue has no more due messages.
+func (d *Dispatcher) Run(ctx context.Context) {
+	poll.Run(ctx, "dispatching mail", d.config.PollInterval, func(ctx context.Context) (bool, error) {
+		claimed, err := d.Dispatch(ctx)
+		return claimed == d.config.BatchSize, err
+	})
+}
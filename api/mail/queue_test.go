@@ -0,0 +1,117 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/outbox"
+)
+
+type mockQueue struct {
+	mock.Mock
+}
+
+func (m *mockQueue) Enqueue(ctx context.Context, message *Message) error {
+	args := m.Called(ctx, message)
+	return args.Error(0)
+}
+
+func (m *mockQueue) Claim(ctx context.Context, limit int, lease time.Duration) ([]QueuedMessage, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]QueuedMessage), args.Error(1)
+}
+
+func (m *mockQueue) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockQueue) Retry(ctx context.Context, id string, next time.Time, lastError string) error {
+	args := m.Called(ctx, id, next, lastError)
+	return args.Error(0)
+}
+
+func (m *mockQueue) MarkDead(ctx context.Context, id string, lastError string) error {
+	args := m.Called(ctx, id, lastError)
+	return args.Error(0)
+}
+
+type failingSender struct {
+	err error
+}
+
+func (f failingSender) Send(context.Context, *Message) error {
+	return f.err
+}
+
+type QueueTestSuite struct {
+	suite.Suite
+	queue  *mockQueue
+	now    time.Time
+	config DispatcherConfig
+}
+
+func (s *QueueTestSuite) SetupTest() {
+	s.queue = new(mockQueue)
+	s.now = time.Date(2026, time.October, 19, 17, 0, 0, 0, time.UTC)
+	s.config = DispatcherConfig{
+		BatchSize:    10,
+		PollInterval: time.Second,
+		Timeout:      time.Second,
+		Lease:        time.Minute,
+		Retry:        outbox.RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Second, MaxDelay: time.Hour},
+	}
+}
+
+func (s *QueueTestSuite) dispatcher(sender Sender) *Dispatcher {
+	dispatcher := NewDispatcher(s.queue, sender, s.config)
+	dispatcher.now = func() time.Time { return s.now }
+	return dispatcher
+}
+
+func (s *QueueTestSuite) TestQueueSender() {
+	ctx := context.Background()
+	message := &Message{To: "user@example.com"}
+	s.queue.On("Enqueue", ctx, message).Return(nil).Once()
+	s.Require().NoError(NewQueueSender(s.queue).Send(ctx, message))
+	s.queue.AssertExpectations(s.T())
+}
+
+func (s *QueueTestSuite) TestDispatch_Sent() {
+	ctx := context.Background()
+	sender := NewMemorySender()
+	s.queue.On("Claim", ctx, 10, time.Minute).
+		Return([]QueuedMessage{{ID: "1", Message: Message{To: "user@example.com"}, Attempts: 1}}, nil).
+		Once()
+	s.queue.On("Delete", ctx, "1").Return(nil).Once()
+
+	claimed, err := s.dispatcher(sender).Dispatch(ctx)
+	s.Require().NoError(err)
+	s.Equal(1, claimed)
+	s.Require().Len(sender.Messages(), 1)
+	s.Equal("user@example.com", sender.Messages()[0].To)
+	s.queue.AssertExpectations(s.T())
+}
+
+func (s *QueueTestSuite) TestDispatch_Failed() {
+	ctx := context.Background()
+	sender := failingSender{errors.New("421 Service not available")}
+	s.queue.On("Claim", ctx, 10, time.Minute).
+		Return([]QueuedMessage{{ID: "1", Attempts: 2}, {ID: "2", Attempts: 3}}, nil).
+		Once()
+	s.queue.On("Retry", ctx, "1", s.now.Add(20*time.Second), "421 Service not available").Return(nil).Once()
+	s.queue.On("MarkDead", ctx, "2", "421 Service not available").Return(nil).Once()
+
+	_, err := s.dispatcher(sender).Dispatch(ctx)
+	s.Require().NoError(err)
+	s.queue.AssertExpectations(s.T())
+}
+
+func TestQueueTestSuite(t *testing.T) {
+	suite.Run(t, new(QueueTestSuite))
+}
@@ -0,0 +1,187 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmlTemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	textTemplate "text/template"
+)
+
+// DefaultLocale is used for the locales that have no templates.
+const DefaultLocale = "en"
+
+//go:embed all:templates
+var embeddedTemplates embed.FS
+
+// Templates renders messages in the locale of their recipient. They are
+// read from a file system laid out as
+//
+//	layouts/base.txt.tmpl, layouts/base.html.tmpl  wrap every message
+//	partials/*.txt.tmpl, partials/*.html.tmpl      shared by every locale
+//	<locale>/_*.txt.tmpl, <locale>/_*.html.tmpl    partials of a locale
+//	<locale>/<name>.txt.tmpl                       the text of a message
+//	<locale>/<name>.html.tmpl                      its optional HTML
+//
+// The layouts define "layout", which includes "content" and "footer". The
+// text of a message defines "subject" and "content", its HTML "content".
+type Templates struct {
+	locales map[string]map[string]*messageTemplate
+}
+
+type messageTemplate struct {
+	text *textTemplate.Template
+	html *htmlTemplate.Template
+}
+
+// Content is a rendered message.
+type Content struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Link is the argument of the "button" partial.
+type Link struct {
+	URL   string
+	Label string
+}
+
+// DefaultTemplates returns the templates built into the server.
+func DefaultTemplates() *Templates {
+	sub, err := fs.Sub(embeddedTemplates, "templates")
+	if err != nil {
+		panic(err)
+	}
+	templates, err := ParseTemplates(sub)
+	if err != nil {
+		panic(err)
+	}
+	return templates
+}
+
+// ParseTemplates parses every template of fsys. The DefaultLocale must have
+// a template of every message.
+func ParseTemplates(fsys fs.FS) (*Templates, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	t := &Templates{locales: make(map[string]map[string]*messageTemplate)}
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == "layouts" || entry.Name() == "partials" {
+			continue
+		}
+		locale := entry.Name()
+		if t.locales[locale], err = parseLocale(fsys, locale); err != nil {
+			return nil, fmt.Errorf("locale %s: %w", locale, err)
+		}
+	}
+
+	defaults := t.locales[DefaultLocale]
+	for locale, messages := range t.locales {
+		for name := range messages {
+			if defaults[name] == nil {
+				return nil, fmt.Errorf("locale %s: %s has no %s template", locale, name, DefaultLocale)
+			}
+		}
+	}
+	return t, nil
+}
+
+func parseLocale(fsys fs.FS, locale string) (map[string]*messageTemplate, error) {
+	names, err := fs.Glob(fsys, path.Join(locale, "*.txt.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	funcs := map[string]any{
+		"locale": func() string { return locale },
+		"link":   func(url string, label string) Link { return Link{url, label} },
+	}
+
+	messages := make(map[string]*messageTemplate)
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".txt.tmpl")
+		if strings.HasPrefix(base, "_") {
+			continue
+		}
+		files, err := templateFiles(fsys, locale, base, "txt")
+		if err != nil {
+			return nil, err
+		}
+		text, err := textTemplate.New(base).Funcs(funcs).ParseFS(fsys, files...)
+		if err != nil {
+			return nil, err
+		}
+		message := &messageTemplate{text: text}
+
+		if _, err := fs.Stat(fsys, path.Join(locale, base+".html.tmpl")); err == nil {
+			if files, err = templateFiles(fsys, locale, base, "html"); err != nil {
+				return nil, err
+			}
+			if message.html, err = htmlTemplate.New(base).Funcs(funcs).ParseFS(fsys, files...); err != nil {
+				return nil, err
+			}
+		}
+		messages[base] = message
+	}
+	return messages, nil
+}
+
+// templateFiles lists the layouts, partials and message files of a message
+// in the given format.
+func templateFiles(fsys fs.FS, locale string, name string, format string) ([]string, error) {
+	var files []string
+	for _, pattern := range []string{
+		"layouts/*." + format + ".tmpl",
+		"partials/*." + format + ".tmpl",
+		path.Join(locale, "_*."+format+".tmpl"),
+	} {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return append(files, path.Join(locale, name+"."+format+".tmpl")), nil
+}
+
+// Render renders the message name with data. A locale without the message,
+// such as "fr-CA", falls back to its language, "fr", and then to the
+// DefaultLocale.
+func (t *Templates) Render(name string, locale string, data any) (*Content, error) {
+	message := t.lookup(name, locale)
+	if message == nil {
+		return nil, fmt.Errorf("no template for message %s", name)
+	}
+
+	var subject, text bytes.Buffer
+	if err := message.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := message.text.ExecuteTemplate(&text, "layout", data); err != nil {
+		return nil, err
+	}
+	content := &Content{Subject: strings.TrimSpace(subject.String()), Text: text.String()}
+	if message.html != nil {
+		var html bytes.Buffer
+		if err := message.html.ExecuteTemplate(&html, "layout", data); err != nil {
+			return nil, err
+		}
+		content.HTML = html.String()
+	}
+	return content, nil
+}
+
+func (t *Templates) lookup(name string, locale string) *messageTemplate {
+	language, _, _ := strings.Cut(locale, "-")
+	for _, candidate := range []string{locale, strings.ToLower(language), DefaultLocale} {
+		if message := t.locales[candidate][name]; message != nil {
+			return message
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FileSender writes every message as an .eml file to a directory instead of
+// sending it, for development. The files open in any mail client.
+type FileSender struct {
+	directory string
+}
+
+// NewFileSender creates a sender writing to directory, which is created if
+// missing.
+func NewFileSender(directory string) (*FileSender, error) {
+	if err := os.MkdirAll(directory, 0o750); err != nil {
+		return nil, err
+	}
+	return &FileSender{directory}, nil
+}
+
+// Send writes message to a new file named after the current time, so that
+// the files sort in the order they were sent.
+func (s *FileSender) Send(_ context.Context, message *Message) (err error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + "-" + hex.EncodeToString(suffix) + ".eml"
+	file, err := os.OpenFile(filepath.Join(s.directory, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	_, err = message.WriteTo(file)
+	return err
+}
+
+// ConsoleSender writes every message to a writer instead of sending it, for
+// development.
+type ConsoleSender struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+// NewConsoleSender creates a sender writing to writer, usually the standard
+// error of the server.
+func NewConsoleSender(writer io.Writer) *ConsoleSender {
+	return &ConsoleSender{writer: writer}
+}
+
+// Send writes message between separator lines, so that concurrent messages
+// do not interleave with each other.
+func (s *ConsoleSender) Send(_ context.Context, message *Message) error {
+	var buffer bytes.Buffer
+	buffer.WriteString("----- BEGIN MESSAGE -----\r\n")
+	if _, err := message.WriteTo(&buffer); err != nil {
+		return err
+	}
+	buffer.WriteString("\r\n----- END MESSAGE -----\r\n")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := buffer.WriteTo(s.writer)
+	return err
+}
+
+// MemorySender keeps the messages it is given, for tests.
+type MemorySender struct {
+	mu       sync.Mutex
+	messages []*Message
+}
+
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+func (s *MemorySender) Send(_ context.Context, message *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (s *MemorySender) Messages() []*Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Message(nil), s.messages...)
+}
@@ -0,0 +1,173 @@
+// Package metrics exposes the auth server metrics to Prometheus.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+)
+
+const namespace = "auth"
+
+// Metrics holds the collectors of the server. It uses its own registry so
+// that tests do not share state through the global one.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	rpcDuration            *prometheus.HistogramVec
+	queryDuration          *prometheus.HistogramVec
+	hashDuration           *prometheus.HistogramVec
+	registrationsStarted   prometheus.Counter
+	registrationsCompleted prometheus.Counter
+	sessionsIssued         *prometheus.CounterVec
+}
+
+// New creates the collectors, including the Go runtime and process ones.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		rpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "rpc",
+			Name:      "duration_seconds",
+			Help:      "Duration of handled connect RPCs.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"procedure", "code"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "repository",
+			Name:      "query_duration_seconds",
+			Help:      "Duration of repository method calls.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"repository", "method", "outcome"}),
+		hashDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "password_hasher",
+			Name:      "duration_seconds",
+			Help:      "Time spent computing argon2id hashes, excluding queueing.",
+			Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation"}),
+		registrationsStarted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "registrations_started_total",
+			Help:      "Registration flows created.",
+		}),
+		registrationsCompleted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "registrations_completed_total",
+			Help:      "Identities created by completed registration flows.",
+		}),
+		sessionsIssued: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sessions_issued_total",
+			Help:      "Sessions created, by authenticator assurance level.",
+		}, []string{"aal"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.rpcDuration,
+		m.queryDuration,
+		m.hashDuration,
+		m.registrationsStarted,
+		m.registrationsCompleted,
+		m.sessionsIssued,
+	)
+	return m
+}
+
+// MustRegister adds collectors owned by other packages, such as the pgx
+// pool statistics.
+func (m *Metrics) MustRegister(collectors ...prometheus.Collector) {
+	m.registry.MustRegister(collectors...)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveRPC records a handled RPC. code is the connect code, or "ok".
+func (m *Metrics) ObserveRPC(procedure string, code string, duration time.Duration) {
+	m.rpcDuration.WithLabelValues(procedure, code).Observe(duration.Seconds())
+}
+
+// ObserveQuery records a repository method call.
+func (m *Metrics) ObserveQuery(repository string, method string, err error, duration time.Duration) {
+	m.queryDuration.WithLabelValues(repository, method, outcome(err)).Observe(duration.Seconds())
+}
+
+// ObserveHash is meant to be used as identity.HasherConfig.Observe.
+func (m *Metrics) ObserveHash(operation identity.HashOperation, duration time.Duration) {
+	m.hashDuration.WithLabelValues(string(operation)).Observe(duration.Seconds())
+}
+
+// RegistrationStarted counts a registration flow created. Metrics is a
+// registration.Observer.
+func (m *Metrics) RegistrationStarted() {
+	m.registrationsStarted.Inc()
+}
+
+// RegistrationCompleted counts an identity created by a registration flow.
+func (m *Metrics) RegistrationCompleted() {
+	m.registrationsCompleted.Inc()
+}
+
+// SessionIssued counts a session created at the given authenticator
+// assurance level.
+func (m *Metrics) SessionIssued(authenticatorAssuranceLevel uint8) {
+	m.sessionsIssued.WithLabelValues(aal(authenticatorAssuranceLevel)).Inc()
+}
+
+// RegisterHasher exposes the queue of a hasher as gauges.
+func (m *Metrics) RegisterHasher(hasher *identity.Hasher) {
+	gauge := func(name string, help string, value func(identity.HasherStats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "password_hasher",
+			Name:      name,
+			Help:      help,
+		}, func() float64 {
+			return value(hasher.Stats())
+		})
+	}
+	counter := func(name string, help string, value func(identity.HasherStats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "password_hasher",
+			Name:      name,
+			Help:      help,
+		}, func() float64 {
+			return value(hasher.Stats())
+		})
+	}
+	m.registry.MustRegister(
+		gauge("workers", "Hash operations allowed to run at once.",
+			func(s identity.HasherStats) float64 { return float64(s.Workers) }),
+		gauge("queue_capacity", "Hash operations allowed to wait for a worker.",
+			func(s identity.HasherStats) float64 { return float64(s.QueueDepth) }),
+		gauge("queued", "Hash operations waiting for a worker.",
+			func(s identity.HasherStats) float64 { return float64(s.Queued) }),
+		gauge("in_flight", "Hash operations being computed.",
+			func(s identity.HasherStats) float64 { return float64(s.InFlight) }),
+		counter("rejected_total", "Hash operations refused because the queue was full.",
+			func(s identity.HasherStats) float64 { return float64(s.Rejected) }),
+	)
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+func aal(level uint8) string {
+	return strconv.Itoa(int(level))
+}
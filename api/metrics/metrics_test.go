@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/registration"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+)
+
+type mockSessionRepository struct {
+	mock.Mock
+}
+
+func (m *mockSessionRepository) CreateSession(ctx context.Context, session *session.Session) error {
+	return m.Called(ctx, session).Error(0)
+}
+
+func (m *mockSessionRepository) QuerySessionByID(ctx context.Context, session *session.Session) error {
+	return m.Called(ctx, session).Error(0)
+}
+
+func (m *mockSessionRepository) QuerySessionWithDevices(ctx context.Context, session *session.Session) error {
+	return m.Called(ctx, session).Error(0)
+}
+
+func (m *mockSessionRepository) InsertDevice(ctx context.Context, newDevice *session.Device) error {
+	return m.Called(ctx, newDevice).Error(0)
+}
+
+func (m *mockSessionRepository) DeleteSession(ctx context.Context, sessionID string) error {
+	return m.Called(ctx, sessionID).Error(0)
+}
+
+func (m *mockSessionRepository) RememberDevice(ctx context.Context, identityID string, device *session.Device) (bool, error) {
+	args := m.Called(ctx, identityID, device)
+	return args.Bool(0), args.Error(1)
+}
+
+type mockFlowRepository struct {
+	registration.Repository
+	mock.Mock
+}
+
+func (m *mockFlowRepository) CreateFlow(ctx context.Context, flow *registration.Flow) error {
+	return m.Called(ctx, flow).Error(0)
+}
+
+type metricsTestSuite struct {
+	suite.Suite
+	metrics *Metrics
+}
+
+func (s *metricsTestSuite) SetupTest() {
+	s.metrics = New()
+}
+
+func (s *metricsTestSuite) scrape() string {
+	recorder := httptest.NewRecorder()
+	s.metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(recorder.Body)
+	s.Require().NoError(err)
+	return string(body)
+}
+
+func (s *metricsTestSuite) TestSessionRepository() {
+	ctx := context.Background()
+	next := new(mockSessionRepository)
+	repository := s.metrics.SessionRepository(next)
+
+	next.On("CreateSession", ctx, mock.Anything).Return(nil).Twice()
+	s.Require().NoError(repository.CreateSession(ctx, &session.Session{AuthenticatorAssuranceLevel: 0}))
+	s.Require().NoError(repository.CreateSession(ctx, &session.Session{AuthenticatorAssuranceLevel: 1}))
+	next.On("QuerySessionByID", ctx, mock.Anything).Return(errors.New("no rows")).Once()
+	s.Require().Error(repository.QuerySessionByID(ctx, &session.Session{}))
+
+	// Sessions are counted by the services, once committed
+	s.Zero(testutil.ToFloat64(s.metrics.sessionsIssued.WithLabelValues("0")))
+	body := s.scrape()
+	s.Contains(body, `auth_repository_query_duration_seconds_count{method="CreateSession",outcome="success",repository="session"} 2`)
+	s.Contains(body, `auth_repository_query_duration_seconds_count{method="QuerySessionByID",outcome="error",repository="session"} 1`)
+	next.AssertExpectations(s.T())
+}
+
+func (s *metricsTestSuite) TestRegistrationCounters() {
+	s.metrics.RegistrationStarted()
+	s.metrics.SessionIssued(0)
+	s.metrics.RegistrationStarted()
+	s.metrics.SessionIssued(0)
+	s.metrics.RegistrationCompleted()
+	s.metrics.SessionIssued(1)
+
+	s.InDelta(2, testutil.ToFloat64(s.metrics.registrationsStarted), 0)
+	s.InDelta(1, testutil.ToFloat64(s.metrics.registrationsCompleted), 0)
+	s.InDelta(2, testutil.ToFloat64(s.metrics.sessionsIssued.WithLabelValues("0")), 0)
+	s.InDelta(1, testutil.ToFloat64(s.metrics.sessionsIssued.WithLabelValues("1")), 0)
+	s.Contains(s.scrape(), `auth_sessions_issued_total{aal="1"} 1`)
+}
+
+func (s *metricsTestSuite) TestHasher() {
+	hasher := identity.NewHasher(identity.HasherConfig{
+		Params:       &identity.Params{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		MemoryBudget: 16 * 1024,
+		QueueDepth:   1,
+		Observe:      s.metrics.ObserveHash,
+	})
+	defer hasher.Close()
+	s.metrics.RegisterHasher(hasher)
+
+	_, err := hasher.CreateHash(context.Background(), "pa$$word")
+	s.Require().NoError(err)
+	s.Equal(1, testutil.CollectAndCount(s.metrics.hashDuration, "auth_password_hasher_duration_seconds"))
+
+	expected := "# HELP auth_password_hasher_workers Hash operations allowed to run at once.\n" +
+		"# TYPE auth_password_hasher_workers gauge\n" +
+		"auth_password_hasher_workers 2\n"
+	s.NoError(testutil.GatherAndCompare(s.metrics.registry, strings.NewReader(expected), "auth_password_hasher_workers"))
+}
+
+func (s *metricsTestSuite) TestHandler() {
+	s.metrics.ObserveRPC("/test.v1.TestService/Ping", "ok", time.Millisecond)
+
+	body := s.scrape()
+	s.Contains(body, `auth_rpc_duration_seconds_count{code="ok",procedure="/test.v1.TestService/Ping"} 1`)
+	s.Contains(body, "go_goroutines")
+}
+
+func TestMetricsTestSuite(t *testing.T) {
+	suite.Run(t, new(metricsTestSuite))
+}
@@ -0,0 +1,301 @@
+package metrics
+
+import (
+	"context"
+	"net/netip"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/domain/audit"
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/lockout"
+	"gitlab.mreg.io/my-registry/auth/domain/outbox"
+	"gitlab.mreg.io/my-registry/auth/domain/ratelimit"
+	"gitlab.mreg.io/my-registry/auth/domain/registration"
+	"gitlab.mreg.io/my-registry/auth/domain/retention"
+	"gitlab.mreg.io/my-registry/auth/domain/risk"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/mail"
+)
+
+// The decorators below time every repository method. The business
+// counters are incremented by the services, once the transactions the
+// writes belong to are committed.
+
+func (m *Metrics) since(repository string, method string, start time.Time, err error) {
+	m.ObserveQuery(repository, method, err, time.Since(start))
+}
+
+type sessionRepository struct {
+	next    session.Repository
+	metrics *Metrics
+}
+
+// SessionRepository instruments a session.Repository.
+func (m *Metrics) SessionRepository(next session.Repository) session.Repository {
+	return &sessionRepository{next, m}
+}
+
+func (r *sessionRepository) CreateSession(ctx context.Context, s *session.Session) (err error) {
+	defer func(start time.Time) { r.metrics.since("session", "CreateSession", start, err) }(time.Now())
+	return r.next.CreateSession(ctx, s)
+}
+
+func (r *sessionRepository) DeleteSession(ctx context.Context, sessionID string) (err error) {
+	defer func(start time.Time) { r.metrics.since("session", "DeleteSession", start, err) }(time.Now())
+	return r.next.DeleteSession(ctx, sessionID)
+}
+
+func (r *sessionRepository) QuerySessionByID(ctx context.Context, s *session.Session) (err error) {
+	defer func(start time.Time) { r.metrics.since("session", "QuerySessionByID", start, err) }(time.Now())
+	return r.next.QuerySessionByID(ctx, s)
+}
+
+func (r *sessionRepository) QuerySessionWithDevices(ctx context.Context, s *session.Session) (err error) {
+	defer func(start time.Time) { r.metrics.since("session", "QuerySessionWithDevices", start, err) }(time.Now())
+	return r.next.QuerySessionWithDevices(ctx, s)
+}
+
+func (r *sessionRepository) InsertDevice(ctx context.Context, device *session.Device) (err error) {
+	defer func(start time.Time) { r.metrics.since("session", "InsertDevice", start, err) }(time.Now())
+	return r.next.InsertDevice(ctx, device)
+}
+
+func (r *sessionRepository) RememberDevice(ctx context.Context, identityID string, device *session.Device) (isNew bool, err error) {
+	defer func(start time.Time) { r.metrics.since("session", "RememberDevice", start, err) }(time.Now())
+	return r.next.RememberDevice(ctx, identityID, device)
+}
+
+type registrationRepository struct {
+	next    registration.Repository
+	metrics *Metrics
+}
+
+// RegistrationRepository instruments a registration.Repository.
+func (m *Metrics) RegistrationRepository(next registration.Repository) registration.Repository {
+	return &registrationRepository{next, m}
+}
+
+func (r *registrationRepository) CreateFlow(ctx context.Context, flow *registration.Flow) (err error) {
+	defer func(start time.Time) { r.metrics.since("registration", "CreateFlow", start, err) }(time.Now())
+	return r.next.CreateFlow(ctx, flow)
+}
+
+func (r *registrationRepository) QueryFlowByFlowID(ctx context.Context, flow *registration.Flow) (err error) {
+	defer func(start time.Time) { r.metrics.since("registration", "QueryFlowByFlowID", start, err) }(time.Now())
+	return r.next.QueryFlowByFlowID(ctx, flow)
+}
+
+func (r *registrationRepository) CountRecentFlows(ctx context.Context, network netip.Prefix, since time.Time) (count int, err error) {
+	defer func(start time.Time) { r.metrics.since("registration", "CountRecentFlows", start, err) }(time.Now())
+	return r.next.CountRecentFlows(ctx, network, since)
+}
+
+type identityRepository struct {
+	next    identity.Repository
+	metrics *Metrics
+}
+
+// IdentityRepository instruments an identity.Repository.
+func (m *Metrics) IdentityRepository(next identity.Repository) identity.Repository {
+	return &identityRepository{next, m}
+}
+
+func (r *identityRepository) CreateIdentity(ctx context.Context, i *identity.Identity) (err error) {
+	defer func(start time.Time) { r.metrics.since("identity", "CreateIdentity", start, err) }(time.Now())
+	return r.next.CreateIdentity(ctx, i)
+}
+
+func (r *identityRepository) QueryEmail(ctx context.Context, email *identity.Email) (err error) {
+	defer func(start time.Time) { r.metrics.since("identity", "QueryEmail", start, err) }(time.Now())
+	return r.next.QueryEmail(ctx, email)
+}
+
+func (r *identityRepository) EmailExists(ctx context.Context, email string) (exists bool, err error) {
+	defer func(start time.Time) { r.metrics.since("identity", "EmailExists", start, err) }(time.Now())
+	return r.next.EmailExists(ctx, email)
+}
+
+func (r *identityRepository) QueryEmails(ctx context.Context, i *identity.Identity) (err error) {
+	defer func(start time.Time) { r.metrics.since("identity", "QueryEmails", start, err) }(time.Now())
+	return r.next.QueryEmails(ctx, i)
+}
+
+type riskRepository struct {
+	next    risk.Repository
+	metrics *Metrics
+}
+
+// RiskRepository instruments a risk.Repository.
+func (m *Metrics) RiskRepository(next risk.Repository) risk.Repository {
+	return &riskRepository{next, m}
+}
+
+func (r *riskRepository) QueryHistory(ctx context.Context, identityID string, location session.Location) (history risk.History, err error) {
+	defer func(start time.Time) { r.metrics.since("risk", "QueryHistory", start, err) }(time.Now())
+	return r.next.QueryHistory(ctx, identityID, location)
+}
+
+type rateLimitRepository struct {
+	next    ratelimit.Repository
+	metrics *Metrics
+}
+
+// RateLimitRepository instruments a ratelimit.Repository.
+func (m *Metrics) RateLimitRepository(next ratelimit.Repository) ratelimit.Repository {
+	return &rateLimitRepository{next, m}
+}
+
+func (r *rateLimitRepository) Take(ctx context.Context, key string, limit ratelimit.Limit) (result ratelimit.Result, err error) {
+	defer func(start time.Time) { r.metrics.since("ratelimit", "Take", start, err) }(time.Now())
+	return r.next.Take(ctx, key, limit)
+}
+
+func (r *rateLimitRepository) Refund(ctx context.Context, key string, limit ratelimit.Limit) (err error) {
+	defer func(start time.Time) { r.metrics.since("ratelimit", "Refund", start, err) }(time.Now())
+	return r.next.Refund(ctx, key, limit)
+}
+
+type lockoutRepository struct {
+	next    lockout.Repository
+	metrics *Metrics
+}
+
+// LockoutRepository instruments a lockout.Repository.
+func (m *Metrics) LockoutRepository(next lockout.Repository) lockout.Repository {
+	return &lockoutRepository{next, m}
+}
+
+func (r *lockoutRepository) QueryState(ctx context.Context, key string) (state lockout.State, err error) {
+	defer func(start time.Time) { r.metrics.since("lockout", "QueryState", start, err) }(time.Now())
+	return r.next.QueryState(ctx, key)
+}
+
+func (r *lockoutRepository) IncrementFailures(ctx context.Context, key string, window time.Duration) (state lockout.State, err error) {
+	defer func(start time.Time) { r.metrics.since("lockout", "IncrementFailures", start, err) }(time.Now())
+	return r.next.IncrementFailures(ctx, key, window)
+}
+
+func (r *lockoutRepository) Lock(ctx context.Context, key string, until time.Time) (err error) {
+	defer func(start time.Time) { r.metrics.since("lockout", "Lock", start, err) }(time.Now())
+	return r.next.Lock(ctx, key, until)
+}
+
+func (r *lockoutRepository) ResetFailures(ctx context.Context, key string) (err error) {
+	defer func(start time.Time) { r.metrics.since("lockout", "ResetFailures", start, err) }(time.Now())
+	return r.next.ResetFailures(ctx, key)
+}
+
+func (r *lockoutRepository) CreateEvent(ctx context.Context, event *lockout.Event) (err error) {
+	defer func(start time.Time) { r.metrics.since("lockout", "CreateEvent", start, err) }(time.Now())
+	return r.next.CreateEvent(ctx, event)
+}
+
+func (r *lockoutRepository) ListEvents(ctx context.Context, identityID string, limit int) (events []lockout.Event, err error) {
+	defer func(start time.Time) { r.metrics.since("lockout", "ListEvents", start, err) }(time.Now())
+	return r.next.ListEvents(ctx, identityID, limit)
+}
+
+type auditRepository struct {
+	next    audit.Repository
+	metrics *Metrics
+}
+
+// AuditRepository instruments an audit.Repository.
+func (m *Metrics) AuditRepository(next audit.Repository) audit.Repository {
+	return &auditRepository{next, m}
+}
+
+func (r *auditRepository) CreateEvent(ctx context.Context, event *audit.Event) (err error) {
+	defer func(start time.Time) { r.metrics.since("audit", "CreateEvent", start, err) }(time.Now())
+	return r.next.CreateEvent(ctx, event)
+}
+
+func (r *auditRepository) QueryEvents(ctx context.Context, identityID string, pageSize int, pageToken string) (events []audit.Event, nextPageToken string, err error) {
+	defer func(start time.Time) { r.metrics.since("audit", "QueryEvents", start, err) }(time.Now())
+	return r.next.QueryEvents(ctx, identityID, pageSize, pageToken)
+}
+
+type outboxRepository struct {
+	next    outbox.Repository
+	metrics *Metrics
+}
+
+// OutboxRepository instruments an outbox.Repository.
+func (m *Metrics) OutboxRepository(next outbox.Repository) outbox.Repository {
+	return &outboxRepository{next, m}
+}
+
+func (r *outboxRepository) ClaimMessages(ctx context.Context, limit int, lease time.Duration) (messages []outbox.Message, err error) {
+	defer func(start time.Time) { r.metrics.since("outbox", "ClaimMessages", start, err) }(time.Now())
+	return r.next.ClaimMessages(ctx, limit, lease)
+}
+
+func (r *outboxRepository) UpdateDelivery(ctx context.Context, message *outbox.Message) (err error) {
+	defer func(start time.Time) { r.metrics.since("outbox", "UpdateDelivery", start, err) }(time.Now())
+	return r.next.UpdateDelivery(ctx, message)
+}
+
+type retentionRepository struct {
+	next    retention.Repository
+	metrics *Metrics
+}
+
+// RetentionRepository instruments a retention.Repository.
+func (m *Metrics) RetentionRepository(next retention.Repository) retention.Repository {
+	return &retentionRepository{next, m}
+}
+
+func (r *retentionRepository) AcquireLease(ctx context.Context, name string, holder string, duration time.Duration) (held bool, err error) {
+	defer func(start time.Time) { r.metrics.since("retention", "AcquireLease", start, err) }(time.Now())
+	return r.next.AcquireLease(ctx, name, holder, duration)
+}
+
+func (r *retentionRepository) DeleteExpiredFlows(ctx context.Context, before time.Time, limit int) (deleted int, err error) {
+	defer func(start time.Time) { r.metrics.since("retention", "DeleteExpiredFlows", start, err) }(time.Now())
+	return r.next.DeleteExpiredFlows(ctx, before, limit)
+}
+
+func (r *retentionRepository) DeleteExpiredSessions(ctx context.Context, before time.Time, historyBefore time.Time, limit int) (deleted int, err error) {
+	defer func(start time.Time) { r.metrics.since("retention", "DeleteExpiredSessions", start, err) }(time.Now())
+	return r.next.DeleteExpiredSessions(ctx, before, historyBefore, limit)
+}
+
+func (r *retentionRepository) DeleteFinishedMessages(ctx context.Context, before time.Time, limit int) (deleted int, err error) {
+	defer func(start time.Time) { r.metrics.since("retention", "DeleteFinishedMessages", start, err) }(time.Now())
+	return r.next.DeleteFinishedMessages(ctx, before, limit)
+}
+
+type mailQueue struct {
+	next    mail.Queue
+	metrics *Metrics
+}
+
+// MailQueue instruments a mail.Queue.
+func (m *Metrics) MailQueue(next mail.Queue) mail.Queue {
+	return &mailQueue{next, m}
+}
+
+func (q *mailQueue) Enqueue(ctx context.Context, message *mail.Message) (err error) {
+	defer func(start time.Time) { q.metrics.since("mail", "Enqueue", start, err) }(time.Now())
+	return q.next.Enqueue(ctx, message)
+}
+
+func (q *mailQueue) Claim(ctx context.Context, limit int, lease time.Duration) (messages []mail.QueuedMessage, err error) {
+	defer func(start time.Time) { q.metrics.since("mail", "Claim", start, err) }(time.Now())
+	return q.next.Claim(ctx, limit, lease)
+}
+
+func (q *mailQueue) Delete(ctx context.Context, id string) (err error) {
+	defer func(start time.Time) { q.metrics.since("mail", "Delete", start, err) }(time.Now())
+	return q.next.Delete(ctx, id)
+}
+
+func (q *mailQueue) Retry(ctx context.Context, id string, next time.Time, lastError string) (err error) {
+	defer func(start time.Time) { q.metrics.since("mail", "Retry", start, err) }(time.Now())
+	return q.next.Retry(ctx, id, next, lastError)
+}
+
+func (q *mailQueue) MarkDead(ctx context.Context, id string, lastError string) (err error) {
+	defer func(start time.Time) { q.metrics.since("mail", "MarkDead", start, err) }(time.Now())
+	return q.next.MarkDead(ctx, id, lastError)
+}
@@ -0,0 +1,53 @@
+// Package poll runs the background workers that go through the database in
+// batches: the mail queue, the outbox and the reaper.
+package poll
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/logging"
+)
+
+// Process claims a batch of items and handles them in order, stopping at
+// the first error. It returns how many items were claimed.
+func Process[T any](ctx context.Context, claim func(ctx context.Context) ([]T, error), handle func(ctx context.Context, item *T) error) (int, error) {
+	items, err := claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i := range items {
+		if err := handle(ctx, &items[i]); err != nil {
+			return len(items), err
+		}
+	}
+	return len(items), nil
+}
+
+// Run calls batch until ctx is done. A full batch suggests more work is
+// due, so the next one starts right away; otherwise Run waits for interval.
+// Errors are logged as "error <doing>".
+func Run(ctx context.Context, doing string, interval time.Duration, batch func(ctx context.Context) (full bool, err error)) {
+	for {
+		full, err := batch(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "error "+doing, logging.Error(err))
+		}
+		if err == nil && full {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Lease returns how long claimed items have to be kept from other workers
+// when each of count attempts may take up to timeout: long enough for all of
+// them to time out, and one more timeout for the rest of the batch.
+func Lease(timeout time.Duration, count int) time.Duration {
+	return timeout * time.Duration(count+1)
+}
@@ -0,0 +1,64 @@
+package poll
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcess(t *testing.T) {
+	ctx := context.Background()
+	var handled []int
+	claimed, err := Process(ctx,
+		func(context.Context) ([]int, error) { return []int{1, 2, 3}, nil },
+		func(_ context.Context, item *int) error {
+			handled = append(handled, *item)
+			if *item == 2 {
+				return errors.New("failed")
+			}
+			return nil
+		})
+	require.Error(t, err)
+	assert.Equal(t, 3, claimed)
+	assert.Equal(t, []int{1, 2}, handled)
+
+	claimed, err = Process(ctx,
+		func(context.Context) ([]int, error) { return nil, errors.New("unavailable") },
+		func(context.Context, *int) error { return nil })
+	require.Error(t, err)
+	assert.Zero(t, claimed)
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var batches int
+	done := make(chan struct{})
+	go func() {
+		// Full batches follow each other without waiting for the hour
+		Run(ctx, "testing", time.Hour, func(context.Context) (bool, error) {
+			batches++
+			if batches == 3 {
+				cancel()
+				return false, nil
+			}
+			return true, nil
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return once ctx was done")
+	}
+	assert.Equal(t, 3, batches)
+}
+
+func TestLease(t *testing.T) {
+	assert.Equal(t, 11*time.Second, Lease(time.Second, 10))
+}
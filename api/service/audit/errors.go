@@ -0,0 +1,5 @@
+package audit
+
+import "errors"
+
+var ErrInvalidPageSize = errors.New("page size must not be negative")
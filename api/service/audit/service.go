@@ -0,0 +1,41 @@
+// Package audit lets administrators review the audit log of an identity.
+package audit
+
+import (
+	"context"
+
+	"gitlab.mreg.io/my-registry/auth/domain/audit"
+)
+
+const (
+	// DefaultPageSize is used when the page size is not set.
+	DefaultPageSize = 50
+	// MaxPageSize bounds larger page sizes.
+	MaxPageSize = 500
+)
+
+type Service interface {
+	// ListEvents returns a page of the events of identityID, newest first,
+	// and the token of the next page, which is empty on the last page.
+	ListEvents(ctx context.Context, identityID string, pageSize int, pageToken string) ([]audit.Event, string, error)
+}
+
+type service struct {
+	repository audit.Repository
+}
+
+func NewService(repository audit.Repository) Service {
+	return &service{repository}
+}
+
+func (s *service) ListEvents(ctx context.Context, identityID string, pageSize int, pageToken string) ([]audit.Event, string, error) {
+	switch {
+	case pageSize < 0:
+		return nil, "", ErrInvalidPageSize
+	case pageSize == 0:
+		pageSize = DefaultPageSize
+	case pageSize > MaxPageSize:
+		pageSize = MaxPageSize
+	}
+	return s.repository.QueryEvents(ctx, identityID, pageSize, pageToken)
+}
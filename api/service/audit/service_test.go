@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/audit"
+)
+
+type mockAuditRepository struct {
+	audit.Repository
+	mock.Mock
+}
+
+func (m *mockAuditRepository) QueryEvents(ctx context.Context, identityID string, pageSize int, pageToken string) ([]audit.Event, string, error) {
+	args := m.Called(ctx, identityID, pageSize, pageToken)
+	events, _ := args.Get(0).([]audit.Event)
+	return events, args.String(1), args.Error(2)
+}
+
+const identityID = "c935b23d-6cb4-448a-814e-b42aec9ef6cf"
+
+type serviceTestSuite struct {
+	suite.Suite
+	repository *mockAuditRepository
+	service    Service
+}
+
+func (s *serviceTestSuite) SetupTest() {
+	s.repository = new(mockAuditRepository)
+	s.service = NewService(s.repository)
+}
+
+func (s *serviceTestSuite) TestListEvents() {
+	ctx := context.Background()
+	events := []audit.Event{{ID: "01928f5e-8c4d-7e5f-a06b-1c2d3e4f5a6b", IdentityID: identityID, Type: audit.EventRegistration}}
+	s.repository.On("QueryEvents", ctx, identityID, 20, "").Return(events, events[0].ID, nil).Once()
+
+	page, next, err := s.service.ListEvents(ctx, identityID, 20, "")
+	s.Require().NoError(err)
+	s.Equal(events, page)
+	s.Equal(events[0].ID, next)
+	s.repository.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestListEvents_PageSize() {
+	ctx := context.Background()
+	s.repository.On("QueryEvents", ctx, identityID, DefaultPageSize, "token").Return(nil, "", nil).Once()
+	s.repository.On("QueryEvents", ctx, identityID, MaxPageSize, "token").Return(nil, "", nil).Once()
+
+	_, _, err := s.service.ListEvents(ctx, identityID, 0, "token")
+	s.Require().NoError(err)
+	_, _, err = s.service.ListEvents(ctx, identityID, MaxPageSize+1, "token")
+	s.Require().NoError(err)
+	_, _, err = s.service.ListEvents(ctx, identityID, -1, "token")
+	s.ErrorIs(err, ErrInvalidPageSize)
+	s.repository.AssertExpectations(s.T())
+}
+
+func TestServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(serviceTestSuite))
+}
@@ -0,0 +1,197 @@
+// Package devicealert warns identities about sign-ins from devices they
+// never used, and lets them revoke those sessions from the warning.
+package devicealert
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"sync"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/domain/audit"
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+	"gitlab.mreg.io/my-registry/auth/domain/transaction"
+	"gitlab.mreg.io/my-registry/auth/logging"
+)
+
+// DefaultSendTimeout bounds the delivery of one notice.
+const DefaultSendTimeout = 30 * time.Second
+
+type Service interface {
+	// Registered records the device of the first session of a new identity.
+	// There is nobody to warn yet.
+	Registered(ctx context.Context, session *session.Session)
+	// SignedIn records device as used by the identity of session, and warns
+	// the identity in the background when its browser family, OS and
+	// country were never seen. Failures are logged, never returned, so that
+	// they do not fail the sign-in.
+	SignedIn(ctx context.Context, session *session.Session, device *session.Device)
+	// RevokeSession deletes the session named by a token from a notice,
+	// on behalf of the client at ipAddress.
+	RevokeSession(ctx context.Context, token string, ipAddress netip.Addr, userAgent string) error
+	// Wait blocks until the notices being sent are delivered or timed out.
+	Wait()
+}
+
+// Config holds the settings of the device alert service.
+type Config struct {
+	// RevokeURL is the public address of the revocation page. The token is
+	// added as the "token" query parameter.
+	RevokeURL string
+	// SendTimeout bounds the delivery of one notice.
+	SendTimeout time.Duration
+}
+
+type service struct {
+	sessions     session.Repository
+	identities   identity.Repository
+	auditLog     audit.Repository
+	transactions transaction.Runner
+	notifier     session.Notifier
+	signer       *session.RevocationSigner
+	config       Config
+	pending      sync.WaitGroup
+}
+
+// NewService creates the device alert service. Known devices are recorded
+// in any case, and notices are only sent when notifier and signer, which
+// signs the revocation links of the notices, are not nil. Revocations are
+// only audited when auditLog is not nil.
+func NewService(sessions session.Repository, identities identity.Repository, auditLog audit.Repository, transactions transaction.Runner, notifier session.Notifier, signer *session.RevocationSigner, config Config) Service {
+	if config.SendTimeout <= 0 {
+		config.SendTimeout = DefaultSendTimeout
+	}
+	return &service{
+		sessions:     sessions,
+		identities:   identities,
+		auditLog:     auditLog,
+		transactions: transactions,
+		notifier:     notifier,
+		signer:       signer,
+		config:       config,
+	}
+}
+
+func (s *service) Registered(ctx context.Context, sessionData *session.Session) {
+	if sessionData.Identity == nil || sessionData.Identity.ID == "" || len(sessionData.Devices) == 0 {
+		return
+	}
+	if _, err := s.sessions.RememberDevice(ctx, sessionData.Identity.ID, &sessionData.Devices[0]); err != nil {
+		slog.WarnContext(ctx, "error recording known device", logging.Error(err))
+	}
+}
+
+func (s *service) SignedIn(ctx context.Context, sessionData *session.Session, device *session.Device) {
+	if sessionData.Identity == nil || sessionData.Identity.ID == "" {
+		return
+	}
+	isNew, err := s.sessions.RememberDevice(ctx, sessionData.Identity.ID, device)
+	if err != nil {
+		slog.WarnContext(ctx, "error recording known device", logging.Error(err))
+		return
+	}
+	if !isNew || s.notifier == nil || s.signer == nil {
+		return
+	}
+
+	owner := &identity.Identity{ID: sessionData.Identity.ID}
+	if err := s.identities.QueryEmails(ctx, owner); err != nil {
+		slog.WarnContext(ctx, "error querying identity emails", logging.Error(err))
+		return
+	}
+	email, ok := verifiedEmail(owner)
+	if !ok {
+		return
+	}
+	revokeURL, err := s.revokeURL(sessionData)
+	if err != nil {
+		slog.WarnContext(ctx, "error building session revocation URL", logging.Error(err))
+		return
+	}
+	s.notify(ctx, session.NewDeviceNotice{
+		Email:     email,
+		Time:      time.Now(),
+		Location:  device.GeoLocation,
+		Device:    device.DisplayName(),
+		RevokeURL: revokeURL,
+		Locale:    sessionData.Identity.Locale,
+	})
+}
+
+// notify sends notice in the background, outliving the request.
+func (s *service) notify(ctx context.Context, notice session.NewDeviceNotice) {
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.config.SendTimeout)
+		defer cancel()
+		if err := s.notifier.NotifyNewDevice(ctx, notice); err != nil {
+			slog.WarnContext(ctx, "error sending new device notice", logging.Error(err))
+		}
+	}()
+}
+
+func (s *service) revokeURL(sessionData *session.Session) (string, error) {
+	revokeURL, err := url.Parse(s.config.RevokeURL)
+	if err != nil {
+		return "", err
+	}
+	query := revokeURL.Query()
+	query.Set("token", s.signer.Sign(sessionData.ID, sessionData.ExpiresAt))
+	revokeURL.RawQuery = query.Encode()
+	return revokeURL.String(), nil
+}
+
+func (s *service) RevokeSession(ctx context.Context, token string, ipAddress netip.Addr, userAgent string) error {
+	if s.signer == nil {
+		return session.ErrInvalidRevocationToken
+	}
+	sessionID, err := s.signer.Verify(token, time.Now())
+	if err != nil {
+		return err
+	}
+	return s.transactions.Run(ctx, func(ctx context.Context) error {
+		sessionData := &session.Session{ID: sessionID, Identity: &identity.Identity{}}
+		if err := s.sessions.QuerySessionByID(ctx, sessionData); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				// The session is already gone, so there is nothing to record
+				return nil
+			}
+			return err
+		}
+		if err := s.sessions.DeleteSession(ctx, sessionID); err != nil {
+			return err
+		}
+		if s.auditLog == nil {
+			return nil
+		}
+		return s.auditLog.CreateEvent(ctx, &audit.Event{
+			Type:       audit.EventSessionRevoked,
+			IdentityID: sessionData.Identity.ID,
+			SessionID:  sessionID,
+			IPAddress:  ipAddress,
+			UserAgent:  userAgent,
+			Outcome:    audit.OutcomeSuccess,
+		})
+	})
+}
+
+func (s *service) Wait() {
+	s.pending.Wait()
+}
+
+// verifiedEmail returns the first verified address of owner. Notices are
+// never sent to addresses the owner has not proven to receive.
+func verifiedEmail(owner *identity.Identity) (string, bool) {
+	for _, email := range owner.Emails {
+		if email.Verified {
+			return email.Value, true
+		}
+	}
+	return "", false
+}
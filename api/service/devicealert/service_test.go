@@ -0,0 +1,256 @@
+package devicealert
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/audit"
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+)
+
+type mockSessionRepository struct {
+	session.Repository
+	mock.Mock
+}
+
+func (m *mockSessionRepository) RememberDevice(ctx context.Context, identityID string, device *session.Device) (bool, error) {
+	args := m.Called(ctx, identityID, device)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockSessionRepository) QuerySessionByID(ctx context.Context, sessionData *session.Session) error {
+	args := m.Called(ctx, sessionData)
+	if fill, ok := args.Get(0).(func(*session.Session)); ok {
+		fill(sessionData)
+	}
+	return args.Error(1)
+}
+
+func (m *mockSessionRepository) DeleteSession(ctx context.Context, sessionID string) error {
+	return m.Called(ctx, sessionID).Error(0)
+}
+
+type mockIdentityRepository struct {
+	identity.Repository
+	mock.Mock
+}
+
+func (m *mockIdentityRepository) QueryEmails(ctx context.Context, id *identity.Identity) error {
+	return m.Called(ctx, id).Error(0)
+}
+
+type mockAuditRepository struct {
+	audit.Repository
+	mock.Mock
+}
+
+func (m *mockAuditRepository) CreateEvent(ctx context.Context, event *audit.Event) error {
+	return m.Called(ctx, event).Error(0)
+}
+
+// directRunner runs units of work directly on the mocked repositories.
+type directRunner struct{}
+
+func (directRunner) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type mockNotifier struct {
+	mock.Mock
+}
+
+func (m *mockNotifier) NotifyNewDevice(ctx context.Context, notice session.NewDeviceNotice) error {
+	return m.Called(ctx, notice).Error(0)
+}
+
+const (
+	identityID = "01928f5e-7a3b-7c1d-9e2f-3a4b5c6d7e8f"
+	sessionID  = "01928f5e-8c4d-7e5f-a06b-1c2d3e4f5a6b"
+	firefox    = "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0"
+)
+
+type serviceTestSuite struct {
+	suite.Suite
+	sessions   *mockSessionRepository
+	identities *mockIdentityRepository
+	auditLog   *mockAuditRepository
+	notifier   *mockNotifier
+	signer     *session.RevocationSigner
+	service    Service
+	session    *session.Session
+	device     session.Device
+}
+
+func (s *serviceTestSuite) SetupTest() {
+	s.sessions = new(mockSessionRepository)
+	s.identities = new(mockIdentityRepository)
+	s.auditLog = new(mockAuditRepository)
+	s.notifier = new(mockNotifier)
+	s.signer = session.NewRevocationSigner([]byte("0123456789abcdef0123456789abcdef"))
+	s.service = NewService(s.sessions, s.identities, s.auditLog, directRunner{}, s.notifier, s.signer, Config{RevokeURL: "https://auth.mreg.io/sessions/revoke"})
+	s.session = &session.Session{
+		ID:        sessionID,
+		ExpiresAt: time.Now().Add(time.Hour),
+		Identity:  &identity.Identity{ID: identityID, Locale: "fr"},
+	}
+	s.device = session.NewDevice(netip.MustParseAddr("81.2.69.142"), firefox)
+	s.device.SetLocation(session.Location{CountryCode: "GB", City: "London"})
+}
+
+func (s *serviceTestSuite) TestSignedIn_NewDevice() {
+	ctx := context.Background()
+	s.sessions.On("RememberDevice", ctx, identityID, &s.device).Return(true, nil).Once()
+	s.identities.On("QueryEmails", ctx, mock.Anything).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*identity.Identity).Emails = []identity.Email{
+				{Value: "old@example.com"},
+				{Value: "user@example.com", Verified: true},
+			}
+		}).
+		Return(nil).
+		Once()
+	var notice session.NewDeviceNotice
+	s.notifier.On("NotifyNewDevice", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { notice = args.Get(1).(session.NewDeviceNotice) }).
+		Return(nil).
+		Once()
+
+	s.service.SignedIn(ctx, s.session, &s.device)
+	s.service.Wait()
+	s.sessions.AssertExpectations(s.T())
+	s.notifier.AssertExpectations(s.T())
+
+	s.Equal("user@example.com", notice.Email)
+	s.Equal("London, GB", notice.Location)
+	s.Equal("Firefox 131 on Linux", notice.Device)
+	s.Equal("fr", notice.Locale)
+	s.WithinDuration(time.Now(), notice.Time, time.Minute)
+
+	revokeURL, err := url.Parse(notice.RevokeURL)
+	s.Require().NoError(err)
+	s.Equal("auth.mreg.io", revokeURL.Host)
+	revokedID, err := s.signer.Verify(revokeURL.Query().Get("token"), time.Now())
+	s.Require().NoError(err)
+	s.Equal(sessionID, revokedID)
+}
+
+func (s *serviceTestSuite) TestSignedIn_KnownDevice() {
+	ctx := context.Background()
+	s.sessions.On("RememberDevice", ctx, identityID, &s.device).Return(false, nil).Once()
+
+	s.service.SignedIn(ctx, s.session, &s.device)
+	s.service.Wait()
+	s.identities.AssertNotCalled(s.T(), "QueryEmails", mock.Anything, mock.Anything)
+	s.notifier.AssertNotCalled(s.T(), "NotifyNewDevice", mock.Anything, mock.Anything)
+}
+
+func (s *serviceTestSuite) TestSignedIn_NoVerifiedEmail() {
+	ctx := context.Background()
+	s.sessions.On("RememberDevice", ctx, identityID, &s.device).Return(true, nil).Once()
+	s.identities.On("QueryEmails", ctx, mock.Anything).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*identity.Identity).Emails = []identity.Email{{Value: "user@example.com"}}
+		}).
+		Return(nil).
+		Once()
+
+	s.service.SignedIn(ctx, s.session, &s.device)
+	s.service.Wait()
+	s.notifier.AssertNotCalled(s.T(), "NotifyNewDevice", mock.Anything, mock.Anything)
+}
+
+func (s *serviceTestSuite) TestSignedIn_NoSigner() {
+	ctx := context.Background()
+	s.sessions.On("RememberDevice", ctx, identityID, &s.device).Return(true, nil).Once()
+
+	// Notices cannot link to the revocation page without a signer
+	service := NewService(s.sessions, s.identities, s.auditLog, directRunner{}, s.notifier, nil, Config{RevokeURL: "https://auth.mreg.io/sessions/revoke"})
+	service.SignedIn(ctx, s.session, &s.device)
+	service.Wait()
+	s.sessions.AssertExpectations(s.T())
+	s.notifier.AssertNotCalled(s.T(), "NotifyNewDevice", mock.Anything, mock.Anything)
+}
+
+func (s *serviceTestSuite) TestSignedIn_Failures() {
+	ctx := context.Background()
+	s.sessions.On("RememberDevice", ctx, identityID, &s.device).Return(false, errors.New("connection refused")).Once()
+	s.service.SignedIn(ctx, s.session, &s.device)
+
+	// Sending errors are logged only
+	s.sessions.On("RememberDevice", ctx, identityID, &s.device).Return(true, nil).Once()
+	s.identities.On("QueryEmails", ctx, mock.Anything).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*identity.Identity).Emails = []identity.Email{{Value: "user@example.com", Verified: true}}
+		}).
+		Return(nil).
+		Once()
+	s.notifier.On("NotifyNewDevice", mock.Anything, mock.Anything).Return(errors.New("relay unavailable")).Once()
+	s.service.SignedIn(ctx, s.session, &s.device)
+	s.service.Wait()
+	s.notifier.AssertExpectations(s.T())
+
+	// Anonymous sessions have no known devices
+	s.service.SignedIn(ctx, &session.Session{ID: sessionID, Identity: &identity.Identity{}}, &s.device)
+	s.sessions.AssertNumberOfCalls(s.T(), "RememberDevice", 2)
+}
+
+func (s *serviceTestSuite) TestRegistered() {
+	ctx := context.Background()
+	s.session.Devices = []session.Device{s.device}
+	s.sessions.On("RememberDevice", ctx, identityID, &s.session.Devices[0]).Return(true, nil).Once()
+
+	s.service.Registered(ctx, s.session)
+	s.service.Wait()
+	s.sessions.AssertExpectations(s.T())
+	s.notifier.AssertNotCalled(s.T(), "NotifyNewDevice", mock.Anything, mock.Anything)
+}
+
+func (s *serviceTestSuite) TestRevokeSession() {
+	ctx := context.Background()
+	clientIP := netip.MustParseAddr("198.51.100.4")
+	owned := func(sessionData *session.Session) {
+		sessionData.Identity.ID = identityID
+	}
+	s.sessions.On("QuerySessionByID", ctx, mock.Anything).Return(owned, nil).Once()
+	s.sessions.On("DeleteSession", ctx, sessionID).Return(nil).Once()
+	s.auditLog.On("CreateEvent", ctx, &audit.Event{
+		Type:       audit.EventSessionRevoked,
+		IdentityID: identityID,
+		SessionID:  sessionID,
+		IPAddress:  clientIP,
+		UserAgent:  firefox,
+		Outcome:    audit.OutcomeSuccess,
+	}).Return(nil).Once()
+
+	token := s.signer.Sign(sessionID, time.Now().Add(time.Hour))
+	s.Require().NoError(s.service.RevokeSession(ctx, token, clientIP, firefox))
+	s.sessions.AssertExpectations(s.T())
+	s.auditLog.AssertExpectations(s.T())
+
+	// A session already gone is neither deleted nor audited again
+	s.sessions.On("QuerySessionByID", ctx, mock.Anything).Return(nil, store.ErrNotFound).Once()
+	s.Require().NoError(s.service.RevokeSession(ctx, token, clientIP, firefox))
+	s.sessions.AssertExpectations(s.T())
+	s.auditLog.AssertExpectations(s.T())
+
+	err := s.service.RevokeSession(ctx, s.signer.Sign(sessionID, time.Now().Add(-time.Second)), clientIP, firefox)
+	s.ErrorIs(err, session.ErrInvalidRevocationToken)
+	err = s.service.RevokeSession(ctx, "forged", clientIP, firefox)
+	s.ErrorIs(err, session.ErrInvalidRevocationToken)
+
+	withoutSigner := NewService(s.sessions, s.identities, s.auditLog, directRunner{}, nil, nil, Config{})
+	s.ErrorIs(withoutSigner.RevokeSession(ctx, "token", clientIP, firefox), session.ErrInvalidRevocationToken)
+}
+
+func TestServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(serviceTestSuite))
+}
@@ -0,0 +1,32 @@
+package lockout
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrAccountLocked = errors.New("account locked")
+	// ErrAddressLocked is returned when the client address is locked, which
+	// says nothing about the account.
+	ErrAddressLocked    = errors.New("too many failed logins from this address")
+	ErrTooManyAttempts  = errors.New("too many failed login attempts")
+	ErrUnauthenticated  = errors.New("unauthenticated")
+	ErrIdentityNotFound = errors.New("identity not found")
+)
+
+// RetryAfterError wraps ErrAccountLocked, ErrAddressLocked or
+// ErrTooManyAttempts with the time the client has to wait before trying
+// again.
+type RetryAfterError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,196 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/domain/audit"
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/lockout"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+	"gitlab.mreg.io/my-registry/auth/domain/transaction"
+)
+
+// eventsLimit is the number of lock events shown to an account owner.
+const eventsLimit = 50
+
+// Service throttles password logins. Login handlers call Check before
+// verifying a password and RecordFailure or RecordSuccess afterwards, which
+// also audit the login.
+type Service interface {
+	Check(ctx context.Context, identityID string, ipAddress netip.Addr) error
+	RecordFailure(ctx context.Context, identityID string, ipAddress netip.Addr, userAgent string) error
+	RecordSuccess(ctx context.Context, identityID string, sessionID string, ipAddress netip.Addr, userAgent string) error
+	Unlock(ctx context.Context, identityID string, actor string) error
+	// ListEvents returns the lock events of the identity signed in with
+	// sessionID, so that owners can see when their account was locked.
+	ListEvents(ctx context.Context, sessionID string) ([]lockout.Event, error)
+}
+
+type service struct {
+	repository     lockout.Repository
+	sessions       session.Repository
+	auditLog       audit.Repository
+	transactions   transaction.Runner
+	identityPolicy lockout.Policy
+	ipPolicy       lockout.Policy
+	now            func() time.Time
+}
+
+func NewService(repository lockout.Repository, sessions session.Repository, auditLog audit.Repository, transactions transaction.Runner, identityPolicy lockout.Policy, ipPolicy lockout.Policy) Service {
+	return &service{repository, sessions, auditLog, transactions, identityPolicy, ipPolicy, time.Now}
+}
+
+// Check returns a RetryAfterError if the identity or the address has to
+// wait before the next attempt. identityID is empty when the login names
+// an unknown account.
+func (s *service) Check(ctx context.Context, identityID string, ipAddress netip.Addr) error {
+	now := s.now()
+	if identityID != "" {
+		state, err := s.repository.QueryState(ctx, lockout.IdentityKey(identityID))
+		if err != nil {
+			return err
+		}
+		if err := retryAfterError(s.identityPolicy, state, now, ErrAccountLocked); err != nil {
+			return err
+		}
+	}
+
+	state, err := s.repository.QueryState(ctx, lockout.IPKey(ipAddress))
+	if err != nil {
+		return err
+	}
+	return retryAfterError(s.ipPolicy, state, now, ErrAddressLocked)
+}
+
+// retryAfterError returns locked if the subject of state is locked.
+func retryAfterError(policy lockout.Policy, state lockout.State, now time.Time, locked error) error {
+	retryAfter := policy.RetryAfter(state, now)
+	if retryAfter <= 0 {
+		return nil
+	}
+	if state.IsLocked(now) {
+		return &RetryAfterError{Err: locked, RetryAfter: retryAfter}
+	}
+	return &RetryAfterError{Err: ErrTooManyAttempts, RetryAfter: retryAfter}
+}
+
+// RecordFailure counts a failed login and locks the identity or the address
+// once its threshold is reached. The counts, the locks, the event telling
+// the owner and the audit event of the login are written in one
+// transaction, so that no identity is locked without the owner knowing.
+func (s *service) RecordFailure(ctx context.Context, identityID string, ipAddress netip.Addr, userAgent string) error {
+	now := s.now()
+	return s.transactions.Run(ctx, func(ctx context.Context) error {
+		if identityID != "" {
+			key := lockout.IdentityKey(identityID)
+			state, err := s.repository.IncrementFailures(ctx, key, s.identityPolicy.Window)
+			if err != nil {
+				return err
+			}
+			if s.identityPolicy.ShouldLock(state, now) {
+				lockedUntil := now.Add(s.identityPolicy.LockDuration)
+				if err := s.repository.Lock(ctx, key, lockedUntil); err != nil {
+					return err
+				}
+				// Let the owner know that the account has been locked
+				event := &lockout.Event{
+					IdentityID:  identityID,
+					Type:        lockout.EventLocked,
+					IPAddress:   ipAddress,
+					LockedUntil: lockedUntil,
+				}
+				if err := s.repository.CreateEvent(ctx, event); err != nil {
+					return err
+				}
+			}
+		}
+
+		// The address is counted for every failure
+		key := lockout.IPKey(ipAddress)
+		state, err := s.repository.IncrementFailures(ctx, key, s.ipPolicy.Window)
+		if err != nil {
+			return err
+		}
+		err = s.auditLog.CreateEvent(ctx, &audit.Event{
+			Type:       audit.EventLogin,
+			IdentityID: identityID,
+			IPAddress:  ipAddress,
+			UserAgent:  userAgent,
+			Outcome:    audit.OutcomeFailure,
+		})
+		if err != nil {
+			return err
+		}
+		if s.ipPolicy.ShouldLock(state, now) {
+			return s.repository.Lock(ctx, key, now.Add(s.ipPolicy.LockDuration))
+		}
+		return nil
+	})
+}
+
+// RecordSuccess clears the failures of the identity. Failures of the address
+// are kept, as a single correct password says nothing about other accounts.
+func (s *service) RecordSuccess(ctx context.Context, identityID string, sessionID string, ipAddress netip.Addr, userAgent string) error {
+	return s.transactions.Run(ctx, func(ctx context.Context) error {
+		if err := s.repository.ResetFailures(ctx, lockout.IdentityKey(identityID)); err != nil {
+			return err
+		}
+		return s.auditLog.CreateEvent(ctx, &audit.Event{
+			Type:       audit.EventLogin,
+			IdentityID: identityID,
+			SessionID:  sessionID,
+			IPAddress:  ipAddress,
+			UserAgent:  userAgent,
+			Outcome:    audit.OutcomeSuccess,
+		})
+	})
+}
+
+// Unlock lifts a lock on behalf of an administrator.
+func (s *service) Unlock(ctx context.Context, identityID string, actor string) error {
+	err := s.transactions.Run(ctx, func(ctx context.Context) error {
+		if err := s.repository.ResetFailures(ctx, lockout.IdentityKey(identityID)); err != nil {
+			return err
+		}
+		err := s.auditLog.CreateEvent(ctx, &audit.Event{
+			Type:       audit.EventAdminAction,
+			Actor:      actor,
+			IdentityID: identityID,
+			Outcome:    audit.OutcomeSuccess,
+			Action:     "unlock",
+		})
+		if err != nil {
+			return err
+		}
+		return s.repository.CreateEvent(ctx, &lockout.Event{
+			IdentityID: identityID,
+			Type:       lockout.EventUnlocked,
+			Actor:      actor,
+		})
+	})
+	// the events reference their identity
+	if errors.Is(err, store.ErrInvalid) {
+		return ErrIdentityNotFound
+	}
+	return err
+}
+
+// ListEvents returns the most recent lock events, newest first. Anonymous
+// sessions, such as those of registration flows, have no events to show.
+func (s *service) ListEvents(ctx context.Context, sessionID string) ([]lockout.Event, error) {
+	sessionData := &session.Session{ID: sessionID, Identity: &identity.Identity{}}
+	if err := s.sessions.QuerySessionByID(ctx, sessionData); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrUnauthenticated
+		}
+		return nil, err
+	}
+	if !sessionData.Active || sessionData.IsExpired() || sessionData.Identity.ID == "" {
+		return nil, ErrUnauthenticated
+	}
+	return s.repository.ListEvents(ctx, sessionData.Identity.ID, eventsLimit)
+}
@@ -0,0 +1,323 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/audit"
+	"gitlab.mreg.io/my-registry/auth/domain/lockout"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+)
+
+type mockLockoutRepository struct {
+	mock.Mock
+}
+
+func (m *mockLockoutRepository) QueryState(ctx context.Context, key string) (lockout.State, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(lockout.State), args.Error(1)
+}
+
+func (m *mockLockoutRepository) IncrementFailures(ctx context.Context, key string, window time.Duration) (lockout.State, error) {
+	args := m.Called(ctx, key, window)
+	return args.Get(0).(lockout.State), args.Error(1)
+}
+
+func (m *mockLockoutRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	args := m.Called(ctx, key, until)
+	return args.Error(0)
+}
+
+func (m *mockLockoutRepository) ResetFailures(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *mockLockoutRepository) CreateEvent(ctx context.Context, event *lockout.Event) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *mockLockoutRepository) ListEvents(ctx context.Context, identityID string, limit int) ([]lockout.Event, error) {
+	args := m.Called(ctx, identityID, limit)
+	events, _ := args.Get(0).([]lockout.Event)
+	return events, args.Error(1)
+}
+
+type mockSessionRepository struct {
+	session.Repository
+	mock.Mock
+}
+
+func (m *mockSessionRepository) QuerySessionByID(ctx context.Context, sessionData *session.Session) error {
+	args := m.Called(ctx, sessionData)
+	if fill, ok := args.Get(0).(func(*session.Session)); ok {
+		fill(sessionData)
+	}
+	return args.Error(1)
+}
+
+type mockAuditRepository struct {
+	audit.Repository
+	mock.Mock
+}
+
+func (m *mockAuditRepository) CreateEvent(ctx context.Context, event *audit.Event) error {
+	return m.Called(ctx, event).Error(0)
+}
+
+// directRunner runs units of work directly on the mocked repositories.
+type directRunner struct{}
+
+func (directRunner) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// txRunner runs units of work with the context of its transaction, so
+// that the calls made in the transaction can be told apart.
+type txRunner struct {
+	ctx context.Context
+}
+
+func (r txRunner) Run(_ context.Context, fn func(ctx context.Context) error) error {
+	return fn(r.ctx)
+}
+
+// txKey marks the context of the transactions of txRunner.
+type txKey struct{}
+
+type serviceTestSuite struct {
+	suite.Suite
+	repository *mockLockoutRepository
+	sessions   *mockSessionRepository
+	auditLog   *mockAuditRepository
+	service    *service
+	now        time.Time
+}
+
+var (
+	identityID  = "c935b23d-6cb4-448a-814e-b42aec9ef6cf"
+	ipAddress   = netip.MustParseAddr("192.0.2.1")
+	identityKey = lockout.IdentityKey(identityID)
+	ipKey       = lockout.IPKey(ipAddress)
+	sessionID   = "01928f5e-8c4d-7e5f-a06b-1c2d3e4f5a6b"
+	userAgent   = "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0"
+	failedLogin = &audit.Event{
+		Type:       audit.EventLogin,
+		IdentityID: identityID,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		Outcome:    audit.OutcomeFailure,
+	}
+	policy = lockout.Policy{
+		Threshold:    3,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockDuration: 15 * time.Minute,
+		Window:       time.Hour,
+	}
+)
+
+func (s *serviceTestSuite) SetupTest() {
+	s.now = time.Now()
+	s.repository = new(mockLockoutRepository)
+	s.sessions = new(mockSessionRepository)
+	s.auditLog = new(mockAuditRepository)
+	s.service = NewService(s.repository, s.sessions, s.auditLog, directRunner{}, policy, policy).(*service)
+	s.service.now = func() time.Time { return s.now }
+}
+
+func (s *serviceTestSuite) TestCheck_Allowed() {
+	ctx := context.Background()
+	s.repository.On("QueryState", ctx, identityKey).Return(lockout.State{}, nil).Once()
+	s.repository.On("QueryState", ctx, ipKey).Return(lockout.State{}, nil).Once()
+
+	s.Require().NoError(s.service.Check(ctx, identityID, ipAddress))
+	s.repository.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestCheck_Locked() {
+	ctx := context.Background()
+	s.repository.On("QueryState", ctx, identityKey).
+		Return(lockout.State{Failures: 3, LastFailureAt: s.now, LockedUntil: s.now.Add(time.Minute)}, nil).Once()
+
+	err := s.service.Check(ctx, identityID, ipAddress)
+	s.Require().ErrorIs(err, ErrAccountLocked)
+	var retryErr *RetryAfterError
+	s.Require().ErrorAs(err, &retryErr)
+	s.Equal(time.Minute, retryErr.RetryAfter)
+	s.repository.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestCheck_IPDelay() {
+	ctx := context.Background()
+	s.repository.On("QueryState", ctx, ipKey).
+		Return(lockout.State{Failures: 2, LastFailureAt: s.now}, nil).Once()
+
+	// Unknown account, only the address is checked
+	err := s.service.Check(ctx, "", ipAddress)
+	s.Require().ErrorIs(err, ErrTooManyAttempts)
+	var retryErr *RetryAfterError
+	s.Require().ErrorAs(err, &retryErr)
+	s.Equal(2*time.Second, retryErr.RetryAfter)
+	s.repository.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestCheck_IPLocked() {
+	ctx := context.Background()
+	s.repository.On("QueryState", ctx, identityKey).Return(lockout.State{}, nil).Once()
+	s.repository.On("QueryState", ctx, ipKey).
+		Return(lockout.State{Failures: 3, LastFailureAt: s.now, LockedUntil: s.now.Add(time.Minute)}, nil).Once()
+
+	err := s.service.Check(ctx, identityID, ipAddress)
+	s.Require().ErrorIs(err, ErrAddressLocked)
+	s.NotErrorIs(err, ErrAccountLocked)
+	s.repository.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestRecordFailure_BelowThreshold() {
+	ctx := context.Background()
+	s.repository.On("IncrementFailures", ctx, identityKey, policy.Window).
+		Return(lockout.State{Failures: 1, LastFailureAt: s.now}, nil).Once()
+	s.repository.On("IncrementFailures", ctx, ipKey, policy.Window).
+		Return(lockout.State{Failures: 1, LastFailureAt: s.now}, nil).Once()
+	s.auditLog.On("CreateEvent", ctx, failedLogin).Return(nil).Once()
+
+	s.Require().NoError(s.service.RecordFailure(ctx, identityID, ipAddress, userAgent))
+	s.repository.AssertExpectations(s.T())
+	s.auditLog.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestRecordFailure_Locks() {
+	// The lock, its event and the audit event are written in the transaction
+	// of the count
+	ctx := context.WithValue(context.Background(), txKey{}, true)
+	s.service.transactions = txRunner{ctx}
+	lockedUntil := s.now.Add(policy.LockDuration)
+	s.repository.On("IncrementFailures", ctx, identityKey, policy.Window).
+		Return(lockout.State{Failures: 3, LastFailureAt: s.now}, nil).Once()
+	s.repository.On("Lock", ctx, identityKey, lockedUntil).Return(nil).Once()
+	s.repository.On("CreateEvent", ctx, &lockout.Event{
+		IdentityID:  identityID,
+		Type:        lockout.EventLocked,
+		IPAddress:   ipAddress,
+		LockedUntil: lockedUntil,
+	}).Return(nil).Once()
+	s.repository.On("IncrementFailures", ctx, ipKey, policy.Window).
+		Return(lockout.State{Failures: 3, LastFailureAt: s.now}, nil).Once()
+	s.auditLog.On("CreateEvent", ctx, failedLogin).Return(nil).Once()
+	s.repository.On("Lock", ctx, ipKey, lockedUntil).Return(nil).Once()
+
+	s.Require().NoError(s.service.RecordFailure(context.Background(), identityID, ipAddress, userAgent))
+	s.repository.AssertExpectations(s.T())
+	s.auditLog.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestRecordFailure_RepositoryError() {
+	ctx := context.Background()
+	s.repository.On("IncrementFailures", ctx, identityKey, policy.Window).
+		Return(lockout.State{}, errors.New("internal")).Once()
+
+	s.Require().Error(s.service.RecordFailure(ctx, identityID, ipAddress, userAgent))
+	s.repository.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestRecordSuccess() {
+	ctx := context.Background()
+	s.repository.On("ResetFailures", ctx, identityKey).Return(nil).Once()
+	s.auditLog.On("CreateEvent", ctx, &audit.Event{
+		Type:       audit.EventLogin,
+		IdentityID: identityID,
+		SessionID:  sessionID,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		Outcome:    audit.OutcomeSuccess,
+	}).Return(nil).Once()
+
+	s.Require().NoError(s.service.RecordSuccess(ctx, identityID, sessionID, ipAddress, userAgent))
+	s.repository.AssertExpectations(s.T())
+	s.auditLog.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestUnlock() {
+	ctx := context.Background()
+	s.repository.On("ResetFailures", ctx, identityKey).Return(nil).Once()
+	s.auditLog.On("CreateEvent", ctx, &audit.Event{
+		Type:       audit.EventAdminAction,
+		Actor:      "admin",
+		IdentityID: identityID,
+		Outcome:    audit.OutcomeSuccess,
+		Action:     "unlock",
+	}).Return(nil).Once()
+	s.repository.On("CreateEvent", ctx, &lockout.Event{
+		IdentityID: identityID,
+		Type:       lockout.EventUnlocked,
+		Actor:      "admin",
+	}).Return(nil).Once()
+
+	s.Require().NoError(s.service.Unlock(ctx, identityID, "admin"))
+	s.repository.AssertExpectations(s.T())
+	s.auditLog.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestUnlock_UnknownIdentity() {
+	ctx := context.Background()
+	s.repository.On("ResetFailures", ctx, identityKey).Return(nil).Once()
+	s.auditLog.On("CreateEvent", ctx, mock.Anything).Return(nil).Once()
+	s.repository.On("CreateEvent", ctx, mock.Anything).
+		Return(&store.ConstraintError{Kind: store.ErrInvalid, Table: "lockout_events"}).Once()
+
+	s.ErrorIs(s.service.Unlock(ctx, identityID, "admin"), ErrIdentityNotFound)
+	s.repository.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestListEvents() {
+	ctx := context.Background()
+	signedIn := func(sessionData *session.Session) {
+		sessionData.Active = true
+		sessionData.ExpiresAt = s.now.Add(time.Hour)
+		sessionData.Identity.ID = identityID
+	}
+	s.sessions.On("QuerySessionByID", ctx, mock.Anything).Return(signedIn, nil).Once()
+	events := []lockout.Event{{IdentityID: identityID, Type: lockout.EventLocked}}
+	s.repository.On("ListEvents", ctx, identityID, eventsLimit).Return(events, nil).Once()
+
+	result, err := s.service.ListEvents(ctx, sessionID)
+	s.Require().NoError(err)
+	s.Equal(events, result)
+	s.repository.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestListEvents_Unauthenticated() {
+	ctx := context.Background()
+	anonymous := func(sessionData *session.Session) {
+		sessionData.Active = true
+		sessionData.ExpiresAt = s.now.Add(time.Hour)
+	}
+	expired := func(sessionData *session.Session) {
+		sessionData.Active = true
+		sessionData.ExpiresAt = s.now.Add(-time.Hour)
+		sessionData.Identity.ID = identityID
+	}
+	s.sessions.On("QuerySessionByID", ctx, mock.Anything).Return(nil, store.ErrNotFound).Once()
+	s.sessions.On("QuerySessionByID", ctx, mock.Anything).Return(anonymous, nil).Once()
+	s.sessions.On("QuerySessionByID", ctx, mock.Anything).Return(expired, nil).Once()
+
+	for range 3 {
+		_, err := s.service.ListEvents(ctx, sessionID)
+		s.ErrorIs(err, ErrUnauthenticated)
+	}
+	s.sessions.AssertExpectations(s.T())
+	s.repository.AssertNotCalled(s.T(), "ListEvents", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(serviceTestSuite))
+}
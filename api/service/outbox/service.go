@@ -0,0 +1,89 @@
+// Package outbox dispatches the messages of the outbox to the webhook
+// endpoints. Delivery is at least once and unordered: subscribers drop
+// the messages whose ID they have already seen.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/domain/outbox"
+	"gitlab.mreg.io/my-registry/auth/poll"
+)
+
+type Service interface {
+	// Dispatch delivers a batch of due messages and returns how many were
+	// claimed.
+	Dispatch(ctx context.Context) (int, error)
+	// Run dispatches until ctx is done, waiting for Config.PollInterval
+	// whenever the outbox has no more due messages.
+	Run(ctx context.Context)
+}
+
+// Config holds the settings of the dispatcher.
+type Config struct {
+	Endpoints    []string
+	BatchSize    int
+	PollInterval time.Duration
+	// Lease keeps claimed messages from other dispatchers. It must outlast
+	// the delivery of a batch, or messages are delivered twice.
+	Lease time.Duration
+	Retry outbox.RetryPolicy
+}
+
+type service struct {
+	repo      outbox.Repository
+	publisher outbox.Publisher
+	config    Config
+	now       func() time.Time
+}
+
+func NewService(repo outbox.Repository, publisher outbox.Publisher, config Config) Service {
+	return &service{repo, publisher, config, time.Now}
+}
+
+func (s *service) Dispatch(ctx context.Context) (int, error) {
+	return poll.Process(ctx, func(ctx context.Context) ([]outbox.Message, error) {
+		return s.repo.ClaimMessages(ctx, s.config.BatchSize, s.config.Lease)
+	}, s.deliver)
+}
+
+// deliver publishes message to the endpoints that have not accepted it yet,
+// and schedules the next attempt if one of them failed.
+func (s *service) deliver(ctx context.Context, message *outbox.Message) error {
+	var errs []error
+	for _, endpoint := range s.config.Endpoints {
+		if message.Delivered(endpoint) {
+			continue
+		}
+		if err := s.publisher.Publish(ctx, endpoint, message); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		message.DeliveredTo = append(message.DeliveredTo, endpoint)
+	}
+
+	message.LastError = ""
+	switch {
+	case len(errs) == 0:
+		message.State = outbox.StateDelivered
+	case s.config.Retry.Exhausted(message.Attempts):
+		message.State = outbox.StateDead
+		message.LastError = errors.Join(errs...).Error()
+		slog.ErrorContext(ctx, "outbox message is dead", slog.String("id", message.ID), slog.String("type", message.Type), slog.String("error", message.LastError))
+	default:
+		message.NextAttemptAt = s.now().Add(s.config.Retry.Delay(message.Attempts))
+		message.LastError = errors.Join(errs...).Error()
+		slog.WarnContext(ctx, "error delivering outbox message", slog.String("id", message.ID), slog.Int("attempts", message.Attempts), slog.String("error", message.LastError))
+	}
+	return s.repo.UpdateDelivery(ctx, message)
+}
+
+func (s *service) Run(ctx context.Context) {
+	poll.Run(ctx, "dispatching outbox messages", s.config.PollInterval, func(ctx context.Context) (bool, error) {
+		claimed, err := s.Dispatch(ctx)
+		return claimed == s.config.BatchSize, err
+	})
+}
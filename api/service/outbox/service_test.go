@@ -0,0 +1,147 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/outbox"
+)
+
+type mockRepository struct {
+	mock.Mock
+}
+
+func (m *mockRepository) ClaimMessages(ctx context.Context, limit int, lease time.Duration) ([]outbox.Message, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]outbox.Message), args.Error(1)
+}
+
+func (m *mockRepository) UpdateDelivery(ctx context.Context, message *outbox.Message) error {
+	args := m.Called(ctx, message)
+	return args.Error(0)
+}
+
+type mockPublisher struct {
+	mock.Mock
+}
+
+func (m *mockPublisher) Publish(ctx context.Context, endpoint string, message *outbox.Message) error {
+	args := m.Called(ctx, endpoint, message)
+	return args.Error(0)
+}
+
+const (
+	first  = "https://billing.mreg.io/webhooks/auth"
+	second = "https://search.mreg.io/webhooks/auth"
+)
+
+type serviceTestSuite struct {
+	suite.Suite
+	repo      *mockRepository
+	publisher *mockPublisher
+	service   *service
+	now       time.Time
+	config    Config
+}
+
+func (s *serviceTestSuite) SetupTest() {
+	s.now = time.Date(2026, time.October, 19, 16, 47, 12, 0, time.UTC)
+	s.repo = new(mockRepository)
+	s.publisher = new(mockPublisher)
+	s.config = Config{
+		Endpoints:    []string{first, second},
+		BatchSize:    10,
+		PollInterval: time.Second,
+		Lease:        time.Minute,
+		Retry:        outbox.RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Second, MaxDelay: time.Hour},
+	}
+	s.service = NewService(s.repo, s.publisher, s.config).(*service)
+	s.service.now = func() time.Time { return s.now }
+}
+
+func (s *serviceTestSuite) claim(ctx context.Context, messages ...outbox.Message) {
+	s.repo.On("ClaimMessages", ctx, s.config.BatchSize, s.config.Lease).Return(messages, nil).Once()
+}
+
+func (s *serviceTestSuite) TestDispatch_Delivered() {
+	ctx := context.Background()
+	s.claim(ctx, outbox.Message{ID: "1", State: outbox.StatePending, Attempts: 1})
+	s.publisher.On("Publish", ctx, first, mock.Anything).Return(nil).Once()
+	s.publisher.On("Publish", ctx, second, mock.Anything).Return(nil).Once()
+	s.repo.On("UpdateDelivery", ctx, mock.MatchedBy(func(message *outbox.Message) bool {
+		return message.State == outbox.StateDelivered && len(message.DeliveredTo) == 2 && message.LastError == ""
+	})).Return(nil).Once()
+
+	claimed, err := s.service.Dispatch(ctx)
+	s.Require().NoError(err)
+	s.Equal(1, claimed)
+	s.repo.AssertExpectations(s.T())
+	s.publisher.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestDispatch_Retry() {
+	ctx := context.Background()
+	// The first endpoint accepted the message on an earlier attempt
+	s.claim(ctx, outbox.Message{ID: "1", State: outbox.StatePending, Attempts: 2, DeliveredTo: []string{first}})
+	s.publisher.On("Publish", ctx, second, mock.Anything).Return(errors.New("503 Service Unavailable")).Once()
+	s.repo.On("UpdateDelivery", ctx, mock.MatchedBy(func(message *outbox.Message) bool {
+		return message.State == outbox.StatePending &&
+			message.NextAttemptAt.Equal(s.now.Add(20*time.Second)) &&
+			message.LastError == "503 Service Unavailable"
+	})).Return(nil).Once()
+
+	_, err := s.service.Dispatch(ctx)
+	s.Require().NoError(err)
+	s.publisher.AssertNotCalled(s.T(), "Publish", ctx, first, mock.Anything)
+	s.repo.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestDispatch_Dead() {
+	ctx := context.Background()
+	s.claim(ctx, outbox.Message{ID: "1", State: outbox.StatePending, Attempts: 3})
+	s.publisher.On("Publish", ctx, first, mock.Anything).Return(nil).Once()
+	s.publisher.On("Publish", ctx, second, mock.Anything).Return(errors.New("connection refused")).Once()
+	s.repo.On("UpdateDelivery", ctx, mock.MatchedBy(func(message *outbox.Message) bool {
+		return message.State == outbox.StateDead && message.LastError == "connection refused"
+	})).Return(nil).Once()
+
+	_, err := s.service.Dispatch(ctx)
+	s.Require().NoError(err)
+	s.repo.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestDispatch_Error() {
+	ctx := context.Background()
+	s.repo.On("ClaimMessages", ctx, s.config.BatchSize, s.config.Lease).Return([]outbox.Message(nil), errors.New("connection refused")).Once()
+
+	_, err := s.service.Dispatch(ctx)
+	s.Error(err)
+}
+
+func (s *serviceTestSuite) TestRun() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.repo.On("ClaimMessages", ctx, s.config.BatchSize, s.config.Lease).Return([]outbox.Message{}, nil).Run(func(mock.Arguments) {
+		cancel()
+	}).Once()
+
+	done := make(chan struct{})
+	go func() {
+		s.service.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		s.Fail("Run did not return once ctx was done")
+	}
+	s.repo.AssertExpectations(s.T())
+}
+
+func TestServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(serviceTestSuite))
+}
@@ -8,4 +8,6 @@ var (
 	ErrSessionExpired   = errors.New("session expired")
 	ErrUnauthenticated  = errors.New("session unauthenticated")
 	ErrFlowExpired      = errors.New("flow expired")
+	ErrChallengeFailed  = errors.New("invalid proof of work solution")
+	ErrRiskBlocked      = errors.New("device blocked by risk policy")
 )
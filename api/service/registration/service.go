@@ -2,144 +2,334 @@ package registration
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"net/netip"
-	"os"
 	"strings"
 	"time"
 
-	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
 
+	"gitlab.mreg.io/my-registry/auth/domain/audit"
+	"gitlab.mreg.io/my-registry/auth/domain/identity"
+	"gitlab.mreg.io/my-registry/auth/domain/network"
 	"gitlab.mreg.io/my-registry/auth/domain/registration"
+	"gitlab.mreg.io/my-registry/auth/domain/risk"
 	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+	"gitlab.mreg.io/my-registry/auth/domain/transaction"
+	"gitlab.mreg.io/my-registry/auth/logging"
+	"gitlab.mreg.io/my-registry/auth/service/devicealert"
+	serviceRisk "gitlab.mreg.io/my-registry/auth/service/risk"
 )
 
+// Repository calls are traced by the pgx tracer through ctx; the service
+// only adds spans for the work that does not reach the database.
+var tracer = otel.Tracer("gitlab.mreg.io/my-registry/auth/service/registration")
+
 type Service interface {
 	CreateRegistrationFlow(ctx context.Context, ipAddress netip.Addr, userAgent string) (*registration.Flow, *session.Session, error)
 	CompleteRegistrationFlow(context.Context, *registration.Flow, string, netip.Addr, string) (*session.Session, error)
 }
 
+// Config holds the settings of the registration service.
+type Config struct {
+	// SessionExpiryInterval is the lifetime of the sessions created by the
+	// service.
+	SessionExpiryInterval time.Duration
+	// FlowExpiryInterval is the lifetime of registration flows.
+	FlowExpiryInterval time.Duration
+	// ChallengePolicy decides the proof of work difficulty of new flows. The
+	// zero value disables challenges.
+	ChallengePolicy registration.ChallengePolicy
+	// Observer, if not nil, is told of the steps of the registration journey
+	// once they are committed.
+	Observer Observer
+}
+
+// Observer counts the steps of the registration journey.
+type Observer interface {
+	RegistrationStarted()
+	RegistrationCompleted()
+	SessionIssued(authenticatorAssuranceLevel uint8)
+}
+
 type service struct {
-	session              session.Repository
-	registrationFlow     registration.Repository
-	identityRepo         identity.Repository
-	sessionInterval      time.Duration
-	registrationInterval time.Duration
+	session          session.Repository
+	registrationFlow registration.Repository
+	identityRepo     identity.Repository
+	auditLog         audit.Repository
+	transactions     transaction.Runner
+	hasher           *identity.Hasher
+	captcha          registration.CaptchaVerifier
+	geo              session.GeoResolver
+	devices          devicealert.Service
+	risk             serviceRisk.Service
+	config           Config
 }
 
-func NewService(session session.Repository, registrationFlow registration.Repository, identityRepo identity.Repository) Service {
-	sessionInterval, err := time.ParseDuration(os.Getenv("SESSION_EXPIRY_INTERVAL"))
-	if err != nil {
-		panic("Environmental variable SESSION_EXPIRY_INTERVAL could not be parsed")
-	}
-	registrationInterval, err := time.ParseDuration(os.Getenv("REGISTRATION_EXPIRY_INTERVAL"))
-	if err != nil {
-		panic("Environmental variable REGISTRATION_EXPIRY_INTERVAL could not be parsed")
-	}
-	return &service{session, registrationFlow, identityRepo, sessionInterval, registrationInterval}
+// NewService creates the registration service. Flows are completed
+// atomically through transactions. Registrations, including those denied
+// by a policy, are only audited when auditLog is not nil, CAPTCHA tokens
+// are only checked when captcha is not nil, devices are only located when geo is not nil,
+// only remembered for their identity when devices is not nil, and only
+// assessed when risk is not nil.
+func NewService(session session.Repository, registrationFlow registration.Repository, identityRepo identity.Repository, auditLog audit.Repository, transactions transaction.Runner, hasher *identity.Hasher, captcha registration.CaptchaVerifier, geo session.GeoResolver, devices devicealert.Service, risk serviceRisk.Service, config Config) Service {
+	return &service{session, registrationFlow, identityRepo, auditLog, transactions, hasher, captcha, geo, devices, risk, config}
 }
 
 func (s *service) CreateRegistrationFlow(ctx context.Context, ipAddress netip.Addr, userAgent string) (*registration.Flow, *session.Session, error) {
+	device := s.newDevice(ctx, ipAddress, userAgent)
+	if err := s.assess(ctx, "", &device); err != nil {
+		return nil, nil, err
+	}
 	sessionModel := &session.Session{
 		Active:                      true,
 		AuthenticatorAssuranceLevel: 0,
-		ExpiryInterval:              s.sessionInterval,
-		Devices: []session.Device{
-			{IPAddress: ipAddress, UserAgent: userAgent, GeoLocation: "(unimplemented)"}, // TODO ip2Geolocation
-		},
+		ExpiryInterval:              s.config.SessionExpiryInterval,
+		Devices:                     []session.Device{device},
 	}
 	if err := s.session.CreateSession(ctx, sessionModel); err != nil {
 		return nil, nil, err
 	}
-	flow := &registration.Flow{SessionID: sessionModel.ID, Interval: s.registrationInterval}
+	flow := &registration.Flow{SessionID: sessionModel.ID, Interval: s.config.FlowExpiryInterval}
+	if s.config.ChallengePolicy.Enabled() {
+		recentFlows, err := s.registrationFlow.CountRecentFlows(ctx, network.ClientNetwork(ipAddress), time.Now().Add(-s.config.ChallengePolicy.Window))
+		if err != nil {
+			return nil, nil, err
+		}
+		if flow.Challenge, err = registration.NewChallenge(s.config.ChallengePolicy.Difficulty(recentFlows)); err != nil {
+			return nil, nil, err
+		}
+	}
 	if err := s.registrationFlow.CreateFlow(ctx, flow); err != nil {
 		return nil, nil, err
 	}
+	if s.config.Observer != nil {
+		s.config.Observer.RegistrationStarted()
+		s.config.Observer.SessionIssued(sessionModel.AuthenticatorAssuranceLevel)
+	}
 	return flow, sessionModel, nil
 }
 
-// CompleteRegistrationFlow will fill the flow identity in the process
+// CompleteRegistrationFlow completes the flow named name and fills the flow
+// identity in the process. The flow does not change once created, so
+// it is checked before the transaction along with the proof of work, the
+// CAPTCHA token and the password, which are only worth checking and hashing
+// once whatever the number of times the transaction is retried. The other
+// checks and the writes run in the transaction, so that concurrent
+// completions for the same email cannot both succeed and a failure leaves
+// nothing behind; device alerts are only raised once it is committed.
 func (s *service) CompleteRegistrationFlow(ctx context.Context, flow *registration.Flow, name string, ipAddress netip.Addr, userAgent string) (*session.Session, error) {
-	var err error
-
 	providedSessionID := flow.SessionID
 	lastSlashIndex := strings.LastIndex(name, "/")
 	if lastSlashIndex == -1 {
 		return nil, ErrUnauthenticated
 	}
 	flow.FlowID = name[:lastSlashIndex]
+
+	device := s.newDevice(ctx, ipAddress, userAgent)
+	// a blocked device is still recorded with the session before failing,
+	// so that the attempt is kept for review
+	riskErr := s.assess(ctx, "", &device)
+
 	// check if flow expires
-	if err = s.registrationFlow.QueryFlowByFlowID(ctx, flow); err != nil {
+	if err := s.registrationFlow.QueryFlowByFlowID(ctx, flow); err != nil {
 		return nil, err
 	}
 	if flow.IsExpired() {
 		return nil, ErrFlowExpired
 	}
-
 	// check if sessionID in cookie match that in db
 	if providedSessionID != flow.SessionID {
 		return nil, ErrUnauthenticated
 	}
-	// check if session expired
-	preSessionData := &session.Session{ID: flow.SessionID}
-	if err = s.session.QuerySessionWithDevices(ctx, preSessionData); err != nil {
-		return nil, err
-	}
-	if preSessionData.IsExpired() {
-		return nil, ErrSessionExpired
-	}
 
-	UserDevice := &session.Device{IPAddress: ipAddress, UserAgent: userAgent, GeoLocation: "(unimplemented)", SessionID: preSessionData.ID}
-	if !preSessionData.DeviceExists(UserDevice) {
-		if err = s.session.InsertDevice(ctx, UserDevice); err != nil {
+	var passwordHash string
+	if riskErr == nil {
+		var err error
+		if passwordHash, err = s.checkCredentials(ctx, flow, ipAddress); err != nil {
 			return nil, err
 		}
 	}
 
-	// check if email exists
-	exist, err := s.identityRepo.EmailExists(ctx, flow.Identity.Emails[0].Value)
-	// look up the email in the database
+	var (
+		preSessionData *session.Session
+		newDevice      *session.Device
+		sessionModel   *session.Session
+	)
+	err := s.transactions.Run(ctx, func(ctx context.Context) error {
+		preSessionData, newDevice, sessionModel = nil, nil, nil
+
+		// check if session expired
+		preSessionData = &session.Session{ID: flow.SessionID}
+		if err := s.session.QuerySessionWithDevices(ctx, preSessionData); err != nil {
+			return err
+		}
+		if preSessionData.IsExpired() {
+			return ErrSessionExpired
+		}
+
+		userDevice := device
+		userDevice.SessionID = preSessionData.ID
+		if !preSessionData.DeviceExists(&userDevice) {
+			if err := s.session.InsertDevice(ctx, &userDevice); err != nil {
+				return err
+			}
+			newDevice = &userDevice
+		}
+		if riskErr != nil {
+			// commit the device and the denied attempt, the error is
+			// returned once committed
+			if s.auditLog == nil {
+				return nil
+			}
+			return s.auditLog.CreateEvent(ctx, &audit.Event{
+				Type:      audit.EventRegistration,
+				SessionID: preSessionData.ID,
+				IPAddress: ipAddress,
+				UserAgent: userAgent,
+				Outcome:   audit.OutcomeDenied,
+			})
+		}
+
+		// check if email exists
+		exist, err := s.identityRepo.EmailExists(ctx, flow.Identity.Emails[0].Value)
+		// look up the email in the database
+		if err != nil {
+			return err
+		}
+		if exist {
+			return ErrEmailExists
+		}
+
+		// create identity
+		newIdentity := &identity.Identity{
+			State:        identity.StateActive,
+			Emails:       []identity.Email{flow.Identity.Emails[0]},
+			Timezone:     flow.Identity.Timezone,
+			Locale:       flow.Identity.Locale,
+			PasswordHash: passwordHash,
+		}
+
+		if err := s.identityRepo.CreateIdentity(ctx, newIdentity); err != nil {
+			// the email was registered since it was looked up
+			if errors.Is(err, store.ErrAlreadyExists) {
+				return ErrEmailExists
+			}
+			return err
+		}
+		if s.auditLog != nil {
+			// the registration happened in the session of the flow
+			err := s.auditLog.CreateEvent(ctx, &audit.Event{
+				Type:       audit.EventRegistration,
+				IdentityID: newIdentity.ID,
+				SessionID:  preSessionData.ID,
+				IPAddress:  ipAddress,
+				UserAgent:  userAgent,
+				Outcome:    audit.OutcomeSuccess,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		// create session
+		sessionModel = &session.Session{
+			Active:                      true,
+			AuthenticatorAssuranceLevel: 1,
+			ExpiryInterval:              s.config.SessionExpiryInterval,
+			Devices:                     []session.Device{device},
+			Identity:                    newIdentity,
+		}
+		return s.session.CreateSession(ctx, sessionModel)
+	})
 	if err != nil {
 		return nil, err
 	}
-	if exist {
-		return nil, ErrEmailExists
+
+	if s.devices != nil && newDevice != nil && riskErr == nil {
+		s.devices.SignedIn(ctx, preSessionData, newDevice)
+	}
+	if riskErr != nil {
+		return nil, riskErr
+	}
+	if s.config.Observer != nil {
+		s.config.Observer.RegistrationCompleted()
+		s.config.Observer.SessionIssued(sessionModel.AuthenticatorAssuranceLevel)
+	}
+	if s.devices != nil {
+		s.devices.Registered(ctx, sessionModel)
+	}
+
+	return sessionModel, nil
+}
+
+// checkCredentials checks the proof of work and the CAPTCHA token of flow
+// before doing any expensive work, then the strength of its password, and
+// returns the hash of the password.
+func (s *service) checkCredentials(ctx context.Context, flow *registration.Flow, ipAddress netip.Addr) (string, error) {
+	if flow.Challenge != nil && !flow.Challenge.Verify(flow.FlowID, flow.Solution) {
+		return "", ErrChallengeFailed
+	}
+	if s.captcha != nil {
+		if err := s.captcha.Verify(ctx, flow.CaptchaToken, ipAddress); err != nil {
+			return "", err
+		}
 	}
 
 	// check if password is insecure
 	if !identity.IsSecure(flow.Password) {
-		return nil, ErrInsecurePassword
+		return "", ErrInsecurePassword
 	}
 
-	// create identity
-	newIdentity := &identity.Identity{
-		State:    identity.StateActive,
-		Emails:   []identity.Email{flow.Identity.Emails[0]},
-		Timezone: flow.Identity.Timezone,
+	ctx, span := tracer.Start(ctx, "identity.CreateHash")
+	defer span.End()
+	passwordHash, err := s.hasher.CreateHash(ctx, flow.Password)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
 	}
+	return passwordHash, nil
+}
 
-	// password hash
-	newIdentity.PasswordHash, err = identity.CreateHash(flow.Password, identity.DefaultParams)
+// newDevice describes the device a request comes from. Locating it is best
+// effort: a failed lookup is logged and leaves the device without a location.
+func (s *service) newDevice(ctx context.Context, ipAddress netip.Addr, userAgent string) session.Device {
+	device := session.NewDevice(ipAddress, userAgent)
+	if s.geo == nil {
+		return device
+	}
+	location, err := s.geo.Lookup(ctx, ipAddress)
 	if err != nil {
-		return nil, err
+		slog.WarnContext(ctx, "error looking up device location", logging.Error(err))
+		return device
 	}
+	device.SetLocation(location)
+	return device
+}
 
-	if err = s.identityRepo.CreateIdentity(ctx, newIdentity); err != nil {
-		return nil, err
+// assess scores device and records the assessment on it. It returns
+// ErrRiskBlocked when the device is blocked. Step-up decisions are only
+// recorded, as there is no stronger authentication to step up to yet, and a
+// failed assessment is logged and lets the device through.
+func (s *service) assess(ctx context.Context, identityID string, device *session.Device) error {
+	if s.risk == nil {
+		return nil
 	}
-
-	// create session
-	sessionModel := &session.Session{
-		Active:                      true,
-		AuthenticatorAssuranceLevel: 1,
-		ExpiryInterval:              s.sessionInterval,
-		Devices: []session.Device{
-			{IPAddress: ipAddress, UserAgent: userAgent, GeoLocation: "(unimplemented)"}, // TODO ip2Geolocation
-		},
-		Identity: newIdentity,
+	assessment, err := s.risk.Assess(ctx, identityID, device)
+	if err != nil {
+		slog.WarnContext(ctx, "error assessing device risk", logging.Error(err))
+		return nil
 	}
-	if err := s.session.CreateSession(ctx, sessionModel); err != nil {
-		return nil, err
+	device.Risk = assessment.Risk()
+	switch assessment.Decision {
+	case risk.DecisionBlock:
+		slog.WarnContext(ctx, "device blocked by risk policy", slog.Int("score", assessment.Score), slog.Any("reasons", assessment.Reasons))
+		return ErrRiskBlocked
+	case risk.DecisionStepUp:
+		slog.InfoContext(ctx, "device requires step-up authentication", slog.Int("score", assessment.Score), slog.Any("reasons", assessment.Reasons))
 	}
-
-	return sessionModel, nil
+	return nil
 }
@@ -2,20 +2,24 @@ package registration
 
 import (
 	"context"
+	"errors"
 	"net/netip"
-	"os"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 
+	"gitlab.mreg.io/my-registry/auth/domain/audit"
 	"gitlab.mreg.io/my-registry/auth/domain/identity"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 
 	"gitlab.mreg.io/my-registry/auth/domain/registration"
+	"gitlab.mreg.io/my-registry/auth/domain/risk"
 	"gitlab.mreg.io/my-registry/auth/domain/session"
+	"gitlab.mreg.io/my-registry/auth/domain/store"
+	"gitlab.mreg.io/my-registry/auth/service/devicealert"
 )
 
 type mockSessionRepository struct {
@@ -47,6 +51,11 @@ func (m *mockSessionRepository) InsertDevice(ctx context.Context, newDevice *ses
 	return args.Error(0)
 }
 
+func (m *mockSessionRepository) RememberDevice(ctx context.Context, identityID string, device *session.Device) (bool, error) {
+	args := m.Called(ctx, identityID, device)
+	return args.Bool(0), args.Error(1)
+}
+
 type mockFlowRepository struct {
 	mock.Mock
 }
@@ -61,6 +70,60 @@ func (m *mockFlowRepository) QueryFlowByFlowID(ctx context.Context, flow *regist
 	return args.Error(0)
 }
 
+func (m *mockFlowRepository) CountRecentFlows(ctx context.Context, network netip.Prefix, since time.Time) (int, error) {
+	args := m.Called(ctx, network, since)
+	return args.Int(0), args.Error(1)
+}
+
+type mockCaptchaVerifier struct {
+	mock.Mock
+}
+
+func (m *mockCaptchaVerifier) Verify(ctx context.Context, token string, remoteIP netip.Addr) error {
+	args := m.Called(ctx, token, remoteIP)
+	return args.Error(0)
+}
+
+type mockGeoResolver struct {
+	mock.Mock
+}
+
+func (m *mockGeoResolver) Lookup(ctx context.Context, addr netip.Addr) (session.Location, error) {
+	args := m.Called(ctx, addr)
+	return args.Get(0).(session.Location), args.Error(1)
+}
+
+type mockDeviceAlerts struct {
+	devicealert.Service
+	mock.Mock
+}
+
+func (m *mockDeviceAlerts) Registered(ctx context.Context, session *session.Session) {
+	m.Called(ctx, session)
+}
+
+func (m *mockDeviceAlerts) SignedIn(ctx context.Context, session *session.Session, device *session.Device) {
+	m.Called(ctx, session, device)
+}
+
+type mockRiskService struct {
+	mock.Mock
+}
+
+func (m *mockRiskService) Assess(ctx context.Context, identityID string, device *session.Device) (risk.Assessment, error) {
+	args := m.Called(ctx, identityID, device)
+	return args.Get(0).(risk.Assessment), args.Error(1)
+}
+
+type mockAuditRepository struct {
+	audit.Repository
+	mock.Mock
+}
+
+func (m *mockAuditRepository) CreateEvent(ctx context.Context, event *audit.Event) error {
+	return m.Called(ctx, event).Error(0)
+}
+
 type mockIdentityRepository struct {
 	mock.Mock
 }
@@ -80,31 +143,76 @@ func (m *mockIdentityRepository) QueryEmail(ctx context.Context, email *identity
 	return args.Error(0)
 }
 
+func (m *mockIdentityRepository) QueryEmails(ctx context.Context, id *identity.Identity) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// directRunner runs units of work directly on the mocked repositories.
+type directRunner struct{}
+
+func (directRunner) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// retryingRunner runs units of work twice, as if the first transaction had
+// failed to serialize when committing.
+type retryingRunner struct{}
+
+func (retryingRunner) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	return fn(ctx)
+}
+
+// countingObserver counts the steps it is told of.
+type countingObserver struct {
+	started, completed int
+	sessions           map[uint8]int
+}
+
+func (o *countingObserver) RegistrationStarted()   { o.started++ }
+func (o *countingObserver) RegistrationCompleted() { o.completed++ }
+func (o *countingObserver) SessionIssued(aal uint8) {
+	if o.sessions == nil {
+		o.sessions = make(map[uint8]int)
+	}
+	o.sessions[aal]++
+}
+
 type serviceTestSuite struct {
 	suite.Suite
 	service                Service
 	mockSessionRepository  *mockSessionRepository
 	mockFlowRepository     *mockFlowRepository
 	mockIdentityRepository *mockIdentityRepository
+	mockCaptchaVerifier    *mockCaptchaVerifier
+	hasher                 *identity.Hasher
+	config                 Config
 }
 
 func (s *serviceTestSuite) SetupSuite() {
-	s.T().Setenv("SESSION_EXPIRY_INTERVAL", "1h")
-	s.T().Setenv("REGISTRATION_EXPIRY_INTERVAL", "1h")
-	s.T().Setenv("CSRF_SECRET", "Wryyyyyyyy")
+	s.config = Config{SessionExpiryInterval: time.Hour, FlowExpiryInterval: time.Hour}
 	s.mockSessionRepository = new(mockSessionRepository)
 	s.mockFlowRepository = new(mockFlowRepository)
 	s.mockIdentityRepository = new(mockIdentityRepository)
+	s.mockCaptchaVerifier = new(mockCaptchaVerifier)
 
-	s.service = NewService(s.mockSessionRepository, s.mockFlowRepository, s.mockIdentityRepository)
+	s.hasher = identity.NewHasher(identity.DefaultHasherConfig)
+
+	s.service = NewService(s.mockSessionRepository, s.mockFlowRepository, s.mockIdentityRepository, nil, directRunner{}, s.hasher, nil, nil, nil, nil, s.config)
+}
+
+func (s *serviceTestSuite) TearDownSuite() {
+	s.hasher.Close()
 }
 
 func (s *serviceTestSuite) TestCreateRegistrationFlow() {
 	IPAddress, _ := netip.ParseAddr("192.168.1.1")
 	userAgent := "Tor"
 	sessionID := "123456789"
-	interval, err := time.ParseDuration(os.Getenv("SESSION_EXPIRY_INTERVAL"))
-	s.Require().NoError(err)
+	interval := s.config.SessionExpiryInterval
 	issuedAt, err := time.Parse(time.UnixDate, "Wed Feb 25 11:06:39 PST 1069")
 	s.Require().NoError(err)
 	expiresAt, err := time.Parse(time.UnixDate, "Wed Feb 26 11:06:39 PST 2069")
@@ -114,11 +222,10 @@ func (s *serviceTestSuite) TestCreateRegistrationFlow() {
 		AuthenticatorAssuranceLevel: 0,
 		ExpiryInterval:              interval,
 		Devices: []session.Device{
-			{IPAddress: IPAddress, UserAgent: userAgent, GeoLocation: "(unimplemented)"}, // TODO ip2Geolocation
+			session.NewDevice(IPAddress, userAgent),
 		},
 	}
-	registrationInterval, err := time.ParseDuration(os.Getenv("REGISTRATION_EXPIRY_INTERVAL"))
-	s.Require().NoError(err)
+	registrationInterval := s.config.FlowExpiryInterval
 	flow := &registration.Flow{SessionID: sessionID, Interval: registrationInterval}
 	ctx := context.Background()
 	call1 := s.mockSessionRepository.
@@ -155,17 +262,17 @@ func (s *serviceTestSuite) TestCreateRegistrationFlow() {
 }
 
 var (
-	userAgent = "Mozilla/5.0"
-	sessionID = "123456789"
-	email     = "test@example.com"
-	password  = "!Securepassword123"
-	timezone  = "America/New_York"
+	userAgent  = "Mozilla/5.0"
+	sessionID  = "123456789"
+	identityID = "01928f5e-7a3b-7c1d-9e2f-3a4b5c6d7e8f"
+	email      = "test@example.com"
+	password   = "!Securepassword123"
+	timezone   = "America/New_York"
 )
 
 func (s *serviceTestSuite) TestCompleteRegistrationFlow_Success() {
 	ipAddress, _ := netip.ParseAddr("192.168.1.1")
-	sessionInterval, err := time.ParseDuration(os.Getenv("SESSION_EXPIRY_INTERVAL"))
-	s.Require().NoError(err)
+	sessionInterval := s.config.SessionExpiryInterval
 	idCreateTime, err := time.Parse(time.UnixDate, "Wed Feb 25 11:06:39 PST 1069")
 	s.Require().NoError(err)
 	// Arrange: create a valid flow and session
@@ -174,6 +281,7 @@ func (s *serviceTestSuite) TestCompleteRegistrationFlow_Success() {
 		Identity: &identity.Identity{
 			Emails:   []identity.Email{{Value: email}},
 			Timezone: timezone,
+			Locale:   "fr",
 		},
 		Password: password,
 	}
@@ -188,7 +296,7 @@ func (s *serviceTestSuite) TestCompleteRegistrationFlow_Success() {
 		AuthenticatorAssuranceLevel: 1,
 		ExpiryInterval:              sessionInterval,
 		Devices: []session.Device{
-			{IPAddress: ipAddress, UserAgent: userAgent, GeoLocation: "(unimplemented)"}, // TODO ip2Geolocation
+			session.NewDevice(ipAddress, userAgent),
 		},
 		Identity: newIdentity,
 	}
@@ -214,13 +322,25 @@ func (s *serviceTestSuite) TestCompleteRegistrationFlow_Success() {
 	s.mockIdentityRepository.On("CreateIdentity", ctx, mock.Anything).
 		Run(func(args mock.Arguments) {
 			newIdentity := args.Get(1).(*identity.Identity)
+			newIdentity.ID = identityID
 			newIdentity.CreateTime = idCreateTime // too lazy to init other fields
 		}).
 		Return(nil).Once()
+	// The registration is audited with the identity, in its transaction
+	auditLog := new(mockAuditRepository)
+	auditLog.On("CreateEvent", ctx, &audit.Event{
+		Type:       audit.EventRegistration,
+		IdentityID: identityID,
+		SessionID:  sessionID,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		Outcome:    audit.OutcomeSuccess,
+	}).Return(nil).Once()
 	s.mockSessionRepository.On("CreateSession", ctx, mock.Anything).Return(nil).Once()
+	service := NewService(s.mockSessionRepository, s.mockFlowRepository, s.mockIdentityRepository, auditLog, directRunner{}, s.hasher, nil, nil, nil, nil, s.config)
 	// Act: call CompleteRegistrationFlow
 	name := "registrationFlows/" + uuid.New().String()
-	sessionModel, err := s.service.CompleteRegistrationFlow(ctx, registrationFlow, name, ipAddress, userAgent)
+	sessionModel, err := service.CompleteRegistrationFlow(ctx, registrationFlow, name, ipAddress, userAgent)
 	s.Require().NoError(err)
 	expectedSession.Identity = sessionModel.Identity
 	// Assert: Ensure no error and valid session returned
@@ -228,11 +348,13 @@ func (s *serviceTestSuite) TestCompleteRegistrationFlow_Success() {
 	s.NotNil(sessionModel)
 	s.Equal(sessionModel.Identity.Emails[0].Value, email)
 	s.Equal(timezone, sessionModel.Identity.Timezone)
+	s.Equal("fr", sessionModel.Identity.Locale)
 	s.Equal(expectedSession, sessionModel)
 	// Assert: Ensure mocks were called
 	s.mockFlowRepository.AssertExpectations(s.T())
 	s.mockSessionRepository.AssertExpectations(s.T())
 	s.mockIdentityRepository.AssertExpectations(s.T())
+	auditLog.AssertExpectations(s.T())
 }
 
 func (s *serviceTestSuite) TestCompleteRegistrationFlow_FlowExpire() {
@@ -395,19 +517,17 @@ func (s *serviceTestSuite) TestCompleteRegistrationFlow_EmailExists() {
 	s.mockIdentityRepository.AssertExpectations(s.T())
 }
 
-func (s *serviceTestSuite) TestCompleteRegistrationFlow_WeakPassword() {
+func (s *serviceTestSuite) TestCompleteRegistrationFlow_EmailRegisteredConcurrently() {
 	ipAddress, _ := netip.ParseAddr("192.168.1.1")
-	// Arrange: create a valid flow and session
 	registrationFlow := &registration.Flow{
 		SessionID: sessionID,
 		Identity: &identity.Identity{
 			Emails:   []identity.Email{{Value: email}},
 			Timezone: timezone,
 		},
-		Password: "NoNumberPassword",
+		Password: password,
 	}
 	ctx := context.Background()
-	// Mocking expected behavior for valid flow and session
 	s.mockFlowRepository.On("QueryFlowByFlowID", ctx, registrationFlow).
 		Run(func(args mock.Arguments) {
 			registrationFlow := args.Get(1).(*registration.Flow)
@@ -417,14 +537,42 @@ func (s *serviceTestSuite) TestCompleteRegistrationFlow_WeakPassword() {
 	s.mockSessionRepository.On("QuerySessionWithDevices", ctx, mock.Anything).
 		Run(func(args mock.Arguments) {
 			preSession := args.Get(1).(*session.Session)
-			preSession.Devices = []session.Device{
-				{GeoLocation: "not the same anyway"},
-			}
 			preSession.ExpiresAt = time.Now().Add(900 * time.Hour)
 		}).
 		Return(nil).Once()
 	s.mockSessionRepository.On("InsertDevice", ctx, mock.Anything).Return(nil).Once()
-	s.mockIdentityRepository.On("EmailExists", ctx, registrationFlow.Identity.Emails[0].Value).Return(false, nil).Once() // Email doesn't exist
+	s.mockIdentityRepository.On("EmailExists", ctx, email).Return(false, nil).Once()
+	// the primary key of the emails catches what the lookup missed
+	duplicate := &store.ConstraintError{Kind: store.ErrAlreadyExists, Table: "emails", Constraint: "emails_pkey"}
+	s.mockIdentityRepository.On("CreateIdentity", ctx, mock.Anything).Return(duplicate).Once()
+
+	name := "registrationFlows/" + uuid.New().String()
+	_, err := s.service.CompleteRegistrationFlow(ctx, registrationFlow, name, ipAddress, userAgent)
+	s.Require().ErrorIs(err, ErrEmailExists)
+	s.mockFlowRepository.AssertExpectations(s.T())
+	s.mockSessionRepository.AssertExpectations(s.T())
+	s.mockIdentityRepository.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestCompleteRegistrationFlow_WeakPassword() {
+	ipAddress, _ := netip.ParseAddr("192.168.1.1")
+	// Arrange: create a valid flow and session
+	registrationFlow := &registration.Flow{
+		SessionID: sessionID,
+		Identity: &identity.Identity{
+			Emails:   []identity.Email{{Value: email}},
+			Timezone: timezone,
+		},
+		Password: "NoNumberPassword",
+	}
+	ctx := context.Background()
+	// Mocking expected behavior for valid flow and session
+	s.mockFlowRepository.On("QueryFlowByFlowID", ctx, registrationFlow).
+		Run(func(args mock.Arguments) {
+			registrationFlow := args.Get(1).(*registration.Flow)
+			registrationFlow.ExpiresAt = time.Now().Add(900 * time.Hour)
+		}).
+		Return(nil).Once()
 	// Act: call CompleteRegistrationFlow
 	var err error
 	name := "registrationFlows/" + uuid.New().String()
@@ -462,6 +610,314 @@ func (s *serviceTestSuite) TestCompleteRegistrationFlow_NoNameInFlow() {
 	s.mockIdentityRepository.AssertExpectations(s.T())
 }
 
+func (s *serviceTestSuite) TestCreateRegistrationFlow_GeoLocation() {
+	ipAddress := netip.MustParseAddr("81.2.69.142")
+	geo := new(mockGeoResolver)
+	service := NewService(s.mockSessionRepository, s.mockFlowRepository, s.mockIdentityRepository, nil, directRunner{}, s.hasher, nil, geo, nil, nil, s.config)
+	ctx := context.Background()
+
+	location := session.Location{CountryCode: "GB", Region: "England", City: "London", ASN: 20712}
+	geo.On("Lookup", ctx, ipAddress).Return(location, nil).Once()
+	var created *session.Session
+	call1 := s.mockSessionRepository.On("CreateSession", ctx, mock.Anything).
+		Run(func(args mock.Arguments) { created = args.Get(1).(*session.Session) }).
+		Return(nil).
+		Twice()
+	call2 := s.mockFlowRepository.On("CreateFlow", ctx, mock.Anything).Return(nil).Twice()
+
+	_, _, err := service.CreateRegistrationFlow(ctx, ipAddress, userAgent)
+	s.Require().NoError(err)
+	s.Equal(location, created.Devices[0].Location)
+	s.Equal("London, England, GB", created.Devices[0].GeoLocation)
+
+	// A failed lookup does not fail the flow
+	geo.On("Lookup", ctx, ipAddress).Return(session.Location{}, errors.New("corrupt database")).Once()
+	_, _, err = service.CreateRegistrationFlow(ctx, ipAddress, userAgent)
+	s.Require().NoError(err)
+	s.Zero(created.Devices[0].Location)
+	s.Empty(created.Devices[0].GeoLocation)
+
+	geo.AssertExpectations(s.T())
+	call1.Unset()
+	call2.Unset()
+}
+
+func (s *serviceTestSuite) TestCompleteRegistrationFlow_RemembersDevice() {
+	ipAddress := netip.MustParseAddr("192.168.1.1")
+	devices := new(mockDeviceAlerts)
+	service := NewService(s.mockSessionRepository, s.mockFlowRepository, s.mockIdentityRepository, nil, directRunner{}, s.hasher, nil, nil, devices, nil, s.config)
+	registrationFlow := &registration.Flow{
+		SessionID: sessionID,
+		Identity: &identity.Identity{
+			Emails:   []identity.Email{{Value: email}},
+			Timezone: timezone,
+		},
+		Password: password,
+	}
+	ctx := context.Background()
+	s.mockFlowRepository.On("QueryFlowByFlowID", ctx, registrationFlow).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*registration.Flow).ExpiresAt = time.Now().Add(time.Hour)
+		}).
+		Return(nil).Once()
+	s.mockSessionRepository.On("QuerySessionWithDevices", ctx, mock.Anything).
+		Run(func(args mock.Arguments) {
+			preSession := args.Get(1).(*session.Session)
+			preSession.Devices = []session.Device{session.NewDevice(ipAddress, userAgent)}
+			preSession.ExpiresAt = time.Now().Add(time.Hour)
+		}).
+		Return(nil).Once()
+	s.mockIdentityRepository.On("EmailExists", ctx, email).Return(false, nil).Once()
+	s.mockIdentityRepository.On("CreateIdentity", ctx, mock.Anything).Return(nil).Once()
+	s.mockSessionRepository.On("CreateSession", ctx, mock.Anything).Return(nil).Once()
+	devices.On("Registered", ctx, mock.Anything).Once()
+
+	sessionModel, err := service.CompleteRegistrationFlow(ctx, registrationFlow, "registrationFlows/"+uuid.New().String(), ipAddress, userAgent)
+	s.Require().NoError(err)
+
+	// The device of the registration is known from the start, and the
+	// unchanged pre-session device is not signed in again
+	devices.AssertExpectations(s.T())
+	s.Same(sessionModel, devices.Calls[0].Arguments.Get(1))
+	devices.AssertNotCalled(s.T(), "SignedIn", mock.Anything, mock.Anything, mock.Anything)
+	s.mockSessionRepository.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestCreateRegistrationFlow_Risk() {
+	ipAddress := netip.MustParseAddr("192.0.2.1")
+	riskService := new(mockRiskService)
+	service := NewService(s.mockSessionRepository, s.mockFlowRepository, s.mockIdentityRepository, nil, directRunner{}, s.hasher, nil, nil, nil, riskService, s.config)
+	ctx := context.Background()
+
+	// A blocked device stores nothing
+	calls := len(s.mockSessionRepository.Calls)
+	riskService.On("Assess", ctx, "", mock.Anything).
+		Return(risk.Assessment{Score: 100, Reasons: []risk.Reason{risk.ReasonDenylistedIP}, Decision: risk.DecisionBlock}, nil).
+		Once()
+	_, _, err := service.CreateRegistrationFlow(ctx, ipAddress, userAgent)
+	s.ErrorIs(err, ErrRiskBlocked)
+	s.Len(s.mockSessionRepository.Calls, calls)
+
+	// Other decisions are stored with the device
+	var created *session.Session
+	call1 := s.mockSessionRepository.On("CreateSession", ctx, mock.Anything).
+		Run(func(args mock.Arguments) { created = args.Get(1).(*session.Session) }).
+		Return(nil).Twice()
+	call2 := s.mockFlowRepository.On("CreateFlow", ctx, mock.Anything).Return(nil).Twice()
+	riskService.On("Assess", ctx, "", mock.Anything).
+		Return(risk.Assessment{Score: 60, Reasons: []risk.Reason{risk.ReasonImpossibleTravel}, Decision: risk.DecisionStepUp}, nil).
+		Once()
+	_, _, err = service.CreateRegistrationFlow(ctx, ipAddress, userAgent)
+	s.Require().NoError(err)
+	s.Equal(session.Risk{Score: 60, Decision: "step_up", Reasons: []string{"impossible_travel"}}, created.Devices[0].Risk)
+
+	// A failed assessment lets the device through
+	riskService.On("Assess", ctx, "", mock.Anything).Return(risk.Assessment{}, errors.New("connection refused")).Once()
+	_, _, err = service.CreateRegistrationFlow(ctx, ipAddress, userAgent)
+	s.Require().NoError(err)
+	s.Zero(created.Devices[0].Risk)
+
+	riskService.AssertExpectations(s.T())
+	call1.Unset()
+	call2.Unset()
+}
+
+func (s *serviceTestSuite) TestCompleteRegistrationFlow_RiskBlocked() {
+	ipAddress := netip.MustParseAddr("192.0.2.1")
+	riskService := new(mockRiskService)
+	auditLog := new(mockAuditRepository)
+	service := NewService(s.mockSessionRepository, s.mockFlowRepository, s.mockIdentityRepository, auditLog, directRunner{}, s.hasher, nil, nil, nil, riskService, s.config)
+	registrationFlow := &registration.Flow{
+		FlowID:    uuid.New().String(),
+		SessionID: sessionID,
+		Identity: &identity.Identity{
+			Emails:   []identity.Email{{Value: email}},
+			Timezone: timezone,
+		},
+		Password: password,
+	}
+	ctx := context.Background()
+	riskService.On("Assess", ctx, "", mock.Anything).
+		Return(risk.Assessment{Score: 100, Reasons: []risk.Reason{risk.ReasonDenylistedIP}, Decision: risk.DecisionBlock}, nil).
+		Once()
+	s.mockFlowRepository.On("QueryFlowByFlowID", ctx, registrationFlow).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*registration.Flow).ExpiresAt = time.Now().Add(time.Hour)
+		}).
+		Return(nil).Once()
+	s.mockSessionRepository.On("QuerySessionWithDevices", ctx, mock.Anything).
+		Run(func(args mock.Arguments) {
+			preSession := args.Get(1).(*session.Session)
+			preSession.ID = sessionID
+			preSession.ExpiresAt = time.Now().Add(time.Hour)
+		}).
+		Return(nil).Once()
+	s.mockSessionRepository.On("InsertDevice", ctx, mock.Anything).Return(nil).Once()
+	// The attempt is kept for review with the device
+	auditLog.On("CreateEvent", ctx, &audit.Event{
+		Type:      audit.EventRegistration,
+		SessionID: sessionID,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		Outcome:   audit.OutcomeDenied,
+	}).Return(nil).Once()
+
+	_, err := service.CompleteRegistrationFlow(ctx, registrationFlow, "registrationFlows/"+uuid.New().String(), ipAddress, userAgent)
+	s.Require().ErrorIs(err, ErrRiskBlocked)
+	auditLog.AssertExpectations(s.T())
+	s.mockSessionRepository.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestCreateRegistrationFlow_Challenge() {
+	ipAddress, _ := netip.ParseAddr("192.168.1.1")
+	policy := registration.ChallengePolicy{BaseDifficulty: 16, MaxDifficulty: 24, Threshold: 20, Window: 10 * time.Minute}
+	config := s.config
+	config.ChallengePolicy = policy
+	service := NewService(s.mockSessionRepository, s.mockFlowRepository, s.mockIdentityRepository, nil, directRunner{}, s.hasher, nil, nil, nil, nil, config)
+	ctx := context.Background()
+
+	call1 := s.mockSessionRepository.On("CreateSession", ctx, mock.Anything).Return(nil).Once()
+	call2 := s.mockFlowRepository.
+		On("CountRecentFlows", ctx, netip.MustParsePrefix("192.168.1.0/24"), mock.Anything).
+		Return(40, nil).
+		Once()
+	call3 := s.mockFlowRepository.On("CreateFlow", ctx, mock.Anything).Return(nil).Once()
+
+	flow, _, err := service.CreateRegistrationFlow(ctx, ipAddress, userAgent)
+	s.Require().NoError(err)
+	s.Require().NotNil(flow.Challenge)
+	s.NotEmpty(flow.Challenge.Seed)
+	s.Equal(uint8(18), flow.Challenge.Difficulty)
+	s.mockFlowRepository.AssertExpectations(s.T())
+
+	call1.Unset()
+	call2.Unset()
+	call3.Unset()
+}
+
+func (s *serviceTestSuite) TestCompleteRegistrationFlow_ChallengeFailed() {
+	ipAddress, _ := netip.ParseAddr("192.168.1.1")
+	registrationFlow := &registration.Flow{
+		SessionID: sessionID,
+		Identity: &identity.Identity{
+			Emails:   []identity.Email{{Value: email}},
+			Timezone: timezone,
+		},
+		Password: password,
+		Solution: "not a solution",
+	}
+	ctx := context.Background()
+	s.mockFlowRepository.On("QueryFlowByFlowID", ctx, registrationFlow).
+		Run(func(args mock.Arguments) {
+			registrationFlow := args.Get(1).(*registration.Flow)
+			registrationFlow.ExpiresAt = time.Now().Add(900 * time.Hour)
+			// Practically impossible to solve by accident
+			registrationFlow.Challenge = &registration.Challenge{Seed: "seed", Difficulty: 64}
+		}).
+		Return(nil).Once()
+
+	name := "registrationFlows/" + uuid.New().String()
+	_, err := s.service.CompleteRegistrationFlow(ctx, registrationFlow, name, ipAddress, userAgent)
+	s.Require().ErrorIs(err, ErrChallengeFailed)
+
+	// Assert: Ensure neither the session nor the email has been looked up
+	s.mockFlowRepository.AssertExpectations(s.T())
+	s.mockSessionRepository.AssertExpectations(s.T())
+	s.mockIdentityRepository.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestCompleteRegistrationFlow_CaptchaFailed() {
+	ipAddress, _ := netip.ParseAddr("192.168.1.1")
+	service := NewService(s.mockSessionRepository, s.mockFlowRepository, s.mockIdentityRepository, nil, directRunner{}, s.hasher, s.mockCaptchaVerifier, nil, nil, nil, s.config)
+	registrationFlow := &registration.Flow{
+		SessionID: sessionID,
+		Identity: &identity.Identity{
+			Emails:   []identity.Email{{Value: email}},
+			Timezone: timezone,
+		},
+		Password:     password,
+		CaptchaToken: "invalid",
+	}
+	ctx := context.Background()
+	s.mockFlowRepository.On("QueryFlowByFlowID", ctx, registrationFlow).
+		Run(func(args mock.Arguments) {
+			registrationFlow := args.Get(1).(*registration.Flow)
+			registrationFlow.ExpiresAt = time.Now().Add(900 * time.Hour)
+		}).
+		Return(nil).Once()
+	s.mockCaptchaVerifier.On("Verify", ctx, "invalid", ipAddress).Return(registration.ErrCaptchaFailed).Once()
+
+	name := "registrationFlows/" + uuid.New().String()
+	_, err := service.CompleteRegistrationFlow(ctx, registrationFlow, name, ipAddress, userAgent)
+	s.Require().ErrorIs(err, registration.ErrCaptchaFailed)
+
+	s.mockFlowRepository.AssertExpectations(s.T())
+	s.mockSessionRepository.AssertExpectations(s.T())
+	s.mockIdentityRepository.AssertExpectations(s.T())
+	s.mockCaptchaVerifier.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestCompleteRegistrationFlow_Retried() {
+	ipAddress, _ := netip.ParseAddr("192.168.1.1")
+	devices := new(mockDeviceAlerts)
+	observer := new(countingObserver)
+	config := s.config
+	config.Observer = observer
+	service := NewService(s.mockSessionRepository, s.mockFlowRepository, s.mockIdentityRepository, nil, retryingRunner{}, s.hasher, s.mockCaptchaVerifier, nil, devices, nil, config)
+	registrationFlow := &registration.Flow{
+		SessionID: sessionID,
+		Identity: &identity.Identity{
+			Emails:   []identity.Email{{Value: email}},
+			Timezone: timezone,
+		},
+		Password:     password,
+		CaptchaToken: "valid",
+	}
+	ctx := context.Background()
+	s.mockFlowRepository.On("QueryFlowByFlowID", ctx, registrationFlow).
+		Run(func(args mock.Arguments) {
+			registrationFlow := args.Get(1).(*registration.Flow)
+			registrationFlow.ExpiresAt = time.Now().Add(900 * time.Hour)
+		}).
+		Return(nil).Once()
+	s.mockSessionRepository.On("QuerySessionWithDevices", ctx, mock.Anything).
+		Run(func(args mock.Arguments) {
+			preSession := args.Get(1).(*session.Session)
+			preSession.ExpiresAt = time.Now().Add(900 * time.Hour)
+		}).
+		Return(nil).Twice()
+	s.mockSessionRepository.On("InsertDevice", ctx, mock.Anything).Return(nil).Twice()
+	s.mockCaptchaVerifier.On("Verify", ctx, "valid", ipAddress).Return(nil).Once()
+	s.mockIdentityRepository.On("EmailExists", ctx, email).Return(false, nil).Twice()
+	var hashes []string
+	s.mockIdentityRepository.On("CreateIdentity", ctx, mock.Anything).
+		Run(func(args mock.Arguments) {
+			hashes = append(hashes, args.Get(1).(*identity.Identity).PasswordHash)
+		}).
+		Return(nil).Twice()
+	s.mockSessionRepository.On("CreateSession", ctx, mock.Anything).Return(nil).Twice()
+	devices.On("SignedIn", ctx, mock.Anything, mock.Anything).Once()
+	devices.On("Registered", ctx, mock.Anything).Once()
+
+	name := "registrationFlows/" + uuid.New().String()
+	sessionModel, err := service.CompleteRegistrationFlow(ctx, registrationFlow, name, ipAddress, userAgent)
+	s.Require().NoError(err)
+
+	// The flow is read, the CAPTCHA token spent and the password hashed only
+	// once, and the device alerts and counters wait for the commit
+	s.Require().Len(hashes, 2)
+	s.NotEmpty(hashes[0])
+	s.Equal(hashes[0], hashes[1])
+	s.Equal(hashes[1], sessionModel.Identity.PasswordHash)
+	s.Equal(1, observer.completed)
+	s.Equal(map[uint8]int{1: 1}, observer.sessions)
+	s.mockFlowRepository.AssertExpectations(s.T())
+	s.mockSessionRepository.AssertExpectations(s.T())
+	s.mockIdentityRepository.AssertExpectations(s.T())
+	s.mockCaptchaVerifier.AssertExpectations(s.T())
+	devices.AssertExpectations(s.T())
+}
+
 func TestServiceTestSuite(t *testing.T) {
 	suite.Run(t, new(serviceTestSuite))
 }
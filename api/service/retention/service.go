@@ -0,0 +1,94 @@
+// Package retention deletes the registration flows and anonymous sessions
+// that expired longer ago than the retention period, the sessions of
+// identities that expired longer ago than the history period, and the
+// outbox messages delivered or dead for as long as the retention period, in
+// batches. A lease keeps the replicas from reaping at the same time.
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/domain/retention"
+	"gitlab.mreg.io/my-registry/auth/poll"
+)
+
+// Reaped counts the rows deleted by one round of the reaper.
+type Reaped struct {
+	Flows    int
+	Sessions int
+	Messages int
+}
+
+type Service interface {
+	// Reap deletes a batch of expired flows, a batch of expired sessions
+	// and a batch of finished outbox messages, if this replica holds the
+	// lease, and returns how many of each were deleted.
+	Reap(ctx context.Context) (Reaped, error)
+	// Run reaps until ctx is done, waiting for Config.Interval whenever no
+	// batch was full.
+	Run(ctx context.Context)
+}
+
+// Config holds the settings of the reaper.
+type Config struct {
+	// Holder identifies this replica in the lease.
+	Holder string
+	// Retention is how long rows are kept after they have expired.
+	Retention time.Duration
+	// HistoryRetention is how long the sessions of identities are kept
+	// after they have expired, with the devices the risk checks compare new
+	// ones with. It is not shorter than Retention.
+	HistoryRetention time.Duration
+	BatchSize        int
+	Interval         time.Duration
+	// Lease is renewed before every batch. It should outlast Interval, so
+	// that the replica reaping keeps doing so.
+	Lease time.Duration
+}
+
+type service struct {
+	repo   retention.Repository
+	config Config
+	now    func() time.Time
+}
+
+func NewService(repo retention.Repository, config Config) Service {
+	return &service{repo, config, time.Now}
+}
+
+func (s *service) Reap(ctx context.Context) (Reaped, error) {
+	var reaped Reaped
+	held, err := s.repo.AcquireLease(ctx, retention.LeaseName, s.config.Holder, s.config.Lease)
+	if err != nil || !held {
+		return reaped, err
+	}
+
+	now := s.now()
+	before := now.Add(-s.config.Retention)
+	// Flows go first, as deleting a session deletes its flows as well
+	if reaped.Flows, err = s.repo.DeleteExpiredFlows(ctx, before, s.config.BatchSize); err != nil {
+		return reaped, err
+	}
+	historyBefore := now.Add(-s.config.HistoryRetention)
+	if reaped.Sessions, err = s.repo.DeleteExpiredSessions(ctx, before, historyBefore, s.config.BatchSize); err != nil {
+		return reaped, err
+	}
+	if reaped.Messages, err = s.repo.DeleteFinishedMessages(ctx, before, s.config.BatchSize); err != nil {
+		return reaped, err
+	}
+	if reaped != (Reaped{}) {
+		slog.DebugContext(ctx, "reaped expired rows",
+			slog.Int("flows", reaped.Flows), slog.Int("sessions", reaped.Sessions), slog.Int("messages", reaped.Messages))
+	}
+	return reaped, nil
+}
+
+func (s *service) Run(ctx context.Context) {
+	poll.Run(ctx, "reaping expired rows", s.config.Interval, func(ctx context.Context) (bool, error) {
+		reaped, err := s.Reap(ctx)
+		full := s.config.BatchSize
+		return reaped.Flows == full || reaped.Sessions == full || reaped.Messages == full, err
+	})
+}
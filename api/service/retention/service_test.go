@@ -0,0 +1,132 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/retention"
+)
+
+type mockRepository struct {
+	mock.Mock
+}
+
+func (m *mockRepository) AcquireLease(ctx context.Context, name string, holder string, duration time.Duration) (bool, error) {
+	args := m.Called(ctx, name, holder, duration)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockRepository) DeleteExpiredFlows(ctx context.Context, before time.Time, limit int) (int, error) {
+	args := m.Called(ctx, before, limit)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *mockRepository) DeleteExpiredSessions(ctx context.Context, before time.Time, historyBefore time.Time, limit int) (int, error) {
+	args := m.Called(ctx, before, historyBefore, limit)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *mockRepository) DeleteFinishedMessages(ctx context.Context, before time.Time, limit int) (int, error) {
+	args := m.Called(ctx, before, limit)
+	return args.Int(0), args.Error(1)
+}
+
+type serviceTestSuite struct {
+	suite.Suite
+	repo    *mockRepository
+	service *service
+	now     time.Time
+	config  Config
+}
+
+func (s *serviceTestSuite) SetupTest() {
+	s.now = time.Date(2026, time.October, 19, 18, 3, 44, 0, time.UTC)
+	s.repo = new(mockRepository)
+	s.config = Config{
+		Holder:           "01J9ZQ3M7YV6N0XK4T2B8C5D1E",
+		Retention:        24 * time.Hour,
+		HistoryRetention: 30 * 24 * time.Hour,
+		BatchSize:        100,
+		Interval:         time.Minute,
+		Lease:            2 * time.Minute,
+	}
+	s.service = NewService(s.repo, s.config).(*service)
+	s.service.now = func() time.Time { return s.now }
+}
+
+func (s *serviceTestSuite) lease(ctx context.Context, held bool) {
+	s.repo.On("AcquireLease", ctx, retention.LeaseName, s.config.Holder, s.config.Lease).Return(held, nil).Once()
+}
+
+func (s *serviceTestSuite) TestReap() {
+	ctx := context.Background()
+	before := s.now.Add(-s.config.Retention)
+	s.lease(ctx, true)
+	s.repo.On("DeleteExpiredFlows", ctx, before, s.config.BatchSize).Return(7, nil).Once()
+	historyBefore := s.now.Add(-s.config.HistoryRetention)
+	s.repo.On("DeleteExpiredSessions", ctx, before, historyBefore, s.config.BatchSize).Return(3, nil).Once()
+	s.repo.On("DeleteFinishedMessages", ctx, before, s.config.BatchSize).Return(5, nil).Once()
+
+	reaped, err := s.service.Reap(ctx)
+	s.Require().NoError(err)
+	s.Equal(Reaped{Flows: 7, Sessions: 3, Messages: 5}, reaped)
+	s.repo.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestReap_LeaseHeldElsewhere() {
+	ctx := context.Background()
+	s.lease(ctx, false)
+
+	reaped, err := s.service.Reap(ctx)
+	s.Require().NoError(err)
+	s.Zero(reaped)
+	s.repo.AssertNotCalled(s.T(), "DeleteExpiredFlows", mock.Anything, mock.Anything, mock.Anything)
+	s.repo.AssertNotCalled(s.T(), "DeleteExpiredSessions", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	s.repo.AssertNotCalled(s.T(), "DeleteFinishedMessages", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *serviceTestSuite) TestReap_Error() {
+	ctx := context.Background()
+	s.lease(ctx, true)
+	s.repo.On("DeleteExpiredFlows", ctx, mock.Anything, s.config.BatchSize).Return(0, errors.New("connection refused")).Once()
+
+	_, err := s.service.Reap(ctx)
+	s.Error(err)
+	s.repo.AssertNotCalled(s.T(), "DeleteExpiredSessions", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	s.repo.AssertNotCalled(s.T(), "DeleteFinishedMessages", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *serviceTestSuite) TestRun() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.lease(ctx, true)
+	s.lease(ctx, true)
+	// The full batch of flows is followed by another round right away
+	s.repo.On("DeleteExpiredFlows", ctx, mock.Anything, s.config.BatchSize).Return(s.config.BatchSize, nil).Once()
+	s.repo.On("DeleteExpiredFlows", ctx, mock.Anything, s.config.BatchSize).Return(0, nil).Once()
+	s.repo.On("DeleteExpiredSessions", ctx, mock.Anything, mock.Anything, s.config.BatchSize).Return(0, nil).Twice()
+	s.repo.On("DeleteFinishedMessages", ctx, mock.Anything, s.config.BatchSize).Return(0, nil).Once()
+	s.repo.On("DeleteFinishedMessages", ctx, mock.Anything, s.config.BatchSize).Return(0, nil).Run(func(mock.Arguments) {
+		cancel()
+	}).Once()
+
+	done := make(chan struct{})
+	go func() {
+		s.service.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		s.Fail("Run did not return once ctx was done")
+	}
+	s.repo.AssertExpectations(s.T())
+}
+
+func TestServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(serviceTestSuite))
+}
@@ -0,0 +1,63 @@
+// Package risk gathers the signals about a device and assesses them with
+// the risk policy.
+package risk
+
+import (
+	"context"
+	"time"
+
+	"gitlab.mreg.io/my-registry/auth/domain/lockout"
+	"gitlab.mreg.io/my-registry/auth/domain/risk"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+)
+
+type Service interface {
+	// Assess scores device before it is added to a session of identityID,
+	// which is empty for anonymous sessions.
+	Assess(ctx context.Context, identityID string, device *session.Device) (risk.Assessment, error)
+}
+
+type service struct {
+	history  risk.Repository
+	failures lockout.Repository
+	denylist risk.Denylist
+	policy   risk.Policy
+	now      func() time.Time
+}
+
+// NewService creates the risk service. Failed logins are only counted when
+// failures is not nil, and addresses only checked when denylist is not nil.
+func NewService(history risk.Repository, failures lockout.Repository, denylist risk.Denylist, policy risk.Policy) Service {
+	return &service{history, failures, denylist, policy, time.Now}
+}
+
+func (s *service) Assess(ctx context.Context, identityID string, device *session.Device) (risk.Assessment, error) {
+	in := risk.Input{Location: device.Location, Now: s.now()}
+	if s.denylist != nil {
+		in.Denylisted = s.denylist.Contains(device.IPAddress)
+	}
+
+	keys := []string{lockout.IPKey(device.IPAddress)}
+	if identityID != "" {
+		history, err := s.history.QueryHistory(ctx, identityID, device.Location)
+		if err != nil {
+			return risk.Assessment{}, err
+		}
+		in.History = history
+		keys = append(keys, lockout.IdentityKey(identityID))
+	}
+
+	if s.failures != nil {
+		for _, key := range keys {
+			state, err := s.failures.QueryState(ctx, key)
+			if err != nil {
+				return risk.Assessment{}, err
+			}
+			if in.Now.Sub(state.LastFailureAt) <= s.policy.FailedLoginWindow {
+				in.RecentFailures = max(in.RecentFailures, state.Failures)
+			}
+		}
+	}
+
+	return s.policy.Evaluate(in), nil
+}
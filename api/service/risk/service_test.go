@@ -0,0 +1,131 @@
+package risk
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"gitlab.mreg.io/my-registry/auth/domain/lockout"
+	"gitlab.mreg.io/my-registry/auth/domain/risk"
+	"gitlab.mreg.io/my-registry/auth/domain/session"
+)
+
+type mockHistoryRepository struct {
+	mock.Mock
+}
+
+func (m *mockHistoryRepository) QueryHistory(ctx context.Context, identityID string, location session.Location) (risk.History, error) {
+	args := m.Called(ctx, identityID, location)
+	return args.Get(0).(risk.History), args.Error(1)
+}
+
+type mockLockoutRepository struct {
+	lockout.Repository
+	mock.Mock
+}
+
+func (m *mockLockoutRepository) QueryState(ctx context.Context, key string) (lockout.State, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(lockout.State), args.Error(1)
+}
+
+var (
+	identityID = "c935b23d-6cb4-448a-814e-b42aec9ef6cf"
+	ipAddress  = netip.MustParseAddr("203.0.113.7")
+	london     = session.Location{CountryCode: "GB", ASN: 20712, Latitude: 51.5142, Longitude: -0.0931, AccuracyRadius: 20}
+	taipei     = session.Location{CountryCode: "TW", ASN: 3462, Latitude: 25.0478, Longitude: 121.5318, AccuracyRadius: 20}
+)
+
+type serviceTestSuite struct {
+	suite.Suite
+	history  *mockHistoryRepository
+	failures *mockLockoutRepository
+	service  *service
+	now      time.Time
+	device   session.Device
+}
+
+func (s *serviceTestSuite) SetupTest() {
+	s.now = time.Now()
+	s.history = new(mockHistoryRepository)
+	s.failures = new(mockLockoutRepository)
+	s.service = NewService(s.history, s.failures, risk.PrefixList{netip.MustParsePrefix("192.0.2.0/24")}, risk.DefaultPolicy).(*service)
+	s.service.now = func() time.Time { return s.now }
+	s.device = session.Device{IPAddress: ipAddress, Location: taipei}
+}
+
+func (s *serviceTestSuite) TestAssess_ImpossibleTravel() {
+	ctx := context.Background()
+	s.history.On("QueryHistory", ctx, identityID, taipei).
+		Return(risk.History{Devices: 3, Last: &risk.Sighting{Location: london, SeenAt: s.now.Add(-time.Hour)}, KnownCountry: true, KnownASN: true}, nil).
+		Once()
+	s.failures.On("QueryState", ctx, lockout.IPKey(ipAddress)).Return(lockout.State{}, nil).Once()
+	s.failures.On("QueryState", ctx, lockout.IdentityKey(identityID)).Return(lockout.State{}, nil).Once()
+
+	assessment, err := s.service.Assess(ctx, identityID, &s.device)
+	s.Require().NoError(err)
+	s.Equal([]risk.Reason{risk.ReasonImpossibleTravel}, assessment.Reasons)
+	s.Equal(risk.DecisionStepUp, assessment.Decision)
+	s.history.AssertExpectations(s.T())
+	s.failures.AssertExpectations(s.T())
+}
+
+func (s *serviceTestSuite) TestAssess_FailedLogins() {
+	ctx := context.Background()
+	s.history.On("QueryHistory", ctx, identityID, taipei).Return(risk.History{}, nil).Once()
+	s.failures.On("QueryState", ctx, lockout.IPKey(ipAddress)).
+		Return(lockout.State{Failures: 2, LastFailureAt: s.now.Add(-time.Minute)}, nil).
+		Once()
+	s.failures.On("QueryState", ctx, lockout.IdentityKey(identityID)).
+		Return(lockout.State{Failures: 7, LastFailureAt: s.now.Add(-time.Hour)}, nil).
+		Once()
+
+	assessment, err := s.service.Assess(ctx, identityID, &s.device)
+	s.Require().NoError(err)
+	s.Equal([]risk.Reason{risk.ReasonFailedLogins}, assessment.Reasons)
+
+	// Failures older than the window are forgotten
+	s.history.On("QueryHistory", ctx, identityID, taipei).Return(risk.History{}, nil).Once()
+	s.failures.On("QueryState", ctx, mock.Anything).
+		Return(lockout.State{Failures: 7, LastFailureAt: s.now.Add(-48 * time.Hour)}, nil).
+		Twice()
+	assessment, err = s.service.Assess(ctx, identityID, &s.device)
+	s.Require().NoError(err)
+	s.Empty(assessment.Reasons)
+}
+
+func (s *serviceTestSuite) TestAssess_Anonymous() {
+	ctx := context.Background()
+	s.device.IPAddress = netip.MustParseAddr("192.0.2.1")
+	s.failures.On("QueryState", ctx, lockout.IPKey(s.device.IPAddress)).Return(lockout.State{}, nil).Once()
+
+	assessment, err := s.service.Assess(ctx, "", &s.device)
+	s.Require().NoError(err)
+	s.Equal([]risk.Reason{risk.ReasonDenylistedIP}, assessment.Reasons)
+	s.Equal(risk.DecisionBlock, assessment.Decision)
+	s.history.AssertNotCalled(s.T(), "QueryHistory", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *serviceTestSuite) TestAssess_Error() {
+	ctx := context.Background()
+	s.history.On("QueryHistory", ctx, identityID, taipei).Return(risk.History{}, errors.New("connection refused")).Once()
+
+	_, err := s.service.Assess(ctx, identityID, &s.device)
+	s.Error(err)
+}
+
+func (s *serviceTestSuite) TestAssess_WithoutOptionalSignals() {
+	service := NewService(s.history, nil, nil, risk.DefaultPolicy)
+	assessment, err := service.Assess(context.Background(), "", &s.device)
+	s.Require().NoError(err)
+	s.Equal(risk.DecisionAllow, assessment.Decision)
+}
+
+func TestServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(serviceTestSuite))
+}
@@ -0,0 +1,60 @@
+// Package tracing configures the OpenTelemetry SDK for the auth server.
+//
+// Spans are started by the connect interceptor, carried through the services
+// in the request context and ended by the pgx tracer for every SQL query.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const ServiceName = "auth"
+
+// Names of the supported exporters.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// NewExporter creates the named exporter. The OTLP exporter sends spans over
+// HTTP and is configured by the standard OTEL_EXPORTER_OTLP_* variables; the
+// stdout one writes them to w for local use. It returns nil for "none".
+func NewExporter(ctx context.Context, name string, w io.Writer) (sdktrace.SpanExporter, error) {
+	switch name {
+	case ExporterNone:
+		return nil, nil
+	case ExporterOTLP:
+		return otlptracehttp.New(ctx)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(w), stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", name)
+	}
+}
+
+// NewTracerProvider batches spans to exporter. Requests are sampled at
+// sampleRatio: their server spans start new traces, as the sampling flag of
+// clients is not trusted, and the spans within a request such as database
+// queries follow its decision.
+func NewTracerProvider(exporter sdktrace.SpanExporter, sampleRatio float64) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(ServiceName))),
+	)
+}
+
+// Propagator reads and writes W3C trace context and baggage headers.
+func Propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
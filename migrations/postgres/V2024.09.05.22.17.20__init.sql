@@ -0,0 +1,86 @@
+-- IDs are ULIDs generated by the repositories, as PostgreSQL has no
+-- gen_random_ulid.
+CREATE TYPE identity_state AS ENUM ('active', 'suspended');
+
+CREATE TABLE identities
+(
+    id                UUID PRIMARY KEY,
+    state             identity_state                                       NOT NULL DEFAULT 'active',
+    full_name         VARCHAR(64),
+    display_name      VARCHAR(64),
+    avatar            VARCHAR(256),
+    timezone          VARCHAR(64)                                          NOT NULL,
+    create_time       TIMESTAMPTZ                                          NOT NULL DEFAULT current_timestamp,
+    update_time       TIMESTAMPTZ CHECK (update_time >= create_time)       NOT NULL DEFAULT current_timestamp,
+    state_update_time TIMESTAMPTZ CHECK (state_update_time >= create_time) NOT NULL DEFAULT current_timestamp
+);
+
+CREATE TABLE sessions
+(
+    id                            UUID PRIMARY KEY,
+    active                        BOOL                                        NOT NULL DEFAULT true,
+    authenticator_assurance_level SMALLINT CHECK (authenticator_assurance_level IS NULL OR
+                                                  authenticator_assurance_level BETWEEN 1 AND 3),
+    issued_at                     TIMESTAMPTZ                                 NOT NULL DEFAULT current_timestamp,
+    expires_at                    TIMESTAMPTZ CHECK (expires_at >= issued_at) NOT NULL,
+    authenticated_at              TIMESTAMPTZ CHECK (authenticated_at >= issued_at AND authenticated_at <= expires_at),
+    identity_id                   UUID                                        NOT NULL REFERENCES identities (id) ON DELETE CASCADE
+);
+CREATE INDEX sessions_identity_id_idx ON sessions (identity_id);
+
+CREATE TABLE emails
+(
+    address     VARCHAR(320) PRIMARY KEY,
+    verified    BOOLEAN                                        NOT NULL DEFAULT false,
+    create_time TIMESTAMPTZ                                    NOT NULL DEFAULT current_timestamp,
+    verified_at TIMESTAMPTZ CHECK (verified_at >= create_time ),
+    update_time TIMESTAMPTZ CHECK (update_time >= create_time) NOT NULL DEFAULT current_timestamp,
+    identity_id UUID                                           NOT NULL REFERENCES identities (id) ON DELETE CASCADE
+);
+CREATE INDEX emails_identity_id_idx ON emails (identity_id);
+
+CREATE TABLE phones
+(
+    number      VARCHAR(16) PRIMARY KEY,
+    verified    BOOLEAN                                        NOT NULL DEFAULT false,
+    create_time TIMESTAMPTZ                                    NOT NULL DEFAULT current_timestamp,
+    verified_at TIMESTAMPTZ CHECK (verified_at >= create_time ),
+    update_time TIMESTAMPTZ CHECK (update_time >= create_time) NOT NULL DEFAULT current_timestamp,
+    identity_id UUID                                           NOT NULL REFERENCES identities (id) ON DELETE CASCADE
+);
+CREATE INDEX phones_identity_id_idx ON phones (identity_id);
+
+CREATE TABLE registration_flows
+(
+    id          UUID PRIMARY KEY,
+    issued_at   TIMESTAMPTZ                                 NOT NULL DEFAULT current_timestamp,
+    expires_at  TIMESTAMPTZ CHECK (expires_at >= issued_at) NOT NULL,
+    identity_id UUID                                        NOT NULL REFERENCES identities (id) ON DELETE CASCADE
+);
+CREATE INDEX registration_flows_identity_id_idx ON registration_flows (identity_id);
+
+CREATE TABLE passwords
+(
+    identity_id   UUID PRIMARY KEY REFERENCES identities (id) ON DELETE CASCADE,
+    password_hash VARCHAR(256) NOT NULL
+);
+
+CREATE TABLE authentication_methods
+(
+    id          UUID PRIMARY KEY,
+    aal         SMALLINT CHECK (aal >= 1) NOT NULL,
+    complete_at TIMESTAMPTZ               NOT NULL DEFAULT current_timestamp,
+    password_id UUID                      NOT NULL REFERENCES passwords (identity_id) ON DELETE CASCADE,
+    session_id  UUID                      NOT NULL REFERENCES sessions (id) ON DELETE CASCADE
+);
+CREATE INDEX authentication_methods_session_id_idx ON authentication_methods (session_id);
+
+CREATE TABLE devices
+(
+    id           UUID PRIMARY KEY,
+    ip_address   INET         NOT NULL,
+    geo_location VARCHAR(64)  NOT NULL,
+    user_agent   VARCHAR(256) NOT NULL,
+    session_id   UUID         NOT NULL REFERENCES sessions (id) ON DELETE CASCADE
+);
+CREATE INDEX devices_session_id_idx ON devices (session_id);
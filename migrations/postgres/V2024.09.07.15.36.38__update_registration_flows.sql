@@ -0,0 +1,6 @@
+ALTER TABLE registration_flows
+    DROP COLUMN identity_id,
+    ADD COLUMN session_id UUID REFERENCES sessions (id) ON DELETE CASCADE;
+CREATE INDEX ON registration_flows (session_id);
+ALTER TABLE sessions
+    ALTER COLUMN identity_id DROP NOT NULL;
\ No newline at end of file
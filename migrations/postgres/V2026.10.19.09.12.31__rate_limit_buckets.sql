@@ -0,0 +1,9 @@
+-- PostgreSQL has no row-level TTL, so expired buckets stay until their key
+-- is used again.
+CREATE TABLE rate_limit_buckets
+(
+    key        VARCHAR(256) PRIMARY KEY,
+    tokens     FLOAT8       NOT NULL,
+    updated_at TIMESTAMPTZ  NOT NULL,
+    expires_at TIMESTAMPTZ  NOT NULL
+);
@@ -0,0 +1,6 @@
+ALTER TABLE devices
+    ADD COLUMN country_code    VARCHAR(2)   NOT NULL DEFAULT '',
+    ADD COLUMN region          VARCHAR(128) NOT NULL DEFAULT '',
+    ADD COLUMN city            VARCHAR(128) NOT NULL DEFAULT '',
+    ADD COLUMN asn             INT8         NOT NULL DEFAULT 0,
+    ADD COLUMN as_organization VARCHAR(256) NOT NULL DEFAULT '';
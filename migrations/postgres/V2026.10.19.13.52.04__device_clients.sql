@@ -0,0 +1,6 @@
+ALTER TABLE devices
+    ADD COLUMN browser         TEXT NOT NULL DEFAULT '',
+    ADD COLUMN browser_version TEXT NOT NULL DEFAULT '',
+    ADD COLUMN os              TEXT NOT NULL DEFAULT '',
+    ADD COLUMN os_version      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN device_type     TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,10 @@
+CREATE TABLE known_devices
+(
+    identity_id   UUID        NOT NULL REFERENCES identities (id) ON DELETE CASCADE,
+    browser       TEXT        NOT NULL,
+    os            TEXT        NOT NULL,
+    country_code  VARCHAR(2)  NOT NULL,
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    last_seen_at  TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    PRIMARY KEY (identity_id, browser, os, country_code)
+);
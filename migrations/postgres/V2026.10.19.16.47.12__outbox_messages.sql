@@ -0,0 +1,14 @@
+CREATE TABLE outbox_messages
+(
+    id              UUID PRIMARY KEY,
+    type            VARCHAR(128) NOT NULL,
+    subject         VARCHAR(64)  NOT NULL,
+    data            JSONB        NOT NULL,
+    create_time     TIMESTAMPTZ  NOT NULL DEFAULT current_timestamp,
+    state           VARCHAR(16)  NOT NULL DEFAULT 'pending' CHECK (state IN ('pending', 'delivered', 'dead')),
+    attempts        INT4         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ  NOT NULL DEFAULT current_timestamp,
+    delivered_to    TEXT[]       NOT NULL DEFAULT ARRAY[]::TEXT[],
+    last_error      TEXT
+);
+CREATE INDEX outbox_messages_pending_next_attempt_at_idx ON outbox_messages (next_attempt_at) WHERE state = 'pending';
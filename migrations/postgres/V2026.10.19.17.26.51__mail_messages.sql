@@ -0,0 +1,16 @@
+CREATE TABLE mail_messages
+(
+    id              UUID PRIMARY KEY,
+    from_address    VARCHAR(320) NOT NULL,
+    to_address      VARCHAR(320) NOT NULL,
+    subject         TEXT         NOT NULL,
+    text_body       TEXT         NOT NULL,
+    html_body       TEXT         NOT NULL DEFAULT '',
+    date            TIMESTAMPTZ  NOT NULL,
+    create_time     TIMESTAMPTZ  NOT NULL DEFAULT current_timestamp,
+    dead            BOOL         NOT NULL DEFAULT false,
+    attempts        INT4         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ  NOT NULL DEFAULT current_timestamp,
+    last_error      TEXT
+);
+CREATE INDEX mail_messages_due_next_attempt_at_idx ON mail_messages (next_attempt_at) WHERE NOT dead;
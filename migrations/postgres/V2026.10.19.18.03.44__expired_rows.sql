@@ -0,0 +1,13 @@
+-- PostgreSQL has no row-level TTL, so expired flows and sessions are
+-- deleted by the reaper of the API, in batches found by these indexes.
+CREATE INDEX registration_flows_expires_at_idx ON registration_flows (expires_at);
+CREATE INDEX sessions_expires_at_idx ON sessions (expires_at);
+
+-- Held by the replica running a background job, for the jobs that only one
+-- replica may run at a time.
+CREATE TABLE leases
+(
+    name       VARCHAR(64) PRIMARY KEY,
+    holder     VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,4 @@
+-- The language the identity registered in, such as "fr", which its emails
+-- and error messages are written in. Empty for the identities registered
+-- before it was recorded.
+ALTER TABLE identities ADD COLUMN locale VARCHAR(35) NOT NULL DEFAULT '';
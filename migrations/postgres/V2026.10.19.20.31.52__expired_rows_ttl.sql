@@ -0,0 +1,4 @@
+-- The reaper deletes the sessions of identities as well, once they expired
+-- longer ago than retention.history_period, in batches found by this index.
+DROP INDEX sessions_anonymous_expires_at_idx;
+CREATE INDEX sessions_expires_at_idx ON sessions (expires_at);
@@ -0,0 +1,2 @@
+-- Handle inconsistency between CI and local migration during flyway clean
+DROP TYPE IF EXISTS identity_state;
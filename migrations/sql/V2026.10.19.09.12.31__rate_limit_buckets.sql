@@ -0,0 +1,7 @@
+CREATE TABLE rate_limit_buckets
+(
+    key        STRING(256) PRIMARY KEY,
+    tokens     FLOAT8      NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+) WITH (ttl_expiration_expression = 'expires_at', ttl_job_cron = '@hourly');
@@ -0,0 +1,19 @@
+CREATE TABLE login_failures
+(
+    key             STRING(64) PRIMARY KEY,
+    failures        INT4        NOT NULL CHECK (failures > 0),
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    locked_until    TIMESTAMPTZ
+);
+
+CREATE TABLE lockout_events
+(
+    id           UUID PRIMARY KEY                                         DEFAULT gen_random_ulid(),
+    identity_id  UUID        NOT NULL REFERENCES identities (id) ON DELETE CASCADE,
+    type         STRING(16)  NOT NULL CHECK (type IN ('locked', 'unlocked')),
+    ip_address   INET,
+    actor        STRING(64),
+    locked_until TIMESTAMPTZ,
+    create_time  TIMESTAMPTZ NOT NULL                                     DEFAULT current_timestamp(),
+    INDEX identity_id_create_time_idx (identity_id, create_time DESC)
+);
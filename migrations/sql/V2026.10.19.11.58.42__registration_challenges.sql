@@ -0,0 +1,4 @@
+ALTER TABLE registration_flows
+    ADD COLUMN challenge_seed STRING(64),
+    ADD COLUMN challenge_difficulty INT2 CHECK (challenge_difficulty BETWEEN 1 AND 255);
+CREATE INDEX ON registration_flows (issued_at);
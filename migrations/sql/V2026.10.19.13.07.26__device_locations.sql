@@ -0,0 +1,6 @@
+ALTER TABLE devices
+    ADD COLUMN country_code    STRING(2)   NOT NULL DEFAULT '',
+    ADD COLUMN region          STRING(128) NOT NULL DEFAULT '',
+    ADD COLUMN city            STRING(128) NOT NULL DEFAULT '',
+    ADD COLUMN asn             INT8        NOT NULL DEFAULT 0,
+    ADD COLUMN as_organization STRING(256) NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+ALTER TABLE devices
+    ADD COLUMN browser         STRING NOT NULL DEFAULT '',
+    ADD COLUMN browser_version STRING NOT NULL DEFAULT '',
+    ADD COLUMN os              STRING NOT NULL DEFAULT '',
+    ADD COLUMN os_version      STRING NOT NULL DEFAULT '',
+    ADD COLUMN device_type     STRING NOT NULL DEFAULT '';
@@ -0,0 +1,10 @@
+CREATE TABLE known_devices
+(
+    identity_id   UUID        NOT NULL REFERENCES identities (id) ON DELETE CASCADE,
+    browser       STRING      NOT NULL,
+    os            STRING      NOT NULL,
+    country_code  STRING(2)   NOT NULL,
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp(),
+    last_seen_at  TIMESTAMPTZ NOT NULL DEFAULT current_timestamp(),
+    PRIMARY KEY (identity_id, browser, os, country_code)
+);
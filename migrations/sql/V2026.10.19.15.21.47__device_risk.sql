@@ -0,0 +1,8 @@
+ALTER TABLE devices
+    ADD COLUMN latitude        FLOAT8      NOT NULL DEFAULT 0,
+    ADD COLUMN longitude       FLOAT8      NOT NULL DEFAULT 0,
+    ADD COLUMN accuracy_radius INT4        NOT NULL DEFAULT 0,
+    ADD COLUMN created_at      TIMESTAMPTZ NOT NULL DEFAULT current_timestamp(),
+    ADD COLUMN risk_score      INT4        NOT NULL DEFAULT 0,
+    ADD COLUMN risk_decision   STRING      NOT NULL DEFAULT '',
+    ADD COLUMN risk_reasons    STRING[]    NOT NULL DEFAULT ARRAY[]::STRING[];
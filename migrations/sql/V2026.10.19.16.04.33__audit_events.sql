@@ -0,0 +1,17 @@
+-- Events outlive the identities and sessions they name, so there are no
+-- foreign keys, and rows are only ever inserted.
+CREATE TABLE audit_events
+(
+    id          UUID PRIMARY KEY     DEFAULT gen_random_ulid(),
+    type        STRING(32)  NOT NULL CHECK (type IN ('registration', 'login', 'session_revoked', 'password_changed',
+                                                     'email_verified', 'admin_action')),
+    actor       STRING(64),
+    identity_id UUID,
+    session_id  UUID,
+    ip_address  INET,
+    user_agent  STRING(256),
+    outcome     STRING(16)  NOT NULL CHECK (outcome IN ('success', 'failure', 'denied')),
+    action      STRING(64),
+    create_time TIMESTAMPTZ NOT NULL DEFAULT current_timestamp(),
+    INDEX identity_id_create_time_idx (identity_id, create_time DESC, id DESC)
+);
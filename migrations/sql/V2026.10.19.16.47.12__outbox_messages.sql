@@ -0,0 +1,14 @@
+CREATE TABLE outbox_messages
+(
+    id              UUID PRIMARY KEY     DEFAULT gen_random_ulid(),
+    type            STRING(128) NOT NULL,
+    subject         STRING(64)  NOT NULL,
+    data            JSONB       NOT NULL,
+    create_time     TIMESTAMPTZ NOT NULL DEFAULT current_timestamp(),
+    state           STRING(16)  NOT NULL DEFAULT 'pending' CHECK (state IN ('pending', 'delivered', 'dead')),
+    attempts        INT4        NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp(),
+    delivered_to    STRING[]    NOT NULL DEFAULT ARRAY[]::STRING[],
+    last_error      STRING,
+    INDEX pending_next_attempt_at_idx (next_attempt_at) WHERE state = 'pending'
+);
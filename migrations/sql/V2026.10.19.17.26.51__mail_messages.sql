@@ -0,0 +1,16 @@
+CREATE TABLE mail_messages
+(
+    id              UUID PRIMARY KEY     DEFAULT gen_random_ulid(),
+    from_address    STRING(320) NOT NULL,
+    to_address      STRING(320) NOT NULL,
+    subject         STRING      NOT NULL,
+    text_body       STRING      NOT NULL,
+    html_body       STRING      NOT NULL DEFAULT '',
+    date            TIMESTAMPTZ NOT NULL,
+    create_time     TIMESTAMPTZ NOT NULL DEFAULT current_timestamp(),
+    dead            BOOL        NOT NULL DEFAULT false,
+    attempts        INT4        NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT current_timestamp(),
+    last_error      STRING,
+    INDEX due_next_attempt_at_idx (next_attempt_at) WHERE NOT dead
+);
@@ -0,0 +1,16 @@
+-- Expired flows and sessions are deleted a week after they expire, and
+-- devices with their sessions. The expression is computed in UTC, as
+-- adding an interval to a TIMESTAMPTZ depends on the session time zone.
+ALTER TABLE registration_flows
+    SET (ttl_expiration_expression = '((expires_at AT TIME ZONE ''UTC'') + INTERVAL ''7 days'') AT TIME ZONE ''UTC''', ttl_job_cron = '@hourly');
+ALTER TABLE sessions
+    SET (ttl_expiration_expression = '((expires_at AT TIME ZONE ''UTC'') + INTERVAL ''7 days'') AT TIME ZONE ''UTC''', ttl_job_cron = '@hourly');
+
+-- Held by the replica running a background job, for the jobs that only one
+-- replica may run at a time.
+CREATE TABLE leases
+(
+    name       STRING(64) PRIMARY KEY,
+    holder     STRING(64)  NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
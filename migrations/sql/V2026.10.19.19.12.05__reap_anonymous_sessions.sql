@@ -0,0 +1,9 @@
+-- Expired flows and sessions are deleted by the reaper of the API, as on
+-- PostgreSQL, so that they are kept for retention.period and the sessions
+-- of identities are kept with the devices the risk checks compare new ones
+-- with. Only anonymous sessions are reaped, in batches found by these
+-- indexes.
+ALTER TABLE registration_flows RESET (ttl);
+ALTER TABLE sessions RESET (ttl);
+CREATE INDEX registration_flows_expires_at_idx ON registration_flows (expires_at);
+CREATE INDEX sessions_anonymous_expires_at_idx ON sessions (expires_at) WHERE identity_id IS NULL;
@@ -0,0 +1,3 @@
+-- Delivered and dead outbox messages are deleted by the reaper once they
+-- are older than retention.period, in batches found by this index.
+CREATE INDEX outbox_messages_finished_next_attempt_at_idx ON outbox_messages (next_attempt_at) WHERE state IN ('delivered', 'dead');
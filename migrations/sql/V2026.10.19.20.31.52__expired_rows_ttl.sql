@@ -0,0 +1,15 @@
+-- Expired flows and sessions are deleted by the row-level TTL of
+-- CockroachDB: flows and anonymous sessions retention_period after they
+-- expire, and the sessions of identities history_period after they expire,
+-- so that their devices stay the history the risk checks compare new ones
+-- with. The placeholders are set in flyway.toml and follow retention.period
+-- and retention.history_period of the API. The expressions are computed in
+-- UTC, as adding an interval to a TIMESTAMPTZ depends on the session time
+-- zone.
+ALTER TABLE registration_flows
+    SET (ttl_expiration_expression = '((expires_at AT TIME ZONE ''UTC'') + INTERVAL ''${retention_period}'') AT TIME ZONE ''UTC''', ttl_job_cron = '@hourly');
+ALTER TABLE sessions
+    SET (ttl_expiration_expression = '((expires_at AT TIME ZONE ''UTC'') + CASE WHEN identity_id IS NULL THEN INTERVAL ''${retention_period}'' ELSE INTERVAL ''${history_period}'' END) AT TIME ZONE ''UTC''', ttl_job_cron = '@hourly');
+-- The reaper of the API only deletes outbox messages on CockroachDB
+DROP INDEX registration_flows@registration_flows_expires_at_idx;
+DROP INDEX sessions@sessions_anonymous_expires_at_idx;